@@ -0,0 +1,154 @@
+// Copyright (c) Edgeless Systems GmbH.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at https://mozilla.org/MPL/2.0/.
+
+/*
+Package advisory provides information about the Intel security advisories that are referenced by a report's TCBAdvisories.
+
+The package embeds a catalogue of advisories that affect SGX, so applications can reason about advisories without
+calling out to Intel:
+
+	for _, id := range report.TCBAdvisories {
+		if adv, ok := advisory.Lookup(id); ok && adv.Category == advisory.SoftwareHardening {
+			// check that the enclave has been built with the required mitigations
+		}
+	}
+
+The embedded catalogue only contains a selection of advisories and is updated with EGo releases. Lookup reports
+whether an advisory is known. Describe falls back to a generic description for unknown IDs, which has no category
+and refers to Intel's security center for the details. Applications that need newer data can load their own
+catalogue with Parse and merge it with Default.
+*/
+package advisory
+
+import (
+	_ "embed"
+	"encoding/json"
+	"fmt"
+	"slices"
+	"strings"
+	"sync"
+)
+
+// Category is the kind of mitigation that an advisory requires.
+type Category string
+
+const (
+	// Microcode advisories are mitigated by a microcode update of the platform (TCB recovery).
+	Microcode Category = "Microcode"
+	// Configuration advisories are mitigated by configuring the platform, e.g., by disabling Hyper-Threading in the BIOS.
+	Configuration Category = "Configuration"
+	// SoftwareHardening advisories are mitigated by hardening the enclave software, e.g., by compiler mitigations.
+	SoftwareHardening Category = "SoftwareHardening"
+)
+
+// Advisory is an Intel security advisory.
+type Advisory struct {
+	ID       string   `json:"id"`       // The ID of the advisory, e.g., INTEL-SA-00334.
+	Title    string   `json:"title"`    // The title of the advisory.
+	CVEs     []string `json:"cves"`     // The IDs of the vulnerabilities that are addressed by the advisory.
+	Category Category `json:"category"` // The kind of mitigation that the advisory requires. Empty if unknown.
+}
+
+// URL returns the URL of the advisory at Intel's security center.
+func (a Advisory) URL() string {
+	return "https://www.intel.com/content/www/us/en/security-center/advisory/" + strings.ToLower(a.ID) + ".html"
+}
+
+// Catalog is a set of advisories.
+type Catalog struct {
+	advisories map[string]Advisory
+}
+
+//go:embed advisories.json
+var embeddedCatalog []byte
+
+var defaultCatalog = sync.OnceValue(func() *Catalog {
+	catalog, err := Parse(embeddedCatalog)
+	if err != nil {
+		panic(err)
+	}
+	return catalog
+})
+
+// Default returns the embedded catalogue.
+func Default() *Catalog {
+	return defaultCatalog()
+}
+
+// Lookup returns the advisory with the given ID from the embedded catalogue.
+func Lookup(id string) (Advisory, bool) {
+	return Default().Lookup(id)
+}
+
+// Describe returns the advisory with the given ID from the embedded catalogue.
+// If the ID is unknown, a generic description without category is returned.
+func Describe(id string) Advisory {
+	return Default().Describe(id)
+}
+
+// Parse parses a catalogue from a JSON array of advisories.
+func Parse(data []byte) (*Catalog, error) {
+	var advisories []Advisory
+	if err := json.Unmarshal(data, &advisories); err != nil {
+		return nil, err
+	}
+	catalog := &Catalog{advisories: make(map[string]Advisory, len(advisories))}
+	for _, adv := range advisories {
+		if adv.ID == "" {
+			return nil, fmt.Errorf("advisory without ID: %q", adv.Title)
+		}
+		switch adv.Category {
+		case Microcode, Configuration, SoftwareHardening:
+		default:
+			return nil, fmt.Errorf("invalid category of advisory %v: %q", adv.ID, adv.Category)
+		}
+		catalog.advisories[adv.ID] = adv
+	}
+	return catalog, nil
+}
+
+// Lookup returns the advisory with the given ID.
+func (c *Catalog) Lookup(id string) (Advisory, bool) {
+	adv, ok := c.advisories[id]
+	return adv, ok
+}
+
+// Describe returns the advisory with the given ID. If the ID is unknown, a generic description without category is
+// returned. Its URL refers to Intel's security center for the details.
+func (c *Catalog) Describe(id string) Advisory {
+	if adv, ok := c.Lookup(id); ok {
+		return adv
+	}
+	return Advisory{ID: id, Title: "Intel security advisory " + id + " (not in the catalogue)"}
+}
+
+// Merge returns a new catalogue that contains the advisories of both catalogues. Advisories of other take precedence.
+func (c *Catalog) Merge(other *Catalog) *Catalog {
+	result := &Catalog{advisories: make(map[string]Advisory, len(c.advisories)+len(other.advisories))}
+	for id, adv := range c.advisories {
+		result.advisories[id] = adv
+	}
+	for id, adv := range other.advisories {
+		result.advisories[id] = adv
+	}
+	return result
+}
+
+// Categories returns the mitigation categories required by the given advisory IDs. IDs that aren't
+// in the catalogue are returned as unknown.
+func (c *Catalog) Categories(ids []string) (categories []Category, unknown []string) {
+	for _, id := range ids {
+		adv, ok := c.Lookup(id)
+		if !ok {
+			unknown = append(unknown, id)
+			continue
+		}
+		if !slices.Contains(categories, adv.Category) {
+			categories = append(categories, adv.Category)
+		}
+	}
+	return categories, unknown
+}
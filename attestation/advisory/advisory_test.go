@@ -0,0 +1,100 @@
+// Copyright (c) Edgeless Systems GmbH.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package advisory
+
+import (
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestDefault(t *testing.T) {
+	assert := assert.New(t)
+
+	adv, ok := Lookup("INTEL-SA-00334")
+	assert.True(ok)
+	assert.Equal("Load Value Injection", adv.Title)
+	assert.Equal([]string{"CVE-2020-0551"}, adv.CVEs)
+	assert.Equal(SoftwareHardening, adv.Category)
+	assert.Equal("https://www.intel.com/content/www/us/en/security-center/advisory/intel-sa-00334.html", adv.URL())
+
+	_, ok = Lookup("INTEL-SA-99999")
+	assert.False(ok)
+
+	assert.Equal(adv, Describe("INTEL-SA-00334"))
+	adv = Describe("INTEL-SA-99999")
+	assert.Equal("INTEL-SA-99999", adv.ID)
+	assert.NotEmpty(adv.Title)
+	assert.Empty(adv.Category)
+	assert.Equal("https://www.intel.com/content/www/us/en/security-center/advisory/intel-sa-99999.html", adv.URL())
+}
+
+func TestParse(t *testing.T) {
+	testCases := map[string]struct {
+		data    string
+		wantErr bool
+	}{
+		"valid": {
+			data: `[{"id":"INTEL-SA-1","title":"t","cves":["CVE-1"],"category":"Microcode"}]`,
+		},
+		"empty": {
+			data: `[]`,
+		},
+		"invalid json": {
+			data:    `{`,
+			wantErr: true,
+		},
+		"missing id": {
+			data:    `[{"title":"t","category":"Microcode"}]`,
+			wantErr: true,
+		},
+		"invalid category": {
+			data:    `[{"id":"INTEL-SA-1","category":"foo"}]`,
+			wantErr: true,
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			_, err := Parse([]byte(tc.data))
+			if tc.wantErr {
+				assert.Error(t, err)
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
+
+func TestMergeAndCategories(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	update, err := Parse([]byte(`[
+		{"id":"INTEL-SA-00334","title":"updated","category":"SoftwareHardening"},
+		{"id":"INTEL-SA-99999","title":"new","category":"Configuration"}
+	]`))
+	require.NoError(err)
+	catalog := Default().Merge(update)
+
+	adv, ok := catalog.Lookup("INTEL-SA-00334")
+	assert.True(ok)
+	assert.Equal("updated", adv.Title)
+	_, ok = catalog.Lookup("INTEL-SA-00828")
+	assert.True(ok)
+
+	// default catalogue isn't modified
+	adv, _ = Lookup("INTEL-SA-00334")
+	assert.Equal("Load Value Injection", adv.Title)
+	_, ok = Lookup("INTEL-SA-99999")
+	assert.False(ok)
+
+	categories, unknown := catalog.Categories([]string{"INTEL-SA-00334", "INTEL-SA-00615", "INTEL-SA-99999", "INTEL-SA-00828", "foo"})
+	assert.Equal([]Category{SoftwareHardening, Configuration, Microcode}, categories)
+	assert.Equal([]string{"foo"}, unknown)
+}
@@ -41,8 +41,12 @@ var (
 //
 // The caller must verify the returned report's content.
 //
-// This function relies on the root CA certificates of the host to verify the TLS connection. Thus, it is currently
-// usable by non-enclaved clients only. A future version of EGo will allow to add root certificates to an enclave.
+// The token doesn't carry the TCB status unless the attestation policy issues it, so the report's TCBStatus
+// is usually tcbstatus.Unknown. Use NewAzureVerifier to get all claims of the token and for more control
+// over fetching the keys, which are cached per providerURL.
+//
+// This function relies on the system's root CA certificates to verify the TLS connection. Inside an enclave, embed
+// a CA bundle via the files section of enclave.json or use NewAzureVerifier with WithRootCAs or WithKeySet.
 func VerifyAzureAttestationToken(token string, providerURL string) (Report, error) {
 	// Ensure providerURL uses HTTPS.
 	uri, err := attestation.ParseHTTPS(providerURL)
@@ -53,5 +57,5 @@ func VerifyAzureAttestationToken(token string, providerURL string) (Report, erro
 	if err != nil {
 		return Report{}, err
 	}
-	return Report(report), nil
+	return Report(report.Report), nil
 }
@@ -0,0 +1,122 @@
+// Copyright (c) Edgeless Systems GmbH.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package attestation
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"net/http"
+	"time"
+
+	"github.com/edgelesssys/ego/internal/attestation"
+)
+
+// AzureVerifier verifies Microsoft Azure Attestation tokens of a single attestation provider.
+//
+// The provider's JSON Web Key Set is cached. It is fetched again if it is older than the configured maximum age
+// (24 hours by default) or if a token is signed with a key that isn't in the cached set, e.g., after a key rotation.
+// An AzureVerifier is safe for concurrent use and should be reused.
+type AzureVerifier struct {
+	verifier *attestation.AzureVerifier
+}
+
+// AzureVerifierOption is an option for NewAzureVerifier.
+type AzureVerifierOption struct {
+	apply func(*azureVerifierOptions)
+}
+
+type azureVerifierOptions struct {
+	config  attestation.KeySetConfig
+	rootCAs *x509.CertPool
+}
+
+// WithHTTPClient sets the HTTP client that is used to fetch the provider's keys.
+func WithHTTPClient(client *http.Client) AzureVerifierOption {
+	return AzureVerifierOption{func(o *azureVerifierOptions) { o.config.HTTPClient = client }}
+}
+
+// WithRootCAs sets the root CAs that are used to verify the TLS connection to the provider.
+// It has no effect if WithHTTPClient is used.
+func WithRootCAs(rootCAs *x509.CertPool) AzureVerifierOption {
+	return AzureVerifierOption{func(o *azureVerifierOptions) { o.rootCAs = rootCAs }}
+}
+
+// WithSigningRoots sets the roots the x5c certificate chains of the provider's keys must chain up to.
+// By default, the last certificate of each chain is used as its root and the trust is based on the TLS connection only.
+func WithSigningRoots(roots *x509.CertPool) AzureVerifierOption {
+	return AzureVerifierOption{func(o *azureVerifierOptions) { o.config.SigningRoots = roots }}
+}
+
+// WithKeySet pins the provider's JSON Web Key Set. The keys are never fetched from the provider,
+// which allows verifying tokens offline.
+func WithKeySet(jwks []byte) AzureVerifierOption {
+	return AzureVerifierOption{func(o *azureVerifierOptions) { o.config.KeySet = jwks }}
+}
+
+// WithKeySetMaxAge sets the duration after which the cached key set is fetched again.
+func WithKeySetMaxAge(maxAge time.Duration) AzureVerifierOption {
+	return AzureVerifierOption{func(o *azureVerifierOptions) { o.config.KeySetMaxAge = maxAge }}
+}
+
+// NewAzureVerifier creates a verifier for tokens of the attestation provider reachable under providerURL.
+// providerURL must use the HTTPS scheme.
+func NewAzureVerifier(providerURL string, opts ...AzureVerifierOption) (*AzureVerifier, error) {
+	uri, err := attestation.ParseHTTPS(providerURL)
+	if err != nil {
+		return nil, err
+	}
+
+	var o azureVerifierOptions
+	for _, opt := range opts {
+		opt.apply(&o)
+	}
+	if o.config.HTTPClient == nil && o.rootCAs != nil {
+		transport := http.DefaultTransport.(*http.Transport).Clone()
+		transport.TLSClientConfig = &tls.Config{RootCAs: o.rootCAs}
+		o.config.HTTPClient = &http.Client{Transport: transport, Timeout: 30 * time.Second}
+	}
+
+	return &AzureVerifier{verifier: attestation.NewAzureVerifier(uri, o.config)}, nil
+}
+
+// Verify takes a Microsoft Azure Attestation token in JSON Web Token compact serialization format and verifies
+// the token's public claims and signature. Note that the token's issuer (iss) has to be equal to the providerURL.
+//
+// The caller must verify the returned report's content.
+func (v *AzureVerifier) Verify(token string) (AzureReport, error) {
+	report, err := v.verifier.Verify(token)
+	if err != nil {
+		return AzureReport{}, err
+	}
+	return newAzureReport(report), nil
+}
+
+// AzureReport is a report that has been created from the claims of a Microsoft Azure Attestation token.
+//
+// The token only carries the TCB status if the attestation policy issues it as x-ms-sgx-tcbstatus claim.
+// Otherwise, the TCBStatus of the embedded Report is tcbstatus.Unknown.
+type AzureReport struct {
+	Report
+	AttestationType string            // The type of attestation (x-ms-attestation-type), e.g., "sgx".
+	PolicyHash      []byte            // The hash of the attestation policy that has been evaluated (x-ms-policy-hash).
+	Collateral      map[string]string // The hashes of the collateral used for the attestation (x-ms-sgx-collateral).
+	ConfigID        []byte            // The SGX CONFIGID of the enclave (x-ms-sgx-config-id).
+	Version         string            // The version of the token format (x-ms-ver).
+	Claims          map[string]any    // All claims of the token as decoded by encoding/json.
+}
+
+func newAzureReport(report attestation.AzureReport) AzureReport {
+	return AzureReport{
+		Report:          Report(report.Report),
+		AttestationType: report.AttestationType,
+		PolicyHash:      report.PolicyHash,
+		Collateral:      report.Collateral,
+		ConfigID:        report.ConfigID,
+		Version:         report.Version,
+		Claims:          report.Claims,
+	}
+}
@@ -0,0 +1,200 @@
+// Copyright (c) Edgeless Systems GmbH.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at https://mozilla.org/MPL/2.0/.
+
+/*
+Package challenge implements a nonce-based challenge protocol that proves the freshness of a remote report.
+
+The client sends a random nonce to the server. The server returns a report over
+sha256(nonce || sha256(certificate) || userData), where certificate is the server's TLS certificate and
+userData is optional data provided by the server. The client verifies that the report is bound to its nonce,
+the certificate of the connection, and the returned user data.
+
+Only the server must run in an enclave. NewHandler takes the function that creates its reports, and Request
+takes the function that verifies them, which is enclave.VerifyRemoteReport if the client is an enclave, too:
+
+	// server in an enclave
+	http.Handle("/attest", challenge.NewHandler(enclave.GetRemoteReport, certDER, nil))
+
+	// client outside of an enclave
+	result, err := challenge.Request(ctx, client, "https://server/attest", eclient.VerifyRemoteReport, verifyReport)
+
+	// client in an enclave
+	result, err := challenge.Request(ctx, client, "https://server/attest", enclave.VerifyRemoteReport, verifyReport)
+*/
+package challenge
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"crypto/sha256"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+
+	"github.com/edgelesssys/ego/attestation"
+)
+
+// NonceSize is the size of a nonce in bytes.
+const NonceSize = 32
+
+// maxResponseSize limits the size of a response read by the client.
+const maxResponseSize = 1 << 20
+
+var (
+	// ErrInvalidNonce is returned if a nonce doesn't have NonceSize bytes.
+	ErrInvalidNonce = errors.New("invalid nonce size")
+
+	// ErrReportDataMismatch is returned if the report isn't bound to the nonce, certificate, and user data.
+	ErrReportDataMismatch = errors.New("report data does not match the challenge")
+)
+
+// Challenge is the request sent by the client.
+type Challenge struct {
+	Nonce []byte `json:"nonce"`
+}
+
+// Response is the response returned by the server.
+type Response struct {
+	Report   []byte `json:"report"`
+	UserData []byte `json:"userData,omitempty"`
+}
+
+// Result is the verified result of a challenge.
+type Result struct {
+	Report   attestation.Report // The verified report.
+	UserData []byte             // The user data the report is bound to.
+}
+
+// NewNonce creates a random nonce.
+func NewNonce() ([]byte, error) {
+	nonce := make([]byte, NonceSize)
+	if _, err := rand.Read(nonce); err != nil {
+		return nil, err
+	}
+	return nonce, nil
+}
+
+// ReportData returns the report data for the given challenge: sha256(nonce || sha256(cert) || userData).
+func ReportData(nonce, cert, userData []byte) ([]byte, error) {
+	if len(nonce) != NonceSize {
+		return nil, ErrInvalidNonce
+	}
+	certHash := sha256.Sum256(cert)
+	hash := sha256.New()
+	hash.Write(nonce)
+	hash.Write(certHash[:])
+	hash.Write(userData)
+	return hash.Sum(nil), nil
+}
+
+// NewHandler creates an http.Handler that answers challenges.
+//
+// getRemoteReport is used to create the report. Usually, this is enclave.GetRemoteReport.
+// cert is the DER-encoded certificate the server uses for TLS. It may be nil if the server doesn't use TLS.
+// userData is returned to the client along with the report and may be nil.
+func NewHandler(getRemoteReport func([]byte) ([]byte, error), cert []byte, userData []byte) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.Method != http.MethodPost {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+
+		var req Challenge
+		if err := json.NewDecoder(http.MaxBytesReader(w, r.Body, 1024)).Decode(&req); err != nil {
+			http.Error(w, "invalid request", http.StatusBadRequest)
+			return
+		}
+		reportData, err := ReportData(req.Nonce, cert, userData)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+
+		report, err := getRemoteReport(reportData)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(Response{Report: report, UserData: userData})
+	})
+}
+
+// Request sends a new challenge to url and verifies the response.
+//
+// The certificate of the TLS connection is used to verify the response. If url doesn't use TLS, the server must have been created with a nil certificate.
+//
+// verifyRemoteReport is either enclave.VerifyRemoteReport or eclient.VerifyRemoteReport.
+//
+// verifyReport is called after the report has been verified against the challenge. The caller must verify either the UniqueID or the tuple (SignerID, ProductID, SecurityVersion, Debug) in the callback.
+func Request(ctx context.Context, client *http.Client, url string, verifyRemoteReport func([]byte) (attestation.Report, error), verifyReport func(attestation.Report) error) (Result, error) {
+	nonce, err := NewNonce()
+	if err != nil {
+		return Result{}, err
+	}
+	reqBody, err := json.Marshal(Challenge{Nonce: nonce})
+	if err != nil {
+		return Result{}, err
+	}
+
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, url, bytes.NewReader(reqBody))
+	if err != nil {
+		return Result{}, err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	resp, err := client.Do(req)
+	if err != nil {
+		return Result{}, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return Result{}, fmt.Errorf("challenge failed, server returned status %v", resp.Status)
+	}
+
+	var response Response
+	if err := json.NewDecoder(http.MaxBytesReader(nil, resp.Body, maxResponseSize)).Decode(&response); err != nil {
+		return Result{}, err
+	}
+
+	var cert []byte
+	if resp.TLS != nil && len(resp.TLS.PeerCertificates) > 0 {
+		cert = resp.TLS.PeerCertificates[0].Raw
+	}
+
+	report, err := VerifyResponse(response, nonce, cert, verifyRemoteReport, verifyReport)
+	if err != nil {
+		return Result{}, err
+	}
+	return Result{Report: report, UserData: response.UserData}, nil
+}
+
+// VerifyResponse verifies that the response is bound to the nonce and the certificate. Use this function
+// if you transfer challenges by other means than Request.
+//
+// verifyRemoteReport is either enclave.VerifyRemoteReport or eclient.VerifyRemoteReport.
+//
+// verifyReport is called after the report has been verified against the challenge. The caller must verify either the UniqueID or the tuple (SignerID, ProductID, SecurityVersion, Debug) in the callback.
+func VerifyResponse(response Response, nonce, cert []byte, verifyRemoteReport func([]byte) (attestation.Report, error), verifyReport func(attestation.Report) error) (attestation.Report, error) {
+	expectedData, err := ReportData(nonce, cert, response.UserData)
+	if err != nil {
+		return attestation.Report{}, err
+	}
+
+	report, err := verifyRemoteReport(response.Report)
+	if err != nil {
+		return attestation.Report{}, err
+	}
+	if len(report.Data) < len(expectedData) || !bytes.Equal(report.Data[:len(expectedData)], expectedData) {
+		return attestation.Report{}, ErrReportDataMismatch
+	}
+	if err := verifyReport(report); err != nil {
+		return attestation.Report{}, err
+	}
+	return report, nil
+}
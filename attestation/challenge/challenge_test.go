@@ -0,0 +1,196 @@
+// Copyright (c) Edgeless Systems GmbH.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package challenge
+
+import (
+	"context"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"testing"
+
+	"github.com/edgelesssys/ego/attestation"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func getRemoteReport(reportData []byte) ([]byte, error) {
+	return append([]byte{2}, reportData...), nil
+}
+
+func verifyRemoteReport(reportBytes []byte) (attestation.Report, error) {
+	if len(reportBytes) != 33 || reportBytes[0] != 2 {
+		return attestation.Report{}, errors.New("invalid remote report")
+	}
+	return attestation.Report{Data: reportBytes[1:], SecurityVersion: 2}, nil
+}
+
+func verifyRemoteReportTCBInvalid(reportBytes []byte) (attestation.Report, error) {
+	report, err := verifyRemoteReport(reportBytes)
+	if err != nil {
+		return attestation.Report{}, err
+	}
+	return report, attestation.ErrTCBLevelInvalid
+}
+
+func verifyReport(report attestation.Report) error {
+	if report.SecurityVersion != 2 {
+		return errors.New("invalid report")
+	}
+	return nil
+}
+
+func TestRequest(t *testing.T) {
+	testCases := map[string]struct {
+		tls                bool
+		wrongCert          bool
+		userData           []byte
+		verifyRemoteReport func([]byte) (attestation.Report, error)
+		verifyReport       func(attestation.Report) error
+		wantErr            error
+	}{
+		"basic": {
+			tls:                true,
+			verifyRemoteReport: verifyRemoteReport,
+			verifyReport:       verifyReport,
+		},
+		"user data": {
+			tls:                true,
+			userData:           []byte("data"),
+			verifyRemoteReport: verifyRemoteReport,
+			verifyReport:       verifyReport,
+		},
+		"no tls": {
+			verifyRemoteReport: verifyRemoteReport,
+			verifyReport:       verifyReport,
+		},
+		"report bound to other certificate": {
+			tls:                true,
+			wrongCert:          true,
+			verifyRemoteReport: verifyRemoteReport,
+			verifyReport:       verifyReport,
+			wantErr:            ErrReportDataMismatch,
+		},
+		"invalid report": {
+			tls:                true,
+			verifyRemoteReport: verifyRemoteReport,
+			verifyReport:       func(attestation.Report) error { return assert.AnError },
+			wantErr:            assert.AnError,
+		},
+		"tcb invalid": {
+			tls:                true,
+			verifyRemoteReport: verifyRemoteReportTCBInvalid,
+			verifyReport:       verifyReport,
+			wantErr:            attestation.ErrTCBLevelInvalid,
+		},
+		"ignore tcb status": {
+			tls:                true,
+			verifyRemoteReport: attestation.IgnoreTCBStatus(verifyRemoteReportTCBInvalid),
+			verifyReport:       verifyReport,
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			assert := assert.New(t)
+			require := require.New(t)
+
+			var server *httptest.Server
+			server = httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+				var cert []byte
+				if tc.tls && !tc.wrongCert {
+					cert = server.Certificate().Raw
+				}
+				NewHandler(getRemoteReport, cert, tc.userData).ServeHTTP(w, r)
+			}))
+			if tc.tls {
+				server.StartTLS()
+			} else {
+				server.Start()
+			}
+			defer server.Close()
+
+			result, err := Request(context.Background(), server.Client(), server.URL, tc.verifyRemoteReport, tc.verifyReport)
+			if tc.wantErr != nil {
+				assert.ErrorIs(err, tc.wantErr)
+				return
+			}
+			require.NoError(err)
+			assert.EqualValues(2, result.Report.SecurityVersion)
+			assert.Equal(tc.userData, result.UserData)
+		})
+	}
+}
+
+func TestHandler(t *testing.T) {
+	testCases := map[string]struct {
+		method   string
+		body     string
+		wantCode int
+	}{
+		"valid": {
+			method:   http.MethodPost,
+			body:     `{"nonce":"` + strings.Repeat("A", 43) + `="}`,
+			wantCode: http.StatusOK,
+		},
+		"wrong method": {
+			method:   http.MethodGet,
+			wantCode: http.StatusMethodNotAllowed,
+		},
+		"invalid json": {
+			method:   http.MethodPost,
+			body:     "foo",
+			wantCode: http.StatusBadRequest,
+		},
+		"short nonce": {
+			method:   http.MethodPost,
+			body:     `{"nonce":"AAAA"}`,
+			wantCode: http.StatusBadRequest,
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			assert := assert.New(t)
+
+			rec := httptest.NewRecorder()
+			NewHandler(getRemoteReport, nil, nil).ServeHTTP(rec, httptest.NewRequest(tc.method, "/", strings.NewReader(tc.body)))
+			assert.Equal(tc.wantCode, rec.Code)
+		})
+	}
+}
+
+func TestVerifyResponse(t *testing.T) {
+	require := require.New(t)
+	assert := assert.New(t)
+
+	nonce, err := NewNonce()
+	require.NoError(err)
+	cert := []byte("cert")
+	reportData, err := ReportData(nonce, cert, []byte("data"))
+	require.NoError(err)
+	report, err := getRemoteReport(reportData)
+	require.NoError(err)
+
+	_, err = VerifyResponse(Response{Report: report, UserData: []byte("data")}, nonce, cert, verifyRemoteReport, verifyReport)
+	assert.NoError(err)
+
+	// tampered user data
+	_, err = VerifyResponse(Response{Report: report, UserData: []byte("other")}, nonce, cert, verifyRemoteReport, verifyReport)
+	assert.ErrorIs(err, ErrReportDataMismatch)
+
+	// other nonce
+	otherNonce, err := NewNonce()
+	require.NoError(err)
+	_, err = VerifyResponse(Response{Report: report, UserData: []byte("data")}, otherNonce, cert, verifyRemoteReport, verifyReport)
+	assert.ErrorIs(err, ErrReportDataMismatch)
+
+	// invalid nonce
+	_, err = VerifyResponse(Response{Report: report, UserData: []byte("data")}, nonce[1:], cert, verifyRemoteReport, verifyReport)
+	assert.ErrorIs(err, ErrInvalidNonce)
+}
@@ -0,0 +1,28 @@
+// Copyright (c) Edgeless Systems GmbH.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package attestation
+
+import (
+	"encoding/json"
+
+	"github.com/edgelesssys/ego/internal/attestation"
+)
+
+// ErrClaimsDigestMismatch is returned by VerifyAttestedClaims if the report isn't over the digest of the claims.
+var ErrClaimsDigestMismatch = attestation.ErrClaimsDigestMismatch
+
+// AttestedClaims are claims whose integrity has been verified by a report.
+type AttestedClaims struct {
+	Claims map[string]any // The claims as decoded by encoding/json.
+	Raw    []byte         // The JSON-encoded claims the report is bound to.
+	Report Report         // The verified report.
+}
+
+// Decode unmarshals the claims into v, which may be a pointer to a typed struct.
+func (c AttestedClaims) Decode(v any) error {
+	return json.Unmarshal(c.Raw, v)
+}
@@ -0,0 +1,335 @@
+// Copyright (c) Edgeless Systems GmbH.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package attestation
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rsa"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"time"
+
+	"github.com/edgelesssys/ego/attestation/tcbstatus"
+	"github.com/go-jose/go-jose/v4"
+	"github.com/go-jose/go-jose/v4/jwt"
+)
+
+// EARProfile is the EAT profile of EAT Attestation Results (EAR).
+const EARProfile = "tag:github.com,2023:veraison/ear"
+
+// EARSubmod is the default name of the submodule that describes the enclave.
+const EARSubmod = "SGX"
+
+// ErrInvalidAttestationResult is returned by VerifyAttestationResult if the token isn't a valid EAR.
+var ErrInvalidAttestationResult = errors.New("invalid attestation result")
+
+// TrustTier is the overall appraisal status of an attester (ear.status).
+type TrustTier string
+
+// Trust tiers as defined by draft-ietf-rats-ar4si.
+const (
+	TrustTierNone            TrustTier = "none"
+	TrustTierAffirming       TrustTier = "affirming"
+	TrustTierWarning         TrustTier = "warning"
+	TrustTierContraindicated TrustTier = "contraindicated"
+)
+
+// Trustworthiness claim values as defined by draft-ietf-rats-ar4si.
+const (
+	TrustClaimNoClaim         int8 = 0
+	TrustClaimAffirming       int8 = 2
+	TrustClaimWarning         int8 = 32
+	TrustClaimContraindicated int8 = 96
+)
+
+// TrustVector is the trustworthiness vector of an attester (ear.trustworthiness-vector).
+type TrustVector struct {
+	InstanceIdentity int8 `json:"instance-identity"`
+	Configuration    int8 `json:"configuration"`
+	Executables      int8 `json:"executables"`
+	FileSystem       int8 `json:"file-system"`
+	Hardware         int8 `json:"hardware"`
+	RuntimeOpaque    int8 `json:"runtime-opaque"`
+	StorageOpaque    int8 `json:"storage-opaque"`
+	SourcedData      int8 `json:"sourced-data"`
+}
+
+// Tier returns the trust tier of the worst claim of the vector.
+func (v TrustVector) Tier() TrustTier {
+	worst := int8(0)
+	for _, claim := range []int8{v.InstanceIdentity, v.Configuration, v.Executables, v.FileSystem, v.Hardware, v.RuntimeOpaque, v.StorageOpaque, v.SourcedData} {
+		worst = max(worst, abs(claim))
+	}
+	switch {
+	case worst >= TrustClaimContraindicated:
+		return TrustTierContraindicated
+	case worst >= TrustClaimWarning:
+		return TrustTierWarning
+	case worst >= TrustClaimAffirming:
+		return TrustTierAffirming
+	}
+	return TrustTierNone
+}
+
+// VerifierID identifies the verifier that produced an attestation result (ear.verifier-id).
+type VerifierID struct {
+	Build     string `json:"build"`
+	Developer string `json:"developer"`
+}
+
+// AttestationResultOptions configures NewAttestationResult.
+type AttestationResultOptions struct {
+	VerifierID VerifierID    // Identifies the verifier. Defaults to the EGo verifier.
+	PolicyID   string        // Identifies the policy the report has been appraised against (ear.appraisal-policy-id).
+	Nonce      string        // Binds the result to a request of the relying party (eat_nonce).
+	Submod     string        // Name of the submodule that describes the enclave. Defaults to EARSubmod.
+	Validity   time.Duration // If positive, the result expires after this duration.
+}
+
+// AttestationResult is an EAT Attestation Result (EAR) for an enclave.
+//
+// See https://datatracker.ietf.org/doc/draft-fv-rats-ear/ for the format.
+type AttestationResult struct {
+	Status      TrustTier   // The overall status.
+	TrustVector TrustVector // The trustworthiness vector.
+	PolicyID    string      // The policy the report has been appraised against.
+	VerifierID  VerifierID  // The verifier that produced the result.
+	Nonce       string      // The nonce of the relying party.
+	Submod      string      // The name of the submodule that describes the enclave.
+	IssuedAt    time.Time   // The time the result has been issued.
+	Expiry      time.Time   // The time the result expires. Zero if it doesn't expire.
+	Evidence    EAREvidence // The enclave's identity as verified from the report.
+}
+
+// EAREvidence is the identity of an enclave as included in an attestation result (ear.veraison.annotated-evidence).
+type EAREvidence struct {
+	UniqueID        string   `json:"mrenclave"`
+	SignerID        string   `json:"mrsigner"`
+	ProductID       []byte   `json:"isvprodid"`
+	SecurityVersion uint     `json:"isvsvn"`
+	Debug           bool     `json:"debug"`
+	TCBStatus       string   `json:"tcb-status"`
+	TCBAdvisories   []string `json:"tcb-advisories,omitempty"`
+}
+
+// NewAttestationResult creates an attestation result for a verified report.
+//
+// policyErr is the outcome of the appraisal of the report's content, e.g., the error returned by
+// the verifyReport callback. A non-nil policyErr contraindicates the enclave's executables.
+// The hardware claim is derived from the report's TCB status and advisories, the configuration claim from the debug flag
+// and whether the TCB status requires a platform configuration change.
+func NewAttestationResult(report Report, policyErr error, opts AttestationResultOptions) AttestationResult {
+	vector := TrustVector{
+		InstanceIdentity: TrustClaimAffirming,
+		Configuration:    TrustClaimAffirming,
+		Executables:      TrustClaimAffirming,
+		Hardware:         hardwareClaim(report.TCBStatus),
+		RuntimeOpaque:    TrustClaimAffirming, // enclave memory is encrypted
+		StorageOpaque:    TrustClaimAffirming, // sealing keys are bound to the hardware
+	}
+	if len(report.TCBAdvisories) > 0 {
+		// the platform is affected by known vulnerabilities
+		vector.Hardware = worseClaim(vector.Hardware, TrustClaimWarning)
+	}
+	switch report.TCBStatus {
+	case tcbstatus.ConfigurationNeeded, tcbstatus.ConfigurationAndSWHardeningNeeded, tcbstatus.OutOfDateConfigurationNeeded:
+		vector.Configuration = TrustClaimWarning
+	}
+	if report.Debug {
+		// memory of debug enclaves can be inspected
+		vector.Configuration = TrustClaimContraindicated
+		vector.RuntimeOpaque = TrustClaimContraindicated
+	}
+	if policyErr != nil {
+		vector.Executables = TrustClaimContraindicated
+	}
+
+	if opts.VerifierID == (VerifierID{}) {
+		opts.VerifierID = VerifierID{Build: "ego", Developer: "https://www.edgeless.systems"}
+	}
+	if opts.Submod == "" {
+		opts.Submod = EARSubmod
+	}
+	now := time.Now()
+	var expiry time.Time
+	if opts.Validity > 0 {
+		expiry = now.Add(opts.Validity)
+	}
+
+	return AttestationResult{
+		Status:      vector.Tier(),
+		TrustVector: vector,
+		PolicyID:    opts.PolicyID,
+		VerifierID:  opts.VerifierID,
+		Nonce:       opts.Nonce,
+		Submod:      opts.Submod,
+		IssuedAt:    now,
+		Expiry:      expiry,
+		Evidence: EAREvidence{
+			UniqueID:        hex.EncodeToString(report.UniqueID),
+			SignerID:        hex.EncodeToString(report.SignerID),
+			ProductID:       report.ProductID,
+			SecurityVersion: report.SecurityVersion,
+			Debug:           report.Debug,
+			TCBStatus:       report.TCBStatus.String(),
+			TCBAdvisories:   report.TCBAdvisories,
+		},
+	}
+}
+
+// Sign serializes the attestation result as JSON Web Token signed with key.
+// key must be an *ecdsa.PrivateKey, *rsa.PrivateKey, or ed25519.PrivateKey.
+func (r AttestationResult) Sign(key crypto.Signer) (string, error) {
+	alg, err := signatureAlgorithm(key.Public())
+	if err != nil {
+		return "", err
+	}
+	signer, err := jose.NewSigner(jose.SigningKey{Algorithm: alg, Key: key}, (&jose.SignerOptions{}).WithType("JWT"))
+	if err != nil {
+		return "", err
+	}
+
+	claims := earClaims{
+		Profile:    EARProfile,
+		IssuedAt:   r.IssuedAt.Unix(),
+		VerifierID: r.VerifierID,
+		Nonce:      r.Nonce,
+		Submods: map[string]earSubmod{r.Submod: {
+			Status:      r.Status,
+			TrustVector: r.TrustVector,
+			PolicyID:    r.PolicyID,
+			Evidence:    r.Evidence,
+		}},
+	}
+	if !r.Expiry.IsZero() {
+		claims.Expiry = r.Expiry.Unix()
+	}
+	return jwt.Signed(signer).Claims(claims).Serialize()
+}
+
+// VerifyAttestationResult verifies the signature of an attestation result created by AttestationResult.Sign
+// and returns the result. The token must contain exactly one submodule.
+//
+// The caller must check the Status of the returned result and should check its Nonce.
+func VerifyAttestationResult(token string, key crypto.PublicKey) (AttestationResult, error) {
+	alg, err := signatureAlgorithm(key)
+	if err != nil {
+		return AttestationResult{}, err
+	}
+	parsed, err := jwt.ParseSigned(token, []jose.SignatureAlgorithm{alg})
+	if err != nil {
+		return AttestationResult{}, err
+	}
+	var claims earClaims
+	if err := parsed.Claims(key, &claims); err != nil {
+		return AttestationResult{}, err
+	}
+
+	if claims.Profile != EARProfile {
+		return AttestationResult{}, fmt.Errorf("%w: unexpected profile %q", ErrInvalidAttestationResult, claims.Profile)
+	}
+	now := time.Now()
+	if claims.Expiry != 0 && !now.Before(time.Unix(claims.Expiry, 0)) {
+		return AttestationResult{}, fmt.Errorf("%w: expired", ErrInvalidAttestationResult)
+	}
+	if time.Unix(claims.IssuedAt, 0).After(now.Add(time.Minute)) {
+		return AttestationResult{}, fmt.Errorf("%w: issued in the future", ErrInvalidAttestationResult)
+	}
+	if len(claims.Submods) != 1 {
+		return AttestationResult{}, fmt.Errorf("%w: expected one submodule, got %v", ErrInvalidAttestationResult, len(claims.Submods))
+	}
+
+	result := AttestationResult{
+		VerifierID: claims.VerifierID,
+		Nonce:      claims.Nonce,
+		IssuedAt:   time.Unix(claims.IssuedAt, 0),
+	}
+	if claims.Expiry != 0 {
+		result.Expiry = time.Unix(claims.Expiry, 0)
+	}
+	for name, submod := range claims.Submods {
+		// The status must not be better than the vector.
+		if submod.Status != submod.TrustVector.Tier() {
+			return AttestationResult{}, fmt.Errorf("%w: status %q doesn't match the trustworthiness vector", ErrInvalidAttestationResult, submod.Status)
+		}
+		result.Submod = name
+		result.Status = submod.Status
+		result.TrustVector = submod.TrustVector
+		result.PolicyID = submod.PolicyID
+		result.Evidence = submod.Evidence
+	}
+	return result, nil
+}
+
+type earClaims struct {
+	Profile    string               `json:"eat_profile"`
+	IssuedAt   int64                `json:"iat"`
+	Expiry     int64                `json:"exp,omitempty"`
+	VerifierID VerifierID           `json:"ear.verifier-id"`
+	Nonce      string               `json:"eat_nonce,omitempty"`
+	Submods    map[string]earSubmod `json:"submods"`
+}
+
+type earSubmod struct {
+	Status      TrustTier   `json:"ear.status"`
+	TrustVector TrustVector `json:"ear.trustworthiness-vector"`
+	PolicyID    string      `json:"ear.appraisal-policy-id,omitempty"`
+	Evidence    EAREvidence `json:"ear.veraison.annotated-evidence"`
+}
+
+func hardwareClaim(status tcbstatus.Status) int8 {
+	switch status {
+	case tcbstatus.UpToDate:
+		return TrustClaimAffirming
+	case tcbstatus.SWHardeningNeeded, tcbstatus.ConfigurationNeeded, tcbstatus.ConfigurationAndSWHardeningNeeded,
+		tcbstatus.OutOfDate, tcbstatus.OutOfDateConfigurationNeeded:
+		// genuine hardware with known vulnerabilities
+		return TrustClaimWarning
+	case tcbstatus.Revoked:
+		return TrustClaimContraindicated
+	}
+	// the status is unknown, so the hardware can't be affirmed
+	return TrustClaimWarning
+}
+
+// worseClaim returns the claim that indicates less trustworthiness.
+func worseClaim(a, b int8) int8 {
+	if abs(a) >= abs(b) {
+		return a
+	}
+	return b
+}
+
+func abs(claim int8) int8 {
+	if claim < 0 {
+		return -claim
+	}
+	return claim
+}
+
+func signatureAlgorithm(key crypto.PublicKey) (jose.SignatureAlgorithm, error) {
+	switch k := key.(type) {
+	case *ecdsa.PublicKey:
+		switch k.Curve {
+		case elliptic.P256():
+			return jose.ES256, nil
+		case elliptic.P384():
+			return jose.ES384, nil
+		case elliptic.P521():
+			return jose.ES512, nil
+		}
+	case *rsa.PublicKey:
+		return jose.PS256, nil
+	case ed25519.PublicKey:
+		return jose.EdDSA, nil
+	}
+	return "", fmt.Errorf("unsupported key type %T", key)
+}
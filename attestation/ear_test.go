@@ -0,0 +1,228 @@
+// Copyright (c) Edgeless Systems GmbH.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package attestation
+
+import (
+	"crypto"
+	"crypto/ecdsa"
+	"crypto/ed25519"
+	"crypto/elliptic"
+	"crypto/rand"
+	"crypto/rsa"
+	"errors"
+	"testing"
+	"time"
+
+	"github.com/edgelesssys/ego/attestation/tcbstatus"
+	"github.com/go-jose/go-jose/v4"
+	"github.com/go-jose/go-jose/v4/jwt"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAttestationResult(t *testing.T) {
+	ecKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	rsaKey, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	_, edKey, err := ed25519.GenerateKey(rand.Reader)
+	require.NoError(t, err)
+
+	testCases := map[string]struct {
+		key        crypto.Signer
+		report     Report
+		wantStatus TrustTier
+	}{
+		"ecdsa": {
+			key:        ecKey,
+			report:     Report{SecurityVersion: 2, UniqueID: []byte{1, 2}, SignerID: []byte{3, 4}, ProductID: []byte{5}},
+			wantStatus: TrustTierAffirming,
+		},
+		"rsa": {
+			key:        rsaKey,
+			report:     Report{TCBStatus: tcbstatus.SWHardeningNeeded, TCBAdvisories: []string{"INTEL-SA-00001"}},
+			wantStatus: TrustTierWarning,
+		},
+		"ed25519 debug": {
+			key:        edKey,
+			report:     Report{Debug: true},
+			wantStatus: TrustTierContraindicated,
+		},
+		"revoked": {
+			key:        ecKey,
+			report:     Report{TCBStatus: tcbstatus.Revoked},
+			wantStatus: TrustTierContraindicated,
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			assert := assert.New(t)
+			require := require.New(t)
+
+			result := NewAttestationResult(tc.report, nil, AttestationResultOptions{PolicyID: "policy", Validity: time.Hour})
+			assert.Equal(tc.wantStatus, result.Status)
+			token, err := result.Sign(tc.key)
+			require.NoError(err)
+
+			verified, err := VerifyAttestationResult(token, tc.key.Public())
+			require.NoError(err)
+			assert.Equal(tc.wantStatus, verified.Status)
+			assert.Equal(result.TrustVector, verified.TrustVector)
+			assert.Equal("policy", verified.PolicyID)
+			assert.Equal(EARSubmod, verified.Submod)
+			assert.Equal(result.Evidence, verified.Evidence)
+			assert.Equal(tc.report.TCBStatus.String(), verified.Evidence.TCBStatus)
+		})
+	}
+}
+
+func TestAttestationResultTrustVector(t *testing.T) {
+	testCases := map[string]struct {
+		report            Report
+		policyErr         error
+		wantHardware      int8
+		wantConfiguration int8
+		wantExecutables   int8
+		wantStatus        TrustTier
+	}{
+		"up to date": {
+			report:            Report{TCBStatus: tcbstatus.UpToDate},
+			wantHardware:      TrustClaimAffirming,
+			wantConfiguration: TrustClaimAffirming,
+			wantExecutables:   TrustClaimAffirming,
+			wantStatus:        TrustTierAffirming,
+		},
+		"up to date with advisories": {
+			report:            Report{TCBStatus: tcbstatus.UpToDate, TCBAdvisories: []string{"INTEL-SA-00615"}},
+			wantHardware:      TrustClaimWarning,
+			wantConfiguration: TrustClaimAffirming,
+			wantExecutables:   TrustClaimAffirming,
+			wantStatus:        TrustTierWarning,
+		},
+		"unknown status with advisories": {
+			report:            Report{TCBAdvisories: []string{"INTEL-SA-00615"}},
+			wantHardware:      TrustClaimWarning,
+			wantConfiguration: TrustClaimAffirming,
+			wantExecutables:   TrustClaimAffirming,
+			wantStatus:        TrustTierWarning,
+		},
+		"unknown status": {
+			report:            Report{TCBStatus: tcbstatus.Unknown},
+			wantHardware:      TrustClaimWarning,
+			wantConfiguration: TrustClaimAffirming,
+			wantExecutables:   TrustClaimAffirming,
+			wantStatus:        TrustTierWarning,
+		},
+		"configuration needed": {
+			report:            Report{TCBStatus: tcbstatus.ConfigurationNeeded, TCBAdvisories: []string{"INTEL-SA-00289"}},
+			wantHardware:      TrustClaimWarning,
+			wantConfiguration: TrustClaimWarning,
+			wantExecutables:   TrustClaimAffirming,
+			wantStatus:        TrustTierWarning,
+		},
+		"revoked with advisories": {
+			report:            Report{TCBStatus: tcbstatus.Revoked, TCBAdvisories: []string{"INTEL-SA-00001"}},
+			wantHardware:      TrustClaimContraindicated,
+			wantConfiguration: TrustClaimAffirming,
+			wantExecutables:   TrustClaimAffirming,
+			wantStatus:        TrustTierContraindicated,
+		},
+		"debug with configuration needed": {
+			report:            Report{TCBStatus: tcbstatus.OutOfDateConfigurationNeeded, Debug: true},
+			wantHardware:      TrustClaimWarning,
+			wantConfiguration: TrustClaimContraindicated,
+			wantExecutables:   TrustClaimAffirming,
+			wantStatus:        TrustTierContraindicated,
+		},
+		"policy error": {
+			report:            Report{TCBStatus: tcbstatus.UpToDate},
+			policyErr:         errors.New("invalid signer"),
+			wantHardware:      TrustClaimAffirming,
+			wantConfiguration: TrustClaimAffirming,
+			wantExecutables:   TrustClaimContraindicated,
+			wantStatus:        TrustTierContraindicated,
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			assert := assert.New(t)
+
+			result := NewAttestationResult(tc.report, tc.policyErr, AttestationResultOptions{})
+			assert.Equal(tc.wantHardware, result.TrustVector.Hardware)
+			assert.Equal(tc.wantConfiguration, result.TrustVector.Configuration)
+			assert.Equal(tc.wantExecutables, result.TrustVector.Executables)
+			assert.Equal(tc.wantStatus, result.Status)
+		})
+	}
+}
+
+func TestVerifyAttestationResult(t *testing.T) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+	otherKey, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+
+	sign := func(claims any) string {
+		signer, err := jose.NewSigner(jose.SigningKey{Algorithm: jose.ES256, Key: key}, nil)
+		require.NoError(t, err)
+		token, err := jwt.Signed(signer).Claims(claims).Serialize()
+		require.NoError(t, err)
+		return token
+	}
+	validSubmods := map[string]earSubmod{"SGX": {Status: TrustTierAffirming, TrustVector: TrustVector{Hardware: TrustClaimAffirming}}}
+
+	testCases := map[string]struct {
+		token   string
+		key     crypto.PublicKey
+		wantErr bool
+	}{
+		"valid": {
+			token: sign(earClaims{Profile: EARProfile, IssuedAt: time.Now().Unix(), Submods: validSubmods}),
+			key:   &key.PublicKey,
+		},
+		"other key": {
+			token:   sign(earClaims{Profile: EARProfile, IssuedAt: time.Now().Unix(), Submods: validSubmods}),
+			key:     &otherKey.PublicKey,
+			wantErr: true,
+		},
+		"other profile": {
+			token:   sign(earClaims{Profile: "foo", IssuedAt: time.Now().Unix(), Submods: validSubmods}),
+			key:     &key.PublicKey,
+			wantErr: true,
+		},
+		"expired": {
+			token:   sign(earClaims{Profile: EARProfile, IssuedAt: time.Now().Add(-2 * time.Hour).Unix(), Expiry: time.Now().Add(-time.Hour).Unix(), Submods: validSubmods}),
+			key:     &key.PublicKey,
+			wantErr: true,
+		},
+		"status better than vector": {
+			token: sign(earClaims{Profile: EARProfile, IssuedAt: time.Now().Unix(), Submods: map[string]earSubmod{
+				"SGX": {Status: TrustTierAffirming, TrustVector: TrustVector{Hardware: TrustClaimContraindicated}},
+			}}),
+			key:     &key.PublicKey,
+			wantErr: true,
+		},
+		"no submods": {
+			token:   sign(earClaims{Profile: EARProfile, IssuedAt: time.Now().Unix()}),
+			key:     &key.PublicKey,
+			wantErr: true,
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			_, err := VerifyAttestationResult(tc.token, tc.key)
+			if tc.wantErr {
+				assert.Error(t, err)
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
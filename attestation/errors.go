@@ -0,0 +1,158 @@
+// Copyright (c) Edgeless Systems GmbH.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package attestation
+
+import (
+	"errors"
+	"fmt"
+)
+
+// ErrorKind classifies why the verification of a report failed.
+type ErrorKind int
+
+const (
+	// ErrorKindUnknown is an error that isn't classified.
+	ErrorKindUnknown ErrorKind = iota
+	// ErrorKindSignature means that the report or its certificate chain has an invalid signature. The report may be forged.
+	ErrorKindSignature
+	// ErrorKindCollateral means that the collateral needed for verification is expired, missing, or invalid.
+	// Verifying again with fresh collateral may succeed.
+	ErrorKindCollateral
+	// ErrorKindTCB means that the report is valid, but the platform's TCB level isn't trusted.
+	ErrorKindTCB
+	// ErrorKindFormat means that the report is malformed.
+	ErrorKindFormat
+	// ErrorKindPlatform means that the verifying platform failed, e.g., the quote provider library couldn't be loaded
+	// or the collateral service is unavailable. Verifying again may succeed.
+	ErrorKindPlatform
+)
+
+func (k ErrorKind) String() string {
+	switch k {
+	case ErrorKindSignature:
+		return "signature"
+	case ErrorKindCollateral:
+		return "collateral"
+	case ErrorKindTCB:
+		return "TCB"
+	case ErrorKindFormat:
+		return "format"
+	case ErrorKindPlatform:
+		return "platform"
+	}
+	return "unknown"
+}
+
+var (
+	// ErrInvalidSignature matches a VerificationError of ErrorKindSignature.
+	ErrInvalidSignature = errors.New("invalid signature")
+	// ErrInvalidCollateral matches a VerificationError of ErrorKindCollateral.
+	ErrInvalidCollateral = errors.New("invalid collateral")
+	// ErrInvalidFormat matches a VerificationError of ErrorKindFormat.
+	ErrInvalidFormat = errors.New("invalid report format")
+	// ErrPlatform matches a VerificationError of ErrorKindPlatform.
+	ErrPlatform = errors.New("platform error")
+)
+
+// VerificationError is returned by VerifyRemoteReport and VerifyLocalReport if the enclave platform failed to verify a report.
+// If only the TCB level isn't up to date, ErrTCBLevelInvalid itself is returned instead, together with the report.
+//
+// Use errors.Is with ErrInvalidSignature, ErrInvalidCollateral, ErrInvalidFormat, or ErrPlatform to check the kind.
+type VerificationError struct {
+	Result uint32    // The numeric Open Enclave result code (oe_result_t).
+	Name   string    // The name of the result, e.g., OE_QUOTE_VERIFICATION_ERROR.
+	Kind   ErrorKind // The classification of the result.
+}
+
+// NewVerificationError creates a VerificationError from an Open Enclave result. The kind is derived from the result's name.
+func NewVerificationError(result uint32, name string) *VerificationError {
+	return &VerificationError{Result: result, Name: name, Kind: resultKinds[name]}
+}
+
+func (e *VerificationError) Error() string {
+	if e.Name == "" {
+		return fmt.Sprintf("OE result %#x", e.Result)
+	}
+	return e.Name
+}
+
+// Is reports whether the error matches target. Target may be the sentinel error of the kind, ErrTCBLevelInvalid,
+// or a VerificationError with the same result.
+func (e *VerificationError) Is(target error) bool {
+	switch target {
+	case ErrInvalidSignature:
+		return e.Kind == ErrorKindSignature
+	case ErrInvalidCollateral:
+		return e.Kind == ErrorKindCollateral
+	case ErrInvalidFormat:
+		return e.Kind == ErrorKindFormat
+	case ErrPlatform:
+		return e.Kind == ErrorKindPlatform
+	case ErrTCBLevelInvalid:
+		return e.Name == resultTCBLevelInvalid
+	}
+	if t, ok := target.(*VerificationError); ok {
+		return e.Result == t.Result
+	}
+	return false
+}
+
+// Transient reports whether verifying the report again, e.g., with fresh collateral, may succeed.
+func (e *VerificationError) Transient() bool {
+	return e.Kind == ErrorKindCollateral || e.Kind == ErrorKindPlatform
+}
+
+const resultTCBLevelInvalid = "OE_TCB_LEVEL_INVALID"
+
+// https://github.com/openenclave/openenclave/blob/master/include/openenclave/bits/result.h
+var resultKinds = map[string]ErrorKind{
+	"OE_VERIFY_FAILED":                   ErrorKindSignature,
+	"OE_VERIFY_FAILED_AES_CMAC_MISMATCH": ErrorKindSignature,
+	"OE_QUOTE_VERIFICATION_ERROR":        ErrorKindSignature,
+	"OE_QUOTE_HASH_MISMATCH":             ErrorKindSignature,
+
+	"OE_VERIFY_CRL_EXPIRED":              ErrorKindCollateral,
+	"OE_VERIFY_CRL_MISSING":              ErrorKindCollateral,
+	"OE_INVALID_ENDORSEMENT":             ErrorKindCollateral,
+	"OE_INVALID_REVOCATION_INFO_VERSION": ErrorKindCollateral,
+	"OE_INVALID_QE_IDENTITY_INFO":        ErrorKindCollateral,
+	"OE_TCB_INFO_PARSE_ERROR":            ErrorKindCollateral,
+	"OE_INVALID_UTC_DATE_TIME":           ErrorKindCollateral,
+
+	resultTCBLevelInvalid: ErrorKindTCB,
+	"OE_VERIFY_REVOKED":   ErrorKindTCB,
+
+	"OE_REPORT_PARSE_ERROR":                 ErrorKindFormat,
+	"OE_INCORRECT_REPORT_SIZE":              ErrorKindFormat,
+	"OE_INVALID_PARAMETER":                  ErrorKindFormat,
+	"OE_UNSUPPORTED_QE_CERTIFICATION":       ErrorKindFormat,
+	"OE_INVALID_SGX_CERTIFICATE_EXTENSIONS": ErrorKindFormat,
+
+	"OE_PLATFORM_ERROR":            ErrorKindPlatform,
+	"OE_SERVICE_UNAVAILABLE":       ErrorKindPlatform,
+	"OE_QUOTE_PROVIDER_LOAD_ERROR": ErrorKindPlatform,
+	"OE_QUOTE_PROVIDER_CALL_ERROR": ErrorKindPlatform,
+	"OE_QUOTE_LIBRARY_LOAD_ERROR":  ErrorKindPlatform,
+	"OE_OUT_OF_MEMORY":             ErrorKindPlatform,
+	"OE_UNSUPPORTED":               ErrorKindPlatform,
+	"OE_UNEXPECTED":                ErrorKindPlatform,
+}
+
+// IgnoreTCBStatus wraps a function that verifies remote reports, e.g., eclient.VerifyRemoteReport, so that
+// ErrTCBLevelInvalid isn't returned. Pass it to packages like grpccreds or challenge to accept platforms whose
+// TCB level isn't up to date.
+//
+// Callers must verify the TCBStatus field in the report themselves.
+func IgnoreTCBStatus(verifyRemoteReport func([]byte) (Report, error)) func([]byte) (Report, error) {
+	return func(reportBytes []byte) (Report, error) {
+		report, err := verifyRemoteReport(reportBytes)
+		if errors.Is(err, ErrTCBLevelInvalid) {
+			return report, nil
+		}
+		return report, err
+	}
+}
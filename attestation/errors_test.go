@@ -0,0 +1,97 @@
+// Copyright (c) Edgeless Systems GmbH.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package attestation
+
+import (
+	"errors"
+	"fmt"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+)
+
+func TestVerificationError(t *testing.T) {
+	testCases := map[string]struct {
+		name          string
+		wantKind      ErrorKind
+		wantIs        error
+		wantTransient bool
+	}{
+		"signature": {
+			name:     "OE_QUOTE_VERIFICATION_ERROR",
+			wantKind: ErrorKindSignature,
+			wantIs:   ErrInvalidSignature,
+		},
+		"collateral": {
+			name:          "OE_VERIFY_CRL_EXPIRED",
+			wantKind:      ErrorKindCollateral,
+			wantIs:        ErrInvalidCollateral,
+			wantTransient: true,
+		},
+		"tcb": {
+			name:     "OE_TCB_LEVEL_INVALID",
+			wantKind: ErrorKindTCB,
+			wantIs:   ErrTCBLevelInvalid,
+		},
+		"format": {
+			name:     "OE_REPORT_PARSE_ERROR",
+			wantKind: ErrorKindFormat,
+			wantIs:   ErrInvalidFormat,
+		},
+		"platform": {
+			name:          "OE_QUOTE_PROVIDER_CALL_ERROR",
+			wantKind:      ErrorKindPlatform,
+			wantIs:        ErrPlatform,
+			wantTransient: true,
+		},
+		"unknown": {
+			name:     "OE_FAILURE",
+			wantKind: ErrorKindUnknown,
+		},
+	}
+
+	sentinels := []error{ErrInvalidSignature, ErrInvalidCollateral, ErrTCBLevelInvalid, ErrInvalidFormat, ErrPlatform}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			assert := assert.New(t)
+
+			err := fmt.Errorf("verifying: %w", NewVerificationError(42, tc.name))
+			assert.EqualError(err, "verifying: "+tc.name)
+
+			var verr *VerificationError
+			assert.ErrorAs(err, &verr)
+			assert.EqualValues(42, verr.Result)
+			assert.Equal(tc.wantKind, verr.Kind)
+			assert.Equal(tc.wantTransient, verr.Transient())
+
+			for _, sentinel := range sentinels {
+				assert.Equal(sentinel == tc.wantIs, errors.Is(err, sentinel), sentinel)
+			}
+			assert.ErrorIs(err, &VerificationError{Result: 42})
+			assert.NotErrorIs(err, &VerificationError{Result: 43})
+		})
+	}
+}
+
+func TestIgnoreTCBStatus(t *testing.T) {
+	assert := assert.New(t)
+
+	verify := func(err error) func([]byte) (Report, error) {
+		return IgnoreTCBStatus(func([]byte) (Report, error) { return Report{SecurityVersion: 2}, err })
+	}
+
+	report, err := verify(ErrTCBLevelInvalid)(nil)
+	assert.NoError(err)
+	assert.EqualValues(2, report.SecurityVersion)
+	_, err = verify(NewVerificationError(0, "OE_TCB_LEVEL_INVALID"))(nil)
+	assert.NoError(err)
+	_, err = verify(ErrInvalidSignature)(nil)
+	assert.ErrorIs(err, ErrInvalidSignature)
+	_, err = verify(nil)(nil)
+	assert.NoError(err)
+}
@@ -0,0 +1,167 @@
+// Copyright (c) Edgeless Systems GmbH.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at https://mozilla.org/MPL/2.0/.
+
+/*
+Package grpccreds provides gRPC transport credentials based on attested TLS.
+
+The server presents a certificate with an embedded report. The client verifies the report and exposes it
+to the application via the peer's AuthInfo. Optionally, the client presents an attested certificate, too,
+and the server verifies it (mutual attestation between enclaves).
+
+Credentials take the functions that create and verify reports, so the same code works inside and outside of
+an enclave. A server in an enclave passes enclave.GetRemoteReport, and a client passes enclave.VerifyRemoteReport
+or eclient.VerifyRemoteReport depending on where it runs:
+
+	// server in an enclave
+	creds, err := grpccreds.NewServerCredentials(enclave.GetRemoteReport)
+	server := grpc.NewServer(grpc.Creds(creds))
+
+	// client outside of an enclave
+	creds, err := grpccreds.NewClientCredentials(eclient.VerifyRemoteReport, verifyReport)
+	conn, err := grpc.NewClient(addr, grpc.WithTransportCredentials(creds))
+
+	// client in an enclave
+	creds, err := grpccreds.NewClientCredentials(enclave.VerifyRemoteReport, verifyReport)
+
+To accept servers on platforms whose TCB level isn't up to date, wrap the verify function with attestation.IgnoreTCBStatus.
+*/
+package grpccreds
+
+import (
+	"context"
+	"crypto/tls"
+	"errors"
+	"net"
+
+	"github.com/edgelesssys/ego/attestation"
+	internal "github.com/edgelesssys/ego/internal/attestation"
+	"google.golang.org/grpc/credentials"
+)
+
+// AuthType is the AuthType of AuthInfo.
+const AuthType = "ego-attestation"
+
+// AuthInfo is the AuthInfo of a connection whose peer has been attested.
+type AuthInfo struct {
+	credentials.TLSInfo
+	Report attestation.Report // The verified report of the peer.
+}
+
+// AuthType returns AuthType.
+func (AuthInfo) AuthType() string {
+	return AuthType
+}
+
+// NewServerCredentials creates transport credentials for a gRPC server running in an enclave.
+//
+// getRemoteReport is used to create the report that is embedded in the server's certificate. Usually, this is enclave.GetRemoteReport.
+func NewServerCredentials(getRemoteReport func([]byte) ([]byte, error), opts ...Option) (credentials.TransportCredentials, error) {
+	o := applyOptions(opts)
+	config, err := internal.CreateAttestationServerTLSConfig(o.hashPublicKey(), getRemoteReport)
+	if err != nil {
+		return nil, err
+	}
+	if o.verifyRemoteReport != nil {
+		config.ClientAuth = tls.RequireAnyClientCert
+	}
+	return &transportCredentials{config: config, opts: o}, nil
+}
+
+// NewClientCredentials creates transport credentials for a gRPC client that verify the server's report.
+//
+// verifyRemoteReport is either enclave.VerifyRemoteReport or eclient.VerifyRemoteReport.
+//
+// verifyReport is called after the certificate has been verified against the report data. The caller must verify either the UniqueID or the tuple (SignerID, ProductID, SecurityVersion, Debug) in the callback.
+func NewClientCredentials(verifyRemoteReport func([]byte) (attestation.Report, error), verifyReport func(attestation.Report) error, opts ...Option) (credentials.TransportCredentials, error) {
+	o := applyOptions(opts)
+	o.verifyRemoteReport = verifyRemoteReport
+	o.verifyReport = verifyReport
+
+	config := &tls.Config{}
+	if o.getRemoteReport != nil {
+		serverConfig, err := internal.CreateAttestationServerTLSConfig(o.hashPublicKey(), o.getRemoteReport)
+		if err != nil {
+			return nil, err
+		}
+		config.Certificates = serverConfig.Certificates
+	}
+	return &transportCredentials{config: config, opts: o, client: true}, nil
+}
+
+type transportCredentials struct {
+	config *tls.Config
+	opts   options
+	client bool
+}
+
+func (c *transportCredentials) ClientHandshake(ctx context.Context, authority string, rawConn net.Conn) (net.Conn, credentials.AuthInfo, error) {
+	if !c.client {
+		return nil, nil, errors.New("server credentials can't be used by a client")
+	}
+	config, report := c.handshakeConfig()
+	conn, info, err := credentials.NewTLS(config).ClientHandshake(ctx, authority, rawConn)
+	if err != nil {
+		return nil, nil, err
+	}
+	return conn, AuthInfo{TLSInfo: info.(credentials.TLSInfo), Report: *report}, nil
+}
+
+func (c *transportCredentials) ServerHandshake(rawConn net.Conn) (net.Conn, credentials.AuthInfo, error) {
+	if c.client {
+		return nil, nil, errors.New("client credentials can't be used by a server")
+	}
+	config, report := c.handshakeConfig()
+	conn, info, err := credentials.NewTLS(config).ServerHandshake(rawConn)
+	if err != nil {
+		return nil, nil, err
+	}
+	if c.opts.verifyRemoteReport == nil {
+		// the client hasn't been attested
+		return conn, info, nil
+	}
+	return conn, AuthInfo{TLSInfo: info.(credentials.TLSInfo), Report: *report}, nil
+}
+
+func (c *transportCredentials) Info() credentials.ProtocolInfo {
+	return credentials.NewTLS(c.config).Info()
+}
+
+func (c *transportCredentials) Clone() credentials.TransportCredentials {
+	return &transportCredentials{config: c.config.Clone(), opts: c.opts, client: c.client}
+}
+
+func (c *transportCredentials) OverrideServerName(serverNameOverride string) error {
+	c.config.ServerName = serverNameOverride
+	return nil
+}
+
+// handshakeConfig returns a TLS config for a single handshake. If the config verifies the peer, the
+// verified report is stored in the returned pointer.
+func (c *transportCredentials) handshakeConfig() (*tls.Config, *attestation.Report) {
+	config := c.config.Clone()
+	report := &attestation.Report{}
+	if c.opts.verifyRemoteReport == nil {
+		return config, report
+	}
+
+	verifyConfig := internal.CreateAttestationClientTLSConfig(
+		func(reportBytes []byte) (internal.Report, error) {
+			report, err := c.opts.verifyRemoteReport(reportBytes)
+			return internal.Report(report), err
+		},
+		internal.Options{},
+		func(rep internal.Report) error {
+			if err := c.opts.verifyReport(attestation.Report(rep)); err != nil {
+				return err
+			}
+			*report = attestation.Report(rep)
+			return nil
+		},
+	)
+	config.VerifyPeerCertificate = verifyConfig.VerifyPeerCertificate
+	config.InsecureSkipVerify = verifyConfig.InsecureSkipVerify
+	return config, report
+}
@@ -0,0 +1,173 @@
+// Copyright (c) Edgeless Systems GmbH.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package grpccreds
+
+import (
+	"context"
+	"errors"
+	"net"
+	"testing"
+
+	"github.com/edgelesssys/ego/attestation"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/credentials"
+	"google.golang.org/grpc/health"
+	healthpb "google.golang.org/grpc/health/grpc_health_v1"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+	"google.golang.org/grpc/test/bufconn"
+)
+
+func getRemoteReport(reportData []byte) ([]byte, error) {
+	return append([]byte{2}, reportData...), nil
+}
+
+func verifyRemoteReport(reportBytes []byte) (attestation.Report, error) {
+	if len(reportBytes) != 33 || reportBytes[0] != 2 {
+		return attestation.Report{}, errors.New("invalid remote report")
+	}
+	return attestation.Report{Data: reportBytes[1:], SecurityVersion: 2}, nil
+}
+
+func verifyReport(report attestation.Report) error {
+	if report.SecurityVersion != 2 {
+		return errors.New("invalid report")
+	}
+	return nil
+}
+
+func TestCredentials(t *testing.T) {
+	testCases := map[string]struct {
+		serverOpts   []Option
+		clientOpts   []Option
+		verifyReport func(attestation.Report) error
+		wantErr      bool
+	}{
+		"basic": {
+			verifyReport: verifyReport,
+		},
+		"oe format": {
+			serverOpts:   []Option{WithOpenEnclaveFormat()},
+			verifyReport: verifyReport,
+		},
+		"invalid report": {
+			verifyReport: func(attestation.Report) error { return errors.New("invalid report") },
+			wantErr:      true,
+		},
+		"mutual attestation": {
+			serverOpts:   []Option{WithClientAttestation(verifyRemoteReport, verifyReport)},
+			clientOpts:   []Option{WithClientCertificate(getRemoteReport)},
+			verifyReport: verifyReport,
+		},
+		"client without certificate": {
+			serverOpts:   []Option{WithClientAttestation(verifyRemoteReport, verifyReport)},
+			verifyReport: verifyReport,
+			wantErr:      true,
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			assert := assert.New(t)
+			require := require.New(t)
+
+			serverCreds, err := NewServerCredentials(getRemoteReport, tc.serverOpts...)
+			require.NoError(err)
+			clientCreds, err := NewClientCredentials(verifyRemoteReport, tc.verifyReport, tc.clientOpts...)
+			require.NoError(err)
+
+			client := startServer(t, serverCreds, clientCreds)
+
+			var p peer.Peer
+			_, err = client.Check(context.Background(), &healthpb.HealthCheckRequest{}, grpc.Peer(&p))
+			if tc.wantErr {
+				assert.Error(err)
+				return
+			}
+			require.NoError(err)
+
+			info, ok := p.AuthInfo.(AuthInfo)
+			require.True(ok)
+			assert.EqualValues(2, info.Report.SecurityVersion)
+			assert.Equal(credentials.PrivacyAndIntegrity, info.SecurityLevel)
+		})
+	}
+}
+
+func TestInterceptors(t *testing.T) {
+	policy := func(fullMethod string, report attestation.Report) error {
+		if fullMethod == healthpb.Health_Watch_FullMethodName {
+			return errors.New("denied")
+		}
+		if report.SecurityVersion != 2 {
+			return errors.New("invalid security version")
+		}
+		return nil
+	}
+
+	testCases := map[string]struct {
+		serverOpts []Option
+		clientOpts []Option
+		wantCheck  codes.Code
+		wantWatch  codes.Code
+	}{
+		"attested client": {
+			serverOpts: []Option{WithClientAttestation(verifyRemoteReport, verifyReport)},
+			clientOpts: []Option{WithClientCertificate(getRemoteReport)},
+			wantCheck:  codes.OK,
+			wantWatch:  codes.PermissionDenied,
+		},
+		"client not attested": {
+			wantCheck: codes.Unauthenticated,
+			wantWatch: codes.Unauthenticated,
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			assert := assert.New(t)
+			require := require.New(t)
+
+			serverCreds, err := NewServerCredentials(getRemoteReport, tc.serverOpts...)
+			require.NoError(err)
+			clientCreds, err := NewClientCredentials(verifyRemoteReport, verifyReport, tc.clientOpts...)
+			require.NoError(err)
+
+			client := startServer(t, serverCreds, clientCreds,
+				grpc.UnaryInterceptor(UnaryServerInterceptor(policy)),
+				grpc.StreamInterceptor(StreamServerInterceptor(policy)),
+			)
+
+			_, err = client.Check(context.Background(), &healthpb.HealthCheckRequest{})
+			assert.Equal(tc.wantCheck, status.Code(err))
+
+			stream, err := client.Watch(context.Background(), &healthpb.HealthCheckRequest{})
+			require.NoError(err)
+			_, err = stream.Recv()
+			assert.Equal(tc.wantWatch, status.Code(err))
+		})
+	}
+}
+
+func startServer(t *testing.T, serverCreds, clientCreds credentials.TransportCredentials, opts ...grpc.ServerOption) healthpb.HealthClient {
+	lis := bufconn.Listen(1 << 16)
+	server := grpc.NewServer(append(opts, grpc.Creds(serverCreds))...)
+	healthpb.RegisterHealthServer(server, health.NewServer())
+	go func() { _ = server.Serve(lis) }()
+	t.Cleanup(server.Stop)
+
+	conn, err := grpc.NewClient("passthrough:///bufnet",
+		grpc.WithContextDialer(func(ctx context.Context, _ string) (net.Conn, error) { return lis.DialContext(ctx) }),
+		grpc.WithTransportCredentials(clientCreds),
+	)
+	require.NoError(t, err)
+	t.Cleanup(func() { conn.Close() })
+	return healthpb.NewHealthClient(conn)
+}
@@ -0,0 +1,70 @@
+// Copyright (c) Edgeless Systems GmbH.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package grpccreds
+
+import (
+	"context"
+
+	"github.com/edgelesssys/ego/attestation"
+	"google.golang.org/grpc"
+	"google.golang.org/grpc/codes"
+	"google.golang.org/grpc/peer"
+	"google.golang.org/grpc/status"
+)
+
+// Policy verifies the report of a peer calling the gRPC method fullMethod.
+type Policy func(fullMethod string, report attestation.Report) error
+
+// ReportFromContext returns the verified report of the peer.
+func ReportFromContext(ctx context.Context) (attestation.Report, bool) {
+	p, ok := peer.FromContext(ctx)
+	if !ok {
+		return attestation.Report{}, false
+	}
+	info, ok := p.AuthInfo.(AuthInfo)
+	if !ok {
+		return attestation.Report{}, false
+	}
+	return info.Report, true
+}
+
+// UnaryServerInterceptor returns an interceptor that enforces policy for each unary call.
+//
+// Calls from peers that haven't been attested fail with codes.Unauthenticated.
+// Calls that don't satisfy policy fail with codes.PermissionDenied.
+func UnaryServerInterceptor(policy Policy) grpc.UnaryServerInterceptor {
+	return func(ctx context.Context, req any, info *grpc.UnaryServerInfo, handler grpc.UnaryHandler) (any, error) {
+		if err := enforce(ctx, policy, info.FullMethod); err != nil {
+			return nil, err
+		}
+		return handler(ctx, req)
+	}
+}
+
+// StreamServerInterceptor returns an interceptor that enforces policy for each stream.
+//
+// Streams from peers that haven't been attested fail with codes.Unauthenticated.
+// Streams that don't satisfy policy fail with codes.PermissionDenied.
+func StreamServerInterceptor(policy Policy) grpc.StreamServerInterceptor {
+	return func(srv any, ss grpc.ServerStream, info *grpc.StreamServerInfo, handler grpc.StreamHandler) error {
+		if err := enforce(ss.Context(), policy, info.FullMethod); err != nil {
+			return err
+		}
+		return handler(srv, ss)
+	}
+}
+
+func enforce(ctx context.Context, policy Policy, fullMethod string) error {
+	report, ok := ReportFromContext(ctx)
+	if !ok {
+		return status.Error(codes.Unauthenticated, "peer has not been attested")
+	}
+	if err := policy(fullMethod, report); err != nil {
+		return status.Errorf(codes.PermissionDenied, "attestation policy: %v", err)
+	}
+	return nil
+}
@@ -0,0 +1,66 @@
+// Copyright (c) Edgeless Systems GmbH.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package grpccreds
+
+import (
+	"github.com/edgelesssys/ego/attestation"
+	internal "github.com/edgelesssys/ego/internal/attestation"
+)
+
+// Option configures transport credentials.
+type Option struct {
+	apply func(*options)
+}
+
+type options struct {
+	openEnclaveFormat  bool
+	getRemoteReport    func([]byte) ([]byte, error)
+	verifyRemoteReport func([]byte) (attestation.Report, error)
+	verifyReport       func(attestation.Report) error
+}
+
+func applyOptions(opts []Option) options {
+	var o options
+	for _, opt := range opts {
+		opt.apply(&o)
+	}
+	return o
+}
+
+func (o options) hashPublicKey() func(pub any) ([]byte, error) {
+	if o.openEnclaveFormat {
+		return internal.HashPublicKeyOE
+	}
+	return internal.HashPublicKey
+}
+
+// WithOpenEnclaveFormat creates the certificate in Open Enclave format.
+// The certificate is accepted by both EGo and Open Enclave peers.
+func WithOpenEnclaveFormat() Option {
+	return Option{func(o *options) { o.openEnclaveFormat = true }}
+}
+
+// WithClientAttestation lets server credentials require a client certificate with an embedded report.
+// The verified report of the client is available in the AuthInfo of the peer.
+//
+// verifyRemoteReport is either enclave.VerifyRemoteReport or eclient.VerifyRemoteReport.
+//
+// verifyReport is called after the certificate has been verified against the report data. The caller must verify either the UniqueID or the tuple (SignerID, ProductID, SecurityVersion, Debug) in the callback.
+func WithClientAttestation(verifyRemoteReport func([]byte) (attestation.Report, error), verifyReport func(attestation.Report) error) Option {
+	return Option{func(o *options) {
+		o.verifyRemoteReport = verifyRemoteReport
+		o.verifyReport = verifyReport
+	}}
+}
+
+// WithClientCertificate lets client credentials present a certificate with an embedded report.
+// Use this if the client runs in an enclave and the server uses WithClientAttestation.
+//
+// getRemoteReport is used to create the report. Usually, this is enclave.GetRemoteReport.
+func WithClientCertificate(getRemoteReport func([]byte) ([]byte, error)) Option {
+	return Option{func(o *options) { o.getRemoteReport = getRemoteReport }}
+}
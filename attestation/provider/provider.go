@@ -0,0 +1,182 @@
+// Copyright (c) Edgeless Systems GmbH.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at https://mozilla.org/MPL/2.0/.
+
+/*
+Package provider abstracts attestation services that issue tokens for remote reports.
+
+An enclave sends its report to the service and gets a signed token, which relying parties verify
+without having to verify the report themselves. The service is selected by a Config, so an app can
+switch between services without changing its call sites:
+
+	// in the enclave
+	p, err := provider.New(provider.Config{Type: provider.TypeAzure, URL: "https://shareduks.uks.attest.azure.net"})
+	token, err := provider.CreateToken(ctx, p, enclave.GetRemoteReport, data)
+
+	// relying party
+	report, err := p.VerifyToken(ctx, token)
+	// verify report.Data == data and the identity of the enclave
+
+CreateToken takes the function that creates the report, so only the enclave needs to be built with EGo.
+VerifyToken only checks the token's signature and claims, so relying parties need neither an enclave nor Open Enclave.
+*/
+package provider
+
+import (
+	"context"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/edgelesssys/ego/attestation"
+	internal "github.com/edgelesssys/ego/internal/attestation"
+)
+
+// Types of attestation services.
+const (
+	// TypeAzure is Microsoft Azure Attestation.
+	TypeAzure = "azure"
+	// TypeTrustAuthority is Intel Trust Authority or a compatible service.
+	TypeTrustAuthority = "trustauthority"
+	// TypeSelfHosted is a self-hosted service with the API of Microsoft Azure Attestation, e.g., ego attestation-service.
+	TypeSelfHosted = "selfhosted"
+)
+
+// DefaultTrustAuthorityCertsURL is the URL of the key set of Intel Trust Authority.
+const DefaultTrustAuthorityCertsURL = "https://portal.trustauthority.intel.com/certs"
+
+// defaultTimeout limits the time a request to the service takes with the default client.
+const defaultTimeout = 30 * time.Second
+
+// Provider creates and verifies attestation tokens.
+type Provider interface {
+	// CreateToken sends the remote report to the attestation service and returns the issued token.
+	// The report data must be the SHA-256 hash of data. Use the CreateToken function to create a matching report.
+	CreateToken(ctx context.Context, report, data []byte) (string, error)
+	// VerifyToken verifies the token and returns the report contained in it. The report's Data is the data
+	// the token was created with. The caller must verify the returned report's content.
+	VerifyToken(ctx context.Context, token string) (attestation.Report, error)
+}
+
+// Config configures a Provider.
+type Config struct {
+	// Type is the type of the attestation service.
+	Type string `json:"type"`
+	// URL is the base URL of the attestation service. It must use the HTTPS scheme.
+	URL string `json:"url"`
+	// APIKey authenticates requests to Intel Trust Authority.
+	APIKey string `json:"apiKey,omitempty"`
+	// CertsURL is the URL of the key set of Intel Trust Authority. Defaults to DefaultTrustAuthorityCertsURL.
+	CertsURL string `json:"certsURL,omitempty"`
+	// Issuer is the expected issuer of Intel Trust Authority tokens. Defaults to "Intel Trust Authority".
+	Issuer string `json:"issuer,omitempty"`
+	// KeySet pins the JSON Web Key Set of the service. The keys are never fetched then.
+	KeySet []byte `json:"keySet,omitempty"`
+
+	// RootCAs are used to verify the TLS connections to the service. If nil, the system roots are used.
+	RootCAs *x509.CertPool `json:"-"`
+	// HTTPClient is used for all requests to the service. If set, RootCAs is ignored.
+	// Defaults to a client with a timeout of 30 seconds.
+	HTTPClient *http.Client `json:"-"`
+}
+
+// New creates a Provider from the config.
+func New(config Config) (Provider, error) {
+	uri, err := internal.ParseHTTPS(config.URL)
+	if err != nil {
+		return nil, err
+	}
+
+	client := config.HTTPClient
+	if client == nil {
+		transport := http.DefaultTransport.(*http.Transport).Clone()
+		transport.TLSClientConfig = &tls.Config{RootCAs: config.RootCAs}
+		client = &http.Client{Transport: transport, Timeout: defaultTimeout}
+	}
+	keySetConfig := internal.KeySetConfig{HTTPClient: client, KeySet: config.KeySet}
+
+	switch config.Type {
+	case TypeAzure, TypeSelfHosted:
+		return &azureProvider{
+			url:      config.URL,
+			client:   client,
+			verifier: internal.NewAzureVerifier(uri, keySetConfig),
+		}, nil
+	case TypeTrustAuthority:
+		certsURL := config.CertsURL
+		if certsURL == "" {
+			certsURL = DefaultTrustAuthorityCertsURL
+		}
+		if _, err := internal.ParseHTTPS(certsURL); err != nil {
+			return nil, fmt.Errorf("certs URL: %w", err)
+		}
+		issuer := config.Issuer
+		if issuer == "" {
+			issuer = internal.DefaultTrustAuthorityIssuer
+		}
+		return &trustAuthorityProvider{
+			url:      config.URL,
+			apiKey:   config.APIKey,
+			client:   client,
+			verifier: internal.NewTrustAuthorityVerifier(certsURL, issuer, keySetConfig),
+		}, nil
+	case "":
+		return nil, errors.New("missing provider type")
+	}
+	return nil, fmt.Errorf("unknown provider type: %q", config.Type)
+}
+
+// CreateToken creates a remote report over the SHA-256 hash of data and sends it to the attestation service.
+//
+// getRemoteReport is used to create the report. Usually, this is enclave.GetRemoteReport.
+func CreateToken(ctx context.Context, p Provider, getRemoteReport func([]byte) ([]byte, error), data []byte) (string, error) {
+	hash := sha256.Sum256(data)
+	report, err := getRemoteReport(hash[:])
+	if err != nil {
+		return "", err
+	}
+	return p.CreateToken(ctx, report, data)
+}
+
+type azureProvider struct {
+	url      string
+	client   *http.Client
+	verifier *internal.AzureVerifier
+}
+
+func (p *azureProvider) CreateToken(ctx context.Context, report, data []byte) (string, error) {
+	return internal.CreateAzureAttestationTokenContext(ctx, report, data, p.url, internal.AzureTokenOptions{HTTPClient: p.client})
+}
+
+func (p *azureProvider) VerifyToken(ctx context.Context, token string) (attestation.Report, error) {
+	report, err := p.verifier.VerifyContext(ctx, token)
+	if err != nil {
+		return attestation.Report{}, err
+	}
+	return attestation.Report(report.Report), nil
+}
+
+type trustAuthorityProvider struct {
+	url      string
+	apiKey   string
+	client   *http.Client
+	verifier *internal.TrustAuthorityVerifier
+}
+
+func (p *trustAuthorityProvider) CreateToken(ctx context.Context, report, data []byte) (string, error) {
+	return internal.CreateTrustAuthorityToken(ctx, report, data, p.url, p.apiKey, p.client)
+}
+
+func (p *trustAuthorityProvider) VerifyToken(ctx context.Context, token string) (attestation.Report, error) {
+	report, err := p.verifier.Verify(ctx, token)
+	if err != nil {
+		return attestation.Report{}, err
+	}
+	return attestation.Report(report), nil
+}
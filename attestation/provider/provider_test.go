@@ -0,0 +1,210 @@
+// Copyright (c) Edgeless Systems GmbH.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package provider
+
+import (
+	"bytes"
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+	"time"
+
+	"github.com/edgelesssys/ego/attestation/tcbstatus"
+	"github.com/go-jose/go-jose/v4"
+	"github.com/go-jose/go-jose/v4/jwt"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// getRemoteReport creates a fake report in the Open Enclave format whose quote is the report data.
+func getRemoteReport(reportData []byte) ([]byte, error) {
+	report := make([]byte, 16)
+	binary.LittleEndian.PutUint32(report, 1)
+	binary.LittleEndian.PutUint32(report[4:], 2)
+	binary.LittleEndian.PutUint64(report[8:], uint64(len(reportData)))
+	return append(report, reportData...), nil
+}
+
+func TestProvider(t *testing.T) {
+	testCases := map[string]struct {
+		providerType string
+	}{
+		"azure":           {providerType: TypeAzure},
+		"self-hosted":     {providerType: TypeSelfHosted},
+		"trust authority": {providerType: TypeTrustAuthority},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			assert := assert.New(t)
+			require := require.New(t)
+
+			service := newFakeService(t)
+			p, err := New(Config{
+				Type:       tc.providerType,
+				URL:        service.server.URL,
+				APIKey:     "key",
+				CertsURL:   service.server.URL + "/certs",
+				HTTPClient: service.server.Client(),
+			})
+			require.NoError(err)
+
+			token, err := CreateToken(context.Background(), p, getRemoteReport, []byte("data"))
+			require.NoError(err)
+			report, err := p.VerifyToken(context.Background(), token)
+			require.NoError(err)
+			assert.Equal([]byte("data"), report.Data)
+			assert.EqualValues(2, report.SecurityVersion)
+			if tc.providerType == TypeTrustAuthority {
+				assert.Equal(tcbstatus.OutOfDate, report.TCBStatus)
+				assert.Equal([]string{"INTEL-SA-00001"}, report.TCBAdvisories)
+			} else {
+				assert.Equal(tcbstatus.Unknown, report.TCBStatus)
+			}
+
+			// token of another service
+			otherService := newFakeService(t)
+			otherP, err := New(Config{Type: tc.providerType, URL: otherService.server.URL, APIKey: "key", CertsURL: otherService.server.URL + "/certs", HTTPClient: otherService.server.Client()})
+			require.NoError(err)
+			token, err = CreateToken(context.Background(), otherP, getRemoteReport, []byte("data"))
+			require.NoError(err)
+			_, err = p.VerifyToken(context.Background(), token)
+			assert.Error(err)
+		})
+	}
+}
+
+func TestNew(t *testing.T) {
+	testCases := map[string]struct {
+		config  Config
+		wantErr bool
+	}{
+		"azure":                   {config: Config{Type: TypeAzure, URL: "https://example.com"}},
+		"trust authority":         {config: Config{Type: TypeTrustAuthority, URL: "https://example.com"}},
+		"missing type":            {config: Config{URL: "https://example.com"}, wantErr: true},
+		"unknown type":            {config: Config{Type: "foo", URL: "https://example.com"}, wantErr: true},
+		"http":                    {config: Config{Type: TypeAzure, URL: "http://example.com"}, wantErr: true},
+		"http certs":              {config: Config{Type: TypeTrustAuthority, URL: "https://example.com", CertsURL: "http://example.com"}, wantErr: true},
+		"self-hosted without url": {config: Config{Type: TypeSelfHosted}, wantErr: true},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			_, err := New(tc.config)
+			if tc.wantErr {
+				assert.Error(t, err)
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
+
+type fakeService struct {
+	server *httptest.Server
+	key    *rsa.PrivateKey
+}
+
+// newFakeService creates a service that implements the token endpoints of both Azure Attestation and Intel Trust Authority.
+func newFakeService(t *testing.T) *fakeService {
+	require := require.New(t)
+
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(err)
+	template := &x509.Certificate{SerialNumber: &big.Int{}, NotAfter: time.Now().Add(time.Hour)}
+	cert, err := x509.CreateCertificate(rand.Reader, template, template, &key.PublicKey, key)
+	require.NoError(err)
+	keySet, err := json.Marshal(map[string]any{"keys": []any{map[string]any{"kty": "RSA", "kid": "aaa", "x5c": []string{base64.StdEncoding.EncodeToString(cert)}}}})
+	require.NoError(err)
+
+	s := &fakeService{key: key}
+	mux := http.NewServeMux()
+	mux.HandleFunc("/certs", func(w http.ResponseWriter, r *http.Request) {
+		_, _ = w.Write(keySet)
+	})
+	mux.HandleFunc("/attest/OpenEnclave", func(w http.ResponseWriter, r *http.Request) {
+		var req struct {
+			Report      string
+			RuntimeData struct{ Data string }
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		report, _ := base64.RawURLEncoding.DecodeString(req.Report)
+		data, _ := base64.RawURLEncoding.DecodeString(req.RuntimeData.Data)
+		if !s.checkReport(report[16:], data) {
+			http.Error(w, "invalid report", http.StatusBadRequest)
+			return
+		}
+		s.writeToken(w, s.server.URL, map[string]any{"x-ms-sgx-ehd": req.RuntimeData.Data, "x-ms-sgx-svn": 2})
+	})
+	mux.HandleFunc("/appraisal/v1/attest", func(w http.ResponseWriter, r *http.Request) {
+		if r.Header.Get("x-api-key") != "key" {
+			http.Error(w, "unauthorized", http.StatusUnauthorized)
+			return
+		}
+		var req struct {
+			Quote       string `json:"quote"`
+			RuntimeData string `json:"runtime_data"`
+		}
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		quote, _ := base64.StdEncoding.DecodeString(req.Quote)
+		data, _ := base64.StdEncoding.DecodeString(req.RuntimeData)
+		if !s.checkReport(quote, data) {
+			http.Error(w, "invalid quote", http.StatusBadRequest)
+			return
+		}
+		s.writeToken(w, "Intel Trust Authority", map[string]any{
+			"attester_type":         "SGX",
+			"attester_held_data":    req.RuntimeData,
+			"attester_tcb_status":   "OutOfDate",
+			"attester_advisory_ids": []string{"INTEL-SA-00001"},
+			"sgx_isvsvn":            2,
+		})
+	})
+	s.server = httptest.NewTLSServer(mux)
+	t.Cleanup(s.server.Close)
+	return s
+}
+
+func (s *fakeService) checkReport(quote, data []byte) bool {
+	hash := sha256.Sum256(data)
+	return bytes.Equal(quote, hash[:])
+}
+
+func (s *fakeService) writeToken(w http.ResponseWriter, issuer string, claims map[string]any) {
+	sig, err := jose.NewSigner(jose.SigningKey{Algorithm: jose.RS256, Key: s.key}, (&jose.SignerOptions{}).WithType("JWT").WithHeader("kid", "aaa"))
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	publicClaims := jwt.Claims{
+		Issuer:    issuer,
+		IssuedAt:  jwt.NewNumericDate(time.Now()),
+		NotBefore: jwt.NewNumericDate(time.Now()),
+		Expiry:    jwt.NewNumericDate(time.Now().Add(time.Hour)),
+	}
+	token, err := jwt.Signed(sig).Claims(publicClaims).Claims(claims).Serialize()
+	if err != nil {
+		http.Error(w, err.Error(), http.StatusInternalServerError)
+		return
+	}
+	_ = json.NewEncoder(w).Encode(map[string]string{"token": token})
+}
@@ -0,0 +1,102 @@
+// Copyright (c) Edgeless Systems GmbH.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package service
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"slices"
+
+	"github.com/edgelesssys/ego/attestation"
+	"github.com/edgelesssys/ego/attestation/tcbstatus"
+)
+
+// Policy is evaluated for each report before a token is issued.
+// A report must match at least one element of each non-empty list.
+// The policy must set UniqueIDs or SignerIDs unless AllowAnyEnclave is set.
+type Policy struct {
+	UniqueIDs          []string `json:"uniqueIDs,omitempty"`          // Accepted UniqueIDs (MRENCLAVE) as hex strings.
+	SignerIDs          []string `json:"signerIDs,omitempty"`          // Accepted SignerIDs (MRSIGNER) as hex strings.
+	ProductIDs         []uint16 `json:"productIDs,omitempty"`         // Accepted ProductIDs.
+	MinSecurityVersion uint     `json:"minSecurityVersion,omitempty"` // Minimum SecurityVersion.
+	AllowDebug         bool     `json:"allowDebug,omitempty"`         // Whether debug enclaves are accepted.
+	AllowAnyEnclave    bool     `json:"allowAnyEnclave,omitempty"`    // Whether enclaves are accepted if neither UniqueIDs nor SignerIDs are set.
+	// Accepted TCB statuses, e.g., "UpToDate" or "SWHardeningNeeded". If empty, only UpToDate is accepted.
+	AllowedTCBStatuses []tcbstatus.Status `json:"allowedTCBStatuses,omitempty"`
+}
+
+// Validate checks the policy for syntax errors and that it restricts the accepted enclaves.
+func (p Policy) Validate() error {
+	if !p.restrictsIdentity() && !p.AllowAnyEnclave {
+		return errNoIdentity
+	}
+	for _, id := range append(slices.Clone(p.UniqueIDs), p.SignerIDs...) {
+		if _, err := hex.DecodeString(id); err != nil {
+			return fmt.Errorf("invalid ID %q: %w", id, err)
+		}
+	}
+	for _, status := range p.AllowedTCBStatuses {
+		if status > tcbstatus.Unknown {
+			return fmt.Errorf("invalid TCB status: %v", status)
+		}
+	}
+	return nil
+}
+
+// Evaluate returns an error if the report doesn't satisfy the policy.
+func (p Policy) Evaluate(report attestation.Report) error {
+	if !p.restrictsIdentity() && !p.AllowAnyEnclave {
+		return errNoIdentity
+	}
+	if len(p.UniqueIDs) > 0 && !containsID(p.UniqueIDs, report.UniqueID) {
+		return fmt.Errorf("UniqueID %x isn't accepted", report.UniqueID)
+	}
+	if len(p.SignerIDs) > 0 && !containsID(p.SignerIDs, report.SignerID) {
+		return fmt.Errorf("SignerID %x isn't accepted", report.SignerID)
+	}
+	if len(p.ProductIDs) > 0 && !slices.Contains(p.ProductIDs, productID(report)) {
+		return fmt.Errorf("ProductID %v isn't accepted", productID(report))
+	}
+	if report.SecurityVersion < p.MinSecurityVersion {
+		return fmt.Errorf("SecurityVersion %v is lower than %v", report.SecurityVersion, p.MinSecurityVersion)
+	}
+	if report.Debug && !p.AllowDebug {
+		return errors.New("debug enclaves aren't accepted")
+	}
+
+	allowedStatuses := p.AllowedTCBStatuses
+	if len(allowedStatuses) == 0 {
+		allowedStatuses = []tcbstatus.Status{tcbstatus.UpToDate}
+	}
+	if !slices.Contains(allowedStatuses, report.TCBStatus) {
+		return fmt.Errorf("TCB status %v isn't accepted", report.TCBStatus)
+	}
+	return nil
+}
+
+var errNoIdentity = errors.New("uniqueIDs or signerIDs must be set unless allowAnyEnclave is set")
+
+func (p Policy) restrictsIdentity() bool {
+	return len(p.UniqueIDs) > 0 || len(p.SignerIDs) > 0
+}
+
+func containsID(ids []string, id []byte) bool {
+	return slices.ContainsFunc(ids, func(s string) bool {
+		want, err := hex.DecodeString(s)
+		return err == nil && bytes.Equal(want, id)
+	})
+}
+
+func productID(report attestation.Report) uint16 {
+	if len(report.ProductID) < 2 {
+		return 0
+	}
+	return binary.LittleEndian.Uint16(report.ProductID)
+}
@@ -0,0 +1,294 @@
+// Copyright (c) Edgeless Systems GmbH.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at https://mozilla.org/MPL/2.0/.
+
+/*
+Package service implements a self-hostable attestation service with the API of Microsoft Azure Attestation.
+
+The service accepts Open Enclave reports on /attest/OpenEnclave, verifies them, evaluates a Policy, and issues
+JSON Web Tokens with the same claim names as Microsoft Azure Attestation. It publishes its keys on /certs.
+Thus, tokens can be verified with attestation.VerifyAzureAttestationToken or attestation.NewAzureVerifier
+using the URL of the service.
+
+The service verifies reports with the function passed to New. This is usually eclient.VerifyRemoteReport,
+or the VerifyRemoteReport method of an eclient.Verifier to cache verification results:
+
+	svc, err := service.New(eclient.VerifyRemoteReport, service.Config{Issuer: "https://attestation.example.com"})
+	log.Fatal(http.ListenAndServeTLS(":8443", "cert.pem", "key.pem", svc))
+
+The ego attestation-service command runs the service.
+*/
+package service
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/base64"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"net/http"
+	"strings"
+	"time"
+
+	"github.com/edgelesssys/ego/attestation"
+	internal "github.com/edgelesssys/ego/internal/attestation"
+	"github.com/go-jose/go-jose/v4"
+	"github.com/go-jose/go-jose/v4/jwt"
+)
+
+const (
+	// AttestPath is the path of the attestation endpoint.
+	AttestPath = "/attest/OpenEnclave"
+	// CertsPath is the path of the key set.
+	CertsPath = "/certs"
+
+	defaultTokenValidity = 8 * time.Hour
+	maxRequestSize       = 1 << 20
+)
+
+// Config configures the service.
+type Config struct {
+	// Issuer is the URL under which the service is reachable. It's used as the tokens' issuer (iss).
+	Issuer string
+	// Policy is evaluated for each report.
+	Policy Policy
+	// SigningKey signs the tokens. If nil, a new key is generated.
+	SigningKey *rsa.PrivateKey
+	// TokenValidity is the duration for which issued tokens are valid. Defaults to 8 hours.
+	TokenValidity time.Duration
+}
+
+// Service is an attestation service. It implements http.Handler.
+type Service struct {
+	verifyRemoteReport func([]byte) (attestation.Report, error)
+	config             Config
+	policyHash         []byte
+	signer             jose.Signer
+	keySet             []byte
+	now                func() time.Time
+}
+
+// New creates a new attestation service.
+//
+// verifyRemoteReport is used to verify the reports. Usually, this is eclient.VerifyRemoteReport.
+func New(verifyRemoteReport func([]byte) (attestation.Report, error), config Config) (*Service, error) {
+	if _, err := internal.ParseHTTPS(config.Issuer); err != nil {
+		return nil, fmt.Errorf("issuer: %w", err)
+	}
+	if err := config.Policy.Validate(); err != nil {
+		return nil, fmt.Errorf("policy: %w", err)
+	}
+	if config.TokenValidity <= 0 {
+		config.TokenValidity = defaultTokenValidity
+	}
+	if config.SigningKey == nil {
+		key, err := rsa.GenerateKey(rand.Reader, 2048)
+		if err != nil {
+			return nil, err
+		}
+		config.SigningKey = key
+	}
+
+	// The key set contains a self-signed certificate of the signing key.
+	template := &x509.Certificate{
+		SerialNumber: &big.Int{},
+		Subject:      pkix.Name{CommonName: config.Issuer},
+		NotBefore:    time.Now().Add(-time.Hour),
+		NotAfter:     time.Now().AddDate(10, 0, 0),
+	}
+	cert, err := x509.CreateCertificate(rand.Reader, template, template, &config.SigningKey.PublicKey, config.SigningKey)
+	if err != nil {
+		return nil, err
+	}
+	certHash := sha256.Sum256(cert)
+	keyID := base64.RawURLEncoding.EncodeToString(certHash[:])
+	keySet, err := json.Marshal(jsonWebKeySet{Keys: []jsonWebKey{{Kty: "RSA", Kid: keyID, X5c: []string{base64.StdEncoding.EncodeToString(cert)}}}})
+	if err != nil {
+		return nil, err
+	}
+
+	signer, err := jose.NewSigner(
+		jose.SigningKey{Algorithm: jose.RS256, Key: config.SigningKey},
+		(&jose.SignerOptions{}).WithType("JWT").WithHeader("kid", keyID),
+	)
+	if err != nil {
+		return nil, err
+	}
+
+	policy, err := json.Marshal(config.Policy)
+	if err != nil {
+		return nil, err
+	}
+	policyHash := sha256.Sum256(policy)
+
+	return &Service{
+		verifyRemoteReport: verifyRemoteReport,
+		config:             config,
+		policyHash:         policyHash[:],
+		signer:             signer,
+		keySet:             keySet,
+		now:                time.Now,
+	}, nil
+}
+
+// ServeHTTP implements http.Handler.
+func (s *Service) ServeHTTP(w http.ResponseWriter, r *http.Request) {
+	switch r.URL.Path {
+	case AttestPath:
+		if r.Method != http.MethodPost {
+			writeError(w, http.StatusMethodNotAllowed, "MethodNotAllowed", "method not allowed")
+			return
+		}
+		s.attest(w, r)
+	case CertsPath:
+		if r.Method != http.MethodGet {
+			writeError(w, http.StatusMethodNotAllowed, "MethodNotAllowed", "method not allowed")
+			return
+		}
+		w.Header().Set("Content-Type", "application/json")
+		_, _ = w.Write(s.keySet)
+	default:
+		writeError(w, http.StatusNotFound, "NotFound", "not found")
+	}
+}
+
+func (s *Service) attest(w http.ResponseWriter, r *http.Request) {
+	var req attestRequest
+	if err := json.NewDecoder(io.LimitReader(r.Body, maxRequestSize)).Decode(&req); err != nil {
+		writeError(w, http.StatusBadRequest, "InvalidParameter", "decoding request: "+err.Error())
+		return
+	}
+	token, err := s.createToken(req)
+	if err != nil {
+		writeError(w, http.StatusBadRequest, "InvalidParameter", err.Error())
+		return
+	}
+	w.Header().Set("Content-Type", "application/json")
+	_ = json.NewEncoder(w).Encode(attestResponse{Token: token})
+}
+
+// createToken verifies the request and issues a token.
+func (s *Service) createToken(req attestRequest) (string, error) {
+	reportBytes, err := decodeBase64URL(req.Report)
+	if err != nil {
+		return "", fmt.Errorf("decoding report: %w", err)
+	}
+	runtimeData, err := decodeBase64URL(req.RuntimeData.Data)
+	if err != nil {
+		return "", fmt.Errorf("decoding runtime data: %w", err)
+	}
+
+	report, err := s.verifyRemoteReport(reportBytes)
+	if err != nil && !errors.Is(err, attestation.ErrTCBLevelInvalid) {
+		return "", fmt.Errorf("verifying report: %w", err)
+	}
+
+	// The report must be bound to the runtime data.
+	hash := sha256.Sum256(runtimeData)
+	if len(report.Data) < len(hash) || string(report.Data[:len(hash)]) != string(hash[:]) {
+		return "", errors.New("report data doesn't match the hash of the runtime data")
+	}
+
+	if err := s.config.Policy.Evaluate(report); err != nil {
+		return "", fmt.Errorf("policy evaluation failed: %w", err)
+	}
+
+	claims := map[string]any{
+		"x-ms-ver":               "1.0",
+		"x-ms-attestation-type":  "sgx",
+		"x-ms-policy-hash":       base64.RawURLEncoding.EncodeToString(s.policyHash),
+		"x-ms-sgx-ehd":           base64.RawURLEncoding.EncodeToString(runtimeData),
+		"x-ms-sgx-svn":           report.SecurityVersion,
+		"x-ms-sgx-is-debuggable": report.Debug,
+		"x-ms-sgx-mrenclave":     hex.EncodeToString(report.UniqueID),
+		"x-ms-sgx-mrsigner":      hex.EncodeToString(report.SignerID),
+		"x-ms-sgx-product-id":    productID(report),
+		"x-ms-sgx-tcbstatus":     report.TCBStatus.String(),
+	}
+	if strings.EqualFold(req.RuntimeData.DataType, "JSON") {
+		var runtime any
+		if err := json.Unmarshal(runtimeData, &runtime); err != nil {
+			return "", fmt.Errorf("decoding JSON runtime data: %w", err)
+		}
+		claims["x-ms-runtime"] = runtime
+	}
+	if req.InitTimeData != nil && strings.EqualFold(req.InitTimeData.DataType, "JSON") {
+		initTimeData, err := decodeBase64URL(req.InitTimeData.Data)
+		if err != nil {
+			return "", fmt.Errorf("decoding init-time data: %w", err)
+		}
+		var initTime any
+		if err := json.Unmarshal(initTimeData, &initTime); err != nil {
+			return "", fmt.Errorf("decoding JSON init-time data: %w", err)
+		}
+		claims["x-ms-inittime"] = initTime
+	}
+
+	jti := make([]byte, 32)
+	if _, err := rand.Read(jti); err != nil {
+		return "", err
+	}
+	now := s.now()
+	publicClaims := jwt.Claims{
+		Issuer:    s.config.Issuer,
+		IssuedAt:  jwt.NewNumericDate(now),
+		NotBefore: jwt.NewNumericDate(now),
+		Expiry:    jwt.NewNumericDate(now.Add(s.config.TokenValidity)),
+		ID:        hex.EncodeToString(jti),
+	}
+	return jwt.Signed(s.signer).Claims(publicClaims).Claims(claims).Serialize()
+}
+
+// attestRequest is an AttestOpenEnclaveRequest of the Microsoft Azure Attestation API.
+type attestRequest struct {
+	Report       string    `json:"report"`
+	RuntimeData  dataType  `json:"runtimeData"`
+	InitTimeData *dataType `json:"initTimeData,omitempty"`
+}
+
+type dataType struct {
+	Data     string `json:"data"`
+	DataType string `json:"dataType"`
+}
+
+type attestResponse struct {
+	Token string `json:"token"`
+}
+
+type jsonWebKeySet struct {
+	Keys []jsonWebKey `json:"keys"`
+}
+
+type jsonWebKey struct {
+	Kty string   `json:"kty"`
+	Kid string   `json:"kid"`
+	X5c []string `json:"x5c"`
+}
+
+func writeError(w http.ResponseWriter, status int, code, message string) {
+	w.Header().Set("Content-Type", "application/json")
+	w.WriteHeader(status)
+	var body struct {
+		Error struct {
+			Code    string `json:"code"`
+			Message string `json:"message"`
+		} `json:"error"`
+	}
+	body.Error.Code = code
+	body.Error.Message = message
+	_ = json.NewEncoder(w).Encode(body)
+}
+
+// decodeBase64URL decodes base64url with or without padding.
+func decodeBase64URL(s string) ([]byte, error) {
+	return base64.RawURLEncoding.DecodeString(strings.TrimRight(s, "="))
+}
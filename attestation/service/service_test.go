@@ -0,0 +1,238 @@
+// Copyright (c) Edgeless Systems GmbH.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package service
+
+import (
+	"bytes"
+	"context"
+	"crypto/sha256"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"net/http"
+	"net/http/httptest"
+	"testing"
+
+	"github.com/edgelesssys/ego/attestation"
+	"github.com/edgelesssys/ego/attestation/provider"
+	"github.com/edgelesssys/ego/attestation/tcbstatus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// getRemoteReport creates a fake report. The first byte is the security version.
+func getRemoteReport(securityVersion byte) func([]byte) ([]byte, error) {
+	return func(reportData []byte) ([]byte, error) {
+		return append([]byte{securityVersion}, reportData...), nil
+	}
+}
+
+// verifyRemoteReport is a fake verifier for reports created by getRemoteReport.
+func verifyRemoteReport(reportBytes []byte) (attestation.Report, error) {
+	if len(reportBytes) != 33 {
+		return attestation.Report{}, errors.New("invalid remote report")
+	}
+	report := attestation.Report{
+		Data:            reportBytes[1:],
+		SecurityVersion: uint(reportBytes[0]),
+		UniqueID:        []byte{1, 2},
+		SignerID:        []byte{3, 4},
+		ProductID:       []byte{5, 0},
+	}
+	if report.SecurityVersion == 1 {
+		report.TCBStatus = tcbstatus.SWHardeningNeeded
+		return report, attestation.ErrTCBLevelInvalid
+	}
+	return report, nil
+}
+
+func TestService(t *testing.T) {
+	testCases := map[string]struct {
+		securityVersion byte
+		policy          Policy
+		wantErr         bool
+	}{
+		"any enclave": {
+			securityVersion: 2,
+			policy:          Policy{AllowAnyEnclave: true},
+		},
+		"matching policy": {
+			securityVersion: 2,
+			policy:          Policy{UniqueIDs: []string{"0102"}, SignerIDs: []string{"ffff", "0304"}, ProductIDs: []uint16{5}, MinSecurityVersion: 2},
+		},
+		"other unique id": {
+			securityVersion: 2,
+			policy:          Policy{UniqueIDs: []string{"0103"}},
+			wantErr:         true,
+		},
+		"other product id": {
+			securityVersion: 2,
+			policy:          Policy{SignerIDs: []string{"0304"}, ProductIDs: []uint16{6}},
+			wantErr:         true,
+		},
+		"security version too low": {
+			securityVersion: 2,
+			policy:          Policy{SignerIDs: []string{"0304"}, MinSecurityVersion: 3},
+			wantErr:         true,
+		},
+		"tcb status not accepted": {
+			securityVersion: 1,
+			policy:          Policy{UniqueIDs: []string{"0102"}},
+			wantErr:         true,
+		},
+		"tcb status accepted": {
+			securityVersion: 1,
+			policy:          Policy{UniqueIDs: []string{"0102"}, AllowedTCBStatuses: []tcbstatus.Status{tcbstatus.UpToDate, tcbstatus.SWHardeningNeeded}},
+		},
+		"invalid report": {
+			securityVersion: 2,
+			policy:          Policy{AllowAnyEnclave: true},
+			wantErr:         true,
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			assert := assert.New(t)
+			require := require.New(t)
+
+			server := startService(t, tc.policy)
+			p, err := provider.New(provider.Config{Type: provider.TypeSelfHosted, URL: server.URL, HTTPClient: server.Client()})
+			require.NoError(err)
+
+			getReport := getRemoteReport(tc.securityVersion)
+			if name == "invalid report" {
+				getReport = func(reportData []byte) ([]byte, error) { return reportData, nil }
+			}
+
+			token, err := provider.CreateToken(context.Background(), p, getReport, []byte("data"))
+			if tc.wantErr {
+				assert.Error(err)
+				return
+			}
+			require.NoError(err)
+
+			// verify with the Azure verifier
+			verifier, err := attestation.NewAzureVerifier(server.URL, attestation.WithHTTPClient(server.Client()))
+			require.NoError(err)
+			report, err := verifier.Verify(token)
+			require.NoError(err)
+			assert.Equal([]byte("data"), report.Data)
+			assert.EqualValues(tc.securityVersion, report.SecurityVersion)
+			assert.Equal([]byte{1, 2}, report.UniqueID)
+			assert.Equal([]byte{3, 4}, report.SignerID)
+			assert.Equal([]byte{5, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0, 0}, report.ProductID)
+			assert.Equal("sgx", report.AttestationType)
+			assert.Equal("1.0", report.Version)
+			assert.Len(report.PolicyHash, sha256.Size)
+			if tc.securityVersion == 1 {
+				assert.Equal(tcbstatus.SWHardeningNeeded, report.TCBStatus)
+			} else {
+				assert.Equal(tcbstatus.UpToDate, report.TCBStatus)
+			}
+		})
+	}
+}
+
+func TestServiceRequests(t *testing.T) {
+	server := startService(t, Policy{AllowAnyEnclave: true})
+	reportData := sha256.Sum256([]byte(`{"a":1}`))
+	report, err := getRemoteReport(2)(reportData[:])
+	require.NoError(t, err)
+
+	testCases := map[string]struct {
+		method   string
+		path     string
+		body     string
+		wantCode int
+	}{
+		"json runtime data": {
+			method:   http.MethodPost,
+			path:     AttestPath + "?api-version=2020-10-01",
+			body:     `{"report":"` + base64URL(report) + `","runtimeData":{"data":"eyJhIjoxfQ","dataType":"JSON"}}`,
+			wantCode: http.StatusOK,
+		},
+		"padded base64": {
+			method:   http.MethodPost,
+			path:     AttestPath,
+			body:     `{"report":"` + base64URL(report) + `","runtimeData":{"data":"eyJhIjoxfQ==","dataType":"Binary"}}`,
+			wantCode: http.StatusOK,
+		},
+		"other runtime data": {
+			method:   http.MethodPost,
+			path:     AttestPath,
+			body:     `{"report":"` + base64URL(report) + `","runtimeData":{"data":"eyJhIjoyfQ","dataType":"Binary"}}`,
+			wantCode: http.StatusBadRequest,
+		},
+		"invalid json": {
+			method:   http.MethodPost,
+			path:     AttestPath,
+			body:     "foo",
+			wantCode: http.StatusBadRequest,
+		},
+		"attest with get": {
+			method:   http.MethodGet,
+			path:     AttestPath,
+			wantCode: http.StatusMethodNotAllowed,
+		},
+		"certs": {
+			method:   http.MethodGet,
+			path:     CertsPath,
+			wantCode: http.StatusOK,
+		},
+		"unknown path": {
+			method:   http.MethodGet,
+			path:     "/foo",
+			wantCode: http.StatusNotFound,
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			req, err := http.NewRequest(tc.method, server.URL+tc.path, bytes.NewBufferString(tc.body))
+			require.NoError(t, err)
+			resp, err := server.Client().Do(req)
+			require.NoError(t, err)
+			defer resp.Body.Close()
+			assert.Equal(t, tc.wantCode, resp.StatusCode)
+			if tc.wantCode != http.StatusOK {
+				var body struct {
+					Error struct{ Code, Message string }
+				}
+				require.NoError(t, json.NewDecoder(resp.Body).Decode(&body))
+				assert.NotEmpty(t, body.Error.Message)
+			}
+		})
+	}
+}
+
+func TestNew(t *testing.T) {
+	_, err := New(verifyRemoteReport, Config{Issuer: "https://example.com", Policy: Policy{AllowAnyEnclave: true}})
+	assert.NoError(t, err)
+	_, err = New(verifyRemoteReport, Config{Issuer: "http://example.com", Policy: Policy{AllowAnyEnclave: true}})
+	assert.Error(t, err)
+	_, err = New(verifyRemoteReport, Config{Issuer: "https://example.com"})
+	assert.Error(t, err)
+	_, err = New(verifyRemoteReport, Config{Issuer: "https://example.com", Policy: Policy{UniqueIDs: []string{"foo"}}})
+	assert.Error(t, err)
+	_, err = New(verifyRemoteReport, Config{Issuer: "https://example.com", Policy: Policy{AllowAnyEnclave: true, AllowedTCBStatuses: []tcbstatus.Status{tcbstatus.Unknown + 1}}})
+	assert.Error(t, err)
+}
+
+func startService(t *testing.T, policy Policy) *httptest.Server {
+	server := httptest.NewUnstartedServer(nil)
+	svc, err := New(verifyRemoteReport, Config{Issuer: "https://" + server.Listener.Addr().String(), Policy: policy})
+	require.NoError(t, err)
+	server.Config.Handler = svc
+	server.StartTLS()
+	t.Cleanup(server.Close)
+	return server
+}
+
+func base64URL(data []byte) string {
+	return base64.RawURLEncoding.EncodeToString(data)
+}
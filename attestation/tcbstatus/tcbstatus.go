@@ -6,6 +6,12 @@
 
 package tcbstatus
 
+import (
+	"cmp"
+	"encoding/json"
+	"fmt"
+)
+
 // Status is the status of the enclave's TCB level.
 type Status uint
 
@@ -44,3 +50,79 @@ func Explain(status Status) string {
 	}
 	return "unknown status"
 }
+
+// Parse parses the name of a TCB status as returned by String, e.g., "SWHardeningNeeded".
+// The names equal the tcbStatus values of Intel's TCB info.
+func Parse(s string) (Status, error) {
+	for status := UpToDate; status <= Unknown; status++ {
+		if status.String() == s {
+			return status, nil
+		}
+	}
+	return Unknown, fmt.Errorf("invalid TCB status: %q", s)
+}
+
+// MarshalText implements encoding.TextMarshaler. Thus, a Status is marshaled to JSON as string.
+func (s Status) MarshalText() ([]byte, error) {
+	if s > Unknown {
+		return nil, fmt.Errorf("invalid TCB status: %d", uint(s))
+	}
+	return []byte(s.String()), nil
+}
+
+// UnmarshalText implements encoding.TextUnmarshaler.
+func (s *Status) UnmarshalText(text []byte) error {
+	status, err := Parse(string(text))
+	if err != nil {
+		return err
+	}
+	*s = status
+	return nil
+}
+
+// UnmarshalJSON implements json.Unmarshaler. It accepts the name of the status and,
+// for compatibility with older encodings, the numeric value.
+func (s *Status) UnmarshalJSON(data []byte) error {
+	var value uint
+	if err := json.Unmarshal(data, &value); err == nil {
+		if Status(value) > Unknown {
+			return fmt.Errorf("invalid TCB status: %d", value)
+		}
+		*s = Status(value)
+		return nil
+	}
+	var text string
+	if err := json.Unmarshal(data, &text); err != nil {
+		return err
+	}
+	return s.UnmarshalText([]byte(text))
+}
+
+// Severity returns the rank of the status from 0 (UpToDate) to 7 (Unknown). A status with a higher
+// severity requires more actions before the platform can be trusted. Unknown is ranked highest, because
+// nothing is known about the platform.
+func (s Status) Severity() int {
+	switch s {
+	case UpToDate:
+		return 0
+	case SWHardeningNeeded:
+		return 1
+	case ConfigurationNeeded:
+		return 2
+	case ConfigurationAndSWHardeningNeeded:
+		return 3
+	case OutOfDate:
+		return 4
+	case OutOfDateConfigurationNeeded:
+		return 5
+	case Revoked:
+		return 6
+	}
+	return 7
+}
+
+// Compare compares the severity of a and b. It returns -1 if a is less severe than b, 0 if both are
+// equally severe, and +1 if a is more severe than b.
+func Compare(a, b Status) int {
+	return cmp.Compare(a.Severity(), b.Severity())
+}
@@ -0,0 +1,57 @@
+// Copyright (c) Edgeless Systems GmbH.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package tcbstatus
+
+import (
+	"encoding/json"
+	"slices"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParse(t *testing.T) {
+	for status := UpToDate; status <= Unknown; status++ {
+		parsed, err := Parse(status.String())
+		assert.NoError(t, err)
+		assert.Equal(t, status, parsed)
+	}
+
+	_, err := Parse("uptodate")
+	assert.Error(t, err)
+	_, err = Parse("Status(8)")
+	assert.Error(t, err)
+}
+
+func TestJSON(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	data, err := json.Marshal(map[string]Status{"status": SWHardeningNeeded})
+	require.NoError(err)
+	assert.JSONEq(`{"status":"SWHardeningNeeded"}`, string(data))
+
+	var statuses []Status
+	require.NoError(json.Unmarshal([]byte(`["OutOfDate", 2]`), &statuses))
+	assert.Equal([]Status{OutOfDate, Revoked}, statuses)
+
+	assert.Error(json.Unmarshal([]byte(`["foo"]`), &statuses))
+	assert.Error(json.Unmarshal([]byte(`[8]`), &statuses))
+	_, err = json.Marshal(Unknown + 1)
+	assert.Error(err)
+}
+
+func TestSeverity(t *testing.T) {
+	statuses := []Status{Unknown, Revoked, UpToDate, OutOfDate, ConfigurationNeeded, SWHardeningNeeded, OutOfDateConfigurationNeeded, ConfigurationAndSWHardeningNeeded}
+	slices.SortFunc(statuses, Compare)
+	assert.Equal(t, []Status{
+		UpToDate, SWHardeningNeeded, ConfigurationNeeded, ConfigurationAndSWHardeningNeeded,
+		OutOfDate, OutOfDateConfigurationNeeded, Revoked, Unknown,
+	}, statuses)
+	assert.Equal(t, 0, Compare(OutOfDate, OutOfDate))
+}
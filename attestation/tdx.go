@@ -0,0 +1,78 @@
+// Copyright (c) Edgeless Systems GmbH.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package attestation
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"slices"
+
+	"github.com/edgelesssys/ego/attestation/tcbstatus"
+)
+
+// TDXReport is a parsed Intel TDX quote.
+type TDXReport struct {
+	Data          []byte           // The report data that has been included in the quote.
+	MRTD          []byte           // The measurement of the initial contents of the TD.
+	RTMRs         [4][]byte        // The runtime extendable measurement registers.
+	MRConfigID    []byte           // The software-defined ID for non-owner-defined configuration of the TD.
+	MROwner       []byte           // The software-defined ID for the TD's owner.
+	MROwnerConfig []byte           // The software-defined ID for owner-defined configuration of the TD.
+	MRSeam        []byte           // The measurement of the TDX module.
+	MRSeamSigner  []byte           // The measurement of the TDX module's signer.
+	TDAttributes  []byte           // The attributes of the TD.
+	XFAM          []byte           // The extended features available mask of the TD.
+	TEETCBSVN     []byte           // The security version numbers of the TDX module and its components.
+	TCBStatus     tcbstatus.Status // The status of the platform's TCB level.
+	// IDs of Intel security advisories that provide insight into the reasons when the TCB status is not UpToDate.
+	// Currently always empty because Open Enclave's TDX verifier doesn't provide the TDX TCB info.
+	TCBAdvisories    []string
+	TCBAdvisoriesErr error // Error that occurred while getting the advisory array (if any).
+}
+
+// Debug reports whether the TD is debuggable. The host can read and modify the memory of a debuggable TD.
+func (r TDXReport) Debug() bool {
+	return len(r.TDAttributes) > 0 && r.TDAttributes[0]&1 != 0
+}
+
+// TDXPolicy describes the TDs that are accepted. Use it to verify the content of a TDXReport.
+type TDXPolicy struct {
+	MRTD  []byte    // The expected MRTD. It's required.
+	RTMRs [4][]byte // The expected RTMRs. An RTMR is only checked if its element isn't nil.
+	// AllowDebug accepts debuggable TDs. The host can read and modify the memory of a debuggable TD.
+	AllowDebug bool
+	// Accepted TCB statuses, e.g., tcbstatus.SWHardeningNeeded. If empty, only tcbstatus.UpToDate is accepted.
+	AllowedTCBStatuses []tcbstatus.Status
+}
+
+// Evaluate returns an error if the report doesn't satisfy the policy.
+func (p TDXPolicy) Evaluate(report TDXReport) error {
+	if len(p.MRTD) == 0 {
+		return errors.New("policy doesn't specify an MRTD")
+	}
+	if !bytes.Equal(report.MRTD, p.MRTD) {
+		return fmt.Errorf("MRTD %x isn't accepted", report.MRTD)
+	}
+	for i, rtmr := range p.RTMRs {
+		if rtmr != nil && !bytes.Equal(report.RTMRs[i], rtmr) {
+			return fmt.Errorf("RTMR%v %x isn't accepted", i, report.RTMRs[i])
+		}
+	}
+	if report.Debug() && !p.AllowDebug {
+		return errors.New("debuggable TDs aren't accepted")
+	}
+
+	allowedStatuses := p.AllowedTCBStatuses
+	if len(allowedStatuses) == 0 {
+		allowedStatuses = []tcbstatus.Status{tcbstatus.UpToDate}
+	}
+	if !slices.Contains(allowedStatuses, report.TCBStatus) {
+		return fmt.Errorf("TCB status %v isn't accepted", report.TCBStatus)
+	}
+	return nil
+}
@@ -0,0 +1,103 @@
+// Copyright (c) Edgeless Systems GmbH.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package attestation
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/edgelesssys/ego/attestation/tcbstatus"
+	"github.com/stretchr/testify/assert"
+)
+
+func TestTDXPolicy(t *testing.T) {
+	mrtd := bytes.Repeat([]byte{1}, 48)
+	rtmr := bytes.Repeat([]byte{2}, 48)
+	report := func() TDXReport {
+		return TDXReport{
+			MRTD:         mrtd,
+			RTMRs:        [4][]byte{rtmr, rtmr, rtmr, rtmr},
+			TDAttributes: make([]byte, 8),
+			TCBStatus:    tcbstatus.UpToDate,
+		}
+	}
+
+	testCases := map[string]struct {
+		policy  TDXPolicy
+		report  func() TDXReport
+		wantErr bool
+	}{
+		"mrtd": {
+			policy: TDXPolicy{MRTD: mrtd},
+			report: report,
+		},
+		"mrtd and rtmrs": {
+			policy: TDXPolicy{MRTD: mrtd, RTMRs: [4][]byte{rtmr, nil, rtmr}},
+			report: report,
+		},
+		"no mrtd": {
+			policy:  TDXPolicy{},
+			report:  report,
+			wantErr: true,
+		},
+		"other mrtd": {
+			policy:  TDXPolicy{MRTD: rtmr},
+			report:  report,
+			wantErr: true,
+		},
+		"other rtmr": {
+			policy:  TDXPolicy{MRTD: mrtd, RTMRs: [4][]byte{3: mrtd}},
+			report:  report,
+			wantErr: true,
+		},
+		"debug": {
+			policy: TDXPolicy{MRTD: mrtd},
+			report: func() TDXReport {
+				r := report()
+				r.TDAttributes = []byte{1, 0, 0, 0, 0, 0, 0, 0}
+				return r
+			},
+			wantErr: true,
+		},
+		"debug allowed": {
+			policy: TDXPolicy{MRTD: mrtd, AllowDebug: true},
+			report: func() TDXReport {
+				r := report()
+				r.TDAttributes = []byte{1, 0, 0, 0, 0, 0, 0, 0}
+				return r
+			},
+		},
+		"out of date": {
+			policy: TDXPolicy{MRTD: mrtd},
+			report: func() TDXReport {
+				r := report()
+				r.TCBStatus = tcbstatus.OutOfDate
+				return r
+			},
+			wantErr: true,
+		},
+		"out of date allowed": {
+			policy: TDXPolicy{MRTD: mrtd, AllowedTCBStatuses: []tcbstatus.Status{tcbstatus.UpToDate, tcbstatus.OutOfDate}},
+			report: func() TDXReport {
+				r := report()
+				r.TCBStatus = tcbstatus.OutOfDate
+				return r
+			},
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			err := tc.policy.Evaluate(tc.report())
+			if tc.wantErr {
+				assert.Error(t, err)
+			} else {
+				assert.NoError(t, err)
+			}
+		})
+	}
+}
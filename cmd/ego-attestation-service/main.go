@@ -0,0 +1,90 @@
+// Copyright (c) Edgeless Systems GmbH.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at https://mozilla.org/MPL/2.0/.
+
+// ego-attestation-service runs a self-hosted attestation service with the API of Microsoft Azure Attestation.
+// It's usually invoked via ego attestation-service.
+package main
+
+import (
+	"crypto/rsa"
+	"crypto/x509"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"flag"
+	"fmt"
+	"log"
+	"net/http"
+	"os"
+	"time"
+
+	"github.com/edgelesssys/ego/attestation/service"
+	"github.com/edgelesssys/ego/eclient"
+)
+
+func main() {
+	addr := flag.String("addr", ":8443", "listen address")
+	issuer := flag.String("issuer", "", "HTTPS URL under which the service is reachable (required)")
+	policyFile := flag.String("policy", "", "JSON file with the policy (required)")
+	tlsCert := flag.String("tls-cert", "", "PEM file with the TLS certificate (required)")
+	tlsKey := flag.String("tls-key", "", "PEM file with the TLS private key (required)")
+	signingKeyFile := flag.String("signing-key", "", "PEM file with the RSA key that signs the tokens (default: generate a new key)")
+	tokenValidity := flag.Duration("token-validity", 8*time.Hour, "validity of issued tokens")
+	flag.Parse()
+
+	if *issuer == "" || *policyFile == "" || *tlsCert == "" || *tlsKey == "" {
+		flag.Usage()
+		os.Exit(2)
+	}
+
+	config := service.Config{Issuer: *issuer, TokenValidity: *tokenValidity}
+	policy, err := os.ReadFile(*policyFile)
+	if err != nil {
+		log.Fatal(err)
+	}
+	if err := json.Unmarshal(policy, &config.Policy); err != nil {
+		log.Fatalf("parsing policy: %v", err)
+	}
+	if *signingKeyFile != "" {
+		key, err := readRSAKey(*signingKeyFile)
+		if err != nil {
+			log.Fatalf("reading signing key: %v", err)
+		}
+		config.SigningKey = key
+	}
+
+	svc, err := service.New(eclient.VerifyRemoteReport, config)
+	if err != nil {
+		log.Fatal(err)
+	}
+
+	log.Printf("serving attestation service on %v", *addr)
+	server := &http.Server{Addr: *addr, Handler: svc, ReadHeaderTimeout: 10 * time.Second}
+	log.Fatal(server.ListenAndServeTLS(*tlsCert, *tlsKey))
+}
+
+func readRSAKey(filename string) (*rsa.PrivateKey, error) {
+	data, err := os.ReadFile(filename)
+	if err != nil {
+		return nil, err
+	}
+	block, _ := pem.Decode(data)
+	if block == nil {
+		return nil, errors.New("no PEM data found")
+	}
+	if key, err := x509.ParsePKCS1PrivateKey(block.Bytes); err == nil {
+		return key, nil
+	}
+	key, err := x509.ParsePKCS8PrivateKey(block.Bytes)
+	if err != nil {
+		return nil, err
+	}
+	rsaKey, ok := key.(*rsa.PrivateKey)
+	if !ok {
+		return nil, fmt.Errorf("unsupported key type %T", key)
+	}
+	return rsaKey, nil
+}
@@ -0,0 +1,38 @@
+// Copyright (c) Edgeless Systems GmbH.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package eclient
+
+import (
+	"crypto"
+	"errors"
+
+	"github.com/edgelesssys/ego/attestation"
+	internal "github.com/edgelesssys/ego/internal/attestation"
+)
+
+// CreateAttestationResult verifies a remote report and returns an EAT Attestation Result (EAR) as JSON Web Token
+// signed with key. Relying parties can verify the token with attestation.VerifyAttestationResult or any EAR
+// implementation instead of verifying the report themselves.
+//
+// verifyReport appraises the report's content. If it returns an error, the result contraindicates the enclave's
+// executables. An out-of-date TCB doesn't fail the verification, but is reflected in the result's hardware claim.
+//
+// key must be an *ecdsa.PrivateKey, *rsa.PrivateKey, or ed25519.PrivateKey.
+func CreateAttestationResult(reportBytes []byte, verifyReport func(attestation.Report) error, key crypto.Signer, opts attestation.AttestationResultOptions) (string, error) {
+	return createAttestationResult(reportBytes, verifyRemoteReport, verifyReport, key, opts)
+}
+
+func createAttestationResult(reportBytes []byte, verifyRemoteReport func([]byte) (internal.Report, error), verifyReport func(attestation.Report) error,
+	key crypto.Signer, opts attestation.AttestationResultOptions,
+) (string, error) {
+	internalReport, err := verifyRemoteReport(reportBytes)
+	if err != nil && !errors.Is(err, attestation.ErrTCBLevelInvalid) {
+		return "", err
+	}
+	report := attestation.Report(internalReport)
+	return attestation.NewAttestationResult(report, verifyReport(report), opts).Sign(key)
+}
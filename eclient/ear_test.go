@@ -0,0 +1,92 @@
+// Copyright (c) Edgeless Systems GmbH.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package eclient
+
+import (
+	"crypto/ecdsa"
+	"crypto/elliptic"
+	"crypto/rand"
+	"errors"
+	"testing"
+
+	"github.com/edgelesssys/ego/attestation"
+	"github.com/edgelesssys/ego/attestation/tcbstatus"
+	internal "github.com/edgelesssys/ego/internal/attestation"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCreateAttestationResult(t *testing.T) {
+	key, err := ecdsa.GenerateKey(elliptic.P256(), rand.Reader)
+	require.NoError(t, err)
+
+	testCases := map[string]struct {
+		verifyRemoteReport func([]byte) (internal.Report, error)
+		verifyReport       func(attestation.Report) error
+		wantErr            bool
+		wantStatus         attestation.TrustTier
+		wantHardware       int8
+		wantExecutables    int8
+	}{
+		"up to date": {
+			verifyRemoteReport: func([]byte) (internal.Report, error) {
+				return internal.Report{UniqueID: []byte{1}}, nil
+			},
+			verifyReport:    func(attestation.Report) error { return nil },
+			wantStatus:      attestation.TrustTierAffirming,
+			wantHardware:    attestation.TrustClaimAffirming,
+			wantExecutables: attestation.TrustClaimAffirming,
+		},
+		"out of date": {
+			verifyRemoteReport: func([]byte) (internal.Report, error) {
+				return internal.Report{UniqueID: []byte{1}, TCBStatus: tcbstatus.OutOfDate}, attestation.ErrTCBLevelInvalid
+			},
+			verifyReport:    func(attestation.Report) error { return nil },
+			wantStatus:      attestation.TrustTierWarning,
+			wantHardware:    attestation.TrustClaimWarning,
+			wantExecutables: attestation.TrustClaimAffirming,
+		},
+		"policy failed": {
+			verifyRemoteReport: func([]byte) (internal.Report, error) {
+				return internal.Report{UniqueID: []byte{1}}, nil
+			},
+			verifyReport:    func(attestation.Report) error { return errors.New("unexpected UniqueID") },
+			wantStatus:      attestation.TrustTierContraindicated,
+			wantHardware:    attestation.TrustClaimAffirming,
+			wantExecutables: attestation.TrustClaimContraindicated,
+		},
+		"invalid report": {
+			verifyRemoteReport: func([]byte) (internal.Report, error) {
+				return internal.Report{}, errors.New("invalid report")
+			},
+			verifyReport: func(attestation.Report) error { return nil },
+			wantErr:      true,
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			assert := assert.New(t)
+			require := require.New(t)
+
+			token, err := createAttestationResult(nil, tc.verifyRemoteReport, tc.verifyReport, key, attestation.AttestationResultOptions{Nonce: "nonce"})
+			if tc.wantErr {
+				assert.Error(err)
+				return
+			}
+			require.NoError(err)
+
+			result, err := attestation.VerifyAttestationResult(token, &key.PublicKey)
+			require.NoError(err)
+			assert.Equal(tc.wantStatus, result.Status)
+			assert.Equal(tc.wantHardware, result.TrustVector.Hardware)
+			assert.Equal(tc.wantExecutables, result.TrustVector.Executables)
+			assert.Equal("nonce", result.Nonce)
+			assert.Equal("01", result.Evidence.UniqueID)
+		})
+	}
+}
@@ -8,6 +8,8 @@ package eclient
 
 import (
 	"crypto/tls"
+	"errors"
+	"net/http"
 
 	"github.com/edgelesssys/ego/attestation"
 	internal "github.com/edgelesssys/ego/internal/attestation"
@@ -19,12 +21,41 @@ import (
 // verifies that the signing authority is rooted to a trusted authority
 // such as the enclave platform manufacturer.
 //
+// If the platform's TCB level isn't up to date, attestation.ErrTCBLevelInvalid is returned together with
+// the report. Other verification failures are returned as *attestation.VerificationError.
+//
 // The caller must verify the returned report's content.
 func VerifyRemoteReport(reportBytes []byte) (attestation.Report, error) {
 	report, err := verifyRemoteReport(reportBytes)
 	return attestation.Report(report), err
 }
 
+// VerifyTDXQuote verifies the integrity of an Intel TDX quote and its signature.
+//
+// Like VerifyRemoteReport, it verifies that the quote is rooted to a trusted authority. If the platform's
+// TCB level isn't up to date, it returns attestation.ErrTCBLevelInvalid itself together with the parsed quote.
+// Other verification failures are returned as *attestation.VerificationError.
+//
+// The caller must verify the returned report's content, i.e., MRTD and the RTMRs, and that the TD isn't debuggable.
+// Use VerifyTDXQuoteWithPolicy to do so.
+func VerifyTDXQuote(quote []byte) (attestation.TDXReport, error) {
+	report, err := verifyTDXQuote(quote)
+	return attestation.TDXReport(report), err
+}
+
+// VerifyTDXQuoteWithPolicy verifies the quote like VerifyTDXQuote and the report's content against policy.
+// A TCB level that isn't up to date is accepted if the policy allows the report's TCB status.
+func VerifyTDXQuoteWithPolicy(quote []byte, policy attestation.TDXPolicy) (attestation.TDXReport, error) {
+	report, err := VerifyTDXQuote(quote)
+	if err != nil && !errors.Is(err, attestation.ErrTCBLevelInvalid) {
+		return attestation.TDXReport{}, err
+	}
+	if err := policy.Evaluate(report); err != nil {
+		return attestation.TDXReport{}, err
+	}
+	return report, nil
+}
+
 // CreateAttestationClientTLSConfig creates a tls.Config object that verifies a certificate with embedded report.
 //
 // The config accepts both EGo and Open Enclave certificates.
@@ -43,6 +74,54 @@ func CreateAttestationClientTLSConfig(verifyReport func(attestation.Report) erro
 	)
 }
 
+// CreateChannelAttestationClient creates an http.Client that binds remote attestation to each TLS connection.
+//
+// After each TLS handshake, the client requests a report from the server on the same connection.
+// The server must wrap its handler with enclave.CreateChannelAttestationHandler. The report's data
+// must equal the connection's exported keying material (RFC 9266 tls-exporter channel binding). Thus,
+// the report can't be replayed on another connection, even if the server's certificate key is leaked.
+//
+// tlsConfig is used to establish the connection and may be nil. TLS 1.3 is enforced.
+//
+// verifyReport is called after the report has been verified against the channel binding. The caller must verify either the UniqueID or the tuple (SignerID, ProductID, SecurityVersion, Debug) in the callback.
+func CreateChannelAttestationClient(tlsConfig *tls.Config, verifyReport func(attestation.Report) error, opts ...AttestOption) *http.Client {
+	var appliedOpts internal.Options
+	for _, o := range opts {
+		o.apply(&appliedOpts)
+	}
+
+	return internal.CreateChannelAttestationClient(
+		tlsConfig,
+		verifyRemoteReport,
+		appliedOpts,
+		func(rep internal.Report) error { return verifyReport(attestation.Report(rep)) },
+	)
+}
+
+// VerifyAttestedClaims verifies an envelope created by enclave.CreateAttestedClaims.
+//
+// It verifies the report and checks that it is over the digest of the claims. Use the Decode method of the
+// result to get the claims as a typed struct.
+//
+// verifyReport is called after the report has been verified against the claims. The caller must verify either the UniqueID or the tuple (SignerID, ProductID, SecurityVersion, Debug) in the callback.
+func VerifyAttestedClaims(envelope []byte, verifyReport func(attestation.Report) error, opts ...AttestOption) (attestation.AttestedClaims, error) {
+	var appliedOpts internal.Options
+	for _, o := range opts {
+		o.apply(&appliedOpts)
+	}
+
+	raw, claims, report, err := internal.VerifyAttestedClaims(
+		envelope,
+		verifyRemoteReport,
+		appliedOpts,
+		func(rep internal.Report) error { return verifyReport(attestation.Report(rep)) },
+	)
+	if err != nil {
+		return attestation.AttestedClaims{}, err
+	}
+	return attestation.AttestedClaims{Claims: claims, Raw: raw, Report: attestation.Report(report)}, nil
+}
+
 // AttestOption	configures an attestation function.
 type AttestOption struct {
 	apply func(*internal.Options)
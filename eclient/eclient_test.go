@@ -8,8 +8,11 @@ package eclient
 
 import (
 	"bytes"
+	"encoding/hex"
 	"errors"
+	"fmt"
 	"net/http"
+	"time"
 
 	"github.com/edgelesssys/ego/attestation"
 )
@@ -33,3 +36,25 @@ func ExampleCreateAttestationClientTLSConfig() {
 	// EGo's enclave package provides functionality for such server
 	_, _ = client.Get("https://example.com")
 }
+
+func ExampleNewHTTPClient() {
+	// the uniqueID is derived from the binary of the enclaved program
+	// and can be obtained using `ego uniqueid`
+	var uniqueID []byte
+
+	verifyReport := func(report attestation.Report) error {
+		if !bytes.Equal(report.UniqueID, uniqueID) {
+			return errors.New("invalid UniqueID")
+		}
+		return nil
+	}
+
+	// verify the server again if a connection is reused after 10 minutes
+	client := NewHTTPClient(verifyReport, WithVerificationTTL(10*time.Minute))
+
+	_, err := client.Get("https://example.com")
+	var attErr *AttestationError
+	if errors.As(err, &attErr) && attErr.Report != nil {
+		fmt.Println("server has unexpected UniqueID:", hex.EncodeToString(attErr.Report.UniqueID))
+	}
+}
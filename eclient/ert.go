@@ -13,30 +13,48 @@ package eclient
 import "C"
 
 import (
-	"errors"
+	"sync"
+	"sync/atomic"
 	"unsafe"
 
 	"github.com/edgelesssys/ego/attestation"
 	internal "github.com/edgelesssys/ego/internal/attestation"
 )
 
+// initializeVerifier initializes the verifier once per process. Initializing it for each verification is costly.
+var initializeVerifier = retryOnce(func() error {
+	if res := C.oe_verifier_initialize(); res != C.OE_OK {
+		return oeError(res)
+	}
+	return nil
+})
+
 func verifyRemoteReport(reportBytes []byte) (internal.Report, error) {
+	return verifyRemoteReportWithEndorsements(reportBytes, nil)
+}
+
+// verifyRemoteReportWithEndorsements verifies the report with the given collateral. If endorsements is nil,
+// the collateral is fetched by the quote provider library.
+func verifyRemoteReportWithEndorsements(reportBytes, endorsements []byte) (internal.Report, error) {
 	if len(reportBytes) <= 0 {
 		return internal.Report{}, attestation.ErrEmptyReport
 	}
+	if err := initializeVerifier(); err != nil {
+		return internal.Report{}, err
+	}
 
-	res := C.oe_verifier_initialize()
-	if res != C.OE_OK {
-		return internal.Report{}, oeError(res)
+	var endorsementsPtr *C.uint8_t
+	if len(endorsements) > 0 {
+		endorsementsPtr = (*C.uint8_t)(&endorsements[0])
 	}
 
 	var claims *C.oe_claim_t
 	var claimsLength C.size_t
 
-	res = C.oe_verify_evidence(
+	res := C.oe_verify_evidence(
 		nil,
 		(*C.uint8_t)(&reportBytes[0]), C.size_t(len(reportBytes)),
-		nil, 0,
+		endorsementsPtr, C.size_t(len(endorsements)),
 		nil, 0,
 		&claims, &claimsLength,
 	)
@@ -57,6 +75,27 @@ func verifyRemoteReport(reportBytes []byte) (internal.Report, error) {
 	return report, verifyErr
 }
 
+// retryOnce is like sync.OnceValue, but calls f again on the next call if it failed.
+func retryOnce(f func() error) func() error {
+	var mut sync.Mutex
+	var done atomic.Bool
+	return func() error {
+		if done.Load() {
+			return nil
+		}
+		mut.Lock()
+		defer mut.Unlock()
+		if done.Load() {
+			return nil
+		}
+		if err := f(); err != nil {
+			return err
+		}
+		done.Store(true)
+		return nil
+	}
+}
+
 func oeError(res C.oe_result_t) error {
-	return errors.New(C.GoString(C.oe_result_str(res)))
+	return attestation.NewVerificationError(uint32(res), C.GoString(C.oe_result_str(res)))
 }
@@ -0,0 +1,89 @@
+// Copyright (c) Edgeless Systems GmbH.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package eclient
+
+import (
+	"fmt"
+	"net/http"
+	"time"
+
+	"github.com/edgelesssys/ego/attestation"
+	internal "github.com/edgelesssys/ego/internal/attestation"
+)
+
+const defaultVerificationTTL = time.Hour
+
+// AttestationError is returned by clients created with NewHTTPClient if the attestation of a server failed.
+type AttestationError struct {
+	Host   string              // The address the client connected to.
+	Report *attestation.Report // The server's report if it could be parsed, nil otherwise.
+	Err    error               // The reason why the attestation failed.
+}
+
+func (e *AttestationError) Error() string {
+	return fmt.Sprintf("attestation of %v failed: %v", e.Host, e.Err)
+}
+
+func (e *AttestationError) Unwrap() error {
+	return e.Err
+}
+
+// NewHTTPClient creates an http.Client that verifies the embedded report of each new TLS connection.
+//
+// The client accepts both EGo and Open Enclave certificates. Verified reports are cached per certificate
+// for one hour or the duration set by WithVerificationTTL. When a connection is reused after the
+// verification of its certificate has expired, the client verifies the server again on a new connection.
+// Requests with a body that can't be rewound are always sent on a new connection. Proxies aren't supported.
+//
+// If the attestation fails, the returned error wraps an *AttestationError.
+//
+// verifyReport is called after the certificate has been verified against the report data. The caller must verify either the UniqueID or the tuple (SignerID, ProductID, SecurityVersion, Debug) in the callback.
+//
+// An AttestOption like WithIgnoreTCBStatus can be passed as an HTTPClientOption.
+func NewHTTPClient(verifyReport func(attestation.Report) error, opts ...HTTPClientOption) *http.Client {
+	appliedOpts := httpClientOptions{verificationTTL: defaultVerificationTTL}
+	for _, o := range opts {
+		o.applyHTTPClient(&appliedOpts)
+	}
+
+	transport := internal.NewHTTPTransport(
+		verifyRemoteReport,
+		appliedOpts.attest,
+		func(rep internal.Report) error { return verifyReport(attestation.Report(rep)) },
+		appliedOpts.verificationTTL,
+		func(host string, report *internal.Report, err error) error {
+			return &AttestationError{Host: host, Report: (*attestation.Report)(report), Err: err}
+		},
+	)
+	return &http.Client{Transport: transport}
+}
+
+// HTTPClientOption configures a client created with NewHTTPClient.
+type HTTPClientOption interface {
+	applyHTTPClient(*httpClientOptions)
+}
+
+type httpClientOptions struct {
+	attest          internal.Options
+	verificationTTL time.Duration
+}
+
+func (o AttestOption) applyHTTPClient(opts *httpClientOptions) {
+	o.apply(&opts.attest)
+}
+
+type verificationTTLOption time.Duration
+
+func (o verificationTTLOption) applyHTTPClient(opts *httpClientOptions) {
+	opts.verificationTTL = time.Duration(o)
+}
+
+// WithVerificationTTL sets how long a verified report is cached by a client created with NewHTTPClient.
+// A duration of zero disables caching and reused connections aren't verified again.
+func WithVerificationTTL(ttl time.Duration) HTTPClientOption {
+	return verificationTTLOption(ttl)
+}
@@ -14,6 +14,14 @@ import (
 	"github.com/edgelesssys/ego/internal/attestation"
 )
 
-func verifyRemoteReport([]byte) (attestation.Report, error) {
+func verifyRemoteReport(reportBytes []byte) (attestation.Report, error) {
+	return verifyRemoteReportWithEndorsements(reportBytes, nil)
+}
+
+func verifyRemoteReportWithEndorsements([]byte, []byte) (attestation.Report, error) {
 	return attestation.Report{}, errors.New("built with ego_mock_eclient tag, no attestation support available")
 }
+
+func verifyTDXQuote([]byte) (attestation.TDXReport, error) {
+	return attestation.TDXReport{}, errors.New("built with ego_mock_eclient tag, no attestation support available")
+}
@@ -0,0 +1,79 @@
+// Copyright (c) Edgeless Systems GmbH.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at https://mozilla.org/MPL/2.0/.
+
+//go:build !ego_mock_eclient
+
+package eclient
+
+// #cgo LDFLAGS: -loehostverify -lcrypto -ldl
+// #include <openenclave/attestation/verifier.h>
+// #include <openenclave/attestation/tdx/evidence.h>
+// static const oe_uuid_t tdxFormat = {OE_FORMAT_UUID_TDX_QUOTE_ECDSA};
+import "C"
+
+import (
+	"unsafe"
+
+	"github.com/edgelesssys/ego/attestation"
+	internal "github.com/edgelesssys/ego/internal/attestation"
+)
+
+var tdxClaimNames = internal.TDXClaimNames{
+	ReportData:    C.OE_CLAIM_TDX_REPORT_DATA,
+	MRTD:          C.OE_CLAIM_TDX_MR_TD,
+	RTMRs:         [4]string{C.OE_CLAIM_TDX_RT_MR0, C.OE_CLAIM_TDX_RT_MR1, C.OE_CLAIM_TDX_RT_MR2, C.OE_CLAIM_TDX_RT_MR3},
+	MRConfigID:    C.OE_CLAIM_TDX_MR_CONFIG_ID,
+	MROwner:       C.OE_CLAIM_TDX_MR_OWNER,
+	MROwnerConfig: C.OE_CLAIM_TDX_MR_OWNER_CONFIG,
+	MRSeam:        C.OE_CLAIM_TDX_MR_SEAM,
+	MRSeamSigner:  C.OE_CLAIM_TDX_MR_SEAM_SIGNER,
+	TDAttributes:  C.OE_CLAIM_TDX_TD_ATTRIBUTES,
+	XFAM:          C.OE_CLAIM_TDX_XFAM,
+	TEETCBSVN:     C.OE_CLAIM_TDX_TEE_TCB_SVN,
+}
+
+// initializeTDXVerifier registers the TDX verifier plugin once per process.
+var initializeTDXVerifier = retryOnce(func() error {
+	if res := C.oe_tdx_verifier_initialize(); res != C.OE_OK {
+		return oeError(res)
+	}
+	return nil
+})
+
+func verifyTDXQuote(quote []byte) (internal.TDXReport, error) {
+	if len(quote) <= 0 {
+		return internal.TDXReport{}, attestation.ErrEmptyReport
+	}
+	if err := initializeTDXVerifier(); err != nil {
+		return internal.TDXReport{}, err
+	}
+
+	var claims *C.oe_claim_t
+	var claimsLength C.size_t
+
+	res := C.oe_verify_evidence(
+		&C.tdxFormat,
+		(*C.uint8_t)(&quote[0]), C.size_t(len(quote)),
+		nil, 0,
+		nil, 0,
+		&claims, &claimsLength,
+	)
+
+	var verifyErr error
+	if res == C.OE_TCB_LEVEL_INVALID {
+		verifyErr = attestation.ErrTCBLevelInvalid
+	} else if res != C.OE_OK {
+		return internal.TDXReport{}, oeError(res)
+	}
+
+	defer C.oe_free_claims(claims, claimsLength)
+
+	report, err := internal.ParseTDXClaims(internal.ClaimsToMap(unsafe.Pointer(claims), uintptr(claimsLength)), tdxClaimNames)
+	if err != nil {
+		return internal.TDXReport{}, err
+	}
+	return report, verifyErr
+}
@@ -0,0 +1,125 @@
+// Copyright (c) Edgeless Systems GmbH.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package eclient
+
+import (
+	"context"
+	"net/http"
+	"time"
+
+	"github.com/edgelesssys/ego/attestation"
+	internal "github.com/edgelesssys/ego/internal/attestation"
+)
+
+// Verifier verifies remote reports and caches the results.
+//
+// Use a Verifier instead of VerifyRemoteReport if many reports are verified, e.g., if a server attests each
+// incoming connection. A report that has already been verified isn't verified again until its result expires.
+// If a CollateralSource is configured, e.g., with WithPCCS, the collateral is cached in memory per platform.
+//
+// A Verifier is safe for concurrent use. Its VerifyRemoteReport method can be passed wherever a
+// verifyRemoteReport function is expected, e.g., to grpccreds.NewClientCredentials.
+type Verifier struct {
+	verifier *internal.Verifier
+}
+
+// CollateralSource provides the collateral for verifying SGX quotes.
+//
+// By default, the collateral is fetched by the quote provider library, which is configured on the host,
+// e.g., by /etc/sgx_default_qcnl.conf for Intel's PCCS.
+type CollateralSource interface {
+	// CollateralKey returns the key of the collateral needed to verify the report. Reports that share
+	// the key share the collateral. Usually, the key is derived from the platform's FMSPC.
+	CollateralKey(reportBytes []byte) (string, error)
+	// GetCollateral returns the collateral for the key in Open Enclave's endorsements format and the
+	// time of the collateral's next update. The collateral is cached until then.
+	GetCollateral(ctx context.Context, key string) (endorsements []byte, nextUpdate time.Time, err error)
+}
+
+// IntelPCSURL is the base URL of the API of the Intel Provisioning Certification Service for SGX.
+const IntelPCSURL = "https://api.trustedservices.intel.com/sgx/certification/v4/"
+
+// NewPCCSCollateralSource creates a CollateralSource that fetches the collateral from an Intel Provisioning Certificate
+// Caching Service (PCCS) or a service with a compatible API. baseURL is the base URL of the API,
+// e.g., https://localhost:8081/sgx/certification/v4/ or IntelPCSURL.
+//
+// If client is nil, a client with a timeout of 30 seconds is used. Pass a client with a custom TLS config
+// if the PCCS uses a self-signed certificate.
+func NewPCCSCollateralSource(baseURL string, client *http.Client) CollateralSource {
+	if client == nil {
+		client = &http.Client{Timeout: 30 * time.Second}
+	}
+	return internal.NewPCCS(baseURL, client)
+}
+
+// NewVerifier creates a new Verifier.
+//
+// By default, results are cached for 10 minutes and at most 1024 results are cached.
+// Collateral is cached for an hour, but never beyond its next update.
+func NewVerifier(opts ...VerifierOption) *Verifier {
+	config := internal.VerifierConfig{ReportErr: attestation.ErrTCBLevelInvalid}
+	for _, o := range opts {
+		o.apply(&config)
+	}
+	return &Verifier{verifier: internal.NewVerifier(verifyRemoteReportWithEndorsements, config)}
+}
+
+// VerifyRemoteReport verifies the integrity of the remote report and its signature like the package-level
+// VerifyRemoteReport function.
+//
+// The caller must verify the returned report's content.
+func (v *Verifier) VerifyRemoteReport(reportBytes []byte) (attestation.Report, error) {
+	return v.VerifyRemoteReportContext(context.Background(), reportBytes)
+}
+
+// VerifyRemoteReportContext is like VerifyRemoteReport, but the context is used for fetching the collateral.
+func (v *Verifier) VerifyRemoteReportContext(ctx context.Context, reportBytes []byte) (attestation.Report, error) {
+	if len(reportBytes) <= 0 {
+		return attestation.Report{}, attestation.ErrEmptyReport
+	}
+	report, err := v.verifier.Verify(ctx, reportBytes)
+	return attestation.Report(report), err
+}
+
+// VerifierOption configures a Verifier.
+type VerifierOption struct {
+	apply func(*internal.VerifierConfig)
+}
+
+// WithResultCache sets the time a verification result is cached and the maximum number of cached results.
+// Results are never cached beyond the next update of the collateral. If ttl is negative, results aren't cached.
+func WithResultCache(ttl time.Duration, maxEntries int) VerifierOption {
+	return VerifierOption{func(c *internal.VerifierConfig) {
+		c.ResultTTL = ttl
+		c.MaxResults = maxEntries
+	}}
+}
+
+// WithCollateralCache sets the time collateral is cached. Collateral is never cached beyond its next update.
+// If ttl is negative, collateral isn't cached.
+//
+// Collateral is only cached if a CollateralSource is set with WithCollateralSource or WithPCCS. Otherwise,
+// Open Enclave gets the collateral from the quote provider library for each verification and this option has no effect.
+func WithCollateralCache(ttl time.Duration) VerifierOption {
+	return VerifierOption{func(c *internal.VerifierConfig) {
+		c.CollateralTTL = ttl
+	}}
+}
+
+// WithPCCS makes the Verifier fetch the collateral from the PCCS at baseURL instead of using the quote provider library.
+// It's a shorthand for WithCollateralSource(NewPCCSCollateralSource(baseURL, nil)).
+func WithPCCS(baseURL string) VerifierOption {
+	return WithCollateralSource(NewPCCSCollateralSource(baseURL, nil))
+}
+
+// WithCollateralSource sets the source of the collateral that is used to verify SGX quotes.
+func WithCollateralSource(source CollateralSource) VerifierOption {
+	return VerifierOption{func(c *internal.VerifierConfig) {
+		c.CollateralKey = source.CollateralKey
+		c.GetCollateral = source.GetCollateral
+	}}
+}
@@ -0,0 +1,62 @@
+// Copyright (c) Edgeless Systems GmbH.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package eclient
+
+import (
+	"errors"
+	"os"
+	"testing"
+	"time"
+
+	"github.com/edgelesssys/ego/attestation"
+)
+
+func ExampleNewVerifier() {
+	// create the verifier once and use it for all verifications
+	verifier := NewVerifier(WithResultCache(5*time.Minute, 100))
+
+	var reportBytes []byte
+	report, err := verifier.VerifyRemoteReport(reportBytes)
+	if err != nil && !errors.Is(err, attestation.ErrTCBLevelInvalid) {
+		return
+	}
+	_ = report // verify the report's content
+}
+
+// Run with a remote report on SGX hardware:
+//
+//	EGO_BENCH_REPORT=report.bin go test -bench VerifyRemoteReport ./eclient
+func BenchmarkVerifyRemoteReport(b *testing.B) {
+	reportBytes := benchReport(b)
+	for i := 0; i < b.N; i++ {
+		if _, err := VerifyRemoteReport(reportBytes); err != nil && !errors.Is(err, attestation.ErrTCBLevelInvalid) {
+			b.Fatal(err)
+		}
+	}
+}
+
+func BenchmarkVerifierVerifyRemoteReport(b *testing.B) {
+	reportBytes := benchReport(b)
+	verifier := NewVerifier()
+	for i := 0; i < b.N; i++ {
+		if _, err := verifier.VerifyRemoteReport(reportBytes); err != nil && !errors.Is(err, attestation.ErrTCBLevelInvalid) {
+			b.Fatal(err)
+		}
+	}
+}
+
+func benchReport(b *testing.B) []byte {
+	path := os.Getenv("EGO_BENCH_REPORT")
+	if path == "" {
+		b.Skip("EGO_BENCH_REPORT not set")
+	}
+	reportBytes, err := os.ReadFile(path)
+	if err != nil {
+		b.Fatal(err)
+	}
+	return reportBytes
+}
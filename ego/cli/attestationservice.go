@@ -0,0 +1,32 @@
+// Copyright (c) Edgeless Systems GmbH.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package cli
+
+import (
+	"os"
+	"os/exec"
+	"path/filepath"
+)
+
+// AttestationService runs the self-hosted attestation service.
+func (c *Cli) AttestationService(args []string) (int, error) {
+	cmd := exec.Command(c.getAttestationServicePath(), args...)
+	cmd.Stdin = os.Stdin
+	cmd.Stdout = os.Stdout
+	cmd.Stderr = os.Stderr
+
+	if err := c.runner.Run(cmd); err != nil {
+		if _, ok := err.(*exec.ExitError); !ok {
+			return 1, err
+		}
+	}
+	return c.runner.ExitCode(cmd), nil
+}
+
+func (c *Cli) getAttestationServicePath() string {
+	return filepath.Join(c.egoPath, "bin", "ego-attestation-service")
+}
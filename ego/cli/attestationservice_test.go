@@ -0,0 +1,32 @@
+// Copyright (c) Edgeless Systems GmbH.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package cli
+
+import (
+	"path/filepath"
+	"testing"
+
+	"github.com/spf13/afero"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAttestationService(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	runner := runner{}
+	cli := NewCli(&runner, afero.NewMemMapFs())
+
+	exitCode, err := cli.AttestationService([]string{"-issuer", "https://example.com"})
+	require.NoError(err)
+	assert.Equal(2, exitCode)
+	require.Len(runner.run, 1)
+	cmd := runner.run[0]
+	assert.Equal(filepath.Join(cli.egoPath, "bin", "ego-attestation-service"), cmd.Path)
+	assert.Equal([]string{"-issuer", "https://example.com"}, cmd.Args[1:])
+}
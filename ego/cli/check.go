@@ -0,0 +1,364 @@
+// Copyright (c) Edgeless Systems GmbH.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package cli
+
+import (
+	"debug/buildinfo"
+	"debug/elf"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"io"
+	"os"
+	"path/filepath"
+	"runtime/debug"
+	"slices"
+	"strings"
+)
+
+const limitationsURL = "https://docs.edgeless.systems/ego/knowledge/limitations"
+
+// ErrNoSymbols is returned if an executable can't be checked because it has been stripped.
+var ErrNoSymbols = errors.New("executable has no symbols, can't check it")
+
+// Finding is a feature used by an executable that is unsupported in EGo enclaves.
+type Finding struct {
+	ID      string `json:"id"`                // Identifies the unsupported feature. Used in the allowUnsupported list of the config.
+	Message string `json:"message"`           // Describes why the feature is unsupported.
+	Package string `json:"package,omitempty"` // The package that uses the feature. Empty if unknown.
+	DocURL  string `json:"docURL"`            // Link to the documentation of the limitation.
+}
+
+func (f Finding) String() string {
+	usedBy := ""
+	if f.Package != "" {
+		usedBy = " used by " + f.Package
+	}
+	return fmt.Sprintf("%v%v: %v (see %v)", f.ID, usedBy, f.Message, f.DocURL)
+}
+
+// allowed returns whether the finding matches an entry of the allowlist.
+// An entry is either a finding ID or an ID and a package separated by a colon.
+func (f Finding) allowed(allowlist []string) bool {
+	return slices.ContainsFunc(allowlist, func(entry string) bool {
+		id, pkg, hasPkg := strings.Cut(entry, ":")
+		return id == f.ID && (!hasPkg || pkg == f.Package)
+	})
+}
+
+// unsupportedFunc describes Go functions that are unsupported in EGo enclaves.
+type unsupportedFunc struct {
+	id      string
+	message string
+	anchor  string
+	match   func(pkg, name string) bool
+}
+
+var unsupportedFuncs = []unsupportedFunc{
+	{
+		id:      "os/exec",
+		message: "spawning processes is unsupported",
+		anchor:  "#partially-unsupported-packages",
+		match:   func(pkg, _ string) bool { return pkg == "os/exec" },
+	},
+	{
+		id:      "os.StartProcess",
+		message: "spawning processes is unsupported",
+		anchor:  "#partially-unsupported-packages",
+		match:   func(_, name string) bool { return name == "os.StartProcess" },
+	},
+	{
+		id:      "os.Pipe",
+		message: "pipes are unsupported",
+		anchor:  "#partially-unsupported-packages",
+		match:   func(_, name string) bool { return name == "os.Pipe" },
+	},
+	{
+		id:      "os/signal",
+		message: "signals aren't passed to the enclave",
+		anchor:  "#partially-unsupported-packages",
+		match:   func(pkg, _ string) bool { return pkg == "os/signal" },
+	},
+	{
+		id:      "plugin",
+		message: "loading plugins is unsupported",
+		anchor:  "#partially-unsupported-packages",
+		match:   func(pkg, _ string) bool { return pkg == "plugin" },
+	},
+}
+
+// unsupportedLibcFuncs are C functions that cause an error if they are used via cgo.
+var unsupportedLibcFuncs = []string{
+	"execl", "execle", "execlp", "execv", "execve", "execvp", "execvpe", "fexecve",
+	"fork", "vfork", "pipe", "pipe2", "popen", "posix_spawn", "posix_spawnp", "system",
+}
+
+// runtimePackagePrefixes are packages of the EGo runtime. Their use of unsupported functions is handled by EGo.
+var runtimePackagePrefixes = []string{
+	"github.com/edgelesssys/ego/",
+	"github.com/edgelesssys/marblerun/marble/premain",
+}
+
+// Check checks whether an executable uses features that are unsupported in EGo enclaves.
+// If filename is a config file, the executable specified therein is checked. Findings in the allowUnsupported list of
+// the config are omitted. If filename is an executable and enclave.json specifies the same executable, its list is used.
+func (c *Cli) Check(filename string) ([]Finding, error) {
+	exe, allowlist, err := c.getCheckConfig(filename)
+	if err != nil {
+		return nil, err
+	}
+	findings, err := c.check(exe)
+	if err != nil {
+		return nil, err
+	}
+	return slices.DeleteFunc(findings, func(f Finding) bool { return f.allowed(allowlist) }), nil
+}
+
+// warnUnsupported prints the findings of the executable as warnings. Errors are ignored because the executable is
+// checked again when it is loaded.
+func (c *Cli) warnUnsupported(w io.Writer, exe string, allowlist []string) {
+	findings, err := c.check(exe)
+	if err != nil {
+		return
+	}
+	for _, f := range findings {
+		if !f.allowed(allowlist) {
+			fmt.Fprintln(w, "WARNING:", f)
+		}
+	}
+}
+
+// getCheckConfig returns the executable to check and the allowlist.
+func (c *Cli) getCheckConfig(filename string) (string, []string, error) {
+	if filepath.Ext(filename) == ".json" {
+		conf, err := c.resolveConfig(filename)
+		if err != nil {
+			return "", nil, err
+		}
+		return conf.Exe, conf.AllowUnsupported, nil
+	}
+	return filename, c.getAllowlistForExecutable(filename), nil
+}
+
+// getAllowlistForExecutable returns the allowlist of enclave.json if it specifies the executable.
+// Only the config itself is loaded, so this is cheap enough to be done on each start of an enclave.
+func (c *Cli) getAllowlistForExecutable(path string) []string {
+	conf, err := c.loadConfig(defaultConfigFilename)
+	if errors.Is(err, errConfigDoesNotExist) {
+		return nil
+	}
+	if err != nil {
+		fmt.Fprintf(os.Stderr, "WARNING: ignoring allowUnsupported of %v: %v\n", defaultConfigFilename, err)
+		return nil
+	}
+	if filepath.Clean(conf.Exe) != filepath.Clean(path) {
+		return nil
+	}
+	return conf.AllowUnsupported
+}
+
+func (c *Cli) check(path string) ([]Finding, error) {
+	file, err := c.fs.Open(path)
+	if err != nil {
+		return nil, fmt.Errorf("opening file: %w", err)
+	}
+	defer file.Close()
+
+	buildInfo, err := buildinfo.Read(file)
+	if err != nil {
+		return nil, fmt.Errorf("reading buildinfo: %w", err)
+	}
+	elfFile, err := elf.NewFile(file)
+	if err != nil {
+		return nil, fmt.Errorf("accessing ELF: %w", err)
+	}
+	return checkELF(elfFile, buildInfo)
+}
+
+func checkELF(elfFile *elf.File, buildInfo *buildinfo.BuildInfo) ([]Finding, error) {
+	var findings []Finding
+
+	// dynamic linking
+	libs, err := elfFile.ImportedLibraries()
+	if err != nil {
+		return nil, fmt.Errorf("reading dynamic section: %w", err)
+	}
+	for _, lib := range libs {
+		findings = append(findings, Finding{
+			ID:      "dynamic/" + lib,
+			Message: "shared objects are unsupported, link libraries statically",
+			DocURL:  limitationsURL,
+		})
+	}
+	for _, setting := range buildInfo.Settings {
+		if setting.Key == "-linkshared" && setting.Value == "true" {
+			findings = append(findings, Finding{
+				ID:      "dynamic/linkshared",
+				Message: "linking against shared Go libraries is unsupported",
+				DocURL:  limitationsURL,
+			})
+		}
+	}
+
+	symbols, err := elfFile.Symbols()
+	if errors.Is(err, elf.ErrNoSymbols) {
+		return nil, ErrNoSymbols
+	}
+	if err != nil {
+		return nil, fmt.Errorf("reading symbols: %w", err)
+	}
+	dynSymbols, err := elfFile.DynamicSymbols()
+	if err != nil && !errors.Is(err, elf.ErrNoSymbols) {
+		return nil, fmt.Errorf("reading dynamic symbols: %w", err)
+	}
+
+	// cgo
+	var libcFuncs []string
+	for _, symbol := range slices.Concat(symbols, dynSymbols) {
+		if elf.ST_TYPE(symbol.Info) != elf.STT_FUNC && symbol.Section != elf.SHN_UNDEF {
+			continue
+		}
+		if slices.Contains(unsupportedLibcFuncs, symbol.Name) && !slices.Contains(libcFuncs, symbol.Name) {
+			libcFuncs = append(libcFuncs, symbol.Name)
+		}
+	}
+	slices.Sort(libcFuncs)
+	for _, name := range libcFuncs {
+		findings = append(findings, Finding{
+			ID:      "cgo/" + name,
+			Message: "the libc function " + name + " is unsupported",
+			DocURL:  limitationsURL + "#cgo-unsupported-libc-functions",
+		})
+	}
+
+	goFindings, err := checkGoFuncs(elfFile, symbols, buildInfo)
+	if err != nil {
+		return nil, err
+	}
+	return append(findings, goFindings...), nil
+}
+
+// checkGoFuncs finds the packages that call unsupported Go functions.
+func checkGoFuncs(elfFile *elf.File, symbols []elf.Symbol, buildInfo *buildinfo.BuildInfo) ([]Finding, error) {
+	// find the unsupported functions that are linked into the binary
+	targets := make(map[uint64]int) // address -> index in unsupportedFuncs
+	linked := make(map[int]bool)
+	for _, symbol := range symbols {
+		if elf.ST_TYPE(symbol.Info) != elf.STT_FUNC {
+			continue
+		}
+		pkg := symbolPackage(symbol.Name)
+		for i, fn := range unsupportedFuncs {
+			if fn.match(pkg, symbol.Name) {
+				targets[symbol.Value] = i
+				linked[i] = true
+			}
+		}
+	}
+	if len(targets) == 0 {
+		return nil, nil
+	}
+
+	text := elfFile.Section(".text")
+	if text == nil {
+		return nil, errors.New("section not found: .text")
+	}
+	code, err := text.Data()
+	if err != nil {
+		return nil, fmt.Errorf("reading .text: %w", err)
+	}
+
+	// find calls to the unsupported functions from packages of the app
+	found := make(map[int]bool)
+	callers := make(map[int][]string)
+	for _, symbol := range symbols {
+		if elf.ST_TYPE(symbol.Info) != elf.STT_FUNC || symbol.Value < text.Addr || symbol.Value+symbol.Size > text.Addr+text.Size {
+			continue
+		}
+		pkg := symbolPackage(symbol.Name)
+		for _, i := range findCalls(code[symbol.Value-text.Addr:symbol.Value-text.Addr+symbol.Size], symbol.Value, targets) {
+			if unsupportedFuncs[i].match(pkg, symbol.Name) {
+				continue
+			}
+			found[i] = true
+			if isAppPackage(pkg, buildInfo) && !slices.Contains(callers[i], pkg) {
+				callers[i] = append(callers[i], pkg)
+			}
+		}
+	}
+
+	var findings []Finding
+	for i, fn := range unsupportedFuncs {
+		if !linked[i] {
+			continue
+		}
+		pkgs := callers[i]
+		if len(pkgs) == 0 {
+			if found[i] {
+				continue // only used by the standard library or the EGo runtime
+			}
+			pkgs = []string{""} // only called indirectly, so the caller is unknown
+		}
+		slices.Sort(pkgs)
+		for _, pkg := range pkgs {
+			findings = append(findings, Finding{
+				ID:      fn.id,
+				Message: fn.message,
+				Package: pkg,
+				DocURL:  limitationsURL + fn.anchor,
+			})
+		}
+	}
+	return findings, nil
+}
+
+// findCalls returns the targets of direct calls (call rel32) in the code that is located at addr.
+// The code isn't decoded. Instead, each 0xe8 byte is taken as the opcode of a call. This is a heuristic: an operand
+// that contains 0xe8 followed by a matching displacement is a false positive, and indirect calls aren't found.
+// Functions that are linked, but whose callers aren't found, are still reported without a package.
+func findCalls(code []byte, addr uint64, targets map[uint64]int) []int {
+	var result []int
+	for i := 0; i+5 <= len(code); i++ {
+		if code[i] != 0xe8 {
+			continue
+		}
+		rel := int32(binary.LittleEndian.Uint32(code[i+1:]))
+		target := addr + uint64(i) + 5 + uint64(int64(rel))
+		if idx, ok := targets[target]; ok {
+			result = append(result, idx)
+		}
+	}
+	return result
+}
+
+// symbolPackage returns the package of a Go symbol, e.g., "github.com/foo/bar" for "github.com/foo/bar.(*T).F".
+func symbolPackage(name string) string {
+	lastSlash := strings.LastIndexByte(name, '/')
+	dot := strings.IndexByte(name[lastSlash+1:], '.')
+	if dot < 0 {
+		return ""
+	}
+	// the linker escapes dots in the last element of the package path
+	return strings.ReplaceAll(name[:lastSlash+1+dot], "%2e", ".")
+}
+
+// isAppPackage returns whether the package belongs to the main module or a dependency, but not to the EGo runtime.
+func isAppPackage(pkg string, buildInfo *buildinfo.BuildInfo) bool {
+	if pkg == "main" {
+		return true
+	}
+	for _, prefix := range runtimePackagePrefixes {
+		if strings.HasPrefix(pkg, prefix) {
+			return false
+		}
+	}
+	inModule := func(path string) bool { return path != "" && (pkg == path || strings.HasPrefix(pkg, path+"/")) }
+	if inModule(buildInfo.Main.Path) {
+		return true
+	}
+	return slices.ContainsFunc(buildInfo.Deps, func(dep *debug.Module) bool { return inModule(dep.Path) })
+}
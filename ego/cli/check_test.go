@@ -0,0 +1,152 @@
+// Copyright (c) Edgeless Systems GmbH.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package cli
+
+import (
+	"debug/buildinfo"
+	"runtime/debug"
+	"testing"
+
+	"github.com/spf13/afero"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestCheck(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	const src = `package main
+
+import (
+	"os"
+	"os/exec"
+	"os/signal"
+)
+
+func main() {
+	_ = exec.Command("ls").Run()
+	pipe()
+	signal.Notify(make(chan os.Signal, 1), os.Interrupt)
+}
+
+//go:noinline
+func pipe() {
+	_, _, _ = os.Pipe()
+}
+`
+
+	fs := afero.Afero{Fs: afero.NewMemMapFs()}
+	cli := NewCli(nil, fs)
+	require.NoError(fs.WriteFile("unsupported", buildExecutable(src), 0o755))
+	require.NoError(fs.WriteFile("hello", elfUnsigned, 0o755))
+
+	findings, err := cli.Check("hello")
+	require.NoError(err)
+	assert.Empty(findings)
+
+	findings, err = cli.Check("unsupported")
+	require.NoError(err)
+	var ids []string
+	for _, f := range findings {
+		assert.Equal("main", f.Package)
+		assert.Contains(f.DocURL, limitationsURL)
+		ids = append(ids, f.ID)
+	}
+	// os.StartProcess is only called by os/exec
+	assert.Equal([]string{"os/exec", "os.Pipe", "os/signal"}, ids)
+
+	// allowlist of enclave.json is used if it specifies the executable
+	require.NoError(fs.WriteFile("enclave.json", []byte(`{"exe":"unsupported","key":"private.pem","heapSize":512,"allowUnsupported":["os/exec","os/signal:main","os.Pipe:other"]}`), 0o644))
+	for _, filename := range []string{"unsupported", "./unsupported", "enclave.json"} {
+		findings, err = cli.Check(filename)
+		require.NoError(err)
+		require.Len(findings, 1)
+		assert.Equal("os.Pipe", findings[0].ID)
+	}
+
+	require.NoError(fs.WriteFile("enclave.json", []byte(`{"exe":"hello","key":"private.pem","heapSize":512,"allowUnsupported":["os/exec"]}`), 0o644))
+	findings, err = cli.Check("unsupported")
+	require.NoError(err)
+	assert.Len(findings, 3)
+
+	_, err = cli.Check("notexist")
+	assert.Error(err)
+}
+
+func TestFindingAllowed(t *testing.T) {
+	finding := Finding{ID: "os/exec", Package: "example.com/foo"}
+
+	testCases := map[string]struct {
+		allowlist []string
+		want      bool
+	}{
+		"empty":         {},
+		"id":            {allowlist: []string{"os/exec"}, want: true},
+		"other id":      {allowlist: []string{"os/signal"}},
+		"id and pkg":    {allowlist: []string{"os/signal", "os/exec:example.com/foo"}, want: true},
+		"other pkg":     {allowlist: []string{"os/exec:example.com/bar"}},
+		"empty pkg":     {allowlist: []string{"os/exec:"}},
+		"pkg as prefix": {allowlist: []string{"os/exec:example.com"}},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			assert.Equal(t, tc.want, finding.allowed(tc.allowlist))
+		})
+	}
+}
+
+func TestSymbolPackage(t *testing.T) {
+	testCases := map[string]string{
+		"main.main":                          "main",
+		"os.Pipe":                            "os",
+		"os/exec.(*Cmd).Run":                 "os/exec",
+		"github.com/foo/bar.(*T).F":          "github.com/foo/bar",
+		"gopkg.in/yaml%2ev3.Unmarshal":       "gopkg.in/yaml.v3",
+		"github.com/foo/bar/baz.F.func1":     "github.com/foo/bar/baz",
+		"github.com/foo/bar.init":            "github.com/foo/bar",
+		"fork":                               "",
+		"go:buildid":                         "",
+		"runtime.morestack_noctxt.abi0":      "runtime",
+		"github.com/foo/bar.F[go.shape.int]": "github.com/foo/bar",
+	}
+	for name, want := range testCases {
+		assert.Equal(t, want, symbolPackage(name), name)
+	}
+}
+
+func TestIsAppPackage(t *testing.T) {
+	buildInfo := &buildinfo.BuildInfo{
+		Main: debug.Module{Path: "example.com/app"},
+		Deps: []*debug.Module{
+			{Path: "github.com/foo/bar"},
+			{Path: "github.com/edgelesssys/ego"},
+		},
+	}
+
+	assert := assert.New(t)
+	assert.True(isAppPackage("main", buildInfo))
+	assert.True(isAppPackage("example.com/app", buildInfo))
+	assert.True(isAppPackage("example.com/app/internal", buildInfo))
+	assert.True(isAppPackage("github.com/foo/bar/baz", buildInfo))
+	assert.False(isAppPackage("github.com/foo/barbaz", buildInfo))
+	assert.False(isAppPackage("os/exec", buildInfo))
+	assert.False(isAppPackage("github.com/edgelesssys/ego/enclave", buildInfo))
+}
+
+func TestFindCalls(t *testing.T) {
+	targets := map[uint64]int{0x2000: 1, 0x0f00: 2}
+	code := []byte{
+		0x90,                         // nop
+		0xe8, 0xfa, 0x0f, 0x00, 0x00, // call 0x2000
+		0xe8, 0xf5, 0xfe, 0xff, 0xff, // call 0x0f00
+		0xe8, 0x00, 0x00, 0x00, 0x00, // call 0x1010
+		0xe8, 0x00, // truncated
+	}
+	assert.Equal(t, []int{1, 2}, findCalls(code, 0x1000, targets))
+}
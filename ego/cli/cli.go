@@ -16,9 +16,11 @@ import (
 
 // Cli implements the ego commands.
 type Cli struct {
-	runner  launch.Runner
-	fs      afero.Afero
-	egoPath string
+	runner        launch.Runner
+	fs            afero.Afero
+	egoPath       string
+	getPassphrase func() ([]byte, error)
+	profile       string
 }
 
 // NewCli creates a new Cli object.
@@ -34,6 +36,11 @@ func NewCli(runner launch.Runner, fs afero.Fs) *Cli {
 	}
 }
 
+// SetProfile sets the profile that is applied to configs. If it is empty, no profile is applied.
+func (c *Cli) SetProfile(profile string) {
+	c.profile = profile
+}
+
 func (c *Cli) getOesignPath() string {
 	return filepath.Join(c.egoPath, "bin", "ego-oesign")
 }
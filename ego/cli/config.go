@@ -0,0 +1,101 @@
+// Copyright (c) Edgeless Systems GmbH.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package cli
+
+import (
+	"encoding/json"
+	"errors"
+	"fmt"
+	"path/filepath"
+
+	"github.com/edgelesssys/ego/ego/config"
+)
+
+// RenderConfig returns the effective config after resolving includes and applying the profile.
+// It is what 'ego sign' uses. Paths are relative to the directory of the config file.
+func (c *Cli) RenderConfig(filename string) ([]byte, error) {
+	conf, err := c.loadConfig(configFilename(filename))
+	if err != nil {
+		return nil, err
+	}
+	if err := conf.Validate(); err != nil {
+		return nil, err
+	}
+	return json.MarshalIndent(conf, "", " ")
+}
+
+// RenderConfigPayload returns the payload that 'ego sign' embeds into the enclave. Unlike RenderConfig, it contains
+// the content of embedded files and omits host paths, and it is what the UniqueID depends on.
+func (c *Cli) RenderConfigPayload(filename string) ([]byte, error) {
+	conf, err := c.readConfigJSONtoStruct(configFilename(filename))
+	if err != nil {
+		return nil, err
+	}
+	return conf.Payload()
+}
+
+// ValidateConfig checks a config and returns all problems that are found.
+// An error is only returned if the config can't be read.
+func (c *Cli) ValidateConfig(filename string) ([]config.Diagnostic, error) {
+	filename = configFilename(filename)
+	conf, err := c.loadConfig(filename)
+	var typeErr *json.UnmarshalTypeError
+	if errors.As(err, &typeErr) {
+		return []config.Diagnostic{{
+			Severity: config.SeverityError,
+			Path:     typeErr.Field,
+			Message:  fmt.Sprintf("cannot use %v as %v", typeErr.Value, typeErr.Type),
+		}}, nil
+	}
+	if err != nil {
+		return nil, err
+	}
+
+	diags := conf.Diagnose()
+	dir := filepath.Dir(filename)
+	for i, file := range conf.Files {
+		if !filepath.IsAbs(file.Source) {
+			file.Source = filepath.Join(dir, file.Source)
+		}
+		if _, err := file.Expand(c.fs); err != nil {
+			diags = append(diags, config.Diagnostic{
+				Severity: config.SeverityError,
+				Path:     fmt.Sprintf("files[%v].source", i),
+				Message:  err.Error(),
+			})
+		}
+	}
+	return diags, nil
+}
+
+// AuditConfig returns the settings of a config that are security-relevant for production deployments.
+// Errors of the config are returned as diagnostics, too, so that they don't hide the findings.
+// An error is only returned if the config can't be read.
+func (c *Cli) AuditConfig(filename string) ([]config.Diagnostic, error) {
+	filename = configFilename(filename)
+	conf, err := c.loadConfig(filename)
+	if err != nil {
+		return nil, err
+	}
+
+	var diags []config.Diagnostic
+	for _, diag := range conf.Diagnose() {
+		if diag.Severity == config.SeverityError {
+			diags = append(diags, diag)
+		}
+	}
+	resolvePaths(conf, filename)
+	return append(diags, conf.Audit(c.fs)...), nil
+}
+
+// configFilename returns the config file that is used if filename is empty.
+func configFilename(filename string) string {
+	if filename == "" {
+		return defaultConfigFilename
+	}
+	return filename
+}
@@ -0,0 +1,144 @@
+// Copyright (c) Edgeless Systems GmbH.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package cli
+
+import (
+	"encoding/base64"
+	"encoding/json"
+	"testing"
+
+	"github.com/edgelesssys/ego/ego/config"
+	"github.com/spf13/afero"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestRenderConfig(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	fs := afero.Afero{Fs: afero.NewMemMapFs()}
+	cli := NewCli(nil, fs)
+
+	require.NoError(fs.WriteFile("base.json", []byte(`{"exe":"exefile", "key":"keyfile", "heapSize":512, "debug":true}`), 0o644))
+	require.NoError(fs.WriteFile("enclave.json", []byte(`{"include":"base.json", "profiles":{"prod":{"debug":false}}}`), 0o644))
+
+	data, err := cli.RenderConfig("")
+	require.NoError(err)
+	var conf config.Config
+	require.NoError(json.Unmarshal(data, &conf))
+	assert.Equal("exefile", conf.Exe)
+	assert.True(conf.Debug)
+
+	cli.SetProfile("prod")
+	data, err = cli.RenderConfig("enclave.json")
+	require.NoError(err)
+	require.NoError(json.Unmarshal(data, &conf))
+	assert.False(conf.Debug)
+
+	// the profile also applies when the config is read for signing
+	signConf, err := cli.readConfigJSONtoStruct("enclave.json")
+	require.NoError(err)
+	assert.False(signConf.Debug)
+
+	// the payload contains the embedded files, but no host paths
+	require.NoError(fs.WriteFile("conf/enclave.json", []byte(`{"exe":"exefile", "key":"keyfile", "heapSize":512, "files":[{"source":"ca.crt", "target":"/ca.crt"}]}`), 0o644))
+	require.NoError(fs.WriteFile("conf/ca.crt", []byte("cert"), 0o644))
+	cli.SetProfile("")
+	payload, err := cli.RenderConfigPayload("conf/enclave.json")
+	require.NoError(err)
+	assert.Contains(string(payload), base64.StdEncoding.EncodeToString([]byte("cert")))
+	assert.NotContains(string(payload), "exefile")
+	assert.NotContains(string(payload), "keyfile")
+	assert.NotContains(string(payload), "source")
+
+	cli.SetProfile("staging")
+	_, err = cli.RenderConfig("")
+	assert.Error(err)
+
+	// a profile can't be applied to the default config
+	require.NoError(fs.Remove("enclave.json"))
+	cli.SetProfile("prod")
+	_, err = cli.getSignConfig("exefile")
+	assert.Error(err)
+	_, err = cli.RenderConfig("")
+	assert.ErrorIs(err, errConfigDoesNotExist)
+}
+
+func TestValidateConfig(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	fs := afero.Afero{Fs: afero.NewMemMapFs()}
+	cli := NewCli(nil, fs)
+
+	_, err := cli.ValidateConfig("")
+	assert.ErrorIs(err, errConfigDoesNotExist)
+
+	require.NoError(fs.WriteFile("conf/enclave.json", []byte(`{"exe":"exefile", "key":"keyfile", "heapSize":512, "files":[{"source":"ca.crt", "target":"/ca.crt"}]}`), 0o644))
+	diags, err := cli.ValidateConfig("conf/enclave.json")
+	require.NoError(err)
+	require.Len(diags, 1)
+	assert.Equal(config.SeverityError, diags[0].Severity)
+	assert.Equal("files[0].source", diags[0].Path)
+
+	// sources are relative to the config
+	require.NoError(fs.WriteFile("conf/ca.crt", nil, 0o644))
+	diags, err = cli.ValidateConfig("conf/enclave.json")
+	require.NoError(err)
+	assert.Empty(diags)
+
+	// glob patterns must match
+	require.NoError(fs.WriteFile("conf/enclave.json", []byte(`{"exe":"exefile", "key":"keyfile", "heapSize":512, "files":[{"source":"certs/*.crt", "target":"/certs"}]}`), 0o644))
+	diags, err = cli.ValidateConfig("conf/enclave.json")
+	require.NoError(err)
+	require.Len(diags, 1)
+	assert.Equal("files[0].source", diags[0].Path)
+	require.NoError(fs.WriteFile("conf/certs/ca.crt", nil, 0o644))
+	diags, err = cli.ValidateConfig("conf/enclave.json")
+	require.NoError(err)
+	assert.Empty(diags)
+
+	require.NoError(fs.WriteFile("enclave.json", []byte(`{"exe":"exefile", "key":"keyfile", "heapSize":"512"}`), 0o644))
+	diags, err = cli.ValidateConfig("")
+	require.NoError(err)
+	require.Len(diags, 1)
+	assert.Equal("heapSize", diags[0].Path)
+
+	require.NoError(fs.WriteFile("enclave.json", []byte(`{"exe":"exefile", "key":"keyfile"`), 0o644))
+	_, err = cli.ValidateConfig("")
+	assert.Error(err)
+}
+
+func TestAuditConfig(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	fs := afero.Afero{Fs: afero.NewMemMapFs()}
+	cli := NewCli(nil, fs)
+
+	require.NoError(fs.WriteFile("enclave.json", []byte(`{"exe":"exefile", "key":"keyfile", "heapSize":512, "debug":true, "profiles":{"prod":{"debug":false}}}`), 0o644))
+	diags, err := cli.AuditConfig("")
+	require.NoError(err)
+	require.Len(diags, 1)
+	assert.Equal("debug", diags[0].Path)
+
+	cli.SetProfile("prod")
+	diags, err = cli.AuditConfig("")
+	require.NoError(err)
+	assert.Empty(diags)
+
+	// an invalid config is still audited
+	require.NoError(fs.WriteFile("enclave.json", []byte(`{"exe":"exefile", "key":"keyfile", "debug":true}`), 0o644))
+	cli.SetProfile("")
+	diags, err = cli.AuditConfig("")
+	require.NoError(err)
+	require.Len(diags, 2)
+	assert.Equal(config.SeverityError, diags[0].Severity)
+	assert.Equal("heapSize", diags[0].Path)
+	assert.Equal("debug", diags[1].Path)
+}
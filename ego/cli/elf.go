@@ -15,6 +15,8 @@ import (
 	"io"
 	"os"
 	"strings"
+
+	"github.com/spf13/afero"
 )
 
 const oeinfoSectionName = ".oeinfo"
@@ -35,7 +37,11 @@ func (c *Cli) embedConfigAsPayload(path string, jsonData []byte) error {
 		return err
 	}
 	defer f.Close()
+	return embedPayload(f, jsonData)
+}
 
+// embedPayload appends the payload to the ELF file and writes its location to the .oeinfo header. An existing payload is replaced.
+func embedPayload(f afero.File, jsonData []byte) error {
 	// Check if a payload already exists
 	payloadSize, payloadOffset, oeInfoOffset, err := getPayloadInformation(f)
 	if err != nil {
@@ -190,23 +196,32 @@ func checkUnsupportedImports(symbols []elf.Symbol) error {
 // If it is built without this tag, heapSize must be <= 16384.
 // (If 512 <= heapSize <= 16384, both modes work.)
 func checkHeapMode(symbols []elf.Symbol, heapSize int) error {
+	switch getHeapMode(symbols) {
+	case HeapModeDefault:
+		if heapSize > 16384 {
+			return ErrNoLargeHeapWithLargeHeapSize
+		}
+	case HeapModeLargeHeap:
+		if heapSize < 512 {
+			return ErrLargeHeapWithSmallHeapSize
+		}
+	}
+	// HeapModeUnknown can't be checked. Will fail on startup if heapSize isn't compatible.
+	return nil
+}
+
+// getHeapMode returns whether the binary has been built with the ego_largeheap build tag.
+func getHeapMode(symbols []elf.Symbol) string {
 	if len(symbols) == 0 {
-		return nil // Can't check. Will fail on startup if heapSize isn't compatible.
+		return HeapModeUnknown
 	}
 	for _, symbol := range symbols {
 		if symbol.Name == "runtime.arenaBaseOffset" {
 			// if this symbol is found, the binary wasn't built with ego_largeheap
-			if heapSize > 16384 {
-				return ErrNoLargeHeapWithLargeHeapSize
-			}
-			return nil
+			return HeapModeDefault
 		}
 	}
-	// if the symbol isn't found, the binary was built with ego_largeheap
-	if heapSize < 512 {
-		return ErrLargeHeapWithSmallHeapSize
-	}
-	return nil
+	return HeapModeLargeHeap
 }
 
 func writeUint64At(w io.WriterAt, x uint64, off int64) error {
@@ -21,7 +21,10 @@ import (
 )
 
 // create an unsigned EGo executable
-var elfUnsigned = func() []byte {
+var elfUnsigned = buildExecutable(`package main;import _"time";func main(){}`)
+
+// buildExecutable compiles the source with ego-go.
+func buildExecutable(src string) []byte {
 	const outFile = "hello"
 	const srcFile = outFile + ".go"
 
@@ -36,8 +39,7 @@ var elfUnsigned = func() []byte {
 	}
 	defer os.RemoveAll(dir)
 
-	// write minimal source file
-	const src = `package main;import _"time";func main(){}`
+	// write source file
 	if err := os.WriteFile(filepath.Join(dir, srcFile), []byte(src), 0o400); err != nil {
 		panic(err)
 	}
@@ -59,7 +61,7 @@ var elfUnsigned = func() []byte {
 	}
 
 	return data
-}()
+}
 
 func TestEmbedConfigAsPayload(t *testing.T) {
 	require := require.New(t)
@@ -0,0 +1,201 @@
+// Copyright (c) Edgeless Systems GmbH.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package cli
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"crypto/sha256"
+	"debug/buildinfo"
+	"debug/elf"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+
+	"github.com/edgelesssys/ego/ego/config"
+)
+
+// Offsets in the enclave properties (oe_sgx_enclave_properties_t) at the start of the .oeinfo section.
+const (
+	offsetNumHeapPages    = 8
+	offsetNumStackPages   = 16
+	offsetNumTCS          = 24
+	offsetProductID       = 32
+	offsetSecurityVersion = 34
+	offsetAttributes      = 40
+
+	sgxFlagsDebug = 0x2
+)
+
+// Heap modes of an enclave.
+const (
+	HeapModeDefault   = "default"
+	HeapModeLargeHeap = "largeheap"
+	HeapModeUnknown   = "unknown"
+)
+
+// EnclaveInfo contains the properties of an enclave executable.
+type EnclaveInfo struct {
+	Bundled         bool              `json:"bundled"`
+	Signed          bool              `json:"signed"`
+	UniqueID        string            `json:"uniqueID"`
+	SignerID        string            `json:"signerID"`
+	ProductID       uint16            `json:"productID"`
+	SecurityVersion uint16            `json:"securityVersion"`
+	Debug           bool              `json:"debug"`
+	NumHeapPages    uint64            `json:"numHeapPages"`
+	NumStackPages   uint64            `json:"numStackPages"`
+	NumTCS          uint64            `json:"numTCS"`
+	GoVersion       string            `json:"goVersion"`
+	MainPath        string            `json:"mainPath"`
+	BuildSettings   map[string]string `json:"buildSettings"`
+	HeapMode        string            `json:"heapMode"`
+	Runtime         string            `json:"runtime"`
+	Config          *config.Config    `json:"config"` // The embedded enclave.json without the content of embedded files. Nil if the enclave hasn't been signed with 'ego sign'.
+}
+
+// Inspect returns the properties of an enclave executable. If the executable is a bundle, the bundled enclave is inspected.
+func (c *Cli) Inspect(path string) (*EnclaveInfo, error) {
+	data, err := c.fs.ReadFile(path)
+	if err != nil {
+		return nil, err
+	}
+
+	bundled := false
+	if enclave, err := extractEnclaveFromBundle(bytes.NewReader(data)); err != nil {
+		return nil, err
+	} else if enclave != nil {
+		data = enclave
+		bundled = true
+	}
+
+	info, err := inspectEnclave(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	info.Bundled = bundled
+	return info, nil
+}
+
+// extractEnclaveFromBundle returns the enclave contained in the .ego.bundle section or nil if the file isn't a bundle.
+func extractEnclaveFromBundle(r io.ReaderAt) ([]byte, error) {
+	elfFile, err := elf.NewFile(r)
+	if err != nil {
+		return nil, err
+	}
+	sec := elfFile.Section(".ego.bundle")
+	if sec == nil {
+		return nil, nil
+	}
+
+	gzipReader, err := gzip.NewReader(sec.Open())
+	if err != nil {
+		return nil, fmt.Errorf("opening bundle: %w", err)
+	}
+	defer gzipReader.Close()
+
+	tarReader := tar.NewReader(gzipReader)
+	for {
+		header, err := tarReader.Next()
+		if err == io.EOF {
+			return nil, errors.New("bundle doesn't contain an enclave")
+		}
+		if err != nil {
+			return nil, fmt.Errorf("reading bundle: %w", err)
+		}
+		if header.Name == "enclave" {
+			return io.ReadAll(tarReader)
+		}
+	}
+}
+
+func inspectEnclave(r io.ReaderAt) (*EnclaveInfo, error) {
+	elfFile, err := elf.NewFile(r)
+	if err != nil {
+		return nil, err
+	}
+	oeInfo := elfFile.Section(oeinfoSectionName)
+	if oeInfo == nil {
+		return nil, ErrNoOEInfo
+	}
+
+	info := &EnclaveInfo{}
+	if err := parseEnclaveProperties(info, oeInfo); err != nil {
+		return nil, fmt.Errorf("reading enclave properties: %w", err)
+	}
+
+	buildInfo, err := buildinfo.Read(r)
+	if err != nil {
+		return nil, fmt.Errorf("reading buildinfo: %w", err)
+	}
+	info.GoVersion = buildInfo.GoVersion
+	info.MainPath = buildInfo.Path
+	info.BuildSettings = make(map[string]string, len(buildInfo.Settings))
+	for _, setting := range buildInfo.Settings {
+		info.BuildSettings[setting.Key] = setting.Value
+	}
+	info.Runtime = getEgoEnclaveName(buildInfo)
+
+	symbols, err := elfFile.Symbols()
+	if err != nil && !errors.Is(err, elf.ErrNoSymbols) {
+		return nil, fmt.Errorf("reading symbols: %w", err)
+	}
+	info.HeapMode = getHeapMode(symbols)
+
+	payloadSize, payloadOffset, _, err := getPayloadInformation(r)
+	if err != nil {
+		return nil, err
+	}
+	if payloadSize > 0 {
+		payload := make([]byte, payloadSize)
+		if _, err := r.ReadAt(payload, payloadOffset); err != nil {
+			return nil, fmt.Errorf("reading payload: %w", err)
+		}
+		var conf config.Config
+		if err := json.Unmarshal(payload, &conf); err != nil {
+			return nil, fmt.Errorf("parsing payload: %w", err)
+		}
+		for i := range conf.Files {
+			conf.Files[i].Base64Content = ""
+		}
+		info.Config = &conf
+	}
+
+	return info, nil
+}
+
+// parseEnclaveProperties parses the enclave properties and the SIGSTRUCT from the .oeinfo section.
+func parseEnclaveProperties(info *EnclaveInfo, oeInfo io.ReaderAt) error {
+	properties := make([]byte, offsetSigstruct+offsetMRENCLAVE+32)
+	if _, err := oeInfo.ReadAt(properties, 0); err != nil {
+		return err
+	}
+
+	info.NumHeapPages = binary.LittleEndian.Uint64(properties[offsetNumHeapPages:])
+	info.NumStackPages = binary.LittleEndian.Uint64(properties[offsetNumStackPages:])
+	info.NumTCS = binary.LittleEndian.Uint64(properties[offsetNumTCS:])
+	info.ProductID = binary.LittleEndian.Uint16(properties[offsetProductID:])
+	info.SecurityVersion = binary.LittleEndian.Uint16(properties[offsetSecurityVersion:])
+	info.Debug = binary.LittleEndian.Uint64(properties[offsetAttributes:])&sgxFlagsDebug != 0
+
+	sigstruct := properties[offsetSigstruct:]
+	info.UniqueID = hex.EncodeToString(sigstruct[offsetMRENCLAVE : offsetMRENCLAVE+32])
+
+	modulus := sigstruct[offsetModulus : offsetModulus+modulusSize]
+	info.Signed = !bytes.Equal(modulus, make([]byte, modulusSize))
+	if info.Signed {
+		sum := sha256.Sum256(modulus)
+		info.SignerID = hex.EncodeToString(sum[:])
+	} else {
+		info.SignerID = hex.EncodeToString(make([]byte, sha256.Size))
+	}
+	return nil
+}
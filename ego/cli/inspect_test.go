@@ -0,0 +1,137 @@
+// Copyright (c) Edgeless Systems GmbH.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package cli
+
+import (
+	"archive/tar"
+	"bytes"
+	"compress/gzip"
+	"debug/elf"
+	"encoding/binary"
+	"encoding/json"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+
+	"github.com/edgelesssys/ego/ego/config"
+	"github.com/spf13/afero"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestInspect(t *testing.T) {
+	if _, err := exec.LookPath("objcopy"); err != nil {
+		t.Skip("objcopy not found, cannot run this test.")
+	}
+
+	assert := assert.New(t)
+	require := require.New(t)
+
+	fs := afero.Afero{Fs: afero.NewMemMapFs()}
+	cli := NewCli(nil, fs)
+
+	// create a fake .oeinfo section
+	oeinfo := make([]byte, 4096)
+	binary.LittleEndian.PutUint64(oeinfo[offsetNumHeapPages:], 100)
+	binary.LittleEndian.PutUint64(oeinfo[offsetNumStackPages:], 1024)
+	binary.LittleEndian.PutUint64(oeinfo[offsetNumTCS:], 32)
+	binary.LittleEndian.PutUint16(oeinfo[offsetProductID:], 2)
+	binary.LittleEndian.PutUint16(oeinfo[offsetSecurityVersion:], 3)
+	binary.LittleEndian.PutUint64(oeinfo[offsetAttributes:], sgxFlagsDebug)
+	copy(oeinfo[offsetSigstruct+offsetMRENCLAVE:], bytes.Repeat([]byte{0xAB}, 32))
+	copy(oeinfo[offsetSigstruct+offsetModulus:], bytes.Repeat([]byte{1}, modulusSize))
+	enclave := addSectionToBytes(t, elfUnsigned, oeinfoSectionName, oeinfo)
+
+	const exe = "enclave"
+	require.NoError(fs.WriteFile(exe, enclave, 0))
+
+	// enclave without embedded enclave.json
+	info, err := cli.Inspect(exe)
+	require.NoError(err)
+	assert.False(info.Bundled)
+	assert.True(info.Signed)
+	assert.Equal("abababababababababababababababababababababababababababababababab", info.UniqueID)
+	assert.Equal("5cf4c99653bf7b21460a25273680c8992f5b25adba052ba176464ab1bd8ec3e2", info.SignerID)
+	assert.EqualValues(2, info.ProductID)
+	assert.EqualValues(3, info.SecurityVersion)
+	assert.True(info.Debug)
+	assert.EqualValues(100, info.NumHeapPages)
+	assert.EqualValues(1024, info.NumStackPages)
+	assert.EqualValues(32, info.NumTCS)
+	assert.NotEmpty(info.GoVersion)
+	assert.Equal("command-line-arguments", info.MainPath)
+	assert.Equal("ego-enclave", info.Runtime)
+	assert.Nil(info.Config)
+
+	// embed payload
+	conf := config.Config{
+		Exe:      exe,
+		HeapSize: 512,
+		Files:    []config.File{{Source: "src", Target: "/dst", Base64Content: "Zm9v"}},
+	}
+	payload, err := json.Marshal(conf)
+	require.NoError(err)
+	require.NoError(cli.embedConfigAsPayload(exe, payload))
+
+	info, err = cli.Inspect(exe)
+	require.NoError(err)
+	require.NotNil(info.Config)
+	assert.Equal(exe, info.Config.Exe)
+	assert.Equal(512, info.Config.HeapSize)
+	require.Len(info.Config.Files, 1)
+	assert.Equal("/dst", info.Config.Files[0].Target)
+	assert.Empty(info.Config.Files[0].Base64Content)
+
+	// bundle
+	signedEnclave, err := fs.ReadFile(exe)
+	require.NoError(err)
+	var image bytes.Buffer
+	gzipWriter := gzip.NewWriter(&image)
+	tarWriter := tar.NewWriter(gzipWriter)
+	for name, content := range map[string][]byte{"ego-host": []byte("host"), "enclave": signedEnclave} {
+		require.NoError(tarWriter.WriteHeader(&tar.Header{Name: name, Mode: 0o755, Size: int64(len(content))}))
+		_, err := tarWriter.Write(content)
+		require.NoError(err)
+	}
+	require.NoError(tarWriter.Close())
+	require.NoError(gzipWriter.Close())
+	const bundle = "bundle"
+	require.NoError(fs.WriteFile(bundle, addSectionToBytes(t, elfUnsigned, ".ego.bundle", image.Bytes()), 0))
+
+	bundleInfo, err := cli.Inspect(bundle)
+	require.NoError(err)
+	assert.True(bundleInfo.Bundled)
+	bundleInfo.Bundled = false
+	assert.Equal(info, bundleInfo)
+
+	// not an enclave
+	require.NoError(fs.WriteFile("plain", elfUnsigned, 0))
+	_, err = cli.Inspect("plain")
+	assert.ErrorIs(err, ErrNoOEInfo)
+}
+
+func TestGetHeapMode(t *testing.T) {
+	assert := assert.New(t)
+	assert.Equal(HeapModeUnknown, getHeapMode(nil))
+	assert.Equal(HeapModeDefault, getHeapMode([]elf.Symbol{{Name: "main.main"}, {Name: "runtime.arenaBaseOffset"}}))
+	assert.Equal(HeapModeLargeHeap, getHeapMode([]elf.Symbol{{Name: "main.main"}}))
+}
+
+// addSectionToBytes returns a copy of the ELF file with an added section.
+func addSectionToBytes(t *testing.T, elfData []byte, sectionName string, content []byte) []byte {
+	require := require.New(t)
+	dir := t.TempDir()
+	elfFile := filepath.Join(dir, "elf")
+	contentFile := filepath.Join(dir, "content")
+	require.NoError(os.WriteFile(elfFile, elfData, 0o644))
+	require.NoError(os.WriteFile(contentFile, content, 0o644))
+	require.NoError(addSectionToELF(elfFile, contentFile, sectionName))
+	result, err := os.ReadFile(elfFile)
+	require.NoError(err)
+	return result
+}
@@ -0,0 +1,387 @@
+// Copyright (c) Edgeless Systems GmbH.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package cli
+
+import (
+	"bytes"
+	"crypto/aes"
+	"crypto/cipher"
+	"crypto/pbkdf2"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io"
+	"math/big"
+	"os"
+)
+
+const (
+	pemTypePublicKey           = "PUBLIC KEY"
+	pemTypePrivateKey          = "PRIVATE KEY"
+	pemTypeRSAPrivateKey       = "RSA PRIVATE KEY"
+	pemTypeEncryptedPrivateKey = "ENCRYPTED PRIVATE KEY"
+
+	pbkdf2Iterations = 600000
+)
+
+var (
+	oidPBES2          = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 5, 13}
+	oidPBKDF2         = asn1.ObjectIdentifier{1, 2, 840, 113549, 1, 5, 12}
+	oidHMACWithSHA256 = asn1.ObjectIdentifier{1, 2, 840, 113549, 2, 9}
+	oidAES256CBC      = asn1.ObjectIdentifier{2, 16, 840, 1, 101, 3, 4, 1, 42}
+)
+
+// ErrPassphraseRequired is returned if a private key is encrypted, but no passphrase is available.
+var ErrPassphraseRequired = errors.New("private key is encrypted, but no passphrase has been provided")
+
+// ErrWrongPassphrase is returned if an encrypted private key can't be decrypted with the passphrase.
+var ErrWrongPassphrase = errors.New("failed to decrypt private key, wrong passphrase?")
+
+// SetPassphraseFunc sets the function that is called to get the passphrase of an encrypted private key.
+func (c *Cli) SetPassphraseFunc(getPassphrase func() ([]byte, error)) {
+	c.getPassphrase = getPassphrase
+}
+
+// GenerateKey generates a new signing key and writes it to privKeyFile. If passphrase isn't empty,
+// the key is encrypted with it. If pubKeyFile isn't empty, the public key is written to it.
+func (c *Cli) GenerateKey(privKeyFile, pubKeyFile string, passphrase []byte) error {
+	if exists, err := c.fs.Exists(privKeyFile); err != nil {
+		return err
+	} else if exists {
+		return fmt.Errorf("%w: %v", os.ErrExist, privKeyFile)
+	}
+
+	key, err := generateSGXKey(rand.Reader)
+	if err != nil {
+		return fmt.Errorf("generating key: %w", err)
+	}
+	return c.writeKeyPair(key, privKeyFile, pubKeyFile, passphrase)
+}
+
+// WritePublicKey reads the private key from privKeyFile and writes its public key to pubKeyFile.
+func (c *Cli) WritePublicKey(privKeyFile, pubKeyFile string) error {
+	key, err := c.readPrivateKey(privKeyFile)
+	if err != nil {
+		return err
+	}
+	return c.writePublicKey(&key.PublicKey, pubKeyFile)
+}
+
+func (c *Cli) writeKeyPair(key *rsa.PrivateKey, privKeyFile, pubKeyFile string, passphrase []byte) error {
+	block, err := marshalPrivateKey(key, passphrase)
+	if err != nil {
+		return err
+	}
+	if err := c.fs.WriteFile(privKeyFile, pem.EncodeToMemory(block), 0o600); err != nil {
+		return err
+	}
+	if pubKeyFile == "" {
+		return nil
+	}
+	return c.writePublicKey(&key.PublicKey, pubKeyFile)
+}
+
+func (c *Cli) writePublicKey(pub *rsa.PublicKey, path string) error {
+	der, err := x509.MarshalPKIXPublicKey(pub)
+	if err != nil {
+		return err
+	}
+	return c.fs.WriteFile(path, pem.EncodeToMemory(&pem.Block{Type: pemTypePublicKey, Bytes: der}), 0o644)
+}
+
+// readPrivateKey reads a PEM-encoded private key. If it is encrypted, the passphrase func is used to decrypt it.
+func (c *Cli) readPrivateKey(path string) (*rsa.PrivateKey, error) {
+	block, err := c.readPEM(path)
+	if err != nil {
+		return nil, err
+	}
+	c.warnIfKeyPermissionsLoose(path)
+	return c.parsePrivateKey(block)
+}
+
+// readPublicKey reads a PEM-encoded public key. If the file contains a private key, its public key is returned.
+func (c *Cli) readPublicKey(path string) (*rsa.PublicKey, error) {
+	block, err := c.readPEM(path)
+	if err != nil {
+		return nil, err
+	}
+	if block.Type != pemTypePublicKey {
+		c.warnIfKeyPermissionsLoose(path)
+		key, err := c.parsePrivateKey(block)
+		if err != nil {
+			return nil, err
+		}
+		return &key.PublicKey, nil
+	}
+
+	key, err := x509.ParsePKIXPublicKey(block.Bytes)
+	if err != nil {
+		return nil, fmt.Errorf("parsing public key: %w", err)
+	}
+	rsaKey, ok := key.(*rsa.PublicKey)
+	if !ok {
+		return nil, fmt.Errorf("expected RSA public key, got %T", key)
+	}
+	return rsaKey, nil
+}
+
+// isEncryptedKey returns whether path contains an encrypted private key. It returns false if the file doesn't exist.
+func (c *Cli) isEncryptedKey(path string) (bool, error) {
+	block, err := c.readPEM(path)
+	if errors.Is(err, os.ErrNotExist) {
+		return false, nil
+	}
+	if err != nil {
+		return false, err
+	}
+	return block.Type == pemTypeEncryptedPrivateKey, nil
+}
+
+func (c *Cli) readPEM(path string) (*pem.Block, error) {
+	pemBytes, err := c.fs.ReadFile(path)
+	if err != nil {
+		return nil, fmt.Errorf("reading key file: %w", err)
+	}
+	block, _ := pem.Decode(pemBytes)
+	if block == nil {
+		return nil, errors.New("failed to decode PEM")
+	}
+	return block, nil
+}
+
+func (c *Cli) parsePrivateKey(block *pem.Block) (*rsa.PrivateKey, error) {
+	der := block.Bytes
+	switch block.Type {
+	case pemTypeRSAPrivateKey:
+		key, err := x509.ParsePKCS1PrivateKey(der)
+		if err != nil {
+			return nil, fmt.Errorf("parsing private key: %w", err)
+		}
+		return key, nil
+	case pemTypeEncryptedPrivateKey:
+		if c.getPassphrase == nil {
+			return nil, ErrPassphraseRequired
+		}
+		passphrase, err := c.getPassphrase()
+		if err != nil {
+			return nil, err
+		}
+		if len(passphrase) == 0 {
+			return nil, ErrPassphraseRequired
+		}
+		if der, err = decryptPKCS8(der, passphrase); err != nil {
+			return nil, err
+		}
+	case pemTypePrivateKey:
+	default:
+		return nil, fmt.Errorf("unexpected PEM type: %v", block.Type)
+	}
+
+	key, err := x509.ParsePKCS8PrivateKey(der)
+	if err != nil {
+		if block.Type == pemTypeEncryptedPrivateKey {
+			return nil, ErrWrongPassphrase
+		}
+		return nil, fmt.Errorf("parsing private key: %w", err)
+	}
+	rsaKey, ok := key.(*rsa.PrivateKey)
+	if !ok {
+		return nil, fmt.Errorf("expected RSA private key, got %T", key)
+	}
+	return rsaKey, nil
+}
+
+// warnIfKeyPermissionsLoose prints a warning if the private key file is accessible by other users.
+func (c *Cli) warnIfKeyPermissionsLoose(path string) {
+	info, err := c.fs.Stat(path)
+	if err != nil {
+		return
+	}
+	if perm := info.Mode().Perm(); perm&0o077 != 0 {
+		fmt.Printf("WARNING: private key file %v is accessible by other users (permissions %#o). Run: chmod 600 %v\n", path, perm, path)
+	}
+}
+
+// generateSGXKey generates an RSA-3072 key with exponent 3 as required by SGX.
+// crypto/rsa only generates keys with exponent 65537.
+func generateSGXKey(random io.Reader) (*rsa.PrivateKey, error) {
+	one := big.NewInt(1)
+	e := big.NewInt(3)
+	for {
+		p, err := rand.Prime(random, modulusSize*8/2)
+		if err != nil {
+			return nil, err
+		}
+		q, err := rand.Prime(random, modulusSize*8/2)
+		if err != nil {
+			return nil, err
+		}
+		if p.Cmp(q) == 0 {
+			continue
+		}
+
+		n := new(big.Int).Mul(p, q)
+		if n.BitLen() != modulusSize*8 {
+			continue
+		}
+		// e must be invertible modulo (p-1)(q-1), i.e., neither p nor q may be 1 mod 3
+		phi := new(big.Int).Mul(new(big.Int).Sub(p, one), new(big.Int).Sub(q, one))
+		d := new(big.Int).ModInverse(e, phi)
+		if d == nil {
+			continue
+		}
+
+		key := &rsa.PrivateKey{
+			PublicKey: rsa.PublicKey{N: n, E: int(e.Int64())},
+			D:         d,
+			Primes:    []*big.Int{p, q},
+		}
+		if err := key.Validate(); err != nil {
+			return nil, err
+		}
+		key.Precompute()
+		return key, nil
+	}
+}
+
+// marshalPrivateKey encodes the key as PKCS #8. If passphrase isn't empty, it is encrypted
+// with PBES2 (PBKDF2 with HMAC-SHA256 and AES-256-CBC), which can also be read by OpenSSL.
+func marshalPrivateKey(key *rsa.PrivateKey, passphrase []byte) (*pem.Block, error) {
+	der, err := x509.MarshalPKCS8PrivateKey(key)
+	if err != nil {
+		return nil, err
+	}
+	if len(passphrase) == 0 {
+		return &pem.Block{Type: pemTypePrivateKey, Bytes: der}, nil
+	}
+	if der, err = encryptPKCS8(der, passphrase); err != nil {
+		return nil, err
+	}
+	return &pem.Block{Type: pemTypeEncryptedPrivateKey, Bytes: der}, nil
+}
+
+type encryptedPrivateKeyInfo struct {
+	Algorithm     pkix.AlgorithmIdentifier
+	EncryptedData []byte
+}
+
+type pbes2Params struct {
+	KeyDerivationFunc pkix.AlgorithmIdentifier
+	EncryptionScheme  pkix.AlgorithmIdentifier
+}
+
+type pbkdf2Params struct {
+	Salt           []byte
+	IterationCount int
+	KeyLength      int                      `asn1:"optional"`
+	PRF            pkix.AlgorithmIdentifier `asn1:"optional"`
+}
+
+func encryptPKCS8(der []byte, passphrase []byte) ([]byte, error) {
+	salt := make([]byte, 16)
+	iv := make([]byte, aes.BlockSize)
+	if _, err := rand.Read(salt); err != nil {
+		return nil, err
+	}
+	if _, err := rand.Read(iv); err != nil {
+		return nil, err
+	}
+
+	key, err := pbkdf2.Key(sha256.New, string(passphrase), salt, pbkdf2Iterations, 32)
+	if err != nil {
+		return nil, err
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	padding := aes.BlockSize - len(der)%aes.BlockSize
+	data := append(bytes.Clone(der), bytes.Repeat([]byte{byte(padding)}, padding)...)
+	cipher.NewCBCEncrypter(block, iv).CryptBlocks(data, data)
+
+	kdfParams, err := asn1.Marshal(pbkdf2Params{
+		Salt:           salt,
+		IterationCount: pbkdf2Iterations,
+		PRF:            pkix.AlgorithmIdentifier{Algorithm: oidHMACWithSHA256, Parameters: asn1.NullRawValue},
+	})
+	if err != nil {
+		return nil, err
+	}
+	ivParam, err := asn1.Marshal(iv)
+	if err != nil {
+		return nil, err
+	}
+	params, err := asn1.Marshal(pbes2Params{
+		KeyDerivationFunc: pkix.AlgorithmIdentifier{Algorithm: oidPBKDF2, Parameters: asn1.RawValue{FullBytes: kdfParams}},
+		EncryptionScheme:  pkix.AlgorithmIdentifier{Algorithm: oidAES256CBC, Parameters: asn1.RawValue{FullBytes: ivParam}},
+	})
+	if err != nil {
+		return nil, err
+	}
+	return asn1.Marshal(encryptedPrivateKeyInfo{
+		Algorithm:     pkix.AlgorithmIdentifier{Algorithm: oidPBES2, Parameters: asn1.RawValue{FullBytes: params}},
+		EncryptedData: data,
+	})
+}
+
+func decryptPKCS8(der []byte, passphrase []byte) ([]byte, error) {
+	var info encryptedPrivateKeyInfo
+	if _, err := asn1.Unmarshal(der, &info); err != nil {
+		return nil, fmt.Errorf("parsing encrypted private key: %w", err)
+	}
+	if !info.Algorithm.Algorithm.Equal(oidPBES2) {
+		return nil, fmt.Errorf("unsupported key encryption algorithm: %v", info.Algorithm.Algorithm)
+	}
+	var params pbes2Params
+	if _, err := asn1.Unmarshal(info.Algorithm.Parameters.FullBytes, &params); err != nil {
+		return nil, fmt.Errorf("parsing encryption parameters: %w", err)
+	}
+	if !params.KeyDerivationFunc.Algorithm.Equal(oidPBKDF2) {
+		return nil, fmt.Errorf("unsupported key derivation function: %v", params.KeyDerivationFunc.Algorithm)
+	}
+	if !params.EncryptionScheme.Algorithm.Equal(oidAES256CBC) {
+		return nil, fmt.Errorf("unsupported encryption scheme: %v", params.EncryptionScheme.Algorithm)
+	}
+	var kdfParams pbkdf2Params
+	if _, err := asn1.Unmarshal(params.KeyDerivationFunc.Parameters.FullBytes, &kdfParams); err != nil {
+		return nil, fmt.Errorf("parsing key derivation parameters: %w", err)
+	}
+	if !kdfParams.PRF.Algorithm.Equal(oidHMACWithSHA256) {
+		return nil, fmt.Errorf("unsupported key derivation PRF: %v", kdfParams.PRF.Algorithm)
+	}
+	var iv []byte
+	if _, err := asn1.Unmarshal(params.EncryptionScheme.Parameters.FullBytes, &iv); err != nil {
+		return nil, fmt.Errorf("parsing IV: %w", err)
+	}
+
+	data := info.EncryptedData
+	if len(iv) != aes.BlockSize || len(data) == 0 || len(data)%aes.BlockSize != 0 {
+		return nil, errors.New("invalid encrypted private key")
+	}
+	key, err := pbkdf2.Key(sha256.New, string(passphrase), kdfParams.Salt, kdfParams.IterationCount, 32)
+	if err != nil {
+		return nil, err
+	}
+	block, err := aes.NewCipher(key)
+	if err != nil {
+		return nil, err
+	}
+	data = bytes.Clone(data)
+	cipher.NewCBCDecrypter(block, iv).CryptBlocks(data, data)
+
+	padding := int(data[len(data)-1])
+	if padding == 0 || padding > aes.BlockSize || !bytes.Equal(data[len(data)-padding:], bytes.Repeat([]byte{byte(padding)}, padding)) {
+		return nil, ErrWrongPassphrase
+	}
+	return data[:len(data)-padding], nil
+}
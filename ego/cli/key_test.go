@@ -0,0 +1,185 @@
+// Copyright (c) Edgeless Systems GmbH.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package cli
+
+import (
+	"crypto/rand"
+	"encoding/pem"
+	"errors"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+
+	"github.com/edgelesssys/ego/ego/internal/launch"
+	"github.com/spf13/afero"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestGenerateSGXKey(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	key, err := generateSGXKey(rand.Reader)
+	require.NoError(err)
+	assert.Equal(3, key.E)
+	assert.Equal(modulusSize*8, key.N.BitLen())
+	assert.NoError(key.Validate())
+}
+
+func TestGenerateKey(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	fs := afero.Afero{Fs: afero.NewMemMapFs()}
+	cli := NewCli(nil, fs)
+
+	require.NoError(cli.GenerateKey("private.pem", "public.pem", nil))
+	info, err := fs.Stat("private.pem")
+	require.NoError(err)
+	assert.Equal(os.FileMode(0o600), info.Mode().Perm())
+
+	key, err := cli.readPrivateKey("private.pem")
+	require.NoError(err)
+	assert.Equal(3, key.E)
+	pub, err := cli.readPublicKey("public.pem")
+	require.NoError(err)
+	assert.True(key.PublicKey.Equal(pub))
+
+	// SignerID can be derived from the private key
+	expected, err := cli.SigneridByKey("public.pem")
+	require.NoError(err)
+	signerID, err := cli.SigneridByKey("private.pem")
+	require.NoError(err)
+	assert.Equal(expected, signerID)
+
+	// existing keys aren't overwritten
+	assert.ErrorIs(cli.GenerateKey("private.pem", "", nil), os.ErrExist)
+
+	// public key can be written again
+	require.NoError(fs.Remove("public.pem"))
+	require.NoError(cli.WritePublicKey("private.pem", "public.pem"))
+	signerID, err = cli.SigneridByKey("public.pem")
+	require.NoError(err)
+	assert.Equal(expected, signerID)
+}
+
+func TestGenerateEncryptedKey(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	fs := afero.Afero{Fs: afero.NewMemMapFs()}
+	cli := NewCli(nil, fs)
+
+	require.NoError(cli.GenerateKey("private.pem", "public.pem", []byte("secret")))
+	encrypted, err := cli.isEncryptedKey("private.pem")
+	require.NoError(err)
+	assert.True(encrypted)
+	pub, err := cli.readPublicKey("public.pem")
+	require.NoError(err)
+
+	_, err = cli.readPrivateKey("private.pem")
+	assert.ErrorIs(err, ErrPassphraseRequired)
+
+	cli.SetPassphraseFunc(func() ([]byte, error) { return nil, nil })
+	_, err = cli.readPrivateKey("private.pem")
+	assert.ErrorIs(err, ErrPassphraseRequired)
+
+	cli.SetPassphraseFunc(func() ([]byte, error) { return nil, errors.New("failed") })
+	_, err = cli.readPrivateKey("private.pem")
+	assert.Error(err)
+
+	cli.SetPassphraseFunc(func() ([]byte, error) { return []byte("wrong"), nil })
+	_, err = cli.readPrivateKey("private.pem")
+	assert.ErrorIs(err, ErrWrongPassphrase)
+
+	cli.SetPassphraseFunc(func() ([]byte, error) { return []byte("secret"), nil })
+	key, err := cli.readPrivateKey("private.pem")
+	require.NoError(err)
+	assert.True(key.PublicKey.Equal(pub))
+
+	require.NoError(fs.Remove("public.pem"))
+	require.NoError(cli.WritePublicKey("private.pem", "public.pem"))
+	pub, err = cli.readPublicKey("public.pem")
+	require.NoError(err)
+	assert.True(key.PublicKey.Equal(pub))
+}
+
+// TestEncryptedKeyOpenSSL checks that encrypted keys are compatible with OpenSSL.
+func TestEncryptedKeyOpenSSL(t *testing.T) {
+	if _, err := exec.LookPath("openssl"); err != nil {
+		t.Skip("openssl not found, cannot run this test.")
+	}
+
+	assert := assert.New(t)
+	require := require.New(t)
+
+	cli := NewCli(launch.OsRunner{}, afero.NewOsFs())
+	cli.SetPassphraseFunc(func() ([]byte, error) { return []byte("secret"), nil })
+	dir := t.TempDir()
+
+	// OpenSSL can read our key
+	privKeyFile := filepath.Join(dir, "private.pem")
+	pubKeyFile := filepath.Join(dir, "public.pem")
+	require.NoError(cli.GenerateKey(privKeyFile, pubKeyFile, []byte("secret")))
+	out, err := exec.Command("openssl", "pkey", "-in", privKeyFile, "-passin", "pass:secret", "-pubout").Output()
+	require.NoError(err)
+	expected, err := os.ReadFile(pubKeyFile)
+	require.NoError(err)
+	assert.Equal(string(expected), string(out))
+
+	// we can read OpenSSL's key
+	opensslKeyFile := filepath.Join(dir, "openssl.pem")
+	out, err = exec.Command("sh", "-c", "openssl genrsa -3 3072 | openssl pkcs8 -topk8 -v2 aes-256-cbc -v2prf hmacWithSHA256 -passout pass:secret -out "+opensslKeyFile).CombinedOutput()
+	require.NoError(err, string(out))
+	data, err := os.ReadFile(opensslKeyFile)
+	require.NoError(err)
+	block, _ := pem.Decode(data)
+	require.NotNil(block)
+	assert.Equal(pemTypeEncryptedPrivateKey, block.Type)
+	key, err := cli.readPrivateKey(opensslKeyFile)
+	require.NoError(err)
+	assert.Equal(3, key.E)
+}
+
+func TestSignEncryptedKey(t *testing.T) {
+	if _, err := exec.LookPath("objcopy"); err != nil {
+		t.Skip("objcopy not found, cannot run this test.")
+	}
+
+	assert := assert.New(t)
+	require := require.New(t)
+
+	fs := afero.Afero{Fs: afero.NewMemMapFs()}
+	runner := signRunner{
+		fs:        fs,
+		sigstruct: newTestSigstruct(),
+		expectedConfig: `ProductID=0
+SecurityVersion=0
+Debug=0
+NumHeapPages=131072
+NumStackPages=1024
+NumTCS=32
+`,
+	}
+	cli := NewCli(&runner, fs)
+
+	require.NoError(fs.WriteFile("exefile", addSectionToBytes(t, elfUnsigned, oeinfoSectionName, make([]byte, 4096)), 0))
+	require.NoError(fs.WriteFile("enclave.json", []byte(`{"exe":"exefile", "key":"keyfile", "heapSize":512}`), 0))
+	require.NoError(cli.GenerateKey("keyfile", "public.pem", []byte("secret")))
+	expected, err := cli.SigneridByKey("public.pem")
+	require.NoError(err)
+
+	assert.ErrorIs(cli.Sign(""), ErrPassphraseRequired)
+
+	cli.SetPassphraseFunc(func() ([]byte, error) { return []byte("secret"), nil })
+	require.NoError(cli.Sign(""))
+	signerID, err := cli.Signerid("exefile")
+	require.NoError(err)
+	assert.Equal(expected, signerID)
+}
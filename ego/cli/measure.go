@@ -0,0 +1,427 @@
+// Copyright (c) Edgeless Systems GmbH.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package cli
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"debug/elf"
+	"encoding/binary"
+	"encoding/hex"
+	"errors"
+	"fmt"
+	"hash"
+	"math/bits"
+	"os"
+	"path/filepath"
+
+	"github.com/edgelesssys/ego/ego/config"
+	"github.com/spf13/afero"
+)
+
+const (
+	pageSize      = 4096
+	sigstructSize = 1808
+
+	// Offsets of the image info in the enclave properties.
+	offsetImageInfo = 88
+
+	sgxFlagsMode64Bit = 0x4
+
+	// SECINFO flags
+	secinfoR   = 0x1
+	secinfoW   = 0x2
+	secinfoX   = 0x4
+	secinfoTCS = 0x100
+	secinfoREG = 0x200
+
+	// The control pages of each TCS are the TCS, 2 SSA, and a guard page.
+	// They are followed by the TLS pages, the thread data, and another guard page.
+	numControlPages    = 4
+	numThreadDataPages = 1
+)
+
+// Measure computes the UniqueID (MRENCLAVE) that 'ego sign' would produce for an executable without signing it.
+// The filename is interpreted like in Sign. Neither the executable nor the config are modified.
+func (c *Cli) Measure(filename string) (string, error) {
+	conf, err := c.readConfig(filename)
+	if err != nil {
+		return "", err
+	}
+
+	buildInfo, symbols, err := c.getMetadataFromELF(conf.Exe)
+	if err != nil {
+		return "", fmt.Errorf("getting ELF metadata: %w", err)
+	}
+	if err := checkUnsupportedImports(symbols); err != nil {
+		return "", err
+	}
+	if err := checkHeapMode(symbols, conf.HeapSize); err != nil {
+		return "", err
+	}
+
+	// embed enclave.json into a copy of the executable like Sign does
+	jsonData, err := conf.Payload()
+	if err != nil {
+		return "", err
+	}
+	exe, err := c.fs.ReadFile(conf.Exe)
+	if err != nil {
+		return "", err
+	}
+	payload, err := withEmbeddedPayload(exe, jsonData)
+	if err != nil {
+		return "", err
+	}
+
+	runtime, err := c.fs.ReadFile(c.getEgoEnclavePath(buildInfo))
+	if err != nil {
+		return "", fmt.Errorf("reading EGo runtime: %w", err)
+	}
+
+	mrenclave, err := measureEnclave(runtime, payload, propertiesFromConfig(conf))
+	if err != nil {
+		return "", err
+	}
+	return hex.EncodeToString(mrenclave), nil
+}
+
+// readConfig gets the config like Sign, but doesn't generate enclave.json if it doesn't exist.
+func (c *Cli) readConfig(filename string) (*config.Config, error) {
+	if filename == "" {
+		return c.readConfigJSONtoStruct(defaultConfigFilename)
+	}
+	if filepath.Ext(filename) == ".json" {
+		return c.readConfigJSONtoStruct(filename)
+	}
+	conf, err := c.readConfigJSONtoStruct(defaultConfigFilename)
+	if errors.Is(err, errConfigDoesNotExist) && c.profile == "" {
+		return defaultConfig(filename), nil
+	}
+	if err != nil {
+		return nil, err
+	}
+	if conf.Exe != filename {
+		return nil, fmt.Errorf("provided path to executable does not match the one in %s", defaultConfigFilename)
+	}
+	return conf, nil
+}
+
+// withEmbeddedPayload returns a copy of the executable with the embedded payload.
+func withEmbeddedPayload(exe []byte, jsonData []byte) ([]byte, error) {
+	fs := afero.Afero{Fs: afero.NewMemMapFs()}
+	if err := fs.WriteFile("exe", exe, 0o644); err != nil {
+		return nil, err
+	}
+	f, err := fs.OpenFile("exe", os.O_RDWR, 0)
+	if err != nil {
+		return nil, err
+	}
+	defer f.Close()
+	if err := embedPayload(f, jsonData); err != nil {
+		return nil, err
+	}
+	return fs.ReadFile("exe")
+}
+
+// enclaveProperties are the properties that 'ego sign' passes to oesign.
+type enclaveProperties struct {
+	productID       uint16
+	securityVersion uint16
+	debug           bool
+	numHeapPages    uint64
+	numStackPages   uint64
+	numTCS          uint64
+	executableHeap  bool
+}
+
+func propertiesFromConfig(conf *config.Config) enclaveProperties {
+	return enclaveProperties{
+		productID:       uint16(conf.ProductID),
+		securityVersion: uint16(conf.SecurityVersion),
+		debug:           conf.Debug,
+		numHeapPages:    uint64(conf.HeapSize) * 1024 * 1024 / pageSize,
+		numStackPages:   uint64(conf.GetStackSize()) * 1024 * 1024 / pageSize,
+		numTCS:          uint64(conf.GetNumTCS()),
+		executableHeap:  conf.ExecutableHeap,
+	}
+}
+
+// measureEnclave simulates how oesign loads the EGo runtime with the payload and returns the MRENCLAVE.
+//
+// The enclave has the following layout:
+//
+//	runtime image | runtime relocations | payload image | payload relocations | payload data | heap | TCS 0 | ... | TCS n
+//
+// Each TCS consists of: guard page | stack | guard page | TCS | 2 SSA | guard page | TLS | thread data | guard page
+func measureEnclave(runtime, payload []byte, props enclaveProperties) ([]byte, error) {
+	runtimeImage, err := loadEnclaveImage(runtime)
+	if err != nil {
+		return nil, fmt.Errorf("loading EGo runtime: %w", err)
+	}
+	payloadImage, err := loadEnclaveImage(payload)
+	if err != nil {
+		return nil, fmt.Errorf("loading payload: %w", err)
+	}
+
+	payloadSize, payloadOffset, _, err := getPayloadInformation(bytes.NewReader(payload))
+	if err != nil {
+		return nil, err
+	}
+	if payloadOffset < 0 || uint64(payloadOffset)+payloadSize > uint64(len(payload)) {
+		return nil, errors.New("invalid payload location")
+	}
+	payloadData := payload[payloadOffset : uint64(payloadOffset)+payloadSize]
+
+	// calculate the layout
+	payloadRVA := uint64(len(runtimeImage.memory))
+	payloadDataRVA := payloadRVA + uint64(len(payloadImage.memory))
+	heapRVA := payloadDataRVA + roundUpToPage(payloadSize)
+	tlsPageCount := runtimeImage.tlsPageCount
+	tcsSize := (1 + props.numStackPages + 1 + numControlPages + tlsPageCount + numThreadDataPages + 1) * pageSize
+	loadedSize := heapRVA + props.numHeapPages*pageSize + props.numTCS*tcsSize
+	enclaveSize := uint64(1) << bits.Len64(loadedSize-1)
+
+	// patch the images like the loader does
+	payloadImage.rva = payloadRVA
+	runtimeImage.patchProperties(props, heapRVA, enclaveSize)
+	payloadImage.patchProperties(props, heapRVA, enclaveSize)
+
+	m := newMeasurement()
+	m.ecreate(enclaveSize)
+
+	runtimeImage.addPages(m)
+	payloadImage.addPages(m)
+	m.addFilledPages(payloadDataRVA, payloadData, secinfoREG|secinfoR)
+
+	heapFlags := uint64(secinfoREG | secinfoR | secinfoW)
+	if props.executableHeap {
+		heapFlags |= secinfoX
+	}
+	zeroPage := make([]byte, pageSize)
+	for i := uint64(0); i < props.numHeapPages; i++ {
+		m.addPage(heapRVA+i*pageSize, zeroPage, heapFlags)
+	}
+
+	stack := bytes.Repeat([]byte{0xcc}, int(props.numStackPages*pageSize))
+	rva := heapRVA + props.numHeapPages*pageSize
+	for i := uint64(0); i < props.numTCS; i++ {
+		// guard page, stack, guard page
+		rva += pageSize
+		m.addFilledPages(rva, stack, secinfoREG|secinfoR|secinfoW)
+		rva += uint64(len(stack)) + pageSize
+
+		// the FS and GS segments point to the thread data
+		threadDataRVA := rva + (numControlPages+tlsPageCount)*pageSize
+		tcs := make([]byte, pageSize)
+		binary.LittleEndian.PutUint64(tcs[16:], rva+pageSize) // OSSA
+		binary.LittleEndian.PutUint32(tcs[28:], 2)            // NSSA
+		binary.LittleEndian.PutUint64(tcs[32:], runtimeImage.entry)
+		binary.LittleEndian.PutUint64(tcs[48:], threadDataRVA) // OFSBASE
+		binary.LittleEndian.PutUint64(tcs[56:], threadDataRVA) // OGSBASE
+		binary.LittleEndian.PutUint32(tcs[64:], 0xFFFFFFFF)    // FSLIMIT
+		binary.LittleEndian.PutUint32(tcs[68:], 0xFFFFFFFF)    // GSLIMIT
+		m.addPage(rva, tcs, secinfoTCS)
+		rva += pageSize
+
+		// SSA pages, guard page, TLS and thread data, guard page
+		m.addFilledPages(rva, make([]byte, 2*pageSize), secinfoREG|secinfoR|secinfoW)
+		rva += 3 * pageSize
+		threadData := make([]byte, (tlsPageCount+numThreadDataPages)*pageSize)
+		m.addFilledPages(rva, threadData, secinfoREG|secinfoR|secinfoW)
+		rva += uint64(len(threadData)) + pageSize
+	}
+
+	return m.hash.Sum(nil), nil
+}
+
+// enclaveImage is an ELF image as it is loaded into the enclave.
+type enclaveImage struct {
+	memory       []byte // the loaded segments followed by the relocations
+	segments     []imageSegment
+	entry        uint64
+	oeinfoAddr   uint64
+	oeinfoSize   uint64
+	relocAddr    uint64
+	relocSize    uint64
+	tlsPageCount uint64
+	rva          uint64 // the address of the image relative to the enclave base
+}
+
+type imageSegment struct {
+	addr  uint64
+	size  uint64
+	flags uint64
+}
+
+func loadEnclaveImage(data []byte) (*enclaveImage, error) {
+	elfFile, err := elf.NewFile(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	if elfFile.Type != elf.ET_DYN {
+		return nil, errors.New("not a position-independent executable")
+	}
+
+	image := &enclaveImage{entry: elfFile.Entry}
+	var imageSize uint64
+	for _, prog := range elfFile.Progs {
+		switch prog.Type {
+		case elf.PT_LOAD:
+			segment := imageSegment{addr: prog.Vaddr, size: prog.Memsz, flags: secinfoREG}
+			if prog.Flags&elf.PF_R != 0 {
+				segment.flags |= secinfoR
+			}
+			if prog.Flags&elf.PF_W != 0 {
+				segment.flags |= secinfoW
+			}
+			if prog.Flags&elf.PF_X != 0 {
+				segment.flags |= secinfoX
+			}
+			if len(image.segments) > 0 {
+				prev := image.segments[len(image.segments)-1]
+				if roundDownToPage(segment.addr) < roundUpToPage(prev.addr+prev.size) {
+					return nil, errors.New("segments must not share pages")
+				}
+			}
+			image.segments = append(image.segments, segment)
+			imageSize = max(imageSize, roundUpToPage(prog.Vaddr+prog.Memsz))
+		case elf.PT_TLS:
+			tlsSize := prog.Memsz
+			if prog.Align > 1 {
+				tlsSize = (tlsSize + prog.Align - 1) / prog.Align * prog.Align
+			}
+			image.tlsPageCount = roundUpToPage(tlsSize) / pageSize
+		}
+	}
+	if len(image.segments) == 0 {
+		return nil, errors.New("no loadable segments")
+	}
+
+	oeinfo := elfFile.Section(oeinfoSectionName)
+	if oeinfo == nil {
+		return nil, ErrNoOEInfo
+	}
+	if oeinfo.Size < offsetSigstruct+sigstructSize {
+		return nil, errors.New("invalid .oeinfo section")
+	}
+	image.oeinfoAddr = oeinfo.Addr
+	image.oeinfoSize = oeinfo.Size
+
+	var relocs []byte
+	if rela := elfFile.Section(".rela.dyn"); rela != nil {
+		if relocs, err = rela.Data(); err != nil {
+			return nil, fmt.Errorf("reading relocations: %w", err)
+		}
+	}
+	image.relocAddr = imageSize
+	image.relocSize = uint64(len(relocs))
+
+	image.memory = make([]byte, imageSize+roundUpToPage(image.relocSize))
+	for _, prog := range elfFile.Progs {
+		if prog.Type != elf.PT_LOAD || prog.Filesz == 0 {
+			continue
+		}
+		if prog.Off+prog.Filesz > uint64(len(data)) || prog.Filesz > prog.Memsz {
+			return nil, errors.New("invalid segment")
+		}
+		copy(image.memory[prog.Vaddr:], data[prog.Off:prog.Off+prog.Filesz])
+	}
+	copy(image.memory[image.relocAddr:], relocs)
+
+	return image, nil
+}
+
+// patchProperties writes the enclave properties and the image info to the .oeinfo section of the loaded image.
+// The SIGSTRUCT isn't part of the measurement.
+func (i *enclaveImage) patchProperties(props enclaveProperties, heapRVA, enclaveSize uint64) {
+	oeinfo := i.memory[i.oeinfoAddr : i.oeinfoAddr+i.oeinfoSize]
+
+	binary.LittleEndian.PutUint64(oeinfo[offsetNumHeapPages:], props.numHeapPages)
+	binary.LittleEndian.PutUint64(oeinfo[offsetNumStackPages:], props.numStackPages)
+	binary.LittleEndian.PutUint64(oeinfo[offsetNumTCS:], props.numTCS)
+	binary.LittleEndian.PutUint16(oeinfo[offsetProductID:], props.productID)
+	binary.LittleEndian.PutUint16(oeinfo[offsetSecurityVersion:], props.securityVersion)
+	attributes := uint64(sgxFlagsMode64Bit)
+	if props.debug {
+		attributes |= sgxFlagsDebug
+	}
+	binary.LittleEndian.PutUint64(oeinfo[offsetAttributes:], attributes)
+
+	imageInfo := oeinfo[offsetImageInfo:]
+	binary.LittleEndian.PutUint64(imageInfo[0:], i.rva+i.oeinfoAddr)
+	binary.LittleEndian.PutUint64(imageInfo[8:], i.oeinfoSize)
+	binary.LittleEndian.PutUint64(imageInfo[16:], i.rva+i.relocAddr)
+	binary.LittleEndian.PutUint64(imageInfo[24:], i.relocSize)
+	binary.LittleEndian.PutUint64(imageInfo[32:], heapRVA)
+	binary.LittleEndian.PutUint64(imageInfo[40:], enclaveSize)
+
+	clear(oeinfo[offsetSigstruct : offsetSigstruct+sigstructSize])
+}
+
+// addPages adds the segments and the relocations of the image to the measurement.
+func (i *enclaveImage) addPages(m *measurement) {
+	rva := i.rva
+	for _, segment := range i.segments {
+		for page := roundDownToPage(segment.addr); page < segment.addr+segment.size; page += pageSize {
+			m.addPage(rva+page, i.memory[page:page+pageSize], segment.flags)
+		}
+	}
+	m.addFilledPages(rva+i.relocAddr, i.memory[i.relocAddr:], secinfoREG|secinfoR)
+}
+
+// measurement computes MRENCLAVE like the SGX instructions ECREATE, EADD, and EEXTEND.
+type measurement struct {
+	hash hash.Hash
+}
+
+func newMeasurement() *measurement {
+	return &measurement{hash: sha256.New()}
+}
+
+func (m *measurement) ecreate(enclaveSize uint64) {
+	block := make([]byte, 64)
+	copy(block, "ECREATE\x00")
+	binary.LittleEndian.PutUint32(block[8:], 1) // SSAFRAMESIZE
+	binary.LittleEndian.PutUint64(block[12:], enclaveSize)
+	m.hash.Write(block)
+}
+
+// addPage simulates EADD of the page followed by EEXTEND of its content.
+func (m *measurement) addPage(rva uint64, page []byte, flags uint64) {
+	block := make([]byte, 64)
+	copy(block, "EADD\x00\x00\x00\x00")
+	binary.LittleEndian.PutUint64(block[8:], rva)
+	binary.LittleEndian.PutUint64(block[16:], flags)
+	m.hash.Write(block)
+
+	for offset := uint64(0); offset < pageSize; offset += 256 {
+		clear(block)
+		copy(block, "EEXTEND\x00")
+		binary.LittleEndian.PutUint64(block[8:], rva+offset)
+		m.hash.Write(block)
+		m.hash.Write(page[offset : offset+256])
+	}
+}
+
+// addFilledPages adds the data as consecutive pages. The last page is padded with zeros.
+func (m *measurement) addFilledPages(rva uint64, data []byte, flags uint64) {
+	page := make([]byte, pageSize)
+	for offset := 0; offset < len(data); offset += pageSize {
+		clear(page)
+		copy(page, data[offset:])
+		m.addPage(rva+uint64(offset), page, flags)
+	}
+}
+
+func roundUpToPage(x uint64) uint64 {
+	return (x + pageSize - 1) &^ (pageSize - 1)
+}
+
+func roundDownToPage(x uint64) uint64 {
+	return x &^ (pageSize - 1)
+}
@@ -0,0 +1,211 @@
+// Copyright (c) Edgeless Systems GmbH.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package cli
+
+import (
+	"bytes"
+	"encoding/binary"
+	"encoding/hex"
+	"os"
+	"os/exec"
+	"path/filepath"
+	"testing"
+
+	"github.com/edgelesssys/ego/ego/internal/launch"
+	"github.com/spf13/afero"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestMeasurement(t *testing.T) {
+	m := newMeasurement()
+	m.ecreate(0x10000)
+	m.addPage(0x1000, bytes.Repeat([]byte{0xAB}, pageSize), secinfoREG|secinfoR)
+	assert.Equal(t, "7ff4570eff81d6bfa4857cd77621dec4c229e63474bc1c11251cabeb612cfca4", hex.EncodeToString(m.hash.Sum(nil)))
+}
+
+func TestMeasureEnclave(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	runtime := newTestEnclaveELF()
+	payload, err := withEmbeddedPayload(newTestEnclaveELF(), []byte(`{"exe":"foo"}`))
+	require.NoError(err)
+	props := enclaveProperties{
+		productID:       1,
+		securityVersion: 2,
+		debug:           true,
+		numHeapPages:    16,
+		numStackPages:   4,
+		numTCS:          2,
+	}
+
+	expected, err := measureEnclave(runtime, payload, props)
+	require.NoError(err)
+	assert.Len(expected, 32)
+
+	// measurement is deterministic and doesn't modify the input
+	mrenclave, err := measureEnclave(runtime, payload, props)
+	require.NoError(err)
+	assert.Equal(expected, mrenclave)
+	assert.Equal(newTestEnclaveELF(), runtime)
+
+	// signing doesn't change the measurement
+	signedPayload := bytes.Clone(payload)
+	copy(signedPayload[testOEInfoAddr+offsetSigstruct:], bytes.Repeat([]byte{2}, sigstructSize))
+	mrenclave, err = measureEnclave(runtime, signedPayload, props)
+	require.NoError(err)
+	assert.Equal(expected, mrenclave)
+
+	otherPayload, err := withEmbeddedPayload(newTestEnclaveELF(), []byte(`{"exe":"bar"}`))
+	require.NoError(err)
+	mrenclave, err = measureEnclave(runtime, otherPayload, props)
+	require.NoError(err)
+	assert.NotEqual(expected, mrenclave)
+
+	_, err = measureEnclave([]byte("foo"), payload, props)
+	assert.Error(err)
+	_, err = measureEnclave(runtime, elfUnsigned, props)
+	assert.Error(err)
+}
+
+func TestMeasureEnclaveProperties(t *testing.T) {
+	runtime := newTestEnclaveELF()
+	payload, err := withEmbeddedPayload(newTestEnclaveELF(), []byte(`{"exe":"foo"}`))
+	require.NoError(t, err)
+	props := enclaveProperties{
+		productID:       1,
+		securityVersion: 2,
+		debug:           true,
+		numHeapPages:    16,
+		numStackPages:   4,
+		numTCS:          2,
+	}
+	expected, err := measureEnclave(runtime, payload, props)
+	require.NoError(t, err)
+
+	testCases := map[string]func(*enclaveProperties){
+		"product id":       func(p *enclaveProperties) { p.productID++ },
+		"security version": func(p *enclaveProperties) { p.securityVersion++ },
+		"debug":            func(p *enclaveProperties) { p.debug = false },
+		"heap pages":       func(p *enclaveProperties) { p.numHeapPages++ },
+		"stack pages":      func(p *enclaveProperties) { p.numStackPages++ },
+		"tcs":              func(p *enclaveProperties) { p.numTCS++ },
+		"executable heap":  func(p *enclaveProperties) { p.executableHeap = true },
+	}
+
+	for name, modify := range testCases {
+		t.Run(name, func(t *testing.T) {
+			otherProps := props
+			modify(&otherProps)
+			mrenclave, err := measureEnclave(runtime, payload, otherProps)
+			require.NoError(t, err)
+			assert.NotEqual(t, expected, mrenclave)
+		})
+	}
+}
+
+// TestMeasureOesign compares the measurement with the UniqueID of an executable signed by oesign.
+// It requires an EGo installation.
+func TestMeasureOesign(t *testing.T) {
+	egoPath, err := exec.LookPath("ego")
+	if err != nil {
+		t.Skip("ego not found, cannot run this test.")
+	}
+	egoPath, err = filepath.EvalSymlinks(egoPath)
+	require.NoError(t, err)
+
+	assert := assert.New(t)
+	require := require.New(t)
+
+	cli := NewCli(launch.OsRunner{}, afero.NewOsFs())
+	cli.egoPath = filepath.Dir(filepath.Dir(egoPath))
+
+	dir := t.TempDir()
+	exe := filepath.Join(dir, "enclave")
+	require.NoError(os.WriteFile(exe, elfUnsigned, 0o755))
+	const conf = `{"exe":"enclave","key":"private.pem","debug":true,"heapSize":512,"productID":3,"securityVersion":4,"env":[{"name":"FOO","value":"bar"}]}`
+	confFile := filepath.Join(dir, "enclave.json")
+	require.NoError(os.WriteFile(confFile, []byte(conf), 0o644))
+
+	measured, err := cli.Measure(confFile)
+	require.NoError(err)
+
+	require.NoError(cli.Sign(confFile))
+	signed, err := cli.Uniqueid(exe)
+	require.NoError(err)
+	assert.Equal(signed, measured)
+
+	// measuring the signed executable doesn't depend on the existing payload or SIGSTRUCT
+	measured, err = cli.Measure(confFile)
+	require.NoError(err)
+	assert.Equal(signed, measured)
+}
+
+const testOEInfoAddr = 0x1000
+
+// newTestEnclaveELF creates a minimal position-independent ELF file with an .oeinfo section.
+func newTestEnclaveELF() []byte {
+	const (
+		phoff     = 64
+		phnum     = 3
+		shstrtab  = 0x2000
+		shoff     = 0x2040
+		shnum     = 3
+		oeinfoLen = 0x1000
+	)
+	data := make([]byte, shoff+shnum*64)
+	le := binary.LittleEndian
+
+	// ELF header
+	copy(data, []byte{0x7f, 'E', 'L', 'F', 2, 1, 1})
+	le.PutUint16(data[16:], 3)  // ET_DYN
+	le.PutUint16(data[18:], 62) // EM_X86_64
+	le.PutUint32(data[20:], 1)
+	le.PutUint64(data[24:], 0x100) // entry
+	le.PutUint64(data[32:], phoff)
+	le.PutUint64(data[40:], shoff)
+	le.PutUint16(data[52:], 64)
+	le.PutUint16(data[54:], 56)
+	le.PutUint16(data[56:], phnum)
+	le.PutUint16(data[58:], 64)
+	le.PutUint16(data[60:], shnum)
+	le.PutUint16(data[62:], 2) // shstrndx
+
+	// program headers: code, data with .oeinfo and bss, TLS
+	progs := [phnum][7]uint64{
+		// type | flags<<32, offset, vaddr, paddr, filesz, memsz, align
+		{1 | 5<<32, 0, 0, 0, 0x1000, 0x1000, 0x1000},
+		{1 | 6<<32, testOEInfoAddr, testOEInfoAddr, testOEInfoAddr, oeinfoLen, 0x2000, 0x1000},
+		{7 | 4<<32, testOEInfoAddr, testOEInfoAddr, testOEInfoAddr, 0, 16, 8},
+	}
+	for i, prog := range progs {
+		for j, v := range prog {
+			le.PutUint64(data[phoff+i*56+j*8:], v)
+		}
+	}
+
+	// some code
+	copy(data[0x100:], bytes.Repeat([]byte{0x90}, 16))
+
+	// section headers: null, .oeinfo, .shstrtab
+	copy(data[shstrtab:], "\x00.oeinfo\x00.shstrtab\x00")
+	section := func(i int, name, typ uint32, flags, addr, offset, size uint64) {
+		sh := data[shoff+i*64:]
+		le.PutUint32(sh[0:], name)
+		le.PutUint32(sh[4:], typ)
+		le.PutUint64(sh[8:], flags)
+		le.PutUint64(sh[16:], addr)
+		le.PutUint64(sh[24:], offset)
+		le.PutUint64(sh[32:], size)
+		le.PutUint64(sh[48:], 1)
+	}
+	section(1, 1, 1, 3, testOEInfoAddr, testOEInfoAddr, oeinfoLen) // PROGBITS, WRITE|ALLOC
+	section(2, 9, 3, 0, 0, shstrtab, 19)                           // STRTAB
+
+	return data
+}
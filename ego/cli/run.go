@@ -7,6 +7,7 @@
 package cli
 
 import (
+	"os"
 	"path/filepath"
 	"runtime/debug"
 	"slices"
@@ -20,6 +21,7 @@ func (c *Cli) Run(filename string, args []string) (int, error) {
 	if err != nil {
 		return 1, err
 	}
+	c.warnUnsupported(os.Stderr, filename, c.getAllowlistForExecutable(filename))
 	return launch.RunEnclave(filename, args, c.getEgoHostPath(), c.getEgoEnclavePath(buildInfo), c.runner)
 }
 
@@ -29,6 +31,7 @@ func (c *Cli) Marblerun(filename string) (int, error) {
 	if err != nil {
 		return 1, err
 	}
+	c.warnUnsupported(os.Stderr, filename, c.getAllowlistForExecutable(filename))
 	return launch.RunEnclaveMarblerun(filename, c.getEgoHostPath(), c.getEgoEnclavePath(buildInfo), c.runner)
 }
 
@@ -37,10 +40,13 @@ func (c *Cli) getEgoHostPath() string {
 }
 
 func (c *Cli) getEgoEnclavePath(buildInfo *debug.BuildInfo) string {
-	egoEnclave := "ego-enclave"
+	return filepath.Join(c.egoPath, "share", getEgoEnclaveName(buildInfo))
+}
+
+func getEgoEnclaveName(buildInfo *debug.BuildInfo) string {
 	if slices.ContainsFunc(buildInfo.Settings, func(bs debug.BuildSetting) bool { return bs.Key == "GOFIPS140" }) {
 		// if app is built with FIPS enabled, also use the FIPS-enabled EGo runtime
-		egoEnclave = "ego-enclave-fips140"
+		return "ego-enclave-fips140"
 	}
-	return filepath.Join(c.egoPath, "share", egoEnclave)
+	return "ego-enclave"
 }
@@ -13,6 +13,7 @@ import (
 	"os"
 	"os/exec"
 	"path/filepath"
+	"runtime"
 	"strconv"
 
 	"github.com/edgelesssys/ego/ego/config"
@@ -31,19 +32,62 @@ var ErrNoOEInfo = errors.New("could not find .oeinfo section")
 var errConfigDoesNotExist = errors.New("enclave config file not found")
 
 func (c *Cli) signWithJSON(conf *config.Config) error {
+	// oesign can't read encrypted keys, so sign with the decrypted key in Go
+	if encrypted, err := c.isEncryptedKey(conf.Key); err != nil {
+		return err
+	} else if encrypted {
+		key, err := c.readPrivateKey(conf.Key)
+		if err != nil {
+			return err
+		}
+		return c.signWithSigner(conf, key)
+	}
+
+	enclavePath, confFile, err := c.prepareSign(conf)
+	if err != nil {
+		return err
+	}
+	defer func() { _ = c.fs.Remove(confFile) }()
+
+	// create public and private key if private key does not exist
+	if err := c.createDefaultKeypair(conf.Key); err != nil {
+		return err
+	}
+	c.warnIfKeyPermissionsLoose(conf.Key)
+
+	return c.oesign(enclavePath, confFile, conf.Key, conf.Exe)
+}
+
+// prepareSign embeds the config into the executable and writes the config file for oesign.
+// It returns the path of the EGo runtime and of the config file, which must be removed by the caller.
+func (c *Cli) prepareSign(conf *config.Config) (enclavePath string, confFile string, reterr error) {
 	buildInfo, symbols, err := c.getMetadataFromELF(conf.Exe)
 	if err != nil {
-		return fmt.Errorf("getting ELF metadata: %w", err)
+		return "", "", fmt.Errorf("getting ELF metadata: %w", err)
 	}
 
 	// First, check if the executable does not contain unsupported imports / symbols.
 	if err := checkUnsupportedImports(symbols); err != nil {
-		return err
+		return "", "", err
+	}
+
+	// Warn about features that are unsupported in enclaves.
+	c.warnUnsupported(os.Stdout, conf.Exe, conf.AllowUnsupported)
+
+	for _, diag := range conf.Diagnose() {
+		if diag.Severity == config.SeverityWarning {
+			fmt.Println("WARNING:", diag.Path+":", diag.Message)
+		}
+	}
+
+	// Warn if the app may need more threads than the enclave provides.
+	for _, warning := range checkConcurrency(conf, runtime.NumCPU()) {
+		fmt.Println("WARNING:", warning)
 	}
 
 	// Check that heapSize is in the supported range of the heap mode the binary was built with.
 	if err := checkHeapMode(symbols, conf.HeapSize); err != nil {
-		return err
+		return "", "", err
 	}
 
 	// write temp .conf file
@@ -61,8 +105,8 @@ func (c *Cli) signWithJSON(conf *config.Config) error {
 	heapPages := conf.HeapSize * 1024 * 1024 / 4096
 	cNumHeapPages := "NumHeapPages=" + strconv.Itoa(heapPages) + "\n"
 
-	cStackPages := "NumStackPages=1024\n"
-	cNumTCS := "NumTCS=32\n"
+	cStackPages := "NumStackPages=" + strconv.Itoa(conf.GetStackSize()*1024*1024/pageSize) + "\n"
+	cNumTCS := "NumTCS=" + strconv.Itoa(conf.GetNumTCS()) + "\n"
 
 	var cExecutableHeap string
 	if conf.ExecutableHeap {
@@ -71,34 +115,39 @@ func (c *Cli) signWithJSON(conf *config.Config) error {
 
 	file, err := c.fs.TempFile("", "")
 	if err != nil {
-		return err
+		return "", "", err
 	}
-	defer func() { _ = c.fs.Remove(file.Name()) }()
+	defer func() {
+		if reterr != nil {
+			_ = c.fs.Remove(file.Name())
+		}
+	}()
 
 	_, err = file.Write([]byte(cProduct + cSecurityVersion + cDebug + cNumHeapPages + cStackPages + cNumTCS + cExecutableHeap))
 	if err != nil {
-		return err
+		return "", "", err
 	}
 
 	if err := file.Close(); err != nil {
-		return err
+		return "", "", err
 	}
 
 	// Prepare JSON data for embedding to the executable
-	jsonData, err := json.Marshal(conf)
+	jsonData, err := conf.Payload()
 	if err != nil {
-		return err
+		return "", "", err
 	}
 
 	// Embed enclave.json inside executable as payload
 	if err := c.embedConfigAsPayload(conf.Exe, jsonData); err != nil {
-		return err
+		return "", "", err
 	}
 
-	// create public and private key if private key does not exist
-	c.createDefaultKeypair(conf.Key)
+	return c.getEgoEnclavePath(buildInfo), file.Name(), nil
+}
 
-	cmd := exec.Command(c.getOesignPath(), "sign", "-e", c.getEgoEnclavePath(buildInfo), "-c", file.Name(), "-k", conf.Key, "--payload", conf.Exe)
+func (c *Cli) oesign(enclavePath, confFile, key, exe string) error {
+	cmd := exec.Command(c.getOesignPath(), "sign", "-e", enclavePath, "-c", confFile, "-k", key, "--payload", exe)
 	out, err := c.runner.CombinedOutput(cmd)
 	if _, ok := err.(*exec.ExitError); ok {
 		return errors.New(string(out))
@@ -106,27 +155,44 @@ func (c *Cli) signWithJSON(conf *config.Config) error {
 	return err
 }
 
-func (c *Cli) signExecutable(path string) error {
+func (c *Cli) getConfigForExecutable(path string) (*config.Config, error) {
 	// Try to parse existing config
 	conf, err := c.readConfigJSONtoStruct(defaultConfigFilename)
 
-	// If an enclave.json exists, check if the path inside it matches the user input and call signWithJSON.
+	// If an enclave.json exists, check if the path inside it matches the user input.
 	// Otherwise, throw an error.
 	if err != nil {
 		if !errors.Is(err, errConfigDoesNotExist) {
-			return err
+			return nil, err
 		}
 	} else if conf.Exe == path {
-		return c.signWithJSON(conf)
+		return conf, nil
 	} else {
-		return fmt.Errorf("provided path to executable does not match the one in %s", defaultConfigFilename)
+		return nil, fmt.Errorf("provided path to executable does not match the one in %s", defaultConfigFilename)
 	}
 
 	// If no enclave.json exists, generate a new one.
+	if c.profile != "" {
+		return nil, fmt.Errorf("profile %q requires a config file", c.profile)
+	}
 	fmt.Println("Generating new", defaultConfigFilename)
 
+	conf = defaultConfig(path)
+	jsonData, err := json.MarshalIndent(conf, "", " ")
+	if err != nil {
+		return nil, err
+	}
+	if err := c.fs.WriteFile(defaultConfigFilename, jsonData, 0o644); err != nil {
+		return nil, err
+	}
+
+	return conf, nil
+}
+
+// defaultConfig returns the config that is generated if an executable is signed without enclave.json.
+func defaultConfig(path string) *config.Config {
 	// sane default values
-	conf = &config.Config{
+	return &config.Config{
 		Exe:             path,
 		Key:             defaultPrivKeyFilename,
 		Debug:           true,
@@ -134,16 +200,46 @@ func (c *Cli) signExecutable(path string) error {
 		ProductID:       1,
 		SecurityVersion: 1,
 	}
+}
 
-	jsonData, err := json.MarshalIndent(conf, "", " ")
-	if err != nil {
-		return err
-	}
-	if err := c.fs.WriteFile(defaultConfigFilename, jsonData, 0o644); err != nil {
-		return err
+// checkConcurrency checks whether the app may need more threads than the enclave provides.
+// Like enc.cpp, it assumes that EGOMAXTHREADS defaults to numTCS-2 and GOMAXPROCS is limited to numTCS-4.
+// numCPU is the number of CPUs of the signing machine, which may differ from the machine the enclave runs on.
+func checkConcurrency(conf *config.Config, numCPU int) []string {
+	numTCS := conf.GetNumTCS()
+	maxThreads := numTCS - 2
+	maxProcs := min(numCPU, numTCS-4)
+	var maxThreadsSet, maxProcsSet bool
+
+	for _, envVar := range conf.Env {
+		if envVar.FromHost {
+			continue
+		}
+		value, err := strconv.Atoi(envVar.Value)
+		if err != nil {
+			continue
+		}
+		switch envVar.Name {
+		case "EGOMAXTHREADS":
+			maxThreads, maxThreadsSet = value, true
+		case "GOMAXPROCS":
+			maxProcs, maxProcsSet = value, true
+		}
 	}
 
-	return c.signWithJSON(conf)
+	var warnings []string
+	if maxThreadsSet && maxThreads > numTCS-2 {
+		warnings = append(warnings, fmt.Sprintf("EGOMAXTHREADS=%v leaves no margin for threads that aren't managed by Go. Set it to at most %v or increase numTCS.", maxThreads, numTCS-2))
+	}
+	switch {
+	case maxProcs > maxThreads && maxProcsSet:
+		warnings = append(warnings, fmt.Sprintf("GOMAXPROCS=%v exceeds the maximum number of threads (%v). Decrease GOMAXPROCS or increase numTCS.", maxProcs, maxThreads))
+	case maxProcs > maxThreads:
+		warnings = append(warnings, fmt.Sprintf("GOMAXPROCS will be %v, which exceeds EGOMAXTHREADS=%v. Set GOMAXPROCS to at most %v.", maxProcs, maxThreads, maxThreads))
+	case !maxProcsSet && numCPU > maxProcs:
+		warnings = append(warnings, fmt.Sprintf("The signing machine has %v CPUs, but GOMAXPROCS will be limited to %v with numTCS=%v. If the enclave runs on a machine with as many CPUs, increase numTCS to at least %v to use all of them.", numCPU, maxProcs, numTCS, numCPU+4))
+	}
+	return warnings
 }
 
 // Reads the provided File and turns it into a struct
@@ -151,71 +247,78 @@ func (c *Cli) signExecutable(path string) error {
 // err != nil indicates that the file could not be read or the
 // JSON could not be unmarshalled
 func (c *Cli) readConfigJSONtoStruct(path string) (*config.Config, error) {
-	data, err := c.fs.ReadFile(path)
-	if errors.Is(err, os.ErrNotExist) {
-		return nil, fmt.Errorf("%w: %v", errConfigDoesNotExist, path)
-	}
+	conf, err := c.resolveConfig(path)
 	if err != nil {
 		return nil, err
 	}
+	if err := conf.PopulateContent(c.fs); err != nil {
+		return nil, fmt.Errorf("failed to populate embedded file content: %w", err)
+	}
+	return conf, nil
+}
 
-	var conf config.Config
-	if err := json.Unmarshal(data, &conf); err != nil {
+// resolveConfig reads and validates a config file and interprets its paths relative to the directory containing it.
+func (c *Cli) resolveConfig(path string) (*config.Config, error) {
+	conf, err := c.loadConfig(path)
+	if err != nil {
 		return nil, err
 	}
 	if err := conf.Validate(); err != nil {
 		return nil, err
 	}
+	resolvePaths(conf, path)
+	return conf, nil
+}
 
-	// interpret paths relative to the directory containing the config file
+// resolvePaths interprets the paths of conf relative to the directory containing the config file at path.
+func resolvePaths(conf *config.Config, path string) {
 	dir := filepath.Dir(path)
 	conf.Exe = filepath.Join(dir, conf.Exe)
 	conf.Key = filepath.Join(dir, conf.Key)
+	for i, file := range conf.Files {
+		if !filepath.IsAbs(file.Source) {
+			conf.Files[i].Source = filepath.Join(dir, file.Source)
+		}
+	}
+}
 
-	if err := conf.PopulateContent(c.fs); err != nil {
-		return nil, fmt.Errorf("failed to populate embedded file content: %w", err)
+// loadConfig reads a config file and applies includes and the profile. Paths are left as they are in the file.
+func (c *Cli) loadConfig(path string) (*config.Config, error) {
+	if _, err := c.fs.Stat(path); errors.Is(err, os.ErrNotExist) {
+		return nil, fmt.Errorf("%w: %v", errConfigDoesNotExist, path)
 	}
-	return &conf, nil
+	return config.Load(c.fs, path, c.profile)
 }
 
 // Creates a public/secret keypair if the provided secret key does not exist
-func (c *Cli) createDefaultKeypair(file string) {
-	if _, err := c.fs.Stat(file); err != nil {
-		if !os.IsNotExist(err) {
-			panic(err)
-		}
-		fmt.Println("Generating new " + file)
-		// SGX requires the RSA exponent to be 3. Go's API does not support this.
-		if out, err := c.runner.CombinedOutput(exec.Command("openssl", "genrsa", "-out", file, "-3", "3072")); err != nil {
-			fmt.Println(string(out))
-			panic(err)
-		}
-		pubPath := filepath.Join(filepath.Dir(file), defaultPubKeyFilename)
-		if out, err := c.runner.CombinedOutput(exec.Command("openssl", "rsa", "-in", file, "-pubout", "-out", pubPath)); err != nil {
-			fmt.Println(string(out))
-			panic(err)
-		}
+func (c *Cli) createDefaultKeypair(file string) error {
+	if _, err := c.fs.Stat(file); err == nil || !errors.Is(err, os.ErrNotExist) {
+		return err
 	}
+	fmt.Println("Generating new " + file)
+	pubPath := filepath.Join(filepath.Dir(file), defaultPubKeyFilename)
+	return c.GenerateKey(file, pubPath, nil)
 }
 
 // Sign signs an executable built with ego-go.
 func (c *Cli) Sign(filename string) error {
+	conf, err := c.getSignConfig(filename)
+	if err != nil {
+		return err
+	}
+	return c.signWithJSON(conf)
+}
+
+// getSignConfig returns the config for signing. If filename is an executable and enclave.json doesn't exist, it is generated.
+func (c *Cli) getSignConfig(filename string) (*config.Config, error) {
 	if filename == "" {
 		// When no filename is defined, use an existing enclave.json for reference, or fail otherwise.
-		conf, err := c.readConfigJSONtoStruct(defaultConfigFilename)
-		if err != nil {
-			return err
-		}
-		return c.signWithJSON(conf)
+		return c.readConfigJSONtoStruct(defaultConfigFilename)
 	}
 	if filepath.Ext(filename) == ".json" {
 		// If the supplied filename seems to be a JSON file, interpret it as a config file.
-		conf, err := c.readConfigJSONtoStruct(filename)
-		if err != nil {
-			return err
-		}
-		return c.signWithJSON(conf)
+		return c.readConfigJSONtoStruct(filename)
 	}
 	// If no config exists or no JSON file has been specified, assume the supplied file name is an executable.
-	return c.signExecutable(filename)
+	return c.getConfigForExecutable(filename)
 }
@@ -150,6 +150,10 @@ NumTCS=32
 ExecutableHeap=1
 `,
 		},
+		"numTCS too small": {
+			existingFiles: map[string]string{"enclave.json": `{"exe":"exefile", "key":"keyfile", "heapSize":2, "numTCS":4}`},
+			expectErr:     true,
+		},
 	}
 
 	for name, tc := range testCases {
@@ -180,9 +184,14 @@ ExecutableHeap=1
 			require.NoError(err)
 			exists, err := fs.Exists(filepath.Join(filepath.Dir(tc.keyfilename), "public.pem"))
 			if tc.expectedKey == "" {
-				assert.EqualValues("newkey", key)
 				require.NoError(err)
-				assert.True(exists)
+				require.True(exists)
+				privKey, err := cli.readPrivateKey(tc.keyfilename)
+				require.NoError(err)
+				assert.Equal(3, privKey.E)
+				pubKey, err := cli.readPublicKey(filepath.Join(filepath.Dir(tc.keyfilename), "public.pem"))
+				require.NoError(err)
+				assert.True(privKey.PublicKey.Equal(pubKey))
 			} else {
 				assert.EqualValues(tc.expectedKey, key)
 				require.NoError(err)
@@ -192,6 +201,74 @@ ExecutableHeap=1
 	}
 }
 
+func TestSignThreads(t *testing.T) {
+	if _, err := exec.LookPath("objcopy"); err != nil {
+		t.Skip("objcopy not found, cannot run this test.")
+	}
+
+	require := require.New(t)
+
+	fs := afero.Afero{Fs: afero.NewMemMapFs()}
+	runner := signRunner{
+		fs: fs,
+		expectedConfig: `ProductID=0
+SecurityVersion=0
+Debug=0
+NumHeapPages=131072
+NumStackPages=512
+NumTCS=64
+`,
+	}
+	cli := NewCli(&runner, fs)
+
+	require.NoError(fs.WriteFile("exefile", addSectionToBytes(t, elfUnsigned, oeinfoSectionName, make([]byte, 4096)), 0))
+	require.NoError(fs.WriteFile("enclave.json", []byte(`{"exe":"exefile", "key":"keyfile", "heapSize":512, "numTCS":64, "stackSize":2}`), 0))
+	require.NoError(cli.Sign(""))
+}
+
+func TestCheckConcurrency(t *testing.T) {
+	testCases := map[string]struct {
+		numTCS       int
+		env          []config.EnvVar
+		numCPU       int
+		wantWarnings int
+	}{
+		"default":                           {numCPU: 8},
+		"more CPUs than default GOMAXPROCS": {numCPU: 64, wantWarnings: 1},
+		"more CPUs with increased numTCS":   {numTCS: 68, numCPU: 64},
+		"GOMAXPROCS set by user": {
+			env:    []config.EnvVar{{Name: "GOMAXPROCS", Value: "8"}},
+			numCPU: 64,
+		},
+		"GOMAXPROCS exceeds max threads": {
+			env:          []config.EnvVar{{Name: "GOMAXPROCS", Value: "31"}},
+			numCPU:       64,
+			wantWarnings: 1,
+		},
+		"GOMAXPROCS exceeds EGOMAXTHREADS": {
+			env:          []config.EnvVar{{Name: "EGOMAXTHREADS", Value: "16"}},
+			numCPU:       64,
+			wantWarnings: 1,
+		},
+		"EGOMAXTHREADS leaves no margin": {
+			env:          []config.EnvVar{{Name: "EGOMAXTHREADS", Value: "32"}},
+			numCPU:       8,
+			wantWarnings: 1,
+		},
+		"EGOMAXTHREADS from host": {
+			env:    []config.EnvVar{{Name: "EGOMAXTHREADS", Value: "32", FromHost: true}},
+			numCPU: 8,
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			conf := &config.Config{NumTCS: tc.numTCS, Env: tc.env}
+			assert.Len(t, checkConcurrency(conf, tc.numCPU), tc.wantWarnings)
+		})
+	}
+}
+
 func TestEmbedFile(t *testing.T) {
 	assert := assert.New(t)
 	require := require.New(t)
@@ -262,16 +339,62 @@ NumTCS=32
 	var conf config.Config
 	require.NoError(json.Unmarshal(payload, &conf))
 	require.Len(conf.Files, 1)
-	assert.Equal(sourcePath, conf.Files[0].Source)
+	assert.Empty(conf.Files[0].Source) // host paths aren't embedded
 	assert.Equal(targetPath, conf.Files[0].Target)
 	actualContent, err := conf.Files[0].GetContent()
 	require.NoError(err)
 	require.Equal(content, actualContent)
 }
 
+// TestSignDirectoryIndependent checks that the embedded payload, and thus the UniqueID,
+// doesn't depend on the directory where the executable is signed nor on the key.
+func TestSignDirectoryIndependent(t *testing.T) {
+	if _, err := exec.LookPath("objcopy"); err != nil {
+		t.Skip("objcopy not found, cannot run this test.")
+	}
+
+	assert := assert.New(t)
+	require := require.New(t)
+
+	exe := addSectionToBytes(t, elfUnsigned, oeinfoSectionName, make([]byte, 4096))
+	cli := NewCli(nil, afero.NewOsFs())
+
+	prepare := func(dir, key, configFile string) []byte {
+		require.NoError(os.MkdirAll(filepath.Join(dir, "data"), 0o755))
+		require.NoError(os.WriteFile(filepath.Join(dir, "exefile"), exe, 0o644))
+		require.NoError(os.WriteFile(filepath.Join(dir, "data", "file"), []byte("foo"), 0o644))
+		conf := `{"exe":"exefile","key":"` + key + `","heapSize":512,
+			"files":[{"source":"data/file","target":"/file"}],
+			"env":[{"name":"A","value":"a"},{"name":"B","value":"b"}]}`
+		require.NoError(os.WriteFile(filepath.Join(dir, "enclave.json"), []byte(conf), 0o644))
+
+		c, err := cli.getSignConfig(configFile)
+		require.NoError(err)
+		_, confFile, err := cli.prepareSign(c)
+		require.NoError(err)
+		require.NoError(os.Remove(confFile))
+
+		data, err := os.ReadFile(filepath.Join(dir, "exefile"))
+		require.NoError(err)
+		return data
+	}
+
+	// sign from within the project directory
+	dir := t.TempDir()
+	t.Chdir(dir)
+	expected := prepare(dir, "private.pem", "enclave.json")
+
+	// sign from another directory with another key
+	otherDir := filepath.Join(t.TempDir(), "other")
+	t.Chdir(t.TempDir())
+	actual := prepare(otherDir, "keys/signing.pem", filepath.Join(otherDir, "enclave.json"))
+	assert.Equal(expected, actual)
+}
+
 type signRunner struct {
 	fs             afero.Afero
 	expectedConfig string
+	sigstruct      []byte // if set, oesign writes it to the executable
 }
 
 func (signRunner) Run(cmd *exec.Cmd) error {
@@ -284,19 +407,6 @@ func (signRunner) Output(cmd *exec.Cmd) ([]byte, error) {
 
 func (s signRunner) CombinedOutput(cmd *exec.Cmd) ([]byte, error) {
 	switch {
-	case cmp.Equal(cmd.Args[:3], []string{"openssl", "genrsa", "-out"}) &&
-		cmp.Equal(cmd.Args[4:], []string{"-3", "3072"}):
-		return nil, s.fs.WriteFile(cmd.Args[3], []byte("newkey"), 0)
-	case cmp.Equal(cmd.Args[:3], []string{"openssl", "rsa", "-in"}) &&
-		cmp.Equal(cmd.Args[4:6], []string{"-pubout", "-out"}):
-		exists, err := s.fs.Exists(cmd.Args[3])
-		if err != nil {
-			return nil, err
-		}
-		if !exists {
-			return nil, errors.New("openssl rsa: " + cmd.Args[3] + " does not exist")
-		}
-		return nil, s.fs.WriteFile(cmd.Args[6], nil, 0)
 	case filepath.Base(cmd.Path) == "ego-oesign" &&
 		cmp.Equal(cmd.Args[1:3], []string{"sign", "-e"}) &&
 		cmp.Equal(cmd.Args[6], "-k") &&
@@ -309,6 +419,10 @@ func (s signRunner) CombinedOutput(cmd *exec.Cmd) ([]byte, error) {
 		if config != s.expectedConfig {
 			return nil, errors.New("unexpected config: " + config)
 		}
+		if s.sigstruct != nil {
+			cli := NewCli(nil, s.fs)
+			return nil, cli.writeSigstruct(cmd.Args[9], s.sigstruct)
+		}
 		return nil, nil
 	}
 	return nil, errors.New("unexpected cmd: " + cmd.Path + strings.Join(cmd.Args, " "))
@@ -0,0 +1,93 @@
+// Copyright (c) Edgeless Systems GmbH.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package cli
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/rsa"
+	"errors"
+	"fmt"
+	"io"
+	"os/exec"
+
+	"github.com/edgelesssys/ego/ego/internal/launch"
+)
+
+// An enclave can be signed by any crypto.Signer with an RSA-3072 key with exponent 3.
+// The signer gets the SHA-256 digest of the SIGSTRUCT and must return an RSASSA-PKCS1-v1_5 signature.
+
+// FileSigner is a crypto.Signer that returns a signature that has been created beforehand,
+// e.g., by signing the digest written by 'ego sign --digest-out' with an HSM.
+type FileSigner struct {
+	PublicKey *rsa.PublicKey
+	Signature []byte
+}
+
+// NewFileSigner creates a FileSigner from a PEM-encoded public key file and a file containing the raw signature.
+func (c *Cli) NewFileSigner(pubKeyFile, signatureFile string) (*FileSigner, error) {
+	pub, err := c.readPublicKey(pubKeyFile)
+	if err != nil {
+		return nil, err
+	}
+	signature, err := c.fs.ReadFile(signatureFile)
+	if err != nil {
+		return nil, fmt.Errorf("reading signature: %w", err)
+	}
+	return &FileSigner{PublicKey: pub, Signature: signature}, nil
+}
+
+// Public returns the public key.
+func (s *FileSigner) Public() crypto.PublicKey {
+	return s.PublicKey
+}
+
+// Sign returns the signature. It is checked by the caller whether it matches the digest.
+func (s *FileSigner) Sign(io.Reader, []byte, crypto.SignerOpts) ([]byte, error) {
+	return s.Signature, nil
+}
+
+// CommandSigner is a crypto.Signer that runs an external command to sign the digest, e.g., a tool that signs with a
+// key stored in a PKCS#11 token. The command gets the SHA-256 digest on stdin and must write the raw
+// RSASSA-PKCS1-v1_5 signature to stdout.
+type CommandSigner struct {
+	PublicKey *rsa.PublicKey
+	Command   string
+	Args      []string
+	runner    launch.Runner
+}
+
+// NewCommandSigner creates a CommandSigner from a PEM-encoded public key file and the command to run.
+func (c *Cli) NewCommandSigner(pubKeyFile string, command string, args ...string) (*CommandSigner, error) {
+	pub, err := c.readPublicKey(pubKeyFile)
+	if err != nil {
+		return nil, err
+	}
+	return &CommandSigner{PublicKey: pub, Command: command, Args: args, runner: c.runner}, nil
+}
+
+// Public returns the public key.
+func (s *CommandSigner) Public() crypto.PublicKey {
+	return s.PublicKey
+}
+
+// Sign runs the command to sign the digest.
+func (s *CommandSigner) Sign(_ io.Reader, digest []byte, opts crypto.SignerOpts) ([]byte, error) {
+	if opts.HashFunc() != crypto.SHA256 {
+		return nil, errors.New("unsupported hash function")
+	}
+	cmd := exec.Command(s.Command, s.Args...)
+	cmd.Stdin = bytes.NewReader(digest)
+	out, err := s.runner.Output(cmd)
+	if err != nil {
+		if exitErr, ok := err.(*exec.ExitError); ok {
+			return nil, fmt.Errorf("%v failed: %s", s.Command, exitErr.Stderr)
+		}
+		return nil, err
+	}
+	return out, nil
+}
@@ -8,13 +8,8 @@ package cli
 
 import (
 	"bytes"
-	"crypto/rsa"
 	"crypto/sha256"
-	"crypto/x509"
 	"encoding/hex"
-	"encoding/pem"
-	"errors"
-	"fmt"
 	"path/filepath"
 	"slices"
 	"strings"
@@ -28,23 +23,11 @@ const (
 	offsetMRENCLAVE = 960
 )
 
-func (c *Cli) signeridByKey(path string) (string, error) {
-	// get RSA key from PEM file
-	pemBytes, err := c.fs.ReadFile(path)
+// SigneridByKey returns the SignerID of a key. The file may contain a public or a private key.
+func (c *Cli) SigneridByKey(path string) (string, error) {
+	rsaKey, err := c.readPublicKey(path)
 	if err != nil {
-		return "", fmt.Errorf("reading key file: %w", err)
-	}
-	block, _ := pem.Decode(pemBytes)
-	if block == nil {
-		return "", errors.New("failed to decode PEM")
-	}
-	key, err := x509.ParsePKIXPublicKey(block.Bytes)
-	if err != nil {
-		return "", fmt.Errorf("parsing public key: %w", err)
-	}
-	rsaKey, ok := key.(*rsa.PublicKey)
-	if !ok {
-		return "", fmt.Errorf("expected RSA public key, got %T", key)
+		return "", err
 	}
 
 	// MRSIGNER is the sha256 of the modulus in little endian
@@ -81,7 +64,7 @@ func (c *Cli) Uniqueid(path string) (string, error) {
 // Signerid returns the SignerID of a signed executable.
 func (c *Cli) Signerid(path string) (string, error) {
 	if filepath.Ext(path) == ".pem" {
-		return c.signeridByKey(path)
+		return c.SigneridByKey(path)
 	}
 	return c.signeridByExecutable(path)
 }
@@ -0,0 +1,120 @@
+// Copyright (c) Edgeless Systems GmbH.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package cli
+
+import (
+	"crypto"
+	"crypto/rand"
+	"fmt"
+
+	"github.com/edgelesssys/ego/ego/config"
+)
+
+// SignDigest prepares an executable for signing with an external signer and writes the digest that must be signed to digestOut.
+// The filename is interpreted like in Sign. The key in the config isn't used.
+//
+// The digest must be signed with RSASSA-PKCS1-v1_5 using an RSA-3072 key with exponent 3, e.g., by an HSM.
+// Add the signature with AddSignature.
+func (c *Cli) SignDigest(filename string, digestOut string) error {
+	conf, err := c.getSignConfig(filename)
+	if err != nil {
+		return err
+	}
+	sigstruct, err := c.prepareSigstruct(conf)
+	if err != nil {
+		return err
+	}
+	return c.fs.WriteFile(digestOut, sigstructDigest(sigstruct), 0o644)
+}
+
+// AddSignature adds the signature of the signer to an executable that has been prepared with SignDigest.
+// The filename is interpreted like in Sign.
+func (c *Cli) AddSignature(filename string, signer crypto.Signer) error {
+	conf, err := c.readConfig(filename)
+	if err != nil {
+		return err
+	}
+	sigstruct, err := c.readSigstruct(conf.Exe)
+	if err != nil {
+		return err
+	}
+	if err := signSigstruct(sigstruct, signer); err != nil {
+		return err
+	}
+	return c.writeSigstruct(conf.Exe, sigstruct)
+}
+
+// SignWithSigner signs an executable with the signer instead of the key in the config.
+// The filename is interpreted like in Sign.
+func (c *Cli) SignWithSigner(filename string, signer crypto.Signer) error {
+	conf, err := c.getSignConfig(filename)
+	if err != nil {
+		return err
+	}
+	return c.signWithSigner(conf, signer)
+}
+
+func (c *Cli) signWithSigner(conf *config.Config, signer crypto.Signer) error {
+	sigstruct, err := c.prepareSigstruct(conf)
+	if err != nil {
+		return err
+	}
+	if err := signSigstruct(sigstruct, signer); err != nil {
+		return err
+	}
+	return c.writeSigstruct(conf.Exe, sigstruct)
+}
+
+// prepareSigstruct lets oesign create the SIGSTRUCT with a temporary key and removes the key and the signature from it.
+// The signed parts of the SIGSTRUCT don't depend on the key, so it can then be signed with any key.
+func (c *Cli) prepareSigstruct(conf *config.Config) ([]byte, error) {
+	enclavePath, confFile, err := c.prepareSign(conf)
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = c.fs.Remove(confFile) }()
+
+	keyFile, err := c.createTempKey()
+	if err != nil {
+		return nil, err
+	}
+	defer func() { _ = c.fs.Remove(keyFile) }()
+
+	if err := c.oesign(enclavePath, confFile, keyFile, conf.Exe); err != nil {
+		return nil, err
+	}
+
+	sigstruct, err := c.readSigstruct(conf.Exe)
+	if err != nil {
+		return nil, err
+	}
+	clearSignature(sigstruct)
+	if err := c.writeSigstruct(conf.Exe, sigstruct); err != nil {
+		return nil, err
+	}
+	return sigstruct, nil
+}
+
+// createTempKey creates a temporary key that is only used to let oesign create the SIGSTRUCT.
+func (c *Cli) createTempKey() (string, error) {
+	key, err := generateSGXKey(rand.Reader)
+	if err != nil {
+		return "", fmt.Errorf("generating temporary key: %w", err)
+	}
+	file, err := c.fs.TempFile("", "ego-key")
+	if err != nil {
+		return "", err
+	}
+	if err := file.Close(); err != nil {
+		return "", err
+	}
+	if err := c.writeKeyPair(key, file.Name(), "", nil); err != nil {
+		_ = c.fs.Remove(file.Name())
+		return "", err
+	}
+	return file.Name(), nil
+}
@@ -0,0 +1,190 @@
+// Copyright (c) Edgeless Systems GmbH.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package cli
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"crypto/x509"
+	"encoding/pem"
+	"errors"
+	"io"
+	"math/big"
+	"os/exec"
+	"slices"
+	"testing"
+
+	"github.com/spf13/afero"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestSignExternal(t *testing.T) {
+	if _, err := exec.LookPath("objcopy"); err != nil {
+		t.Skip("objcopy not found, cannot run this test.")
+	}
+
+	assert := assert.New(t)
+	require := require.New(t)
+
+	const exe = "exefile"
+	fs := afero.Afero{Fs: afero.NewMemMapFs()}
+	sigstruct := newTestSigstruct()
+	runner := signRunner{
+		fs:        fs,
+		sigstruct: sigstruct,
+		expectedConfig: `ProductID=0
+SecurityVersion=0
+Debug=0
+NumHeapPages=131072
+NumStackPages=1024
+NumTCS=32
+`,
+	}
+	cli := NewCli(&runner, fs)
+
+	require.NoError(fs.WriteFile(exe, addSectionToBytes(t, elfUnsigned, oeinfoSectionName, make([]byte, 4096)), 0))
+	require.NoError(fs.WriteFile("enclave.json", []byte(`{"exe":"exefile", "key":"keyfile", "heapSize":512}`), 0))
+
+	key := newTestSGXKey(t)
+	pubKey, err := x509.MarshalPKIXPublicKey(&key.PublicKey)
+	require.NoError(err)
+	require.NoError(fs.WriteFile("public.pem", pem.EncodeToMemory(&pem.Block{Type: "PUBLIC KEY", Bytes: pubKey}), 0))
+	expectedSignerID, err := cli.Signerid("public.pem")
+	require.NoError(err)
+
+	// adding a signature requires a prepared executable
+	assert.ErrorIs(cli.AddSignature("", key), ErrNotPrepared)
+
+	// prepare
+	require.NoError(cli.SignDigest("", "digest"))
+	digest, err := fs.ReadFile("digest")
+	require.NoError(err)
+	assert.Equal(sigstructDigest(sigstruct), digest)
+
+	// the key in the config isn't created and the temporary key is removed
+	exists, err := fs.Exists("keyfile")
+	require.NoError(err)
+	assert.False(exists)
+	tempFiles, err := fs.ReadDir(afero.GetTempDir(fs, ""))
+	require.NoError(err)
+	assert.Empty(tempFiles)
+
+	// the prepared executable isn't signed
+	signerID, err := cli.Signerid(exe)
+	require.NoError(err)
+	assert.Equal("0000000000000000000000000000000000000000000000000000000000000000", signerID)
+
+	// sign the digest externally
+	signature, err := rsa.SignPKCS1v15(nil, key, crypto.SHA256, digest)
+	require.NoError(err)
+	require.NoError(fs.WriteFile("signature", signature, 0))
+
+	// signature of other data is rejected
+	otherDigest := sha256.Sum256([]byte("foo"))
+	otherSignature, err := rsa.SignPKCS1v15(nil, key, crypto.SHA256, otherDigest[:])
+	require.NoError(err)
+	assert.ErrorIs(cli.AddSignature("", &FileSigner{PublicKey: &key.PublicKey, Signature: otherSignature}), ErrSignatureMismatch)
+
+	// add the signature
+	signer, err := cli.NewFileSigner("public.pem", "signature")
+	require.NoError(err)
+	require.NoError(cli.AddSignature("", signer))
+
+	signerID, err = cli.Signerid(exe)
+	require.NoError(err)
+	assert.Equal(expectedSignerID, signerID)
+	signedSigstruct, err := cli.readSigstruct(exe)
+	require.NoError(err)
+	assertValidSigstruct(t, signedSigstruct, &key.PublicKey)
+	assert.Equal(digest, sigstructDigest(signedSigstruct))
+
+	// sign in one step
+	require.NoError(cli.SignWithSigner("", key))
+	signerID, err = cli.Signerid(exe)
+	require.NoError(err)
+	assert.Equal(expectedSignerID, signerID)
+
+	// SGX requires exponent 3
+	otherKey, err := rsa.GenerateKey(rand.Reader, 3072)
+	require.NoError(err)
+	assert.Error(cli.SignWithSigner("", otherKey))
+}
+
+func TestCommandSigner(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	key := newTestSGXKey(t)
+	signer := &CommandSigner{PublicKey: &key.PublicKey, Command: "sign", runner: &commandSignerRunner{key: key}}
+	sigstruct := newTestSigstruct()
+	require.NoError(signSigstruct(sigstruct, signer))
+	assertValidSigstruct(t, sigstruct, &key.PublicKey)
+
+	signer.runner = &commandSignerRunner{key: key, err: errors.New("failed")}
+	assert.Error(signSigstruct(newTestSigstruct(), signer))
+}
+
+type commandSignerRunner struct {
+	runner
+	key *rsa.PrivateKey
+	err error
+}
+
+func (r *commandSignerRunner) Output(cmd *exec.Cmd) ([]byte, error) {
+	if r.err != nil {
+		return nil, r.err
+	}
+	digest, err := io.ReadAll(cmd.Stdin)
+	if err != nil {
+		return nil, err
+	}
+	return rsa.SignPKCS1v15(nil, r.key, crypto.SHA256, digest)
+}
+
+// assertValidSigstruct checks the signature like the CPU does.
+func assertValidSigstruct(t *testing.T, sigstruct []byte, pub *rsa.PublicKey) {
+	t.Helper()
+	readLittleEndian := func(offset int) *big.Int {
+		b := slices.Clone(sigstruct[offset : offset+modulusSize])
+		slices.Reverse(b)
+		return new(big.Int).SetBytes(b)
+	}
+	n := readLittleEndian(offsetModulus)
+	s := readLittleEndian(offsetSignature)
+	q1 := readLittleEndian(offsetQ1)
+	q2 := readLittleEndian(offsetQ2)
+	assert.Equal(t, 0, pub.N.Cmp(n))
+	assert.EqualValues(t, []byte{3, 0, 0, 0}, sigstruct[offsetExponent:offsetExponent+4])
+
+	// s^2 = q1*n + r1 and r1*s = q2*n + r2 with 0 <= r1, r2 < n
+	r1 := new(big.Int).Sub(new(big.Int).Mul(s, s), new(big.Int).Mul(q1, n))
+	assert.True(t, r1.Sign() >= 0 && r1.Cmp(n) < 0)
+	r2 := new(big.Int).Sub(new(big.Int).Mul(r1, s), new(big.Int).Mul(q2, n))
+	assert.True(t, r2.Sign() >= 0 && r2.Cmp(n) < 0)
+
+	assert.NoError(t, rsa.VerifyPKCS1v15(pub, crypto.SHA256, sigstructDigest(sigstruct), s.FillBytes(make([]byte, modulusSize))))
+}
+
+// newTestSigstruct returns a SIGSTRUCT as created by oesign with a random key.
+func newTestSigstruct() []byte {
+	sigstruct := make([]byte, sigstructSize)
+	copy(sigstruct, []byte{6, 0, 0, 0, 0xE1, 0, 0, 0, 0, 0, 1, 0, 0, 0, 0, 0})
+	copy(sigstruct[offsetModulus:], bytes.Repeat([]byte{0xAA}, modulusSize))
+	copy(sigstruct[offsetSignature:], bytes.Repeat([]byte{0xBB}, modulusSize))
+	copy(sigstruct[offsetMRENCLAVE:], bytes.Repeat([]byte{0xCC}, 32))
+	return sigstruct
+}
+
+func newTestSGXKey(t *testing.T) *rsa.PrivateKey {
+	key, err := generateSGXKey(rand.Reader)
+	require.NoError(t, err)
+	return key
+}
@@ -0,0 +1,118 @@
+// Copyright (c) Edgeless Systems GmbH.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package cli
+
+import (
+	"bytes"
+	"crypto"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/sha256"
+	"encoding/binary"
+	"errors"
+	"fmt"
+	"math/big"
+	"os"
+	"slices"
+)
+
+const (
+	offsetExponent      = 512
+	offsetSignature     = 516
+	offsetSigstructBody = 900
+	offsetQ1            = 1040
+	offsetQ2            = 1424
+
+	sigstructHeaderSize = 128
+	sigstructBodySize   = 128
+)
+
+// ErrNotPrepared is returned if a signature should be added to an executable that hasn't been prepared for signing.
+var ErrNotPrepared = errors.New("executable hasn't been prepared for signing, run 'ego sign --digest-out' first")
+
+// ErrSignatureMismatch is returned if a signature doesn't match the digest of the executable.
+var ErrSignatureMismatch = errors.New("signature doesn't match the digest of the executable")
+
+func (c *Cli) readSigstruct(path string) ([]byte, error) {
+	return c.readDataFromELF(path, oeinfoSectionName, offsetSigstruct, sigstructSize)
+}
+
+func (c *Cli) writeSigstruct(path string, sigstruct []byte) error {
+	f, err := c.fs.OpenFile(path, os.O_RDWR, 0)
+	if err != nil {
+		return err
+	}
+	defer f.Close()
+
+	_, _, oeInfoOffset, err := getPayloadInformation(f)
+	if err != nil {
+		return err
+	}
+	if _, err := f.WriteAt(sigstruct, oeInfoOffset+offsetSigstruct); err != nil {
+		return err
+	}
+	return nil
+}
+
+// sigstructDigest returns the SHA-256 digest of the signed parts of the SIGSTRUCT.
+// The signed parts don't include the key and the signature.
+func sigstructDigest(sigstruct []byte) []byte {
+	hash := sha256.New()
+	hash.Write(sigstruct[:sigstructHeaderSize])
+	hash.Write(sigstruct[offsetSigstructBody : offsetSigstructBody+sigstructBodySize])
+	return hash.Sum(nil)
+}
+
+// clearSignature removes the key and the signature from the SIGSTRUCT.
+func clearSignature(sigstruct []byte) {
+	clear(sigstruct[offsetModulus : offsetSignature+modulusSize])
+	clear(sigstruct[offsetQ1 : offsetQ2+modulusSize])
+}
+
+// signSigstruct signs the SIGSTRUCT with the signer and writes the key and the signature to it.
+func signSigstruct(sigstruct []byte, signer crypto.Signer) error {
+	if bytes.Equal(sigstruct[offsetMRENCLAVE:offsetMRENCLAVE+32], make([]byte, 32)) {
+		return ErrNotPrepared
+	}
+	pub, ok := signer.Public().(*rsa.PublicKey)
+	if !ok {
+		return fmt.Errorf("expected RSA public key, got %T", signer.Public())
+	}
+	if pub.N.BitLen() != modulusSize*8 || pub.E != 3 {
+		return errors.New("SGX requires an RSA-3072 key with exponent 3")
+	}
+
+	digest := sigstructDigest(sigstruct)
+	signature, err := signer.Sign(rand.Reader, digest, crypto.SHA256)
+	if err != nil {
+		return fmt.Errorf("signing: %w", err)
+	}
+	if err := rsa.VerifyPKCS1v15(pub, crypto.SHA256, digest, signature); err != nil {
+		return ErrSignatureMismatch
+	}
+
+	// Q1 and Q2 allow the CPU to verify the signature without divisions
+	s := new(big.Int).SetBytes(signature)
+	s2 := new(big.Int).Mul(s, s)
+	q1 := new(big.Int).Div(s2, pub.N)
+	q2 := new(big.Int).Mul(s2, s)
+	q2.Sub(q2, new(big.Int).Mul(new(big.Int).Mul(q1, s), pub.N))
+	q2.Div(q2, pub.N)
+
+	// SIGSTRUCT stores the numbers in little endian
+	putLittleEndian := func(offset int, x *big.Int) {
+		b := x.FillBytes(make([]byte, modulusSize))
+		slices.Reverse(b)
+		copy(sigstruct[offset:], b)
+	}
+	putLittleEndian(offsetModulus, pub.N)
+	binary.LittleEndian.PutUint32(sigstruct[offsetExponent:], uint32(pub.E))
+	putLittleEndian(offsetSignature, s)
+	putLittleEndian(offsetQ1, q1)
+	putLittleEndian(offsetQ2, q2)
+	return nil
+}
@@ -30,9 +30,7 @@ func main() {
 	for _, c := range rootCmd.Commands() {
 		name := c.Name()
 		fmt.Fprintf(cmdList, "* [%v](#ego-%v): %v\n", name, name, c.Short)
-		if err := doc.GenMarkdown(c, body); err != nil {
-			panic(err)
-		}
+		genMarkdown(c, body)
 	}
 
 	// Remove "see also" sections. They list parent and child commands, which is not interesting for us.
@@ -40,3 +38,15 @@ func main() {
 
 	fmt.Printf("Commands:\n\n%s\n%s", cmdList, cleanedBody)
 }
+
+// genMarkdown generates Markdown for the command and its subcommands.
+func genMarkdown(c *cobra.Command, body *bytes.Buffer) {
+	if err := doc.GenMarkdown(c, body); err != nil {
+		panic(err)
+	}
+	for _, sub := range c.Commands() {
+		if sub.IsAvailableCommand() {
+			genMarkdown(sub, body)
+		}
+	}
+}
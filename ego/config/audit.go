@@ -0,0 +1,70 @@
+// Copyright (c) Edgeless Systems GmbH.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package config
+
+import (
+	"fmt"
+	"regexp"
+
+	"github.com/spf13/afero"
+)
+
+// LargeFileSize is the size in bytes above which Audit reports embedded files.
+const LargeFileSize = 8 * 1024 * 1024
+
+// secretNamePattern matches names of environment variables that likely contain secrets.
+var secretNamePattern = regexp.MustCompile(`(?i)secret|passw(or)?d|passphrase|token|api_?key|private|credential|(^|_)(key|auth)($|_)`)
+
+// Audit returns the settings that are security-relevant for production deployments.
+// Sources of hostfs mounts and embedded files are looked up in fs relative to the working directory.
+// Files must not have been expanded by PopulateContent yet, so that the paths refer to the entries of the config.
+func (c *Config) Audit(fs afero.Afero) []Diagnostic {
+	var diags []Diagnostic
+	add := func(path, format string, args ...any) {
+		diags = append(diags, Diagnostic{Severity: SeverityWarning, Path: path, Message: fmt.Sprintf(format, args...)})
+	}
+
+	if c.Debug {
+		add("debug", "debug is enabled, so the host can read the enclave's memory with a debugger")
+	}
+	if c.ExecutableHeap {
+		add("executableHeap", "the heap is executable, which makes code injection easier")
+	}
+
+	for i, mountPoint := range c.Mounts {
+		if mountPoint.Type != "hostfs" {
+			continue
+		}
+		path := fmt.Sprintf("mounts[%v]", i)
+		if mountPoint.Target == "/" {
+			add(path, "'/' is mounted as hostfs, so the host can read and modify all files of the enclave")
+		}
+		if info, err := fs.Stat(mountPoint.Source); err == nil && info.Mode().Perm()&0o002 != 0 {
+			add(path+".source", "'%s' is world-writable, so any user on the host can modify the files of mount target '%s'", mountPoint.Source, mountPoint.Target)
+		}
+	}
+
+	for i, envVar := range c.Env {
+		if envVar.FromHost && secretNamePattern.MatchString(envVar.Name) {
+			add(fmt.Sprintf("env[%v].fromHost", i), "'%s' looks like a secret, but is taken from the host, which can read and modify it", envVar.Name)
+		}
+	}
+
+	for i, file := range c.Files {
+		expanded, err := file.Expand(fs)
+		if err != nil {
+			continue
+		}
+		for _, file := range expanded {
+			if info, err := fs.Stat(file.Source); err == nil && info.Size() > LargeFileSize {
+				add(fmt.Sprintf("files[%v].source", i), "'%s' has %v MB. Embedded files are kept in enclave memory and slow down enclave startup", file.Source, info.Size()/1024/1024)
+			}
+		}
+	}
+
+	return diags
+}
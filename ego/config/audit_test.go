@@ -0,0 +1,79 @@
+// Copyright (c) Edgeless Systems GmbH.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package config
+
+import (
+	"testing"
+
+	"github.com/spf13/afero"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAudit(t *testing.T) {
+	require := require.New(t)
+
+	fs := afero.Afero{Fs: afero.NewMemMapFs()}
+	require.NoError(fs.Mkdir("private", 0o700))
+	require.NoError(fs.Mkdir("shared", 0o777))
+	require.NoError(fs.Chmod("shared", 0o777))
+	require.NoError(fs.WriteFile("small", make([]byte, 1024), 0o644))
+	require.NoError(fs.WriteFile("large", make([]byte, LargeFileSize+1), 0o644))
+
+	testCases := map[string]struct {
+		config    Config
+		wantPaths []string
+	}{
+		"secure": {
+			config: Config{
+				Mounts: []FileSystemMount{{Source: "private", Target: "/data", Type: "hostfs"}, {Target: "/tmp", Type: "memfs"}},
+				Env:    []EnvVar{{Name: "LANG", FromHost: true}, {Name: "API_TOKEN", Value: "static"}, {Name: "KEYBOARD", FromHost: true}},
+				Files:  []File{{Source: "small", Target: "/small"}},
+			},
+		},
+		"debug": {
+			config:    Config{Debug: true},
+			wantPaths: []string{"debug"},
+		},
+		"executable heap": {
+			config:    Config{ExecutableHeap: true},
+			wantPaths: []string{"executableHeap"},
+		},
+		"root hostfs": {
+			config:    Config{Mounts: []FileSystemMount{{Target: "/tmp", Type: "memfs"}, {Source: "/", Target: "/", Type: "hostfs"}}},
+			wantPaths: []string{"mounts[1]"},
+		},
+		"world-writable source": {
+			config:    Config{Mounts: []FileSystemMount{{Source: "shared", Target: "/data", Type: "hostfs"}}},
+			wantPaths: []string{"mounts[0].source"},
+		},
+		"secrets from host": {
+			config: Config{Env: []EnvVar{
+				{Name: "DB_PASSWORD", FromHost: true},
+				{Name: "LANG", FromHost: true},
+				{Name: "api_key", FromHost: true},
+				{Name: "SIGNING_KEY", FromHost: true},
+			}},
+			wantPaths: []string{"env[0].fromHost", "env[2].fromHost", "env[3].fromHost"},
+		},
+		"large file": {
+			config:    Config{Files: []File{{Source: "small", Target: "/small"}, {Source: "large", Target: "/large"}}},
+			wantPaths: []string{"files[1].source"},
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			var paths []string
+			for _, diag := range tc.config.Audit(fs) {
+				assert.Equal(t, SeverityWarning, diag.Severity)
+				paths = append(paths, diag.Path)
+			}
+			assert.Equal(t, tc.wantPaths, paths)
+		})
+	}
+}
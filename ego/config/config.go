@@ -7,8 +7,13 @@
 package config
 
 import (
+	"cmp"
 	"encoding/base64"
+	"encoding/json"
+	"errors"
 	"fmt"
+	"slices"
+	"strconv"
 	"strings"
 
 	"github.com/spf13/afero"
@@ -23,16 +28,55 @@ type Config struct {
 	ExecutableHeap  bool              `json:"executableHeap"`
 	ProductID       int               `json:"productID"`
 	SecurityVersion int               `json:"securityVersion"`
+	NumTCS          int               `json:"numTCS,omitempty"`
+	StackSize       int               `json:"stackSize,omitempty"`
 	Mounts          []FileSystemMount `json:"mounts"`
 	Env             []EnvVar          `json:"env"`
 	Files           []File            `json:"files"`
+
+	// AllowUnsupported lists findings of 'ego check' that are accepted. Not embedded into the enclave.
+	AllowUnsupported []string `json:"allowUnsupported,omitempty"`
+}
+
+const (
+	// DefaultNumTCS is the number of TCS if numTCS isn't set.
+	DefaultNumTCS = 32
+	// MinNumTCS is the minimum number of TCS. The EGo runtime only limits the number of threads if there are at least 6 TCS.
+	MinNumTCS = 6
+	// MaxNumTCS is the maximum number of TCS.
+	MaxNumTCS = 1024
+	// DefaultStackSize is the stack size in MB if stackSize isn't set.
+	DefaultStackSize = 4
+	// MaxStackSize is the maximum stack size in MB.
+	MaxStackSize = 256
+)
+
+// GetNumTCS returns the number of TCS, i.e., the maximum number of threads in the enclave.
+func (c *Config) GetNumTCS() int {
+	if c.NumTCS == 0 {
+		return DefaultNumTCS
+	}
+	return c.NumTCS
+}
+
+// GetStackSize returns the stack size of each thread in MB.
+func (c *Config) GetStackSize() int {
+	if c.StackSize == 0 {
+		return DefaultStackSize
+	}
+	return c.StackSize
 }
 
-// File defines File used in Config/enclave.json. Reads from source, adds content to the payload. Premain writes decoded content to target
+// File defines File used in Config/enclave.json. Reads from source, adds content to the payload. Premain writes decoded content to target.
+// Source can also be a directory or a glob pattern, see Expand.
 type File struct {
 	Base64Content string `json:"content,omitempty"`
 	Source        string `json:"source"`
 	Target        string `json:"target"`
+	// Mode is the octal permission mode of the file in the enclave, e.g., "0644". Defaults to DefaultFileMode.
+	Mode string `json:"mode,omitempty"`
+	// Compress compresses the content in the payload with gzip.
+	Compress bool `json:"compress,omitempty"`
 }
 
 // FileSystemMount defines a single mount point for the enclave's filesystem
@@ -51,109 +95,226 @@ type EnvVar struct {
 	FromHost bool   `json:"fromHost"`
 }
 
-// Validate Exe, Key, HeapSize and Mounts
+// Severity is the severity of a Diagnostic.
+type Severity string
+
+const (
+	// SeverityError means that the config can't be used.
+	SeverityError Severity = "error"
+	// SeverityWarning means that the config can be used, but may not work as intended.
+	SeverityWarning Severity = "warning"
+)
+
+// Diagnostic is a problem found in a config.
+type Diagnostic struct {
+	Severity Severity `json:"severity"`
+	// Path is the JSON path of the setting, e.g., mounts[0].target.
+	Path    string `json:"path"`
+	Message string `json:"message"`
+}
+
+func (d Diagnostic) String() string {
+	return fmt.Sprintf("%v: %v: %v", d.Severity, d.Path, d.Message)
+}
+
+// Validate Exe, Key, HeapSize and Mounts. The first error is returned. Use Diagnose to also get the warnings.
 func (c *Config) Validate() error {
+	for _, diag := range c.Diagnose() {
+		if diag.Severity == SeverityError {
+			return errors.New(diag.Message)
+		}
+	}
+	return nil
+}
+
+// Diagnose checks the config and returns all problems that are found.
+func (c *Config) Diagnose() []Diagnostic {
+	var diags []Diagnostic
+	addError := func(path, format string, args ...any) {
+		diags = append(diags, Diagnostic{Severity: SeverityError, Path: path, Message: fmt.Sprintf(format, args...)})
+	}
+	addWarning := func(path, format string, args ...any) {
+		diags = append(diags, Diagnostic{Severity: SeverityWarning, Path: path, Message: fmt.Sprintf(format, args...)})
+	}
+
 	if c.HeapSize == 0 {
-		return fmt.Errorf("heapSize not set in config file")
+		addError("heapSize", "heapSize not set in config file")
 	}
 	if c.Exe == "" {
-		return fmt.Errorf("exe not set in config file")
+		addError("exe", "exe not set in config file")
 	}
 	if c.Key == "" {
-		return fmt.Errorf("key not set in config file")
+		addError("key", "key not set in config file")
+	}
+
+	if c.NumTCS != 0 && (c.NumTCS < MinNumTCS || c.NumTCS > MaxNumTCS) {
+		addError("numTCS", "numTCS must be between %v and %v", MinNumTCS, MaxNumTCS)
+	}
+	if c.StackSize < 0 || c.StackSize > MaxStackSize {
+		addError("stackSize", "stackSize must be between 1 and %v", MaxStackSize)
 	}
 
 	// Validate file system mounts
 	alreadyUsedMountPoints := make(map[string]bool, len(c.Mounts))
-	for _, mountPoint := range c.Mounts {
+	for i, mountPoint := range c.Mounts {
+		path := fmt.Sprintf("mounts[%v]", i)
+
 		// Check if target is defined
 		if mountPoint.Target == "" {
-			return fmt.Errorf("missing target for mount declaration")
+			addError(path+".target", "missing target for mount declaration")
+			continue
 		}
 
 		// Check if a target is defined multiple times. This will cause the syscall in the premain to return an error.
-		if _, ok := alreadyUsedMountPoints[mountPoint.Target]; ok {
-			fmt.Printf("ERROR: '%s': Mount point was defined multiple times. Check your configuration.\n", mountPoint.Target)
-			return fmt.Errorf("mount target '%s' was defined multiple times", mountPoint.Target)
-		}
-
-		// Check if type is defined
-		if mountPoint.Type == "" {
-			return fmt.Errorf("missing type for mount target '%s'", mountPoint.Target)
-		}
-
-		// Check if source is not empty when using hostfs
-		if mountPoint.Type == "hostfs" && mountPoint.Source == "" {
-			return fmt.Errorf("no source given for mount target '%s", mountPoint.Target)
-		}
-
-		// Check if 'hostfs' or 'memfs' was set as type
-		if mountPoint.Type != "hostfs" && mountPoint.Type != "memfs" {
-			fmt.Printf("ERROR: '%s': Only mount types 'hostfs' and 'memfs' are accepted.\n", mountPoint.Target)
-			return fmt.Errorf("an invalid mount type was specified: %s", mountPoint.Type)
-		}
-
-		// Warn user that 'memfs' source does nothing
-		if mountPoint.Type == "memfs" && mountPoint.Source != "" && mountPoint.Source != "/" {
-			fmt.Printf("WARNING: '%s': The mount point of type 'memfs' specified a source directory, will be ignored.\n", mountPoint.Target)
-		}
-
-		// Warn user that a read-only 'memfs' is useless
-		if mountPoint.Type == "memfs" && mountPoint.ReadOnly {
-			fmt.Printf("WARNING: '%s': The mount point of type 'memfs' is set as read-only, making it effectively useless. Check your configuration.\n", mountPoint.Target)
+		if alreadyUsedMountPoints[mountPoint.Target] {
+			addError(path+".target", "mount target '%s' was defined multiple times", mountPoint.Target)
 		}
+		alreadyUsedMountPoints[mountPoint.Target] = true
 
-		if mountPoint.Target == "/" && mountPoint.Type == "hostfs" {
-			fmt.Printf("WARNING: '%s' is defined as 'hostfs'. This might be insecure, please make sure you explicitly allow the paths you want to expose to the enclave.\n", mountPoint.Target)
+		switch mountPoint.Type {
+		case "":
+			addError(path+".type", "missing type for mount target '%s'", mountPoint.Target)
+		case "hostfs":
+			// Check if source is not empty when using hostfs
+			if mountPoint.Source == "" {
+				addError(path+".source", "no source given for mount target '%s'", mountPoint.Target)
+			}
+			if mountPoint.Target == "/" {
+				addWarning(path, "'%s' is defined as 'hostfs'. This might be insecure, please make sure you explicitly allow the paths you want to expose to the enclave.", mountPoint.Target)
+			}
+		case "memfs":
+			// Warn user that 'memfs' source does nothing
+			if mountPoint.Source != "" && mountPoint.Source != "/" {
+				addWarning(path+".source", "'%s': The mount point of type 'memfs' specified a source directory, will be ignored.", mountPoint.Target)
+			}
+			// Warn user that a read-only 'memfs' is useless
+			if mountPoint.ReadOnly {
+				addWarning(path+".readOnly", "'%s': The mount point of type 'memfs' is set as read-only, making it effectively useless. Check your configuration.", mountPoint.Target)
+			}
+		default:
+			addError(path+".type", "an invalid mount type was specified: %s. Only mount types 'hostfs' and 'memfs' are accepted.", mountPoint.Type)
 		}
-
-		// Add already existing target to map of used targets for redefiniton checks
-		alreadyUsedMountPoints[mountPoint.Target] = true
 	}
 
 	// Validate environment variables
 	alreadyUsedEnvVars := make(map[string]bool, len(c.Env))
-	for _, envVar := range c.Env {
+	for i, envVar := range c.Env {
+		path := fmt.Sprintf("env[%v]", i)
+
 		// Check if name is missing for environment variable
 		if envVar.Name == "" {
-			return fmt.Errorf("missing name for environment variable definition in config")
+			addError(path+".name", "missing name for environment variable definition in config")
+			continue
 		}
 
 		// Check if name contains '=', which technically is only allowed on Windows, not on Unix
 		if strings.Contains(envVar.Name, "=") {
-			return fmt.Errorf("'%s': = is a disallowed character for the environment variable name", envVar.Name)
+			addError(path+".name", "'%s': = is a disallowed character for the environment variable name", envVar.Name)
 		}
 
 		// Check if value is not supposed to be copied from host but also does not contain any value
 		if !envVar.FromHost && envVar.Value == "" {
-			fmt.Printf("WARNING: '%s': Trying to initialize an environment variable without a value specified, nor copying it from the host system. Will be ignored. \n", envVar.Name)
+			addWarning(path+".value", "'%s': Trying to initialize an environment variable without a value specified, nor copying it from the host system. Will be ignored.", envVar.Name)
 		}
 
 		// Check if environment variable was declared multiple times
-		if _, ok := alreadyUsedEnvVars[envVar.Name]; ok {
-			fmt.Printf("ERROR: '%s': Environment variable was defined multiple times. Check your configuration.\n", envVar.Name)
-			return fmt.Errorf("envrionment variable '%s' was defined multiple times", envVar.Name)
+		if alreadyUsedEnvVars[envVar.Name] {
+			addError(path+".name", "environment variable '%s' was defined multiple times", envVar.Name)
 		}
-
 		alreadyUsedEnvVars[envVar.Name] = true
+
+		// More threads than TCS cause the enclave to fail
+		if envVar.Name == "EGOMAXTHREADS" && !envVar.FromHost {
+			if maxThreads, err := strconv.Atoi(envVar.Value); err == nil && maxThreads > c.GetNumTCS() {
+				addError(path+".value", "EGOMAXTHREADS=%v exceeds numTCS=%v", maxThreads, c.GetNumTCS())
+			}
+		}
 	}
 
-	return nil
+	// Validate embedded files
+	for i, file := range c.Files {
+		if _, err := file.GetMode(); err != nil {
+			addError(fmt.Sprintf("files[%v].mode", i), "%v", err)
+		}
+	}
+
+	return diags
 }
 
-// PopulateContent encodes Source into base64Content
+// PopulateContent expands directories and glob patterns of Files and encodes the content of each file into Base64Content.
 func (c *Config) PopulateContent(fs afero.Afero) error {
-	for i, file := range c.Files {
+	var files []File
+	for _, file := range c.Files {
+		expanded, err := file.Expand(fs)
+		if err != nil {
+			return err
+		}
+		files = append(files, expanded...)
+	}
+
+	for i, file := range files {
 		buff, err := fs.ReadFile(file.Source)
 		if err != nil {
 			return err
 		}
-		c.Files[i].Base64Content = base64.StdEncoding.EncodeToString(buff)
+		if file.Compress {
+			if buff, err = compress(buff); err != nil {
+				return err
+			}
+		}
+		files[i].Base64Content = base64.StdEncoding.EncodeToString(buff)
 	}
+	c.Files = files
 	return nil
 }
 
+// Payload returns the encoded payload that is embedded into the enclave and thus part of its measurement.
+// It only contains the settings that the enclave uses at runtime. Host paths like Exe, Key, and the sources
+// of embedded files aren't included, and mounts, environment variables, and files are ordered canonically.
+// So the UniqueID doesn't depend on where the enclave has been signed.
+func (c *Config) Payload() ([]byte, error) {
+	mounts := slices.Clone(c.Mounts)
+	slices.SortFunc(mounts, func(a, b FileSystemMount) int { return cmp.Compare(a.Target, b.Target) })
+	env := slices.Clone(c.Env)
+	slices.SortFunc(env, func(a, b EnvVar) int { return cmp.Compare(a.Name, b.Name) })
+
+	// a later file with the same target overwrites an earlier one, so keep their order
+	files := make([]payloadFile, len(c.Files))
+	for i, file := range c.Files {
+		mode, err := file.GetMode()
+		if err != nil {
+			return nil, err
+		}
+		files[i] = payloadFile{Base64Content: file.Base64Content, Target: file.Target, Compress: file.Compress}
+		// the default mode is omitted so that the payload doesn't depend on whether it is set explicitly
+		if mode != DefaultFileMode {
+			files[i].Mode = fmt.Sprintf("%04o", mode)
+		}
+	}
+	slices.SortStableFunc(files, func(a, b payloadFile) int { return cmp.Compare(a.Target, b.Target) })
+
+	return json.Marshal(payload{Mounts: mounts, Env: env, Files: files})
+}
+
+// payload is the subset of Config that is embedded into the enclave. It can be decoded as Config.
+type payload struct {
+	Mounts []FileSystemMount `json:"mounts,omitempty"`
+	Env    []EnvVar          `json:"env,omitempty"`
+	Files  []payloadFile     `json:"files,omitempty"`
+}
+
+type payloadFile struct {
+	Base64Content string `json:"content"`
+	Target        string `json:"target"`
+	Mode          string `json:"mode,omitempty"`
+	Compress      bool   `json:"compress,omitempty"`
+}
+
 // GetContent return the decoded content
 func (f *File) GetContent() ([]byte, error) {
-	return base64.StdEncoding.DecodeString(f.Base64Content)
+	content, err := base64.StdEncoding.DecodeString(f.Base64Content)
+	if err != nil || !f.Compress {
+		return content, err
+	}
+	return decompress(content)
 }
@@ -7,6 +7,7 @@
 package config
 
 import (
+	"encoding/json"
 	"testing"
 
 	"github.com/spf13/afero"
@@ -119,6 +120,67 @@ func TestValidateEnvVars(t *testing.T) {
 	assert.Error(config.Validate())
 }
 
+func TestDiagnose(t *testing.T) {
+	config := Config{
+		HeapSize: 512,
+		Key:      "somekey.key",
+		Mounts: []FileSystemMount{
+			{Target: "/data", Type: "hostfs", Source: "/data"},
+			{Target: "/tmp", Type: "memfs", ReadOnly: true},
+			{Target: "/data", Type: "memfs"},
+		},
+		Env: []EnvVar{{Name: "FOO"}, {Name: "A=B", Value: "1"}},
+	}
+
+	expected := []Diagnostic{
+		{Severity: SeverityError, Path: "exe", Message: "exe not set in config file"},
+		{Severity: SeverityWarning, Path: "mounts[1].readOnly", Message: "'/tmp': The mount point of type 'memfs' is set as read-only, making it effectively useless. Check your configuration."},
+		{Severity: SeverityError, Path: "mounts[2].target", Message: "mount target '/data' was defined multiple times"},
+		{Severity: SeverityWarning, Path: "env[0].value", Message: "'FOO': Trying to initialize an environment variable without a value specified, nor copying it from the host system. Will be ignored."},
+		{Severity: SeverityError, Path: "env[1].name", Message: "'A=B': = is a disallowed character for the environment variable name"},
+	}
+	assert.Equal(t, expected, config.Diagnose())
+
+	// Validate returns the first error
+	assert.EqualError(t, config.Validate(), "exe not set in config file")
+}
+
+func TestValidateThreads(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	config := Config{HeapSize: 512, Exe: "text_exe", Key: "somekey.key"}
+	require.NoError(config.Validate())
+	assert.Equal(DefaultNumTCS, config.GetNumTCS())
+	assert.Equal(DefaultStackSize, config.GetStackSize())
+
+	config.NumTCS = 64
+	config.StackSize = 2
+	require.NoError(config.Validate())
+	assert.Equal(64, config.GetNumTCS())
+	assert.Equal(2, config.GetStackSize())
+
+	config.NumTCS = MinNumTCS - 1
+	assert.Error(config.Validate())
+	config.NumTCS = MaxNumTCS + 1
+	assert.Error(config.Validate())
+	config.NumTCS = 64
+
+	config.StackSize = -1
+	assert.Error(config.Validate())
+	config.StackSize = MaxStackSize + 1
+	assert.Error(config.Validate())
+	config.StackSize = 0
+
+	// EGOMAXTHREADS must not exceed numTCS
+	config.Env = []EnvVar{{Name: "EGOMAXTHREADS", Value: "64"}}
+	assert.NoError(config.Validate())
+	config.Env = []EnvVar{{Name: "EGOMAXTHREADS", Value: "65"}}
+	assert.Error(config.Validate())
+	config.Env = []EnvVar{{Name: "EGOMAXTHREADS", Value: "65", FromHost: true}}
+	assert.NoError(config.Validate())
+}
+
 func TestEmbeddedFile(t *testing.T) {
 	assert := assert.New(t)
 	require := require.New(t)
@@ -157,3 +219,65 @@ func TestEmbeddedFile(t *testing.T) {
 	require.NoError(err)
 	assert.Equal(content2, content)
 }
+
+func TestPayload(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	config := Config{
+		Exe:             "/home/user/app/exe",
+		Key:             "/home/user/app/private.pem",
+		Debug:           true,
+		HeapSize:        512,
+		ProductID:       1,
+		SecurityVersion: 2,
+		Mounts: []FileSystemMount{
+			{Target: "/tmp", Type: "memfs"},
+			{Source: "/data", Target: "/data", Type: "hostfs", ReadOnly: true},
+		},
+		Env: []EnvVar{
+			{Name: "FOO", Value: "foo"},
+			{Name: "BAR", FromHost: true},
+		},
+		Files: []File{
+			{Source: "/home/user/app/b", Target: "/b", Base64Content: "Yg=="},
+			{Source: "/home/user/app/a", Target: "/a", Base64Content: "YQ=="},
+			{Source: "/home/user/app/b2", Target: "/b", Base64Content: "YjI="},
+		},
+	}
+
+	payload, err := config.Payload()
+	require.NoError(err)
+	assert.Equal(`{"mounts":[{"source":"/data","target":"/data","type":"hostfs","readOnly":true},{"source":"","target":"/tmp","type":"memfs","readOnly":false}],`+
+		`"env":[{"name":"BAR","value":"","fromHost":true},{"name":"FOO","value":"foo","fromHost":false}],`+
+		`"files":[{"content":"YQ==","target":"/a"},{"content":"Yg==","target":"/b"},{"content":"YjI=","target":"/b"}]}`, string(payload))
+
+	// the config isn't modified
+	assert.Equal("/tmp", config.Mounts[0].Target)
+	assert.Equal("FOO", config.Env[0].Name)
+	assert.Equal("/home/user/app/b", config.Files[0].Source)
+
+	// the payload can be decoded as Config
+	var decoded Config
+	require.NoError(json.Unmarshal(payload, &decoded))
+	assert.Empty(decoded.Exe)
+	assert.Len(decoded.Mounts, 2)
+	assert.Len(decoded.Env, 2)
+	require.Len(decoded.Files, 3)
+	content, err := decoded.Files[0].GetContent()
+	require.NoError(err)
+	assert.Equal([]byte("a"), content)
+
+	// the order in the config doesn't matter
+	config.Mounts[0], config.Mounts[1] = config.Mounts[1], config.Mounts[0]
+	config.Env[0], config.Env[1] = config.Env[1], config.Env[0]
+	config.Files[0], config.Files[1] = config.Files[1], config.Files[0]
+	otherPayload, err := config.Payload()
+	require.NoError(err)
+	assert.Equal(payload, otherPayload)
+
+	// an empty config results in an empty payload
+	payload, err = (&Config{Exe: "exe", Key: "key", HeapSize: 512}).Payload()
+	require.NoError(err)
+	assert.Equal("{}", string(payload))
+}
@@ -0,0 +1,133 @@
+// Copyright (c) Edgeless Systems GmbH.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package config
+
+import (
+	"bytes"
+	"compress/gzip"
+	"fmt"
+	"io"
+	"os"
+	"path"
+	"path/filepath"
+	"strconv"
+	"strings"
+
+	"github.com/spf13/afero"
+)
+
+// DefaultFileMode is the mode of embedded files if Mode isn't set.
+const DefaultFileMode os.FileMode = 0o600
+
+// GetMode returns the permission mode of the file in the enclave.
+func (f *File) GetMode() (os.FileMode, error) {
+	if f.Mode == "" {
+		return DefaultFileMode, nil
+	}
+	mode, err := strconv.ParseUint(f.Mode, 8, 32)
+	if err != nil || mode > 0o777 {
+		return 0, fmt.Errorf("invalid mode %q: must be an octal number like 0644", f.Mode)
+	}
+	return os.FileMode(mode), nil
+}
+
+// GetDirMode returns the permission mode of the parent directories that are created for the file.
+// Directories can be entered by everyone who can read the file.
+func (f *File) GetDirMode() (os.FileMode, error) {
+	mode, err := f.GetMode()
+	if err != nil {
+		return 0, err
+	}
+	return 0o700 | mode | (mode&0o044)>>2, nil
+}
+
+// Expand returns an entry for each regular file of Source, which can be a file, a directory, or a glob pattern.
+//
+// The files of a directory are embedded recursively below Target. The matches of a glob pattern are embedded
+// into the Target directory with their base names, and directories are again embedded recursively.
+// Symbolic links to files are followed, while symbolic links to directories are skipped.
+// Files are returned in lexical order, so the payload is deterministic.
+func (f File) Expand(fs afero.Afero) ([]File, error) {
+	sources := []string{f.Source}
+	isPattern := strings.ContainsAny(f.Source, `*?[\`)
+	if isPattern {
+		var err error
+		if sources, err = afero.Glob(fs, f.Source); err != nil {
+			return nil, err
+		}
+		if len(sources) == 0 {
+			return nil, fmt.Errorf("no files match %v", f.Source)
+		}
+	}
+
+	var files []File
+	for _, source := range sources {
+		target := f.Target
+		if isPattern {
+			target = path.Join(f.Target, filepath.Base(source))
+		}
+
+		info, err := fs.Stat(source)
+		if err != nil {
+			return nil, err
+		}
+		if !info.IsDir() {
+			files = append(files, f.withSource(source, target))
+			continue
+		}
+
+		err = fs.Walk(source, func(name string, _ os.FileInfo, err error) error {
+			if err != nil {
+				return err
+			}
+			info, err := fs.Stat(name)
+			if err != nil || !info.Mode().IsRegular() {
+				return err
+			}
+			rel, err := filepath.Rel(source, name)
+			if err != nil {
+				return err
+			}
+			files = append(files, f.withSource(name, path.Join(target, filepath.ToSlash(rel))))
+			return nil
+		})
+		if err != nil {
+			return nil, err
+		}
+	}
+	return files, nil
+}
+
+func (f File) withSource(source, target string) File {
+	f.Source = source
+	f.Target = target
+	return f
+}
+
+// compress compresses data deterministically with gzip.
+func compress(data []byte) ([]byte, error) {
+	var buf bytes.Buffer
+	w, err := gzip.NewWriterLevel(&buf, gzip.BestCompression)
+	if err != nil {
+		return nil, err
+	}
+	if _, err := w.Write(data); err != nil {
+		return nil, err
+	}
+	if err := w.Close(); err != nil {
+		return nil, err
+	}
+	return buf.Bytes(), nil
+}
+
+func decompress(data []byte) ([]byte, error) {
+	r, err := gzip.NewReader(bytes.NewReader(data))
+	if err != nil {
+		return nil, err
+	}
+	return io.ReadAll(r)
+}
@@ -0,0 +1,181 @@
+// Copyright (c) Edgeless Systems GmbH.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package config
+
+import (
+	"bytes"
+	"encoding/json"
+	"os"
+	"testing"
+
+	"github.com/spf13/afero"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestFileExpand(t *testing.T) {
+	fs := afero.Afero{Fs: afero.NewMemMapFs()}
+	for _, name := range []string{"assets/b.css", "assets/a.css", "assets/img/logo.png", "templates/index.html", "templates/base.html", "templates/README"} {
+		require.NoError(t, fs.WriteFile(name, []byte(name), 0o644))
+	}
+	require.NoError(t, fs.Mkdir("assets/empty", 0o755))
+
+	testCases := map[string]struct {
+		file    File
+		want    map[string]string // source -> target
+		wantErr bool
+	}{
+		"file": {
+			file: File{Source: "assets/a.css", Target: "/style.css"},
+			want: map[string]string{"assets/a.css": "/style.css"},
+		},
+		"directory": {
+			file: File{Source: "assets", Target: "/srv/static"},
+			want: map[string]string{
+				"assets/a.css":        "/srv/static/a.css",
+				"assets/b.css":        "/srv/static/b.css",
+				"assets/img/logo.png": "/srv/static/img/logo.png",
+			},
+		},
+		"glob": {
+			file: File{Source: "templates/*.html", Target: "/templates"},
+			want: map[string]string{
+				"templates/base.html":  "/templates/base.html",
+				"templates/index.html": "/templates/index.html",
+			},
+		},
+		"glob matching directory": {
+			file: File{Source: "assets/i*", Target: "/srv"},
+			want: map[string]string{"assets/img/logo.png": "/srv/img/logo.png"},
+		},
+		"no match": {
+			file:    File{Source: "templates/*.txt", Target: "/templates"},
+			wantErr: true,
+		},
+		"does not exist": {
+			file:    File{Source: "doesnotexist", Target: "/file"},
+			wantErr: true,
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			assert := assert.New(t)
+
+			tc.file.Mode = "0644"
+			tc.file.Compress = true
+			files, err := tc.file.Expand(fs)
+			if tc.wantErr {
+				assert.Error(err)
+				return
+			}
+			require.NoError(t, err)
+
+			got := map[string]string{}
+			for i, file := range files {
+				got[file.Source] = file.Target
+				assert.Equal("0644", file.Mode)
+				assert.True(file.Compress)
+				if i > 0 {
+					assert.Less(files[i-1].Source, file.Source)
+				}
+			}
+			assert.Equal(tc.want, got)
+		})
+	}
+}
+
+func TestFileMode(t *testing.T) {
+	testCases := map[string]struct {
+		mode        string
+		wantMode    os.FileMode
+		wantDirMode os.FileMode
+		wantErr     bool
+	}{
+		"default":     {wantMode: 0o600, wantDirMode: 0o700},
+		"readable":    {mode: "0644", wantMode: 0o644, wantDirMode: 0o755},
+		"short":       {mode: "640", wantMode: 0o640, wantDirMode: 0o750},
+		"executable":  {mode: "0700", wantMode: 0o700, wantDirMode: 0o700},
+		"read-only":   {mode: "0400", wantMode: 0o400, wantDirMode: 0o700},
+		"not octal":   {mode: "0999", wantErr: true},
+		"too large":   {mode: "01777", wantErr: true},
+		"not numeric": {mode: "rw-r--r--", wantErr: true},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			assert := assert.New(t)
+
+			file := File{Mode: tc.mode}
+			mode, err := file.GetMode()
+			dirMode, dirErr := file.GetDirMode()
+			if tc.wantErr {
+				assert.Error(err)
+				assert.Error(dirErr)
+				return
+			}
+			assert.NoError(err)
+			assert.NoError(dirErr)
+			assert.Equal(tc.wantMode, mode)
+			assert.Equal(tc.wantDirMode, dirMode)
+		})
+	}
+
+	// invalid modes are reported by Diagnose
+	config := Config{HeapSize: 512, Exe: "exe", Key: "key", Files: []File{{Source: "a", Target: "/a", Mode: "0999"}}}
+	diags := config.Diagnose()
+	require.Len(t, diags, 1)
+	assert.Equal(t, "files[0].mode", diags[0].Path)
+}
+
+func TestCompressedFile(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	fs := afero.Afero{Fs: afero.NewMemMapFs()}
+	content := bytes.Repeat([]byte("compressible "), 1000)
+	require.NoError(fs.WriteFile("file", content, 0o644))
+
+	c := Config{Files: []File{{Source: "file", Target: "/file", Compress: true}}}
+	require.NoError(c.PopulateContent(fs))
+	assert.Less(len(c.Files[0].Base64Content), len(content)/10)
+	decoded, err := c.Files[0].GetContent()
+	require.NoError(err)
+	assert.Equal(content, decoded)
+
+	// the payload is deterministic and can be decoded as Config
+	payload, err := c.Payload()
+	require.NoError(err)
+	require.NoError(c.PopulateContent(fs))
+	otherPayload, err := c.Payload()
+	require.NoError(err)
+	assert.Equal(payload, otherPayload)
+
+	var payloadConfig Config
+	require.NoError(json.Unmarshal(payload, &payloadConfig))
+	require.Len(payloadConfig.Files, 1)
+	decoded, err = payloadConfig.Files[0].GetContent()
+	require.NoError(err)
+	assert.Equal(content, decoded)
+}
+
+func TestPayloadFileMode(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	config := Config{Files: []File{
+		{Target: "/a", Base64Content: "YQ==", Mode: "0600"},
+		{Target: "/b", Base64Content: "Yg==", Mode: "644"},
+	}}
+	payload, err := config.Payload()
+	require.NoError(err)
+	assert.Equal(`{"files":[{"content":"YQ==","target":"/a"},{"content":"Yg==","target":"/b","mode":"0644"}]}`, string(payload))
+
+	config.Files[1].Mode = "0x1"
+	_, err = config.Payload()
+	assert.Error(err)
+}
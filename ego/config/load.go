@@ -0,0 +1,288 @@
+// Copyright (c) Edgeless Systems GmbH.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package config
+
+import (
+	"encoding/json"
+	"fmt"
+	"maps"
+	"path/filepath"
+	"slices"
+	"strings"
+
+	"github.com/spf13/afero"
+)
+
+const (
+	keyInclude  = "include"
+	keyProfiles = "profiles"
+)
+
+// listKeys are the settings whose entries are merged instead of replaced.
+// Entries are identified by the returned key. Entries of an overlay replace entries of the base
+// with the same key at the same position, and other entries are appended.
+var listKeys = map[string]func(json.RawMessage) (string, error){
+	"mounts":           fieldKey("target"),
+	"env":              fieldKey("name"),
+	"files":            fieldKey("target"),
+	"allowUnsupported": stringKey,
+}
+
+// Load reads the config file at path, resolves its include chain, and applies the given profile if it isn't empty.
+// Relative paths in included configs are rewritten to be relative to the directory of path.
+// The returned config isn't validated.
+func Load(fs afero.Afero, path string, profile string) (*Config, error) {
+	l, err := loadLayer(fs, path, filepath.Dir(path), nil)
+	if err != nil {
+		return nil, err
+	}
+
+	settings := l.settings
+	if profile != "" {
+		overlay, ok := l.profiles[profile]
+		if !ok {
+			return nil, fmt.Errorf("profile %q not found in %v, available profiles: %v", profile, path, slices.Sorted(maps.Keys(l.profiles)))
+		}
+		if settings, err = merge(settings, overlay); err != nil {
+			return nil, fmt.Errorf("applying profile %q: %w", profile, err)
+		}
+	}
+
+	data, err := json.Marshal(settings)
+	if err != nil {
+		return nil, err
+	}
+	var conf Config
+	if err := json.Unmarshal(data, &conf); err != nil {
+		return nil, err
+	}
+	return &conf, nil
+}
+
+// layer is a config file merged with the configs it includes.
+type layer struct {
+	settings map[string]json.RawMessage
+	profiles map[string]map[string]json.RawMessage
+}
+
+func loadLayer(fs afero.Afero, path string, rootDir string, chain []string) (layer, error) {
+	if slices.Contains(chain, filepath.Clean(path)) {
+		return layer{}, fmt.Errorf("include cycle: %v -> %v", strings.Join(chain, " -> "), filepath.Clean(path))
+	}
+	chain = append(chain, filepath.Clean(path))
+
+	data, err := fs.ReadFile(path)
+	if err != nil {
+		return layer{}, err
+	}
+	var settings map[string]json.RawMessage
+	if err := json.Unmarshal(data, &settings); err != nil {
+		return layer{}, fmt.Errorf("%v: %w", path, err)
+	}
+	if settings == nil {
+		settings = map[string]json.RawMessage{}
+	}
+
+	var include string
+	if raw, ok := settings[keyInclude]; ok {
+		if err := json.Unmarshal(raw, &include); err != nil {
+			return layer{}, fmt.Errorf("%v: %v must be a path: %w", path, keyInclude, err)
+		}
+		delete(settings, keyInclude)
+	}
+	profiles := map[string]map[string]json.RawMessage{}
+	if raw, ok := settings[keyProfiles]; ok {
+		if err := json.Unmarshal(raw, &profiles); err != nil {
+			return layer{}, fmt.Errorf("%v: %v must map names to configs: %w", path, keyProfiles, err)
+		}
+		delete(settings, keyProfiles)
+		if profiles == nil {
+			profiles = map[string]map[string]json.RawMessage{}
+		}
+	}
+
+	// paths are relative to the file that sets them
+	dir := filepath.Dir(path)
+	if settings, err = rebasePaths(settings, dir, rootDir); err != nil {
+		return layer{}, fmt.Errorf("%v: %w", path, err)
+	}
+	for name, overlay := range profiles {
+		if _, ok := overlay[keyInclude]; ok {
+			return layer{}, fmt.Errorf("%v: profile %q must not set %v", path, name, keyInclude)
+		}
+		if _, ok := overlay[keyProfiles]; ok {
+			return layer{}, fmt.Errorf("%v: profile %q must not set %v", path, name, keyProfiles)
+		}
+		if profiles[name], err = rebasePaths(overlay, dir, rootDir); err != nil {
+			return layer{}, fmt.Errorf("%v: profile %q: %w", path, name, err)
+		}
+	}
+
+	if include == "" {
+		return layer{settings: settings, profiles: profiles}, nil
+	}
+	if !filepath.IsAbs(include) {
+		include = filepath.Join(dir, include)
+	}
+	base, err := loadLayer(fs, include, rootDir, chain)
+	if err != nil {
+		return layer{}, err
+	}
+
+	if base.settings, err = merge(base.settings, settings); err != nil {
+		return layer{}, fmt.Errorf("%v: %w", path, err)
+	}
+	for name, overlay := range profiles {
+		baseProfile, ok := base.profiles[name]
+		if !ok {
+			base.profiles[name] = overlay
+			continue
+		}
+		if base.profiles[name], err = merge(baseProfile, overlay); err != nil {
+			return layer{}, fmt.Errorf("%v: profile %q: %w", path, name, err)
+		}
+	}
+	return base, nil
+}
+
+// merge applies the settings of overlay to base. Lists are merged according to listKeys,
+// null resets a setting, and other settings are replaced.
+func merge(base, overlay map[string]json.RawMessage) (map[string]json.RawMessage, error) {
+	result := maps.Clone(base)
+	if result == nil {
+		result = map[string]json.RawMessage{}
+	}
+	for key, value := range overlay {
+		baseValue, ok := result[key]
+		switch listKey := listKeys[key]; {
+		case string(value) == "null":
+			delete(result, key)
+		case ok && listKey != nil:
+			merged, err := mergeList(baseValue, value, listKey)
+			if err != nil {
+				return nil, fmt.Errorf("merging %v: %w", key, err)
+			}
+			result[key] = merged
+		default:
+			result[key] = value
+		}
+	}
+	return result, nil
+}
+
+func mergeList(base, overlay json.RawMessage, key func(json.RawMessage) (string, error)) (json.RawMessage, error) {
+	var baseList, overlayList []json.RawMessage
+	if err := json.Unmarshal(base, &baseList); err != nil {
+		return nil, err
+	}
+	if err := json.Unmarshal(overlay, &overlayList); err != nil {
+		return nil, err
+	}
+
+	index := make(map[string]int, len(baseList))
+	for i, entry := range baseList {
+		k, err := key(entry)
+		if err != nil {
+			return nil, err
+		}
+		index[k] = i
+	}
+	for _, entry := range overlayList {
+		k, err := key(entry)
+		if err != nil {
+			return nil, err
+		}
+		if i, ok := index[k]; ok {
+			baseList[i] = entry
+		} else {
+			index[k] = len(baseList)
+			baseList = append(baseList, entry)
+		}
+	}
+	return json.Marshal(baseList)
+}
+
+func fieldKey(field string) func(json.RawMessage) (string, error) {
+	return func(entry json.RawMessage) (string, error) {
+		var fields map[string]json.RawMessage
+		if err := json.Unmarshal(entry, &fields); err != nil {
+			return "", err
+		}
+		var key string
+		if raw, ok := fields[field]; ok {
+			if err := json.Unmarshal(raw, &key); err != nil {
+				return "", fmt.Errorf("%v: %w", field, err)
+			}
+		}
+		return key, nil
+	}
+}
+
+func stringKey(entry json.RawMessage) (string, error) {
+	var key string
+	err := json.Unmarshal(entry, &key)
+	return key, err
+}
+
+// rebasePaths rewrites the relative host paths of settings from dir to be relative to rootDir.
+func rebasePaths(settings map[string]json.RawMessage, dir, rootDir string) (map[string]json.RawMessage, error) {
+	if filepath.Clean(dir) == filepath.Clean(rootDir) {
+		return settings, nil
+	}
+	rebase := func(raw json.RawMessage) (json.RawMessage, error) {
+		var path string
+		if err := json.Unmarshal(raw, &path); err != nil {
+			return nil, err
+		}
+		if path == "" || filepath.IsAbs(path) {
+			return raw, nil
+		}
+		path = filepath.Join(dir, path)
+		if rel, err := filepath.Rel(rootDir, path); err == nil {
+			path = rel
+		}
+		return json.Marshal(path)
+	}
+
+	result := maps.Clone(settings)
+	for _, key := range []string{"exe", "key"} {
+		raw, ok := result[key]
+		if !ok {
+			continue
+		}
+		path, err := rebase(raw)
+		if err != nil {
+			return nil, fmt.Errorf("%v: %w", key, err)
+		}
+		result[key] = path
+	}
+
+	raw, ok := result["files"]
+	if !ok || string(raw) == "null" {
+		return result, nil
+	}
+	var files []map[string]json.RawMessage
+	if err := json.Unmarshal(raw, &files); err != nil {
+		return nil, fmt.Errorf("files: %w", err)
+	}
+	for _, file := range files {
+		source, ok := file["source"]
+		if !ok {
+			continue
+		}
+		var err error
+		if file["source"], err = rebase(source); err != nil {
+			return nil, fmt.Errorf("files: source: %w", err)
+		}
+	}
+	filesData, err := json.Marshal(files)
+	if err != nil {
+		return nil, err
+	}
+	result["files"] = filesData
+	return result, nil
+}
@@ -0,0 +1,174 @@
+// Copyright (c) Edgeless Systems GmbH.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package config
+
+import (
+	"testing"
+
+	"github.com/spf13/afero"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestLoad(t *testing.T) {
+	const base = `{
+	"exe": "app",
+	"key": "private.pem",
+	"heapSize": 512,
+	"debug": true,
+	"mounts": [
+		{"target": "/data", "type": "hostfs", "source": "/home/user"},
+		{"target": "/tmp", "type": "memfs"}
+	],
+	"env": [{"name": "LANG", "fromHost": true}, {"name": "MODE", "value": "base"}],
+	"files": [{"source": "ca.crt", "target": "/etc/ca.crt"}],
+	"allowUnsupported": ["os/exec"],
+	"profiles": {
+		"prod": {"debug": false, "env": [{"name": "MODE", "value": "prod"}]}
+	}
+}`
+
+	testCases := map[string]struct {
+		files    map[string]string
+		path     string
+		profile  string
+		wantErr  bool
+		override func(*Config)
+	}{
+		"no include": {
+			files: map[string]string{"enclave.json": base},
+			path:  "enclave.json",
+		},
+		"profile": {
+			files:   map[string]string{"enclave.json": base},
+			path:    "enclave.json",
+			profile: "prod",
+			override: func(c *Config) {
+				c.Debug = false
+				c.Env[1].Value = "prod"
+			},
+		},
+		"unknown profile": {
+			files:   map[string]string{"enclave.json": base},
+			path:    "enclave.json",
+			profile: "staging",
+			wantErr: true,
+		},
+		"include": {
+			files: map[string]string{
+				"base/enclave.json": base,
+				"staging.json": `{
+					"include": "base/enclave.json",
+					"heapSize": 1024,
+					"mounts": [{"target": "/tmp", "type": "memfs", "readOnly": true}, {"target": "/log", "type": "memfs"}],
+					"files": [{"source": "config.yaml", "target": "/etc/config.yaml"}],
+					"allowUnsupported": ["os/exec", "os/signal"]
+				}`,
+			},
+			path: "staging.json",
+			override: func(c *Config) {
+				c.Exe = "base/app"
+				c.Key = "base/private.pem"
+				c.HeapSize = 1024
+				c.Mounts[1].ReadOnly = true
+				c.Mounts = append(c.Mounts, FileSystemMount{Target: "/log", Type: "memfs"})
+				c.Files[0].Source = "base/ca.crt"
+				c.Files = append(c.Files, File{Source: "config.yaml", Target: "/etc/config.yaml"})
+				c.AllowUnsupported = append(c.AllowUnsupported, "os/signal")
+			},
+		},
+		"include and profile": {
+			files: map[string]string{
+				"conf/base.json": base,
+				"conf/enclave.json": `{
+					"include": "base.json",
+					"profiles": {"prod": {"heapSize": 2048}}
+				}`,
+			},
+			path:    "conf/enclave.json",
+			profile: "prod",
+			override: func(c *Config) {
+				c.Debug = false
+				c.HeapSize = 2048
+				c.Env[1].Value = "prod"
+			},
+		},
+		"null resets": {
+			files: map[string]string{
+				"base.json":    base,
+				"enclave.json": `{"include": "base.json", "env": null, "files": []}`,
+			},
+			path: "enclave.json",
+			override: func(c *Config) {
+				c.Env = nil
+			},
+		},
+		"include cycle": {
+			files: map[string]string{
+				"a.json": `{"include": "b.json"}`,
+				"b.json": `{"include": "a.json"}`,
+			},
+			path:    "a.json",
+			wantErr: true,
+		},
+		"include does not exist": {
+			files:   map[string]string{"enclave.json": `{"include": "base.json"}`},
+			path:    "enclave.json",
+			wantErr: true,
+		},
+		"profile sets include": {
+			files:   map[string]string{"enclave.json": `{"profiles": {"prod": {"include": "base.json"}}}`},
+			path:    "enclave.json",
+			wantErr: true,
+		},
+		"invalid list": {
+			files: map[string]string{
+				"base.json":    base,
+				"enclave.json": `{"include": "base.json", "env": {"name": "MODE"}}`,
+			},
+			path:    "enclave.json",
+			wantErr: true,
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			assert := assert.New(t)
+			require := require.New(t)
+
+			fs := afero.Afero{Fs: afero.NewMemMapFs()}
+			for name, data := range tc.files {
+				require.NoError(fs.WriteFile(name, []byte(data), 0o644))
+			}
+
+			conf, err := Load(fs, tc.path, tc.profile)
+			if tc.wantErr {
+				assert.Error(err)
+				return
+			}
+			require.NoError(err)
+
+			want := Config{
+				Exe:      "app",
+				Key:      "private.pem",
+				HeapSize: 512,
+				Debug:    true,
+				Mounts: []FileSystemMount{
+					{Target: "/data", Type: "hostfs", Source: "/home/user"},
+					{Target: "/tmp", Type: "memfs"},
+				},
+				Env:              []EnvVar{{Name: "LANG", FromHost: true}, {Name: "MODE", Value: "base"}},
+				Files:            []File{{Source: "ca.crt", Target: "/etc/ca.crt"}},
+				AllowUnsupported: []string{"os/exec"},
+			}
+			if tc.override != nil {
+				tc.override(&want)
+			}
+			assert.Equal(want, *conf)
+		})
+	}
+}
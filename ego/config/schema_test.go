@@ -0,0 +1,63 @@
+// Copyright (c) Edgeless Systems GmbH.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package config
+
+import (
+	"encoding/json"
+	"reflect"
+	"slices"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+// TestSchema checks that the schema has the same settings as the structs.
+func TestSchema(t *testing.T) {
+	var schema struct {
+		Properties map[string]json.RawMessage
+		Defs       map[string]struct {
+			Properties map[string]json.RawMessage
+		} `json:"$defs"`
+	}
+	require.NoError(t, json.Unmarshal(Schema(), &schema))
+
+	testCases := map[string]struct {
+		typ        any
+		properties map[string]json.RawMessage
+	}{
+		"settings": {typ: Config{}, properties: schema.Defs["settings"].Properties},
+		"mount":    {typ: FileSystemMount{}, properties: schema.Defs["mount"].Properties},
+		"envVar":   {typ: EnvVar{}, properties: schema.Defs["envVar"].Properties},
+		"file":     {typ: File{}, properties: schema.Defs["file"].Properties},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			var fields []string
+			typ := reflect.TypeOf(tc.typ)
+			for i := range typ.NumField() {
+				field, _, _ := strings.Cut(typ.Field(i).Tag.Get("json"), ",")
+				// the content of embedded files is read from the source
+				if field != "content" {
+					fields = append(fields, field)
+				}
+			}
+			var properties []string
+			for property := range tc.properties {
+				properties = append(properties, property)
+			}
+			slices.Sort(fields)
+			slices.Sort(properties)
+			assert.Equal(t, fields, properties)
+		})
+	}
+
+	assert.Contains(t, schema.Properties, keyInclude)
+	assert.Contains(t, schema.Properties, keyProfiles)
+}
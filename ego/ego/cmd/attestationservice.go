@@ -0,0 +1,51 @@
+// Copyright (c) Edgeless Systems GmbH.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package cmd
+
+import (
+	"os"
+
+	"github.com/spf13/cobra"
+)
+
+func newAttestationServiceCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "attestation-service [flags]",
+		Short: "Run a self-hosted attestation service",
+		Long: `Run a self-hosted attestation service with the API of Microsoft Azure Attestation.
+
+The service accepts Open Enclave reports on /attest/OpenEnclave, verifies them, evaluates the policy,
+and issues JSON Web Tokens with the same claims as Microsoft Azure Attestation. It publishes its keys on /certs.
+Tokens can be verified with attestation.VerifyAzureAttestationToken using the issuer URL.
+
+The flags are passed to the service. Run 'ego attestation-service -help' to list them.
+
+The policy file has the following format. A report must match at least one element of each non-empty list.
+Either uniqueIDs or signerIDs must be set. Set allowAnyEnclave to accept reports of any enclave instead.
+  {
+    "uniqueIDs": ["<hex>"],
+    "signerIDs": ["<hex>"],
+    "productIDs": [1],
+    "minSecurityVersion": 1,
+    "allowDebug": false,
+    "allowAnyEnclave": false,
+    "allowedTCBStatuses": ["UpToDate", "SWHardeningNeeded"]
+  }`,
+		Example:               "  ego attestation-service -issuer https://attestation.example.com -tls-cert cert.pem -tls-key key.pem -policy policy.json",
+		DisableFlagParsing:    true,
+		DisableFlagsInUseLine: true,
+
+		Run: func(cmd *cobra.Command, args []string) {
+			exitCode, err := newCli().AttestationService(args)
+			handleErr(err)
+			os.Exit(exitCode)
+		},
+	}
+
+	hideHelpFlag(cmd)
+	return cmd
+}
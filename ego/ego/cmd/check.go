@@ -0,0 +1,76 @@
+// Copyright (c) Edgeless Systems GmbH.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/edgelesssys/ego/ego/cli"
+	"github.com/spf13/cobra"
+)
+
+func newCheckCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "check <executable | config.json>",
+		Short: "Check an executable for features that are unsupported in enclaves",
+		Long: `Check an executable for features that are unsupported in enclaves.
+
+This includes spawning processes (os/exec, os.StartProcess), os.Pipe, os/signal, plugins,
+libc functions like fork, exec, and pipe used via cgo, and dynamic linking.
+Each finding is reported with the package that uses the feature and a link to the documentation.
+The calling package is determined heuristically from the machine code and may be missing or inaccurate.
+The same checks are also done by 'ego sign' and 'ego run', which print the findings as warnings.
+
+Findings can be accepted by adding them to the allowUnsupported list of the config.
+An entry is either the ID of a finding or its ID and package separated by a colon.
+If an executable is passed and enclave.json in the current directory specifies it, its list is used.
+
+The command exits with a non-zero status if there are findings.`,
+		Example: `  ego check myapp
+  ego check --json enclave.json`,
+		Args:          cobra.ExactArgs(1),
+		SilenceErrors: true,
+
+		RunE: func(cmd *cobra.Command, args []string) error {
+			findings, err := newCli().Check(args[0])
+			handleErr(err)
+			if err != nil {
+				return err
+			}
+
+			asJSON, err := cmd.Flags().GetBool("json")
+			if err != nil {
+				return err
+			}
+			if asJSON {
+				if findings == nil {
+					findings = []cli.Finding{}
+				}
+				out, err := json.MarshalIndent(findings, "", "  ")
+				if err != nil {
+					return err
+				}
+				fmt.Println(string(out))
+			} else if len(findings) == 0 {
+				fmt.Println("No unsupported features found.")
+			} else {
+				for _, f := range findings {
+					fmt.Println(f)
+				}
+			}
+
+			if len(findings) > 0 {
+				return fmt.Errorf("found %v unsupported features", len(findings))
+			}
+			return nil
+		},
+	}
+
+	cmd.Flags().Bool("json", false, "print the findings as JSON")
+	return cmd
+}
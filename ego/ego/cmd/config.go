@@ -0,0 +1,217 @@
+// Copyright (c) Edgeless Systems GmbH.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+
+	"github.com/edgelesssys/ego/ego/config"
+	"github.com/spf13/cobra"
+)
+
+func newConfigCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "config",
+		Short: "Work with enclave configuration files",
+		Long: `Work with enclave configuration files.
+
+A configuration can include a base configuration with "include" and define named profiles in "profiles".
+Select a profile with --profile, e.g., 'ego sign --profile prod'.`,
+		Args: cobra.NoArgs,
+	}
+
+	cmd.AddCommand(newConfigRenderCmd())
+	cmd.AddCommand(newConfigValidateCmd())
+	cmd.AddCommand(newConfigSchemaCmd())
+	cmd.AddCommand(newConfigAuditCmd())
+	return cmd
+}
+
+func newConfigRenderCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "render [config.json]",
+		Short: "Print the effective configuration",
+		Long: `Print the effective configuration after resolving includes and applying the profile.
+This is the configuration that 'ego sign' uses. The configuration defaults to enclave.json.
+
+With --payload, print the payload that 'ego sign' embeds into the enclave instead. It contains the content
+of embedded files, omits host paths like exe and key, and is part of the measurement of the enclave.`,
+		Example: `  ego config render
+  ego config render --profile prod staging.json
+  ego config render --payload`,
+		Args:          cobra.MaximumNArgs(1),
+		SilenceErrors: true,
+
+		RunE: func(cmd *cobra.Command, args []string) error {
+			var filename string
+			if len(args) > 0 {
+				filename = args[0]
+			}
+			c, err := newCliWithProfile(cmd)
+			if err != nil {
+				return err
+			}
+			payload, err := cmd.Flags().GetBool("payload")
+			if err != nil {
+				return err
+			}
+			var out []byte
+			if payload {
+				out, err = c.RenderConfigPayload(filename)
+			} else {
+				out, err = c.RenderConfig(filename)
+			}
+			handleErr(err)
+			if err != nil {
+				return err
+			}
+			fmt.Println(string(out))
+			return nil
+		},
+	}
+
+	addProfileFlag(cmd)
+	cmd.Flags().Bool("payload", false, "print the payload that is embedded into the enclave")
+	return cmd
+}
+
+func newConfigValidateCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "validate [config.json]",
+		Short: "Check a configuration for errors",
+		Long: `Check a configuration for errors and print all problems with the JSON path of the setting.
+The configuration defaults to enclave.json.
+
+The command exits with a non-zero status if there are errors. Warnings don't affect the status.`,
+		Example: `  ego config validate
+  ego config validate --json --profile prod enclave.json`,
+		Args:          cobra.MaximumNArgs(1),
+		SilenceErrors: true,
+
+		RunE: func(cmd *cobra.Command, args []string) error {
+			var filename string
+			if len(args) > 0 {
+				filename = args[0]
+			}
+			c, err := newCliWithProfile(cmd)
+			if err != nil {
+				return err
+			}
+			diags, err := c.ValidateConfig(filename)
+			handleErr(err)
+			if err != nil {
+				return err
+			}
+			if err := printDiagnostics(cmd, diags, "No problems found."); err != nil {
+				return err
+			}
+
+			var numErrors int
+			for _, diag := range diags {
+				if diag.Severity == config.SeverityError {
+					numErrors++
+				}
+			}
+			if numErrors > 0 {
+				return fmt.Errorf("found %v errors", numErrors)
+			}
+			return nil
+		},
+	}
+
+	addProfileFlag(cmd)
+	cmd.Flags().Bool("json", false, "print the problems as JSON")
+	return cmd
+}
+
+func newConfigSchemaCmd() *cobra.Command {
+	return &cobra.Command{
+		Use:   "schema",
+		Short: "Print the JSON Schema of the configuration",
+		Long: `Print the JSON Schema of the configuration. Editors can use it for completion and validation.
+Save it to a file and reference it with "$schema" in the configuration or in the settings of your editor.`,
+		Example:               "  ego config schema > enclave.schema.json",
+		Args:                  cobra.NoArgs,
+		DisableFlagsInUseLine: true,
+
+		Run: func(cmd *cobra.Command, args []string) {
+			fmt.Print(string(config.Schema()))
+		},
+	}
+}
+
+func newConfigAuditCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "audit [config.json]",
+		Short: "Check a configuration for settings that are insecure in production",
+		Long: `Check a configuration for settings that are insecure in production.
+
+This includes enabled debugging, '/' mounted as hostfs, an executable heap, secrets taken from the host,
+world-writable hostfs sources, and large embedded files. Embedded files are looked up relative to the
+configuration, and hostfs sources relative to the current directory as they are at runtime.
+Errors of the configuration are reported together with the findings. The configuration defaults to enclave.json.
+
+The command exits with a non-zero status if there are findings, so it can be used in CI.`,
+		Example: `  ego config audit
+  ego config audit --profile prod enclave.json`,
+		Args:          cobra.MaximumNArgs(1),
+		SilenceErrors: true,
+
+		RunE: func(cmd *cobra.Command, args []string) error {
+			var filename string
+			if len(args) > 0 {
+				filename = args[0]
+			}
+			c, err := newCliWithProfile(cmd)
+			if err != nil {
+				return err
+			}
+			diags, err := c.AuditConfig(filename)
+			handleErr(err)
+			if err != nil {
+				return err
+			}
+			if err := printDiagnostics(cmd, diags, "No insecure settings found."); err != nil {
+				return err
+			}
+			if len(diags) > 0 {
+				return fmt.Errorf("found %v insecure settings", len(diags))
+			}
+			return nil
+		},
+	}
+
+	addProfileFlag(cmd)
+	cmd.Flags().Bool("json", false, "print the findings as JSON")
+	return cmd
+}
+
+// printDiagnostics prints diags as text or, if --json is set, as JSON.
+func printDiagnostics(cmd *cobra.Command, diags []config.Diagnostic, noDiagsMsg string) error {
+	asJSON, err := cmd.Flags().GetBool("json")
+	if err != nil {
+		return err
+	}
+	if asJSON {
+		if diags == nil {
+			diags = []config.Diagnostic{}
+		}
+		out, err := json.MarshalIndent(diags, "", "  ")
+		if err != nil {
+			return err
+		}
+		fmt.Println(string(out))
+	} else if len(diags) == 0 {
+		fmt.Println(noDiagsMsg)
+	} else {
+		for _, diag := range diags {
+			fmt.Println(diag)
+		}
+	}
+	return nil
+}
@@ -0,0 +1,100 @@
+// Copyright (c) Edgeless Systems GmbH.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package cmd
+
+import (
+	"encoding/json"
+	"fmt"
+	"os"
+	"slices"
+	"text/tabwriter"
+
+	"github.com/edgelesssys/ego/ego/cli"
+	"github.com/spf13/cobra"
+)
+
+func newInspectCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "inspect <executable>",
+		Short: "Print the properties of an executable",
+		Long: `Print the properties of an executable.
+
+This includes the enclave properties set by 'ego sign', the UniqueID and SignerID, the Go build info,
+the heap mode, the EGo runtime that is used to run the enclave, and the embedded enclave.json.
+The content of embedded files is omitted.
+
+If the executable is a bundle created by 'ego bundle', the bundled enclave is inspected.`,
+		Args:          cobra.ExactArgs(1),
+		SilenceErrors: true,
+
+		RunE: func(cmd *cobra.Command, args []string) error {
+			info, err := newCli().Inspect(args[0])
+			handleErr(err)
+			if err != nil {
+				return err
+			}
+
+			asJSON, err := cmd.Flags().GetBool("json")
+			if err != nil {
+				return err
+			}
+			if asJSON {
+				out, err := json.MarshalIndent(info, "", "  ")
+				if err != nil {
+					return err
+				}
+				fmt.Println(string(out))
+				return nil
+			}
+			return printEnclaveInfo(info)
+		},
+	}
+
+	cmd.Flags().Bool("json", false, "print the properties as JSON")
+	return cmd
+}
+
+func printEnclaveInfo(info *cli.EnclaveInfo) error {
+	w := tabwriter.NewWriter(os.Stdout, 0, 0, 2, ' ', 0)
+	fmt.Fprintf(w, "Bundled:\t%v\n", info.Bundled)
+	fmt.Fprintf(w, "Signed:\t%v\n", info.Signed)
+	fmt.Fprintf(w, "UniqueID:\t%v\n", info.UniqueID)
+	fmt.Fprintf(w, "SignerID:\t%v\n", info.SignerID)
+	fmt.Fprintf(w, "ProductID:\t%v\n", info.ProductID)
+	fmt.Fprintf(w, "SecurityVersion:\t%v\n", info.SecurityVersion)
+	fmt.Fprintf(w, "Debug:\t%v\n", info.Debug)
+	fmt.Fprintf(w, "NumHeapPages:\t%v\n", info.NumHeapPages)
+	fmt.Fprintf(w, "NumStackPages:\t%v\n", info.NumStackPages)
+	fmt.Fprintf(w, "NumTCS:\t%v\n", info.NumTCS)
+	fmt.Fprintf(w, "GoVersion:\t%v\n", info.GoVersion)
+	fmt.Fprintf(w, "MainPath:\t%v\n", info.MainPath)
+	fmt.Fprintf(w, "HeapMode:\t%v\n", info.HeapMode)
+	fmt.Fprintf(w, "Runtime:\t%v\n", info.Runtime)
+
+	keys := make([]string, 0, len(info.BuildSettings))
+	for key := range info.BuildSettings {
+		keys = append(keys, key)
+	}
+	slices.Sort(keys)
+	for _, key := range keys {
+		fmt.Fprintf(w, "BuildSetting %v:\t%v\n", key, info.BuildSettings[key])
+	}
+	if err := w.Flush(); err != nil {
+		return err
+	}
+
+	if info.Config == nil {
+		fmt.Println("\nNo enclave.json embedded. Sign the executable with 'ego sign'.")
+		return nil
+	}
+	conf, err := json.MarshalIndent(info.Config, "", "  ")
+	if err != nil {
+		return err
+	}
+	fmt.Printf("\nEmbedded enclave.json:\n%s\n", conf)
+	return nil
+}
@@ -0,0 +1,151 @@
+// Copyright (c) Edgeless Systems GmbH.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package cmd
+
+import (
+	"bytes"
+	"errors"
+	"fmt"
+	"os"
+	"path/filepath"
+
+	"github.com/spf13/cobra"
+)
+
+func newKeyCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "key",
+		Short: "Manage signing keys",
+		Long: `Manage the RSA-3072 keys with exponent 3 that are used to sign executables.
+
+Encrypted private keys are supported by all commands that read private keys, including 'ego sign'.
+The passphrase is read from the ` + passphraseEnv + ` environment variable or prompted for on the terminal.`,
+		Args: cobra.NoArgs,
+	}
+
+	cmd.AddCommand(newKeyGenerateCmd())
+	cmd.AddCommand(newKeyPubCmd())
+	cmd.AddCommand(newKeySigneridCmd())
+	return cmd
+}
+
+func newKeyGenerateCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "generate [private.pem]",
+		Short: "Generate a new signing key",
+		Long: `Generate a new signing key. The private key is written to private.pem by default and
+the public key is written to public.pem in the same directory.`,
+		Example: `  ego key generate
+  ego key generate --encrypt keys/signing.pem --pubout keys/signing.pub.pem`,
+		Args:          cobra.MaximumNArgs(1),
+		SilenceErrors: true,
+
+		RunE: func(cmd *cobra.Command, args []string) error {
+			privKeyFile := "private.pem"
+			if len(args) > 0 {
+				privKeyFile = args[0]
+			}
+			flags := cmd.Flags()
+			pubKeyFile, err := flags.GetString("pubout")
+			if err != nil {
+				return err
+			}
+			if pubKeyFile == "" {
+				pubKeyFile = filepath.Join(filepath.Dir(privKeyFile), "public.pem")
+			}
+			encrypt, err := flags.GetBool("encrypt")
+			if err != nil {
+				return err
+			}
+
+			var passphrase []byte
+			if encrypt {
+				passphrase, err = getNewPassphrase()
+			}
+			if err == nil {
+				err = newCli().GenerateKey(privKeyFile, pubKeyFile, passphrase)
+			}
+			handleErr(err)
+			if err != nil {
+				return err
+			}
+			fmt.Println("Generated", privKeyFile, "and", pubKeyFile)
+			return nil
+		},
+	}
+
+	cmd.Flags().String("pubout", "", "write the public key to this file (default \"public.pem\" next to the private key)")
+	cmd.Flags().Bool("encrypt", false, "encrypt the private key with a passphrase")
+	return cmd
+}
+
+func newKeyPubCmd() *cobra.Command {
+	return &cobra.Command{
+		Use:                   "pub <private.pem> [public.pem]",
+		Short:                 "Write the public key of a private key",
+		Long:                  "Write the public key of a private key in PEM format. It is written to public.pem by default.",
+		Args:                  cobra.RangeArgs(1, 2),
+		SilenceErrors:         true,
+		DisableFlagsInUseLine: true,
+
+		RunE: func(cmd *cobra.Command, args []string) error {
+			pubKeyFile := "public.pem"
+			if len(args) > 1 {
+				pubKeyFile = args[1]
+			}
+			err := newCli().WritePublicKey(args[0], pubKeyFile)
+			handleErr(err)
+			return err // nil if no error
+		},
+	}
+}
+
+func newKeySigneridCmd() *cobra.Command {
+	return &cobra.Command{
+		Use:                   "signerid <key.pem>",
+		Short:                 "Print the SignerID of a key",
+		Long:                  "Print the SignerID of a public or private key. This is the SignerID of executables signed with the key.",
+		Args:                  cobra.ExactArgs(1),
+		SilenceErrors:         true,
+		DisableFlagsInUseLine: true,
+
+		RunE: func(cmd *cobra.Command, args []string) error {
+			id, err := newCli().SigneridByKey(args[0])
+			handleErr(err)
+			if err != nil {
+				return err
+			}
+			fmt.Println(id)
+			return nil
+		},
+	}
+}
+
+// getNewPassphrase gets the passphrase for a new key and lets the user confirm it if it is prompted for.
+func getNewPassphrase() ([]byte, error) {
+	if passphrase, ok := os.LookupEnv(passphraseEnv); ok {
+		if passphrase == "" {
+			return nil, errors.New(passphraseEnv + " is empty")
+		}
+		return []byte(passphrase), nil
+	}
+	passphrase, err := promptPassphrase("Enter passphrase for the new key: ")
+	if err != nil {
+		return nil, err
+	}
+	if len(passphrase) == 0 {
+		return nil, errors.New("passphrase must not be empty")
+	}
+	confirmation, err := promptPassphrase("Confirm passphrase: ")
+	if err != nil {
+		return nil, err
+	}
+	if !bytes.Equal(passphrase, confirmation) {
+		return nil, errors.New("passphrases don't match")
+	}
+	return passphrase, nil
+}
@@ -0,0 +1,55 @@
+// Copyright (c) Edgeless Systems GmbH.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package cmd
+
+import (
+	"fmt"
+
+	"github.com/spf13/cobra"
+)
+
+func newMeasureCmd() *cobra.Command {
+	cmd := &cobra.Command{
+		Use:   "measure [executable | config.json]",
+		Short: "Print the UniqueID that an executable will have after signing",
+		Long: `Print the UniqueID that an executable will have after signing.
+
+The UniqueID is computed like 'ego sign' does, but the executable isn't modified and no signing key is needed.
+Use this to verify reproducible builds or to register the UniqueID before the executable is signed.`,
+		SilenceErrors: true,
+		Example: `  ego measure <executable>
+    Measures the executable with the configuration "enclave.json" in the current directory or with a default configuration if it doesn't exist.
+
+  ego measure
+    Searches in the current directory for "enclave.json" and measures the therein provided executable.
+
+  ego measure <config.json>
+    Measures an executable according to a given configuration.`,
+		Args: cobra.MaximumNArgs(1),
+
+		RunE: func(cmd *cobra.Command, args []string) error {
+			var filename string
+			if len(args) > 0 {
+				filename = args[0]
+			}
+			c, err := newCliWithProfile(cmd)
+			if err != nil {
+				return err
+			}
+			id, err := c.Measure(filename)
+			handleErr(err)
+			if err != nil {
+				return err
+			}
+			fmt.Println(id)
+			return nil
+		},
+	}
+
+	addProfileFlag(cmd)
+	return cmd
+}
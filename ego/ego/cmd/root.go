@@ -27,8 +27,14 @@ func NewRootCmd() *cobra.Command {
 	rootCmd.AddCommand(newBundleCmd())
 	rootCmd.AddCommand(newSigneridCmd())
 	rootCmd.AddCommand(newUniqueidCmd())
+	rootCmd.AddCommand(newInspectCmd())
+	rootCmd.AddCommand(newCheckCmd())
+	rootCmd.AddCommand(newMeasureCmd())
+	rootCmd.AddCommand(newKeyCmd())
+	rootCmd.AddCommand(newConfigCmd())
 	rootCmd.AddCommand(newEnvCmd())
 	rootCmd.AddCommand(newInstallCmd())
+	rootCmd.AddCommand(newAttestationServiceCmd())
 
 	return rootCmd
 }
@@ -7,14 +7,25 @@
 package cmd
 
 import (
+	"errors"
+
+	"github.com/edgelesssys/ego/ego/cli"
 	"github.com/spf13/cobra"
 )
 
 func newSignCmd() *cobra.Command {
-	return &cobra.Command{
-		Use:           "sign [executable | config.json]",
-		Short:         "Sign an executable built with ego-go",
-		Long:          "Sign an executable built with ego-go. Executables must be signed before they can be run in an enclave.",
+	cmd := &cobra.Command{
+		Use:   "sign [executable | config.json]",
+		Short: "Sign an executable built with ego-go",
+		Long: `Sign an executable built with ego-go. Executables must be signed before they can be run in an enclave.
+
+By default, the executable is signed with the private key specified in the configuration.
+If the key doesn't exist, a new one is generated. If the key is encrypted, the passphrase is read from the
+` + passphraseEnv + ` environment variable or prompted for on the terminal.
+If the key is kept elsewhere, e.g., in an HSM, sign in two steps: First, use --digest-out to prepare the executable
+and write the digest that must be signed. Sign the digest with RSASSA-PKCS1-v1_5 using the RSA-3072 key with exponent 3.
+Then, use --signature-in and --pubkey to add the signature to the executable.
+Alternatively, use --sign-command to sign the digest in one step with an external command, e.g., a PKCS#11 tool.`,
 		SilenceErrors: true,
 		Example: `  ego sign <executable>
     Generates a new key "private.pem" and a default configuration "enclave.json" in the current directory and signs the executable.
@@ -23,18 +34,75 @@ func newSignCmd() *cobra.Command {
     Searches in the current directory for "enclave.json" and signs the therein provided executable.
 
   ego sign <config.json>
-    Signs an executable according to a given configuration.`,
-		Args:                  cobra.MaximumNArgs(1),
-		DisableFlagsInUseLine: true,
+    Signs an executable according to a given configuration.
+
+  ego sign --profile prod
+    Signs an executable according to "enclave.json" with the settings of profile "prod" applied.
+
+  ego sign --digest-out digest.bin
+  openssl pkeyutl -sign -inkey private.pem -pkeyopt digest:sha256 -in digest.bin -out signature.bin
+  ego sign --signature-in signature.bin --pubkey public.pem
+    Signs an executable in two steps.
+
+  ego sign --pubkey public.pem --sign-command "openssl pkeyutl -sign -inkey private.pem -pkeyopt digest:sha256"
+    Signs an executable with an external command that gets the digest on stdin and writes the signature to stdout.`,
+		Args: cobra.MaximumNArgs(1),
 
 		RunE: func(cmd *cobra.Command, args []string) error {
 			var filename string
 			if len(args) > 0 {
 				filename = args[0]
 			}
-			err := newCli().Sign(filename)
+			flags := cmd.Flags()
+			digestOut, err := flags.GetString("digest-out")
+			if err != nil {
+				return err
+			}
+			signatureIn, err := flags.GetString("signature-in")
+			if err != nil {
+				return err
+			}
+			pubKey, err := flags.GetString("pubkey")
+			if err != nil {
+				return err
+			}
+			signCommand, err := flags.GetString("sign-command")
+			if err != nil {
+				return err
+			}
+
+			c, err := newCliWithProfile(cmd)
+			if err != nil {
+				return err
+			}
+			switch {
+			case (signatureIn != "" || signCommand != "") && pubKey == "":
+				err = errors.New("--pubkey is required for --signature-in and --sign-command")
+			case digestOut != "":
+				err = c.SignDigest(filename, digestOut)
+			case signatureIn != "":
+				var signer *cli.FileSigner
+				if signer, err = c.NewFileSigner(pubKey, signatureIn); err == nil {
+					err = c.AddSignature(filename, signer)
+				}
+			case signCommand != "":
+				var signer *cli.CommandSigner
+				if signer, err = c.NewCommandSigner(pubKey, "sh", "-c", signCommand); err == nil {
+					err = c.SignWithSigner(filename, signer)
+				}
+			default:
+				err = c.Sign(filename)
+			}
 			handleErr(err)
 			return err // nil if no error
 		},
 	}
+
+	cmd.Flags().String("digest-out", "", "prepare the executable for signing and write the digest that must be signed to this file")
+	cmd.Flags().String("signature-in", "", "add the signature from this file to an executable prepared with --digest-out")
+	cmd.Flags().String("pubkey", "", "public key in PEM format that belongs to the signature")
+	cmd.Flags().String("sign-command", "", "sign with this shell command, which gets the digest on stdin and writes the signature to stdout")
+	addProfileFlag(cmd)
+	cmd.MarkFlagsMutuallyExclusive("digest-out", "signature-in", "sign-command")
+	return cmd
 }
@@ -7,18 +7,72 @@
 package cmd
 
 import (
+	"bufio"
 	"fmt"
 	"os"
+	"strings"
 
 	"github.com/edgelesssys/ego/ego/cli"
 	"github.com/edgelesssys/ego/ego/internal/launch"
 	"github.com/klauspost/cpuid/v2"
 	"github.com/spf13/afero"
 	"github.com/spf13/cobra"
+	"golang.org/x/sys/unix"
 )
 
+// passphraseEnv is the environment variable that contains the passphrase of an encrypted private key.
+const passphraseEnv = "EGO_KEY_PASSPHRASE"
+
 func newCli() *cli.Cli {
-	return cli.NewCli(launch.OsRunner{}, afero.NewOsFs())
+	c := cli.NewCli(launch.OsRunner{}, afero.NewOsFs())
+	c.SetPassphraseFunc(getPassphrase)
+	return c
+}
+
+// newCliWithProfile creates a Cli that applies the profile passed with --profile.
+func newCliWithProfile(cmd *cobra.Command) (*cli.Cli, error) {
+	profile, err := cmd.Flags().GetString("profile")
+	if err != nil {
+		return nil, err
+	}
+	c := newCli()
+	c.SetProfile(profile)
+	return c, nil
+}
+
+func addProfileFlag(cmd *cobra.Command) {
+	cmd.Flags().String("profile", "", "apply this profile of the configuration")
+}
+
+// getPassphrase gets the passphrase of an encrypted private key from the environment or prompts for it.
+func getPassphrase() ([]byte, error) {
+	if passphrase, ok := os.LookupEnv(passphraseEnv); ok {
+		return []byte(passphrase), nil
+	}
+	return promptPassphrase("Enter passphrase for the private key: ")
+}
+
+// promptPassphrase reads a passphrase from the terminal without echoing it.
+func promptPassphrase(prompt string) ([]byte, error) {
+	fd := int(os.Stdin.Fd())
+	state, err := unix.IoctlGetTermios(fd, unix.TCGETS)
+	if err != nil {
+		return nil, fmt.Errorf("%w: set %v or run in a terminal", cli.ErrPassphraseRequired, passphraseEnv)
+	}
+	noEcho := *state
+	noEcho.Lflag &^= unix.ECHO
+	if err := unix.IoctlSetTermios(fd, unix.TCSETS, &noEcho); err != nil {
+		return nil, err
+	}
+	defer func() { _ = unix.IoctlSetTermios(fd, unix.TCSETS, state) }()
+
+	fmt.Fprint(os.Stderr, prompt)
+	line, err := bufio.NewReader(os.Stdin).ReadString('\n')
+	fmt.Fprintln(os.Stderr)
+	if err != nil {
+		return nil, err
+	}
+	return []byte(strings.TrimRight(line, "\r\n")), nil
 }
 
 func must(err error) {
@@ -204,10 +204,18 @@ func writeFiles(files []config.File, fs afero.Fs) error {
 		if err != nil {
 			return err
 		}
-		if err := afs.MkdirAll(filepath.Dir(file.Target), 0o700); err != nil {
+		mode, err := file.GetMode()
+		if err != nil {
+			return err
+		}
+		dirMode, err := file.GetDirMode()
+		if err != nil {
+			return err
+		}
+		if err := afs.MkdirAll(filepath.Dir(file.Target), dirMode); err != nil {
 			return err
 		}
-		if err := afs.WriteFile(file.Target, buf, 0o600); err != nil {
+		if err := afs.WriteFile(file.Target, buf, mode); err != nil {
 			return err
 		}
 	}
@@ -271,3 +271,49 @@ func TestEmbeddedFile(t *testing.T) {
 	require.NoError(err)
 	assert.Equal(content, actualContent)
 }
+
+func TestEmbeddedDirectory(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	hostEnviron := []string{"EDG_CWD=/host"}
+	hostFS := afero.Afero{Fs: afero.NewMemMapFs()}
+	require.NoError(hostFS.WriteFile("static/index.html", []byte("index"), 0o644))
+	require.NoError(hostFS.WriteFile("static/css/style.css", []byte("style"), 0o644))
+	require.NoError(hostFS.WriteFile("secret.key", []byte("key"), 0o644))
+
+	conf := config.Config{
+		Files: []config.File{
+			{Source: "static", Target: "/srv/static", Mode: "0644", Compress: true},
+			{Source: "secret.key", Target: "/keys/secret.key"},
+		},
+	}
+	require.NoError(conf.PopulateContent(hostFS))
+	payload, err := conf.Payload()
+	require.NoError(err)
+
+	fs := afero.Afero{Fs: afero.NewMemMapFs()}
+	require.NoError(PreMain(string(payload), &assertionMounter{}, fs, hostEnviron))
+
+	testCases := map[string]struct {
+		content string
+		mode    os.FileMode
+	}{
+		"/srv/static/index.html":    {content: "index", mode: 0o644},
+		"/srv/static/css/style.css": {content: "style", mode: 0o644},
+		"/srv/static/css":           {mode: os.ModeDir | 0o755},
+		"/keys/secret.key":          {content: "key", mode: 0o600},
+		"/keys":                     {mode: os.ModeDir | 0o700},
+	}
+	for name, tc := range testCases {
+		info, err := fs.Stat(name)
+		require.NoError(err, name)
+		assert.Equal(tc.mode, info.Mode(), name)
+		if info.IsDir() {
+			continue
+		}
+		content, err := fs.ReadFile(name)
+		require.NoError(err)
+		assert.Equal(tc.content, string(content))
+	}
+}
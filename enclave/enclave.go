@@ -7,9 +7,12 @@
 package enclave
 
 import (
+	"context"
 	"crypto/sha256"
 	"crypto/tls"
 	"crypto/x509"
+	"net/http"
+	"time"
 
 	"github.com/edgelesssys/ego/attestation"
 	internal "github.com/edgelesssys/ego/internal/attestation"
@@ -75,9 +78,80 @@ func CreateAttestationClientTLSConfig(verifyReport func(attestation.Report) erro
 	)
 }
 
+// CreateChannelAttestationHandler wraps next with an http.Handler that serves remote reports bound to the TLS connection.
+//
+// Clients created by CreateChannelAttestationClient request the report after each TLS handshake. The report
+// contains the connection's exported keying material (RFC 9266 tls-exporter channel binding) as report data.
+// All other requests are passed to next.
+func CreateChannelAttestationHandler(next http.Handler) http.Handler {
+	return internal.CreateChannelAttestationHandler(GetRemoteReport, next)
+}
+
+// CreateChannelAttestationClient creates an http.Client that binds remote attestation to each TLS connection.
+//
+// After each TLS handshake, the client requests a report from the server on the same connection.
+// The server must wrap its handler with CreateChannelAttestationHandler.
+//
+// tlsConfig is used to establish the connection and may be nil. TLS 1.3 is enforced.
+//
+// verifyReport is called after the report has been verified against the channel binding. The caller must verify either the UniqueID or the tuple (SignerID, ProductID, SecurityVersion, Debug) in the callback.
+func CreateChannelAttestationClient(tlsConfig *tls.Config, verifyReport func(attestation.Report) error, opts ...AttestOption) *http.Client {
+	var appliedOpts internal.Options
+	for _, o := range opts {
+		o.apply(&appliedOpts)
+	}
+
+	return internal.CreateChannelAttestationClient(
+		tlsConfig,
+		func(reportBytes []byte) (internal.Report, error) {
+			report, err := VerifyRemoteReport(reportBytes)
+			return internal.Report(report), err
+		},
+		appliedOpts,
+		func(rep internal.Report) error { return verifyReport(attestation.Report(rep)) },
+	)
+}
+
+// CreateAttestedClaims creates a remote report over the digest of claims and returns an envelope
+// containing the JSON-encoded claims and the report.
+//
+// Use this function to attest data that exceeds the 64 bytes of reportData. The envelope can be
+// verified with VerifyAttestedClaims of this package or the eclient package.
+func CreateAttestedClaims(claims map[string]any) ([]byte, error) {
+	return internal.CreateAttestedClaims(GetRemoteReport, claims)
+}
+
+// VerifyAttestedClaims verifies an envelope created by CreateAttestedClaims.
+//
+// It verifies the report and checks that it is over the digest of the claims.
+//
+// verifyReport is called after the report has been verified against the claims. The caller must verify either the UniqueID or the tuple (SignerID, ProductID, SecurityVersion, Debug) in the callback.
+func VerifyAttestedClaims(envelope []byte, verifyReport func(attestation.Report) error, opts ...AttestOption) (attestation.AttestedClaims, error) {
+	var appliedOpts internal.Options
+	for _, o := range opts {
+		o.apply(&appliedOpts)
+	}
+
+	raw, claims, report, err := internal.VerifyAttestedClaims(
+		envelope,
+		func(reportBytes []byte) (internal.Report, error) {
+			report, err := VerifyRemoteReport(reportBytes)
+			return internal.Report(report), err
+		},
+		appliedOpts,
+		func(rep internal.Report) error { return verifyReport(attestation.Report(rep)) },
+	)
+	if err != nil {
+		return attestation.AttestedClaims{}, err
+	}
+	return attestation.AttestedClaims{Claims: claims, Raw: raw, Report: attestation.Report(report)}, nil
+}
+
 // CreateAzureAttestationToken creates a Microsoft Azure Attestation token by creating a
 // remote report and sending it to an Attestation Provider, who is reachable under url.
 // A JSON Web Token in compact serialization is returned.
+//
+// The TLS certificate of the Attestation Provider isn't verified. Use CreateAzureAttestationTokenContext for a trusted connection.
 func CreateAzureAttestationToken(data []byte, url string) (string, error) {
 	hash := sha256.Sum256(data)
 	report, err := GetRemoteReport(hash[:])
@@ -87,6 +161,68 @@ func CreateAzureAttestationToken(data []byte, url string) (string, error) {
 	return internal.CreateAzureAttestationToken(report, data, url)
 }
 
+// CreateAzureAttestationTokenContext creates a Microsoft Azure Attestation token by creating a
+// remote report and sending it to an Attestation Provider, who is reachable under url.
+// data is sent as runtime data. A JSON Web Token in compact serialization is returned.
+//
+// The TLS certificate of the Attestation Provider is verified against the system roots by default.
+// To make them available in the enclave, embed a CA bundle via the files section of enclave.json,
+// e.g., with target /etc/ssl/certs/ca-certificates.crt. Alternatively, use WithAzureRootCAs or WithAzurePinnedCertificate.
+//
+// Requests that fail because of a network error, a server error, or rate limiting are retried with exponential backoff.
+// By default, a request is attempted 3 times. Use WithAzureRetry to change this. Retrying stops early if ctx is done.
+func CreateAzureAttestationTokenContext(ctx context.Context, data []byte, url string, opts ...AzureOption) (string, error) {
+	var o internal.AzureTokenOptions
+	for _, opt := range opts {
+		opt.apply(&o)
+	}
+	hash := sha256.Sum256(data)
+	report, err := GetRemoteReport(hash[:])
+	if err != nil {
+		return "", err
+	}
+	return internal.CreateAzureAttestationTokenContext(ctx, report, data, url, o)
+}
+
+// AzureOption configures CreateAzureAttestationTokenContext.
+type AzureOption struct {
+	apply func(*internal.AzureTokenOptions)
+}
+
+// WithAzureRootCAs sets the root CAs that are used to verify the Attestation Provider's certificate.
+func WithAzureRootCAs(rootCAs *x509.CertPool) AzureOption {
+	return AzureOption{func(o *internal.AzureTokenOptions) { o.RootCAs = rootCAs }}
+}
+
+// WithAzurePinnedCertificate sets the DER-encoded certificate the Attestation Provider must present.
+func WithAzurePinnedCertificate(cert []byte) AzureOption {
+	return AzureOption{func(o *internal.AzureTokenOptions) { o.PinnedCertificate = cert }}
+}
+
+// WithAzureJSONRuntimeData sends the runtime data as JSON instead of binary data.
+// The runtime data is then available as x-ms-runtime claim in the token.
+func WithAzureJSONRuntimeData() AzureOption {
+	return AzureOption{func(o *internal.AzureTokenOptions) { o.RuntimeDataJSON = true }}
+}
+
+// WithAzureInitTimeData sends data as init-time data. If isJSON is true, the data is sent as JSON
+// and available as x-ms-inittime claim in the token.
+func WithAzureInitTimeData(data []byte, isJSON bool) AzureOption {
+	return AzureOption{func(o *internal.AzureTokenOptions) {
+		o.InitTimeData = data
+		o.InitTimeDataJSON = isJSON
+	}}
+}
+
+// WithAzureRetry sets the maximum number of attempts and the delay before the first retry, which doubles for each further retry.
+// By default, a request is attempted 3 times with an initial delay of one second.
+func WithAzureRetry(maxAttempts int, backoff time.Duration) AzureOption {
+	return AzureOption{func(o *internal.AzureTokenOptions) {
+		o.MaxAttempts = maxAttempts
+		o.Backoff = backoff
+	}}
+}
+
 // AttestOption	configures an attestation function.
 type AttestOption struct {
 	apply func(*internal.Options)
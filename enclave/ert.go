@@ -34,13 +34,16 @@ const (
 
 const maxReportData = 64
 
+// oeUnsupported is the value of OE_UNSUPPORTED in openenclave/bits/result.h.
+const oeUnsupported = 0x10
+
 var errReportDataTooLarge = errors.New("reportData too large")
 
 // GetRemoteReport gets a report signed by the enclave platform for use in remote attestation.
 //
 // The report shall contain the data given by the reportData parameter. The report can only
 // hold a maximum of 64 byte reportData. Use a hash value of your data as reportData if your
-// data exceeds this limit.
+// data exceeds this limit, or use CreateAttestedClaims.
 //
 // If reportData is less than 64 bytes, it will be padded with zero bytes.
 func GetRemoteReport(reportData []byte) ([]byte, error) {
@@ -92,12 +95,9 @@ func VerifyRemoteReport(reportBytes []byte) (attestation.Report, error) {
 		0, 0,
 	)
 
-	var verifyErr error
-	if err := oeError(errno, res); err != nil {
-		if err.Error() != "OE_TCB_LEVEL_INVALID" {
-			return attestation.Report{}, err
-		}
-		verifyErr = attestation.ErrTCBLevelInvalid
+	verifyErr := verificationError(errno, res)
+	if verifyErr != nil && !errors.Is(verifyErr, attestation.ErrTCBLevelInvalid) {
+		return attestation.Report{}, verifyErr
 	}
 
 	defer func() { _, _, _ = syscall.Syscall(sysFreeClaims, claims, claimsLength, 0) }()
@@ -165,7 +165,7 @@ func VerifyLocalReport(reportBytes []byte) (attestation.Report, error) {
 		uintptr(len(reportBytes)),
 		uintptr(unsafe.Pointer(&report)),
 	)
-	if err := oeError(errno, res); err != nil {
+	if err := verificationError(errno, res); err != nil {
 		return attestation.Report{}, err
 	}
 
@@ -221,11 +221,42 @@ func oeError(errno syscall.Errno, res uintptr) error {
 		return nil
 	}
 
-	resStr, _, errno := syscall.Syscall(sysResultStr, res, 0, 0)
+	resStr, err := resultString(res)
+	if err != nil {
+		return err
+	}
+	return errors.New(resStr)
+}
+
+// verificationError is like oeError, but returns an OE result as *attestation.VerificationError.
+// OE_TCB_LEVEL_INVALID is returned as attestation.ErrTCBLevelInvalid.
+func verificationError(errno syscall.Errno, res uintptr) error {
+	if errno == syscall.ENOSYS {
+		return attestation.NewVerificationError(oeUnsupported, "OE_UNSUPPORTED")
+	}
 	if errno != 0 {
 		return errno
 	}
-	return errors.New(C.GoString((*C.char)(unsafe.Pointer(resStr)))) //nolint:govet
+	if res == 0 {
+		return nil
+	}
+
+	resStr, err := resultString(res)
+	if err != nil {
+		return err
+	}
+	if resStr == "OE_TCB_LEVEL_INVALID" {
+		return attestation.ErrTCBLevelInvalid
+	}
+	return attestation.NewVerificationError(uint32(res), resStr)
+}
+
+func resultString(res uintptr) (string, error) {
+	resStr, _, errno := syscall.Syscall(sysResultStr, res, 0, 0)
+	if errno != 0 {
+		return "", errno
+	}
+	return C.GoString((*C.char)(unsafe.Pointer(resStr))), nil //nolint:govet
 }
 
 func getBytesPointer(data []byte) uintptr {
@@ -143,7 +143,7 @@ func CreateAttestationClientTLSConfig(verifyRemoteReport func([]byte) (Report, e
 		for _, ex := range cert.Extensions {
 			if ex.Id.Equal(oidOeNewQuote) {
 				report, err := verifyRemoteReport(ex.Value)
-				if err != nil && err != opts.IgnoreErr {
+				if err != nil && (opts.IgnoreErr == nil || !errors.Is(err, opts.IgnoreErr)) {
 					return err
 				}
 				if !bytes.Equal(report.Data[:len(hash)], hash) && !bytes.Equal(report.Data[:len(hashOE)], hashOE) {
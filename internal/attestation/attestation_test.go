@@ -8,6 +8,7 @@ package attestation
 
 import (
 	"errors"
+	"fmt"
 	"io"
 	"net/http"
 	"net/http/httptest"
@@ -106,6 +107,16 @@ func TestTLSConfig(t *testing.T) {
 			opts:               Options{IgnoreErr: failToVerifyRemoteReportErr},
 			verifyReport:       verifyReport,
 		},
+		"ignore wrapped remote report error": {
+			hashPublicKey:   HashPublicKey,
+			getRemoteReport: getRemoteReport,
+			verifyRemoteReport: func(reportBytes []byte) (Report, error) {
+				report, err := failToVerifyRemoteReport(reportBytes)
+				return report, fmt.Errorf("wrapped: %w", err)
+			},
+			opts:         Options{IgnoreErr: failToVerifyRemoteReportErr},
+			verifyReport: verifyReport,
+		},
 		"ignore other remote report error": {
 			hashPublicKey:      HashPublicKey,
 			getRemoteReport:    getRemoteReport,
@@ -0,0 +1,175 @@
+// Copyright (c) Edgeless Systems GmbH.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package attestation
+
+import (
+	"bufio"
+	"bytes"
+	"context"
+	"crypto/tls"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net"
+	"net/http"
+	"time"
+)
+
+// ChannelAttestationPath is the path under which a server provides a report bound to the TLS channel.
+const ChannelAttestationPath = "/.well-known/ego/channel-attestation"
+
+// The exporter label and size are defined by RFC 9266 (Channel Bindings for TLS 1.3).
+const (
+	channelBindingLabel = "EXPORTER-Channel-Binding"
+	channelBindingSize  = 32
+)
+
+type channelAttestationResponse struct {
+	Report []byte `json:"report"`
+}
+
+// ExportChannelBinding returns the tls-exporter channel binding of a TLS connection.
+func ExportChannelBinding(state tls.ConnectionState) ([]byte, error) {
+	return state.ExportKeyingMaterial(channelBindingLabel, nil, channelBindingSize)
+}
+
+// CreateChannelAttestationHandler creates an http.Handler that serves a report over the channel binding
+// of the requesting connection on ChannelAttestationPath. All other requests are passed to next.
+func CreateChannelAttestationHandler(getRemoteReport func([]byte) ([]byte, error), next http.Handler) http.Handler {
+	return http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if r.URL.Path != ChannelAttestationPath {
+			next.ServeHTTP(w, r)
+			return
+		}
+		if r.Method != http.MethodGet {
+			http.Error(w, "method not allowed", http.StatusMethodNotAllowed)
+			return
+		}
+		if r.TLS == nil {
+			http.Error(w, "channel attestation requires TLS", http.StatusBadRequest)
+			return
+		}
+
+		binding, err := ExportChannelBinding(*r.TLS)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusBadRequest)
+			return
+		}
+		report, err := getRemoteReport(binding)
+		if err != nil {
+			http.Error(w, err.Error(), http.StatusInternalServerError)
+			return
+		}
+
+		w.Header().Set("Content-Type", "application/json")
+		_ = json.NewEncoder(w).Encode(channelAttestationResponse{Report: report})
+	})
+}
+
+// CreateChannelAttestationClient creates an http.Client that attests the server on each new connection.
+//
+// After the TLS handshake, the client requests a report from ChannelAttestationPath on the same connection
+// and verifies that the report data matches the connection's channel binding. Only then the connection is
+// used for requests.
+func CreateChannelAttestationClient(tlsConfig *tls.Config, verifyRemoteReport func([]byte) (Report, error), opts Options, verifyReport func(Report) error) *http.Client {
+	if tlsConfig == nil {
+		tlsConfig = &tls.Config{}
+	} else {
+		tlsConfig = tlsConfig.Clone()
+	}
+	// Keying material exporters are only secure with TLS 1.3 (or the extended master secret extension).
+	if tlsConfig.MinVersion < tls.VersionTLS13 {
+		tlsConfig.MinVersion = tls.VersionTLS13
+	}
+	// The attestation request is sent using HTTP/1.1, so the connection must not negotiate HTTP/2.
+	tlsConfig.NextProtos = []string{"http/1.1"}
+
+	dial := func(ctx context.Context, network, addr string) (net.Conn, error) {
+		return dialChannelAttested(ctx, network, addr, tlsConfig, verifyRemoteReport, opts, verifyReport)
+	}
+	return &http.Client{Transport: &http.Transport{DialTLSContext: dial}}
+}
+
+func dialChannelAttested(ctx context.Context, network, addr string, tlsConfig *tls.Config, verifyRemoteReport func([]byte) (Report, error), opts Options, verifyReport func(Report) error) (net.Conn, error) {
+	config := tlsConfig
+	if config.ServerName == "" {
+		host, _, err := net.SplitHostPort(addr)
+		if err != nil {
+			return nil, err
+		}
+		config = config.Clone()
+		config.ServerName = host
+	}
+
+	dialer := &tls.Dialer{Config: config}
+	conn, err := dialer.DialContext(ctx, network, addr)
+	if err != nil {
+		return nil, err
+	}
+	tlsConn := conn.(*tls.Conn)
+
+	if err := attestChannel(ctx, tlsConn, addr, verifyRemoteReport, opts, verifyReport); err != nil {
+		tlsConn.Close()
+		return nil, fmt.Errorf("channel attestation: %w", err)
+	}
+	return tlsConn, nil
+}
+
+func attestChannel(ctx context.Context, conn *tls.Conn, addr string, verifyRemoteReport func([]byte) (Report, error), opts Options, verifyReport func(Report) error) error {
+	binding, err := ExportChannelBinding(conn.ConnectionState())
+	if err != nil {
+		return err
+	}
+
+	if deadline, ok := ctx.Deadline(); ok {
+		if err := conn.SetDeadline(deadline); err != nil {
+			return err
+		}
+		defer func() { _ = conn.SetDeadline(time.Time{}) }()
+	}
+
+	// Send the request directly on the connection so that the report is bound to this session.
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, "https://"+addr+ChannelAttestationPath, nil)
+	if err != nil {
+		return err
+	}
+	if err := req.Write(conn); err != nil {
+		return err
+	}
+	reader := bufio.NewReader(conn)
+	resp, err := http.ReadResponse(reader, req)
+	if err != nil {
+		return err
+	}
+	respBody, err := io.ReadAll(resp.Body)
+	resp.Body.Close()
+	if err != nil {
+		return err
+	}
+	if resp.StatusCode != http.StatusOK {
+		return fmt.Errorf("server returned status %v", resp.Status)
+	}
+	// The connection is handed over to the HTTP transport afterwards, so nothing may be left in the buffer.
+	if reader.Buffered() > 0 {
+		return errors.New("unexpected data after attestation response")
+	}
+
+	var body channelAttestationResponse
+	if err := json.Unmarshal(respBody, &body); err != nil {
+		return err
+	}
+
+	report, err := verifyRemoteReport(body.Report)
+	if err != nil && (opts.IgnoreErr == nil || !errors.Is(err, opts.IgnoreErr)) {
+		return err
+	}
+	if len(report.Data) < len(binding) || !bytes.Equal(report.Data[:len(binding)], binding) {
+		return errors.New("report data does not match the channel binding")
+	}
+	return verifyReport(report)
+}
@@ -0,0 +1,179 @@
+// Copyright (c) Edgeless Systems GmbH.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package attestation
+
+import (
+	"crypto/tls"
+	"crypto/x509"
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"sync/atomic"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestChannelAttestation(t *testing.T) {
+	getRemoteReport := func(reportData []byte) ([]byte, error) {
+		return append([]byte{2}, reportData...), nil
+	}
+	getWrongRemoteReport := func(reportData []byte) ([]byte, error) {
+		return append([]byte{2}, make([]byte, len(reportData))...), nil
+	}
+	failToGetRemoteReport := func([]byte) ([]byte, error) {
+		return nil, errors.New("failed")
+	}
+
+	verifyRemoteReport := func(reportBytes []byte) (Report, error) {
+		if len(reportBytes) != 33 || reportBytes[0] != 2 {
+			return Report{}, errors.New("invalid remote report")
+		}
+		return Report{Data: reportBytes[1:], SecurityVersion: 2}, nil
+	}
+	failToVerifyRemoteReportErr := errors.New("invalid remote report")
+	failToVerifyRemoteReport := func(reportBytes []byte) (Report, error) {
+		return Report{Data: reportBytes[1:], SecurityVersion: 2}, failToVerifyRemoteReportErr
+	}
+
+	verifyReport := func(report Report) error {
+		if report.SecurityVersion != 2 {
+			return errors.New("invalid report")
+		}
+		return nil
+	}
+	failToVerifyReport := func(Report) error {
+		return errors.New("invalid report")
+	}
+
+	testCases := map[string]struct {
+		getRemoteReport    func([]byte) ([]byte, error)
+		verifyRemoteReport func([]byte) (Report, error)
+		opts               Options
+		verifyReport       func(Report) error
+		wantErr            bool
+	}{
+		"basic": {
+			getRemoteReport:    getRemoteReport,
+			verifyRemoteReport: verifyRemoteReport,
+			verifyReport:       verifyReport,
+		},
+		"report not bound to channel": {
+			getRemoteReport:    getWrongRemoteReport,
+			verifyRemoteReport: verifyRemoteReport,
+			verifyReport:       verifyReport,
+			wantErr:            true,
+		},
+		"server fails to get report": {
+			getRemoteReport:    failToGetRemoteReport,
+			verifyRemoteReport: verifyRemoteReport,
+			verifyReport:       verifyReport,
+			wantErr:            true,
+		},
+		"invalid remote report": {
+			getRemoteReport:    getRemoteReport,
+			verifyRemoteReport: failToVerifyRemoteReport,
+			verifyReport:       verifyReport,
+			wantErr:            true,
+		},
+		"invalid report": {
+			getRemoteReport:    getRemoteReport,
+			verifyRemoteReport: verifyRemoteReport,
+			verifyReport:       failToVerifyReport,
+			wantErr:            true,
+		},
+		"ignore remote report error": {
+			getRemoteReport:    getRemoteReport,
+			verifyRemoteReport: failToVerifyRemoteReport,
+			opts:               Options{IgnoreErr: failToVerifyRemoteReportErr},
+			verifyReport:       verifyReport,
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			assert := assert.New(t)
+			require := require.New(t)
+
+			server := httptest.NewUnstartedServer(CreateChannelAttestationHandler(tc.getRemoteReport,
+				http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+					_, _ = io.WriteString(w, "hello")
+				}),
+			))
+			server.StartTLS()
+			defer server.Close()
+
+			roots := x509.NewCertPool()
+			roots.AddCert(server.Certificate())
+			client := CreateChannelAttestationClient(&tls.Config{RootCAs: roots}, tc.verifyRemoteReport, tc.opts, tc.verifyReport)
+
+			resp, err := client.Get(server.URL)
+			if tc.wantErr {
+				assert.Error(err)
+				return
+			}
+			require.NoError(err)
+			defer resp.Body.Close()
+
+			body, err := io.ReadAll(resp.Body)
+			require.NoError(err)
+			assert.EqualValues("hello", body)
+		})
+	}
+}
+
+func TestChannelAttestationReusesConnection(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	var reportCount atomic.Int32
+	getRemoteReport := func(reportData []byte) ([]byte, error) {
+		reportCount.Add(1)
+		return reportData, nil
+	}
+	verifyRemoteReport := func(reportBytes []byte) (Report, error) {
+		return Report{Data: reportBytes}, nil
+	}
+
+	server := httptest.NewUnstartedServer(CreateChannelAttestationHandler(getRemoteReport,
+		http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+			_, _ = io.WriteString(w, "hello")
+		}),
+	))
+	server.StartTLS()
+	defer server.Close()
+
+	client := CreateChannelAttestationClient(&tls.Config{InsecureSkipVerify: true}, verifyRemoteReport, Options{}, func(Report) error { return nil })
+
+	for i := 0; i < 3; i++ {
+		resp, err := client.Get(server.URL)
+		require.NoError(err)
+		_, err = io.ReadAll(resp.Body)
+		require.NoError(err)
+		resp.Body.Close()
+	}
+	assert.EqualValues(1, reportCount.Load())
+}
+
+func TestChannelAttestationHandlerRequiresTLS(t *testing.T) {
+	assert := assert.New(t)
+
+	handler := CreateChannelAttestationHandler(
+		func([]byte) ([]byte, error) { return nil, nil },
+		http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}),
+	)
+
+	rec := httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodGet, ChannelAttestationPath, nil))
+	assert.Equal(http.StatusBadRequest, rec.Code)
+
+	rec = httptest.NewRecorder()
+	handler.ServeHTTP(rec, httptest.NewRequest(http.MethodPost, ChannelAttestationPath, nil))
+	assert.Equal(http.StatusMethodNotAllowed, rec.Code)
+}
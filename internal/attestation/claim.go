@@ -24,6 +24,18 @@ func ParseClaims(claims uintptr, claimsLength uintptr) (Report, error) {
 	return parseClaims((*[1 << 28]C.oe_claim_t)(unsafe.Pointer(claims))[:claimsLength:claimsLength]) //nolint:govet
 }
 
+// claimTCBStatus is the name of the TCB status claim, which is the same for all TEE types.
+const claimTCBStatus = C.OE_CLAIM_TCB_STATUS
+
+// ClaimsToMap converts the claims to a map from claim name to value.
+func ClaimsToMap(claims unsafe.Pointer, claimsLength uintptr) map[string][]byte {
+	result := map[string][]byte{}
+	for _, claim := range unsafe.Slice((*C.oe_claim_t)(claims), claimsLength) {
+		result[C.GoString(claim.name)] = claimBytes(claim)
+	}
+	return result
+}
+
 func parseClaims(claims []C.oe_claim_t) (Report, error) {
 	report := Report{TCBStatus: tcbstatus.Unknown}
 	hasAttributes := false
@@ -0,0 +1,76 @@
+// Copyright (c) Edgeless Systems GmbH.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package attestation
+
+import (
+	"bytes"
+	"crypto/sha256"
+	"encoding/json"
+	"errors"
+	"fmt"
+)
+
+// ErrClaimsDigestMismatch is returned if the report of an envelope isn't over the digest of its claims.
+var ErrClaimsDigestMismatch = errors.New("report data does not match the digest of the claims")
+
+// claimsEnvelope is the serialized evidence for claims. The report data is the SHA-256 digest of Claims.
+type claimsEnvelope struct {
+	Claims json.RawMessage `json:"claims"`
+	Report []byte          `json:"report"`
+}
+
+// CreateAttestedClaims creates an envelope containing the JSON-encoded claims and a report over their digest.
+func CreateAttestedClaims(getRemoteReport func([]byte) ([]byte, error), claims map[string]any) ([]byte, error) {
+	if claims == nil {
+		claims = map[string]any{}
+	}
+	// json.Marshal sorts map keys, so the encoding is deterministic.
+	rawClaims, err := json.Marshal(claims)
+	if err != nil {
+		return nil, err
+	}
+	digest := sha256.Sum256(rawClaims)
+	report, err := getRemoteReport(digest[:])
+	if err != nil {
+		return nil, err
+	}
+	return json.Marshal(claimsEnvelope{Claims: rawClaims, Report: report})
+}
+
+// VerifyAttestedClaims verifies the report of an envelope created by CreateAttestedClaims and checks that it
+// is over the digest of the claims. It returns the JSON-encoded claims, the decoded claims, and the verified report.
+func VerifyAttestedClaims(envelope []byte, verifyRemoteReport func([]byte) (Report, error), opts Options, verifyReport func(Report) error) ([]byte, map[string]any, Report, error) {
+	var env claimsEnvelope
+	if err := json.Unmarshal(envelope, &env); err != nil {
+		return nil, nil, Report{}, err
+	}
+	if len(env.Claims) == 0 {
+		return nil, nil, Report{}, errors.New("envelope does not contain claims")
+	}
+	var claims map[string]any
+	if err := json.Unmarshal(env.Claims, &claims); err != nil {
+		return nil, nil, Report{}, fmt.Errorf("decoding claims: %w", err)
+	}
+	if claims == nil {
+		return nil, nil, Report{}, errors.New("envelope does not contain claims")
+	}
+
+	report, err := verifyRemoteReport(env.Report)
+	if err != nil && (opts.IgnoreErr == nil || !errors.Is(err, opts.IgnoreErr)) {
+		return nil, nil, Report{}, err
+	}
+
+	// The digest is computed over the claims exactly as they are contained in the envelope.
+	digest := sha256.Sum256(env.Claims)
+	if len(report.Data) < len(digest) || !bytes.Equal(report.Data[:len(digest)], digest[:]) {
+		return nil, nil, Report{}, ErrClaimsDigestMismatch
+	}
+	if err := verifyReport(report); err != nil {
+		return nil, nil, Report{}, err
+	}
+	return env.Claims, claims, report, nil
+}
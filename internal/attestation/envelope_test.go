@@ -0,0 +1,105 @@
+// Copyright (c) Edgeless Systems GmbH.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package attestation
+
+import (
+	"encoding/json"
+	"errors"
+	"strings"
+	"testing"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestAttestedClaims(t *testing.T) {
+	getRemoteReport := func(reportData []byte) ([]byte, error) {
+		return append([]byte{2}, reportData...), nil
+	}
+	verifyRemoteReport := func(reportBytes []byte) (Report, error) {
+		if len(reportBytes) != 33 || reportBytes[0] != 2 {
+			return Report{}, errors.New("invalid remote report")
+		}
+		return Report{Data: reportBytes[1:], SecurityVersion: 2}, nil
+	}
+	verifyReport := func(report Report) error {
+		if report.SecurityVersion != 2 {
+			return errors.New("invalid report")
+		}
+		return nil
+	}
+
+	claims := map[string]any{
+		"name":  "test",
+		"count": 3,
+		"cert":  strings.Repeat("A", 1000),
+	}
+
+	testCases := map[string]struct {
+		modify       func(envelope map[string]json.RawMessage)
+		verifyReport func(Report) error
+		wantErr      error
+	}{
+		"valid": {
+			verifyReport: verifyReport,
+		},
+		"tampered claims": {
+			modify: func(envelope map[string]json.RawMessage) {
+				envelope["claims"] = json.RawMessage(`{"name":"evil"}`)
+			},
+			verifyReport: verifyReport,
+			wantErr:      ErrClaimsDigestMismatch,
+		},
+		"missing claims": {
+			modify: func(envelope map[string]json.RawMessage) {
+				delete(envelope, "claims")
+			},
+			verifyReport: verifyReport,
+			wantErr:      errors.New("envelope does not contain claims"),
+		},
+		"invalid report": {
+			verifyReport: func(Report) error { return assert.AnError },
+			wantErr:      assert.AnError,
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			assert := assert.New(t)
+			require := require.New(t)
+
+			envelope, err := CreateAttestedClaims(getRemoteReport, claims)
+			require.NoError(err)
+
+			if tc.modify != nil {
+				var env map[string]json.RawMessage
+				require.NoError(json.Unmarshal(envelope, &env))
+				tc.modify(env)
+				envelope, err = json.Marshal(env)
+				require.NoError(err)
+			}
+
+			raw, gotClaims, report, err := VerifyAttestedClaims(envelope, verifyRemoteReport, Options{}, tc.verifyReport)
+			if tc.wantErr != nil {
+				assert.ErrorContains(err, tc.wantErr.Error())
+				return
+			}
+			require.NoError(err)
+			assert.EqualValues(2, report.SecurityVersion)
+			assert.Equal("test", gotClaims["name"])
+			assert.EqualValues(3, gotClaims["count"])
+
+			var typed struct {
+				Name  string
+				Count int
+			}
+			require.NoError(json.Unmarshal(raw, &typed))
+			assert.Equal("test", typed.Name)
+			assert.Equal(3, typed.Count)
+		})
+	}
+}
@@ -0,0 +1,191 @@
+// Copyright (c) Edgeless Systems GmbH.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package attestation
+
+import (
+	"context"
+	"crypto/sha256"
+	"crypto/tls"
+	"crypto/x509"
+	"net"
+	"net/http"
+	"net/http/httptrace"
+	"sync"
+	"time"
+)
+
+// HTTPTransport is an http.RoundTripper that verifies the embedded report of each new TLS connection.
+//
+// Verified reports are cached per certificate for the configured TTL. If a connection is about to be
+// reused after its certificate's cache entry has expired, the connection is closed. The request is
+// then retried on a new connection that is verified again. Requests whose body can't be rewound,
+// i.e., that have a body but no GetBody, can't be retried. They are always sent on a new connection.
+//
+// Proxies aren't supported, because the transport would send the request through a TLS connection
+// to the proxy instead of the attested connection. Proxy environment variables like HTTPS_PROXY are ignored.
+type HTTPTransport struct {
+	transport          *http.Transport
+	freshTransport     *http.Transport
+	cache              *verificationCache
+	verifyRemoteReport func([]byte) (Report, error)
+	opts               Options
+	verifyReport       func(Report) error
+	attestationError   func(host string, report *Report, err error) error
+}
+
+// NewHTTPTransport creates a new HTTPTransport. If ttl is not positive, verified reports aren't cached and
+// connections are reused without verifying them again. If the attestation of a server fails, the transport
+// returns the error created by attestationError.
+func NewHTTPTransport(verifyRemoteReport func([]byte) (Report, error), opts Options, verifyReport func(Report) error, ttl time.Duration,
+	attestationError func(host string, report *Report, err error) error,
+) *HTTPTransport {
+	t := &HTTPTransport{
+		cache:              newVerificationCache(ttl),
+		verifyRemoteReport: verifyRemoteReport,
+		opts:               opts,
+		verifyReport:       verifyReport,
+		attestationError:   attestationError,
+	}
+	t.transport = &http.Transport{
+		DialTLSContext:      t.dialTLS,
+		MaxIdleConns:        100,
+		IdleConnTimeout:     90 * time.Second,
+		TLSHandshakeTimeout: 10 * time.Second,
+	}
+	t.freshTransport = &http.Transport{
+		DialTLSContext:      t.dialTLS,
+		DisableKeepAlives:   true,
+		TLSHandshakeTimeout: 10 * time.Second,
+	}
+	return t
+}
+
+// RoundTrip implements http.RoundTripper.
+func (t *HTTPTransport) RoundTrip(req *http.Request) (*http.Response, error) {
+	if t.cache.ttl > 0 && req.Body != nil && req.Body != http.NoBody && req.GetBody == nil {
+		// The request couldn't be retried if a reused connection had to be closed.
+		return t.freshTransport.RoundTrip(req)
+	}
+	if t.cache.ttl > 0 {
+		trace := &httptrace.ClientTrace{GotConn: func(info httptrace.GotConnInfo) {
+			if !info.Reused {
+				return
+			}
+			conn, ok := info.Conn.(*tls.Conn)
+			if !ok {
+				return
+			}
+			if certs := conn.ConnectionState().PeerCertificates; len(certs) == 0 || !t.cache.valid(certs[0].Raw) {
+				// The transport retries the request on a new connection.
+				_ = conn.Close()
+			}
+		}}
+		req = req.WithContext(httptrace.WithClientTrace(req.Context(), trace))
+	}
+	return t.transport.RoundTrip(req)
+}
+
+// CloseIdleConnections closes idle connections.
+func (t *HTTPTransport) CloseIdleConnections() {
+	t.transport.CloseIdleConnections()
+	t.freshTransport.CloseIdleConnections()
+}
+
+func (t *HTTPTransport) dialTLS(ctx context.Context, network, addr string) (net.Conn, error) {
+	host, _, err := net.SplitHostPort(addr)
+	if err != nil {
+		return nil, err
+	}
+
+	var report *Report
+	var attestationErr error
+
+	config := CreateAttestationClientTLSConfig(t.verifyRemoteReport, t.opts, func(r Report) error {
+		report = &r
+		return t.verifyReport(r)
+	})
+	config.ServerName = host
+	verifyPeerCertificate := config.VerifyPeerCertificate
+	config.VerifyPeerCertificate = func(rawCerts [][]byte, verifiedChains [][]*x509.Certificate) error {
+		if len(rawCerts) > 0 {
+			if cached, ok := t.cache.get(rawCerts[0]); ok {
+				report = &cached
+				return nil
+			}
+		}
+		if err := verifyPeerCertificate(rawCerts, verifiedChains); err != nil {
+			attestationErr = err
+			return err
+		}
+		t.cache.put(rawCerts[0], *report)
+		return nil
+	}
+
+	dialer := &tls.Dialer{Config: config}
+	conn, err := dialer.DialContext(ctx, network, addr)
+	if err != nil {
+		if attestationErr != nil {
+			return nil, t.attestationError(addr, report, attestationErr)
+		}
+		return nil, err
+	}
+	return conn, nil
+}
+
+type verificationCache struct {
+	ttl     time.Duration
+	now     func() time.Time
+	mut     sync.Mutex
+	entries map[[sha256.Size]byte]verificationCacheEntry
+}
+
+type verificationCacheEntry struct {
+	report  Report
+	expires time.Time
+}
+
+func newVerificationCache(ttl time.Duration) *verificationCache {
+	return &verificationCache{
+		ttl:     ttl,
+		now:     time.Now,
+		entries: map[[sha256.Size]byte]verificationCacheEntry{},
+	}
+}
+
+func (c *verificationCache) get(cert []byte) (Report, bool) {
+	if c.ttl <= 0 {
+		return Report{}, false
+	}
+	c.mut.Lock()
+	defer c.mut.Unlock()
+	entry, ok := c.entries[sha256.Sum256(cert)]
+	if !ok || !c.now().Before(entry.expires) {
+		return Report{}, false
+	}
+	return entry.report, true
+}
+
+func (c *verificationCache) valid(cert []byte) bool {
+	_, ok := c.get(cert)
+	return ok
+}
+
+func (c *verificationCache) put(cert []byte, report Report) {
+	if c.ttl <= 0 {
+		return
+	}
+	c.mut.Lock()
+	defer c.mut.Unlock()
+
+	now := c.now()
+	for digest, entry := range c.entries {
+		if !now.Before(entry.expires) {
+			delete(c.entries, digest)
+		}
+	}
+	c.entries[sha256.Sum256(cert)] = verificationCacheEntry{report: report, expires: now.Add(c.ttl)}
+}
@@ -0,0 +1,246 @@
+// Copyright (c) Edgeless Systems GmbH.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package attestation
+
+import (
+	"errors"
+	"io"
+	"net/http"
+	"net/http/httptest"
+	"strings"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestHTTPTransport(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	getRemoteReport := func(reportData []byte) ([]byte, error) {
+		return append([]byte{2}, reportData...), nil
+	}
+	var verifyCount atomic.Int32
+	verifyRemoteReport := func(reportBytes []byte) (Report, error) {
+		verifyCount.Add(1)
+		return Report{Data: reportBytes[1:], SecurityVersion: 2}, nil
+	}
+
+	serverConfig, err := CreateAttestationServerTLSConfig(HashPublicKey, getRemoteReport)
+	require.NoError(err)
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_, _ = io.WriteString(w, "hello")
+	}))
+	server.TLS = serverConfig
+	server.StartTLS()
+	defer server.Close()
+
+	transport := NewHTTPTransport(verifyRemoteReport, Options{}, func(Report) error { return nil }, time.Hour, newTestAttestationError)
+	now := time.Now()
+	transport.cache.now = func() time.Time { return now }
+	client := http.Client{Transport: transport}
+
+	get := func() {
+		t.Helper()
+		resp, err := client.Get(server.URL)
+		require.NoError(err)
+		defer resp.Body.Close()
+		body, err := io.ReadAll(resp.Body)
+		require.NoError(err)
+		assert.EqualValues("hello", body)
+	}
+
+	// connection is reused
+	get()
+	get()
+	assert.EqualValues(1, verifyCount.Load())
+
+	// new connection uses the cache
+	transport.CloseIdleConnections()
+	get()
+	assert.EqualValues(1, verifyCount.Load())
+
+	// reused connection is verified again after the TTL expired
+	now = now.Add(time.Hour)
+	get()
+	assert.EqualValues(2, verifyCount.Load())
+	get()
+	assert.EqualValues(2, verifyCount.Load())
+
+	// POST with body is retried as well
+	now = now.Add(time.Hour)
+	resp, err := client.Post(server.URL, "text/plain", strings.NewReader("body"))
+	require.NoError(err)
+	resp.Body.Close()
+	assert.EqualValues(3, verifyCount.Load())
+
+	// a body that can't be rewound is sent on a new connection
+	now = now.Add(time.Hour)
+	req, err := http.NewRequest(http.MethodPost, server.URL, io.NopCloser(strings.NewReader("body")))
+	require.NoError(err)
+	require.Nil(req.GetBody)
+	resp, err = client.Do(req)
+	require.NoError(err)
+	resp.Body.Close()
+	assert.EqualValues(4, verifyCount.Load())
+	// the verification of the new connection also applies to the reused one
+	get()
+	assert.EqualValues(4, verifyCount.Load())
+}
+
+// TestHTTPTransportProxy checks that a proxy from the environment doesn't bypass the attestation.
+func TestHTTPTransportProxy(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	getRemoteReport := func(reportData []byte) ([]byte, error) {
+		return append([]byte{2}, reportData...), nil
+	}
+	var verifyCount atomic.Int32
+	verifyRemoteReport := func(reportBytes []byte) (Report, error) {
+		verifyCount.Add(1)
+		return Report{Data: reportBytes[1:]}, nil
+	}
+
+	var proxyUsed atomic.Bool
+	proxy := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		proxyUsed.Store(true)
+		w.WriteHeader(http.StatusBadGateway)
+	}))
+	defer proxy.Close()
+	t.Setenv("HTTPS_PROXY", proxy.URL)
+	t.Setenv("NO_PROXY", "")
+
+	serverConfig, err := CreateAttestationServerTLSConfig(HashPublicKey, getRemoteReport)
+	require.NoError(err)
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	server.TLS = serverConfig
+	server.StartTLS()
+	defer server.Close()
+
+	client := http.Client{Transport: NewHTTPTransport(verifyRemoteReport, Options{}, func(Report) error { return nil }, time.Hour, newTestAttestationError)}
+	resp, err := client.Get(server.URL)
+	require.NoError(err)
+	resp.Body.Close()
+	assert.EqualValues(1, verifyCount.Load())
+	assert.False(proxyUsed.Load())
+}
+
+func TestHTTPTransportNoCache(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	getRemoteReport := func(reportData []byte) ([]byte, error) {
+		return append([]byte{2}, reportData...), nil
+	}
+	var verifyCount atomic.Int32
+	verifyRemoteReport := func(reportBytes []byte) (Report, error) {
+		verifyCount.Add(1)
+		return Report{Data: reportBytes[1:]}, nil
+	}
+
+	serverConfig, err := CreateAttestationServerTLSConfig(HashPublicKey, getRemoteReport)
+	require.NoError(err)
+	server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+	server.TLS = serverConfig
+	server.StartTLS()
+	defer server.Close()
+
+	transport := NewHTTPTransport(verifyRemoteReport, Options{}, func(Report) error { return nil }, 0, newTestAttestationError)
+	client := http.Client{Transport: transport}
+
+	for i := 0; i < 2; i++ {
+		resp, err := client.Get(server.URL)
+		require.NoError(err)
+		resp.Body.Close()
+		transport.CloseIdleConnections()
+	}
+	assert.EqualValues(2, verifyCount.Load())
+}
+
+func TestHTTPTransportError(t *testing.T) {
+	getRemoteReport := func(reportData []byte) ([]byte, error) {
+		return append([]byte{2}, reportData...), nil
+	}
+	verifyRemoteReport := func(reportBytes []byte) (Report, error) {
+		return Report{Data: reportBytes[1:], SecurityVersion: 2}, nil
+	}
+	errVerifyRemoteReport := errors.New("invalid remote report")
+	failToVerifyRemoteReport := func([]byte) (Report, error) {
+		return Report{}, errVerifyRemoteReport
+	}
+	errVerifyReport := errors.New("invalid report")
+
+	testCases := map[string]struct {
+		verifyRemoteReport func([]byte) (Report, error)
+		verifyReport       func(Report) error
+		wantErr            error
+		wantReport         bool
+	}{
+		"invalid remote report": {
+			verifyRemoteReport: failToVerifyRemoteReport,
+			verifyReport:       func(Report) error { return nil },
+			wantErr:            errVerifyRemoteReport,
+		},
+		"invalid report": {
+			verifyRemoteReport: verifyRemoteReport,
+			verifyReport:       func(Report) error { return errVerifyReport },
+			wantErr:            errVerifyReport,
+			wantReport:         true,
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			assert := assert.New(t)
+			require := require.New(t)
+
+			serverConfig, err := CreateAttestationServerTLSConfig(HashPublicKey, getRemoteReport)
+			require.NoError(err)
+			server := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {}))
+			server.TLS = serverConfig
+			server.StartTLS()
+			defer server.Close()
+
+			client := http.Client{Transport: NewHTTPTransport(tc.verifyRemoteReport, Options{}, tc.verifyReport, time.Hour, newTestAttestationError)}
+			_, err = client.Get(server.URL)
+			require.Error(err)
+
+			var attErr *testAttestationError
+			require.ErrorAs(err, &attErr)
+			assert.ErrorIs(err, tc.wantErr)
+			assert.Equal(server.Listener.Addr().String(), attErr.Host)
+			if tc.wantReport {
+				require.NotNil(attErr.Report)
+				assert.EqualValues(2, attErr.Report.SecurityVersion)
+			} else {
+				assert.Nil(attErr.Report)
+			}
+		})
+	}
+}
+
+type testAttestationError struct {
+	Host   string
+	Report *Report
+	Err    error
+}
+
+func newTestAttestationError(host string, report *Report, err error) error {
+	return &testAttestationError{Host: host, Report: report, Err: err}
+}
+
+func (e *testAttestationError) Error() string {
+	return e.Err.Error()
+}
+
+func (e *testAttestationError) Unwrap() error {
+	return e.Err
+}
@@ -0,0 +1,256 @@
+// Copyright (c) Edgeless Systems GmbH.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package attestation
+
+import (
+	"context"
+	"crypto/x509"
+	"encoding/base64"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"sync"
+	"time"
+
+	"github.com/go-jose/go-jose/v4"
+)
+
+const (
+	// defaultKeySetMaxAge is the duration after which a cached key set is fetched again.
+	defaultKeySetMaxAge = 24 * time.Hour
+	// defaultMinKeySetRefreshInterval limits how often an unknown key ID triggers fetching the key set.
+	defaultMinKeySetRefreshInterval = time.Minute
+	// maxKeySetSize limits the size of a fetched key set.
+	maxKeySetSize = 1 << 20
+	// defaultKeySetFetchTimeout limits the time fetching the key set takes with the default client.
+	defaultKeySetFetchTimeout = 30 * time.Second
+)
+
+// KeySetConfig configures how the key set of a token issuer is obtained. The zero value is a valid config.
+type KeySetConfig struct {
+	// HTTPClient is used to fetch the key set. Defaults to a client with a timeout of 30 seconds.
+	HTTPClient *http.Client
+	// SigningRoots are the roots the x5c certificate chains of the keys must chain up to. If nil, the last
+	// certificate of each chain is used as its root, i.e., the trust is based on the TLS channel only.
+	SigningRoots *x509.CertPool
+	// KeySet is a pinned JSON Web Key Set. If set, keys are never fetched from the issuer.
+	KeySet []byte
+	// KeySetMaxAge is the duration after which a cached key set is fetched again. Defaults to 24 hours.
+	KeySetMaxAge time.Duration
+	// MinRefreshInterval limits how often a token with an unknown key ID triggers fetching the key set. Defaults to one minute.
+	MinRefreshInterval time.Duration
+}
+
+// keySetCache caches the key set of a token issuer. The key set is fetched again if it is older than
+// KeySetMaxAge or if a token is signed with an unknown key.
+type keySetCache struct {
+	url    string
+	config KeySetConfig
+
+	mut       sync.Mutex
+	keySet    *jose.JSONWebKeySet
+	fetchedAt time.Time
+	fetching  *keySetFetch // the running fetch; nil if no fetch is running
+}
+
+type keySetFetch struct {
+	done chan struct{}
+	err  error
+}
+
+func newKeySetCache(url string, config KeySetConfig) *keySetCache {
+	if config.HTTPClient == nil {
+		config.HTTPClient = &http.Client{Timeout: defaultKeySetFetchTimeout}
+	}
+	if config.KeySetMaxAge <= 0 {
+		config.KeySetMaxAge = defaultKeySetMaxAge
+	}
+	if config.MinRefreshInterval <= 0 {
+		config.MinRefreshInterval = defaultMinKeySetRefreshInterval
+	}
+	return &keySetCache{url: url, config: config}
+}
+
+// get returns the cached key set. It fetches the key set if it is outdated or doesn't contain keyID.
+// The key set is fetched without holding the lock. Concurrent callers wait for a running fetch.
+func (c *keySetCache) get(ctx context.Context, keyID string, now time.Time) (*jose.JSONWebKeySet, error) {
+	for {
+		c.mut.Lock()
+		if c.config.KeySet != nil {
+			keySet, err := c.pinnedKeySet(now)
+			c.mut.Unlock()
+			return keySet, err
+		}
+
+		outdated := c.keySet == nil || !now.Before(c.fetchedAt.Add(c.config.KeySetMaxAge))
+		unknownKey := c.keySet != nil && len(c.keySet.Key(keyID)) == 0
+		// Don't let tokens with unknown keys trigger a fetch for every verification.
+		if !outdated && (!unknownKey || now.Before(c.fetchedAt.Add(c.config.MinRefreshInterval))) {
+			keySet := c.keySet
+			c.mut.Unlock()
+			return keySet, nil
+		}
+
+		if c.fetching == nil {
+			return c.fetchUnlock(ctx, now, outdated)
+		}
+
+		// wait for the running fetch and use its result
+		fetch := c.fetching
+		c.mut.Unlock()
+		select {
+		case <-fetch.done:
+		case <-ctx.Done():
+			return nil, ctx.Err()
+		}
+		// If the fetch failed because the fetching caller has been canceled, retry with our context.
+		if fetch.err != nil && !errors.Is(fetch.err, context.Canceled) && !errors.Is(fetch.err, context.DeadlineExceeded) {
+			return c.fetchFailed(fetch.err, outdated)
+		}
+	}
+}
+
+// pinnedKeySet returns the parsed pinned key set. c.mut must be held.
+func (c *keySetCache) pinnedKeySet(now time.Time) (*jose.JSONWebKeySet, error) {
+	if c.keySet == nil {
+		keySet, err := parseKeySet(c.config.KeySet, c.config.SigningRoots, now)
+		if err != nil {
+			return nil, fmt.Errorf("parsing pinned key set: %w", err)
+		}
+		c.keySet = &keySet
+	}
+	return c.keySet, nil
+}
+
+// fetchUnlock fetches the key set. c.mut must be held. It's released while fetching and on return.
+func (c *keySetCache) fetchUnlock(ctx context.Context, now time.Time, outdated bool) (*jose.JSONWebKeySet, error) {
+	fetch := &keySetFetch{done: make(chan struct{})}
+	c.fetching = fetch
+	c.mut.Unlock()
+
+	keySet, err := c.fetch(ctx, now)
+
+	c.mut.Lock()
+	c.fetching = nil
+	fetch.err = err
+	close(fetch.done)
+	if err != nil {
+		c.mut.Unlock()
+		return c.fetchFailed(err, outdated)
+	}
+	c.keySet = &keySet
+	c.fetchedAt = now
+	c.mut.Unlock()
+	return &keySet, nil
+}
+
+func (c *keySetCache) fetchFailed(err error, outdated bool) (*jose.JSONWebKeySet, error) {
+	c.mut.Lock()
+	defer c.mut.Unlock()
+	if c.keySet != nil && !outdated {
+		// keep using the cached keys; the token will fail verification if its key is unknown
+		return c.keySet, nil
+	}
+	return nil, err
+}
+
+func (c *keySetCache) fetch(ctx context.Context, now time.Time) (jose.JSONWebKeySet, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, c.url, nil)
+	if err != nil {
+		return jose.JSONWebKeySet{}, err
+	}
+	req.Header.Set("Accept", "application/json")
+
+	resp, err := c.config.HTTPClient.Do(req)
+	if err != nil {
+		return jose.JSONWebKeySet{}, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return jose.JSONWebKeySet{}, fmt.Errorf("http response has status %v", resp.Status)
+	}
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxKeySetSize))
+	if err != nil {
+		return jose.JSONWebKeySet{}, err
+	}
+
+	return parseKeySet(body, c.config.SigningRoots, now)
+}
+
+// parseKeySet parses a key set whose keys are given as x5c certificate chains. The chains are verified
+// against roots. If roots is nil, the last certificate of each chain is used as its root.
+// Invalid keys are skipped. An error is returned if no key is valid.
+func parseKeySet(keySetBytes []byte, roots *x509.CertPool, now time.Time) (jose.JSONWebKeySet, error) {
+	var rawKeySet struct {
+		Keys []struct {
+			X5c []string
+			Kid string
+		}
+	}
+	if err := json.Unmarshal(keySetBytes, &rawKeySet); err != nil {
+		return jose.JSONWebKeySet{}, err
+	}
+
+	var keySet jose.JSONWebKeySet
+	var keyErrs []error
+	for _, key := range rawKeySet.Keys {
+		chain, err := parseKeyChain(key.X5c, roots, now)
+		if err != nil {
+			keyErrs = append(keyErrs, fmt.Errorf("key %q: %w", key.Kid, err))
+			continue
+		}
+		keySet.Keys = append(keySet.Keys, jose.JSONWebKey{KeyID: key.Kid, Key: chain[0].PublicKey, Certificates: chain})
+	}
+
+	if len(keySet.Keys) == 0 {
+		if len(keyErrs) == 0 {
+			return jose.JSONWebKeySet{}, errors.New("key set has no keys")
+		}
+		return jose.JSONWebKeySet{}, fmt.Errorf("key set has no valid keys: %w", errors.Join(keyErrs...))
+	}
+	return keySet, nil
+}
+
+// parseKeyChain parses and verifies the x5c certificate chain of a key.
+func parseKeyChain(x5c []string, roots *x509.CertPool, now time.Time) ([]*x509.Certificate, error) {
+	if len(x5c) == 0 {
+		return nil, errors.New("missing x5c")
+	}
+
+	var chain []*x509.Certificate
+	for i, rawCertBase64 := range x5c {
+		rawCert, err := base64.StdEncoding.DecodeString(rawCertBase64)
+		if err != nil {
+			return nil, fmt.Errorf("decoding certificate %v: %w", i, err)
+		}
+		cert, err := x509.ParseCertificate(rawCert)
+		if err != nil {
+			return nil, fmt.Errorf("parsing certificate %v: %w", i, err)
+		}
+		chain = append(chain, cert)
+	}
+
+	opts := x509.VerifyOptions{
+		Roots:         roots,
+		Intermediates: x509.NewCertPool(),
+		CurrentTime:   now,
+		KeyUsages:     []x509.ExtKeyUsage{x509.ExtKeyUsageAny},
+	}
+	for _, cert := range chain[1:] {
+		opts.Intermediates.AddCert(cert)
+	}
+	if opts.Roots == nil {
+		opts.Roots = x509.NewCertPool()
+		opts.Roots.AddCert(chain[len(chain)-1])
+	}
+	if _, err := chain[0].Verify(opts); err != nil {
+		return nil, fmt.Errorf("verifying x5c chain: %w", err)
+	}
+	return chain, nil
+}
@@ -0,0 +1,153 @@
+// Copyright (c) Edgeless Systems GmbH.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package attestation
+
+import (
+	"context"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"net/http"
+	"net/http/httptest"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseKeySet(t *testing.T) {
+	caTemplate := &x509.Certificate{
+		Subject:               pkix.Name{CommonName: "ca"},
+		IsCA:                  true,
+		BasicConstraintsValid: true,
+		KeyUsage:              x509.KeyUsageCertSign,
+	}
+	ca, caKey := createCert(t, caTemplate, nil, nil)
+	leaf, _ := createCert(t, &x509.Certificate{Subject: pkix.Name{CommonName: "leaf"}}, ca, caKey)
+	otherCA, otherCAKey := createCert(t, caTemplate, nil, nil)
+	otherLeaf, _ := createCert(t, &x509.Certificate{Subject: pkix.Name{CommonName: "leaf"}}, otherCA, otherCAKey)
+	expired, _ := createCert(t, &x509.Certificate{NotAfter: time.Now().Add(-time.Hour)}, nil, nil)
+
+	roots := x509.NewCertPool()
+	roots.AddCert(ca)
+
+	testCases := map[string]struct {
+		keySet  []byte
+		roots   *x509.CertPool
+		wantErr bool
+	}{
+		"self-signed": {
+			keySet: createKeySet(t, map[string][]*x509.Certificate{"aaa": {ca}}),
+		},
+		"chain": {
+			keySet: createKeySet(t, map[string][]*x509.Certificate{"aaa": {leaf, ca}}),
+		},
+		"chain with roots": {
+			keySet: createKeySet(t, map[string][]*x509.Certificate{"aaa": {leaf}}),
+			roots:  roots,
+		},
+		"chain with other roots": {
+			keySet:  createKeySet(t, map[string][]*x509.Certificate{"aaa": {otherLeaf, otherCA}}),
+			roots:   roots,
+			wantErr: true,
+		},
+		"broken chain": {
+			keySet:  createKeySet(t, map[string][]*x509.Certificate{"aaa": {otherLeaf, ca}}),
+			wantErr: true,
+		},
+		"expired": {
+			keySet:  createKeySet(t, map[string][]*x509.Certificate{"aaa": {expired}}),
+			wantErr: true,
+		},
+		"missing x5c": {
+			keySet:  []byte(`{"keys":[{"kid":"aaa","kty":"RSA"}]}`),
+			wantErr: true,
+		},
+		"invalid base64": {
+			keySet:  []byte(`{"keys":[{"kid":"aaa","kty":"RSA","x5c":["AA-_"]}]}`),
+			wantErr: true,
+		},
+		"invalid certificate": {
+			keySet:  []byte(`{"keys":[{"kid":"aaa","kty":"RSA","x5c":["AAAA"]}]}`),
+			wantErr: true,
+		},
+		"invalid key is skipped": {
+			keySet: createKeySet(t, map[string][]*x509.Certificate{"aaa": {leaf, ca}, "bbb": {otherLeaf, ca}}),
+		},
+		"unverified key is skipped": {
+			keySet: createKeySet(t, map[string][]*x509.Certificate{"aaa": {leaf}, "bbb": {otherLeaf, otherCA}}),
+			roots:  roots,
+		},
+		"no keys": {
+			keySet:  []byte(`{"keys":[]}`),
+			wantErr: true,
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			assert := assert.New(t)
+			require := require.New(t)
+
+			keySet, err := parseKeySet(tc.keySet, tc.roots, time.Now())
+			if tc.wantErr {
+				assert.Error(err)
+				return
+			}
+			require.NoError(err)
+			require.Len(keySet.Keys, 1)
+			assert.Equal("aaa", keySet.Keys[0].KeyID)
+		})
+	}
+}
+
+func TestKeySetCacheConcurrentGet(t *testing.T) {
+	assert := assert.New(t)
+
+	cert, _ := createCert(t, &x509.Certificate{}, nil, nil)
+	keySet := createKeySet(t, map[string][]*x509.Certificate{"aaa": {cert}})
+	var requests atomic.Int32
+	release := make(chan struct{})
+	server := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		requests.Add(1)
+		<-release
+		_, _ = w.Write(keySet)
+	}))
+	defer server.Close()
+
+	cache := newKeySetCache(server.URL, KeySetConfig{})
+	now := time.Now()
+
+	var wg sync.WaitGroup
+	for i := 0; i < 5; i++ {
+		wg.Add(1)
+		go func() {
+			defer wg.Done()
+			keys, err := cache.get(context.Background(), "aaa", now)
+			if assert.NoError(err) {
+				assert.Len(keys.Keys, 1)
+			}
+		}()
+	}
+
+	// the lock isn't held while fetching
+	assert.Eventually(func() bool { return requests.Load() == 1 }, time.Second, time.Millisecond)
+	cache.mut.Lock()
+	cache.mut.Unlock()
+
+	// a waiting caller can give up
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err := cache.get(ctx, "aaa", now)
+	assert.ErrorIs(err, context.Canceled)
+
+	close(release)
+	wg.Wait()
+	assert.EqualValues(1, requests.Load())
+}
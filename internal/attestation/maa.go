@@ -8,6 +8,7 @@ package attestation
 
 import (
 	"bytes"
+	"context"
 	"crypto/tls"
 	"crypto/x509"
 	"encoding/base64"
@@ -16,22 +17,61 @@ import (
 	"encoding/json"
 	"errors"
 	"fmt"
-	"io"
+	"net"
 	"net/http"
 	"net/url"
+	"sync"
 	"time"
 
-	"github.com/go-jose/go-jose/v4"
-	"github.com/go-jose/go-jose/v4/jwt"
+	"github.com/edgelesssys/ego/attestation/tcbstatus"
 )
 
 // CreateAzureAttestationToken creates a Microsoft Azure Attestation Token by sending a report
 // to an Attestation Provider, who is reachable under baseurl. A JSON Web Token in compact
 // serialization is returned.
+//
+// The TLS certificate of the provider isn't verified. Use CreateAzureAttestationTokenContext for a trusted connection.
 func CreateAzureAttestationToken(report, data []byte, baseurl string) (string, error) {
+	// Skip TLS certificate verification, since the enclave may not have a set of Root CAs.
+	return CreateAzureAttestationTokenContext(context.Background(), report, data, baseurl, AzureTokenOptions{InsecureSkipVerify: true, MaxAttempts: 1})
+}
+
+var errCertificateNotPinned = errors.New("attestation provider's certificate doesn't match the pinned certificate")
+
+// AzureTokenOptions configures CreateAzureAttestationTokenContext.
+type AzureTokenOptions struct {
+	// HTTPClient is used to send the request. If set, the TLS options are ignored.
+	HTTPClient *http.Client
+	// RootCAs are used to verify the provider's certificate. If nil, the system roots are used.
+	RootCAs *x509.CertPool
+	// PinnedCertificate is the DER-encoded certificate the provider must present. The certificate chain isn't verified then.
+	PinnedCertificate []byte
+	// InsecureSkipVerify disables the verification of the provider's certificate.
+	InsecureSkipVerify bool
+	// RuntimeDataJSON sends the runtime data with data type JSON instead of Binary.
+	RuntimeDataJSON bool
+	// InitTimeData is sent as init-time data if not nil.
+	InitTimeData []byte
+	// InitTimeDataJSON sends the init-time data with data type JSON instead of Binary.
+	InitTimeDataJSON bool
+	// MaxAttempts is the maximum number of attempts. Defaults to 3.
+	MaxAttempts int
+	// Backoff is the delay before the first retry. It doubles for each further retry. Defaults to one second.
+	Backoff time.Duration
+}
+
+// CreateAzureAttestationTokenContext creates a Microsoft Azure Attestation Token by sending a report
+// to an Attestation Provider, who is reachable under baseurl. The report data must be the SHA-256 hash of runtimeData.
+// A JSON Web Token in compact serialization is returned.
+//
+// Requests that fail because of a network error, a server error, or rate limiting are retried with exponential backoff.
+func CreateAzureAttestationTokenContext(ctx context.Context, report, runtimeData []byte, baseurl string, opts AzureTokenOptions) (string, error) {
 	// Create attestation request struct.
-	rtd := rtdata{Data: base64.RawURLEncoding.EncodeToString(data), DataType: "Binary"}
-	attReq := attestOERequest{Report: base64.RawURLEncoding.EncodeToString(report), RuntimeData: rtd}
+	attReq := attestOERequest{Report: base64.RawURLEncoding.EncodeToString(report), RuntimeData: newRtdata(runtimeData, opts.RuntimeDataJSON)}
+	if opts.InitTimeData != nil {
+		initTimeData := newRtdata(opts.InitTimeData, opts.InitTimeDataJSON)
+		attReq.InitTimeData = &initTimeData
+	}
 
 	// Parse url and add path.
 	uri, err := url.Parse(baseurl)
@@ -50,94 +90,145 @@ func CreateAzureAttestationToken(report, data []byte, baseurl string) (string, e
 		return "", err
 	}
 
-	// Create HTTP client skiping TLS certificate verification, since
-	// the enclave does not have a set of Root CAs. There is no need
-	// for a trusted connection.
-	tlsConfig := &tls.Config{InsecureSkipVerify: true}
-	client := http.Client{Transport: &http.Transport{TLSClientConfig: tlsConfig}}
-	resp, err := client.Post(uri.String(), "application/json", bytes.NewReader(jsonReq))
-	if err != nil {
-		return "", err
+	client := opts.HTTPClient
+	if client == nil {
+		transport, shared := azureTransport(opts)
+		if !shared {
+			defer transport.CloseIdleConnections()
+		}
+		client = &http.Client{Transport: transport}
 	}
-	defer resp.Body.Close()
-
-	// Check response and return the token.
-	if resp.StatusCode != http.StatusOK {
-		return "", fmt.Errorf("attestation request failed, attestation provider returned status code %v", resp.StatusCode)
+	maxAttempts := opts.MaxAttempts
+	if maxAttempts <= 0 {
+		maxAttempts = 3
 	}
-	body := new(attestationResponse)
-	if err := json.NewDecoder(resp.Body).Decode(body); err != nil {
-		return "", err
+	backoff := opts.Backoff
+	if backoff <= 0 {
+		backoff = time.Second
 	}
-	return body.Token, nil
-}
 
-// VerifyAzureAttestationToken takes a Microsoft Azure Attestation Token in JSON Web Token compact
-// serialization format and verifies the tokens public claims and signature. The Attestation providers
-// keys are loaded from baseURL over an TLS connection. The validation is based on the trust in this TLS channel.
-// Note, that the token's issuer (iss) has to equal the baseURL's string representation.
-//
-// Attention: the calling function needs to ensure the scheme of baseURL is HTTPS,
-// e.g. by calling the ParseHTTPS function of this package.
-func VerifyAzureAttestationToken(rawToken string, baseURL *url.URL) (Report, error) {
-	// Parse baseURL and add path.
-	path, err := url.Parse("/certs")
-	if err != nil {
-		return Report{}, err
+	for attempt := 1; ; attempt++ {
+		token, retry, err := postAttestationRequest(ctx, client, uri.String(), jsonReq)
+		if err == nil {
+			return token, nil
+		}
+		if !retry || attempt >= maxAttempts {
+			return "", err
+		}
+		select {
+		case <-ctx.Done():
+			return "", fmt.Errorf("%w (last error: %v)", ctx.Err(), err)
+		case <-time.After(backoff):
+		}
+		backoff *= 2
 	}
-	uri := baseURL.ResolveReference(path)
+}
 
-	// Get JSON Web Key set.
-	jwkSetBytes, err := httpGet(uri.String())
-	if err != nil {
-		return Report{}, err
+// azureTransports holds a transport per set of TLS options, so that connections to the provider are reused across calls.
+var azureTransports = struct {
+	mut        sync.Mutex
+	transports map[azureTransportKey]*http.Transport
+}{transports: map[azureTransportKey]*http.Transport{}}
+
+type azureTransportKey struct {
+	rootCAs            *x509.CertPool
+	pinnedCertificate  string
+	insecureSkipVerify bool
+}
+
+// maxAzureTransports limits the number of shared transports, e.g., if a caller creates new root CAs for each call.
+const maxAzureTransports = 16
+
+// azureTransport returns a transport for the TLS options of o. If the transport isn't shared, the caller must close its connections.
+func azureTransport(o AzureTokenOptions) (*http.Transport, bool) {
+	key := azureTransportKey{rootCAs: o.RootCAs, pinnedCertificate: string(o.PinnedCertificate), insecureSkipVerify: o.InsecureSkipVerify}
+
+	azureTransports.mut.Lock()
+	defer azureTransports.mut.Unlock()
+	if transport, ok := azureTransports.transports[key]; ok {
+		return transport, true
 	}
-	keySet, err := parseKeySet(jwkSetBytes)
-	if err != nil {
-		return Report{}, err
+	transport := newAzureTransport(o.tlsConfig())
+	if len(azureTransports.transports) >= maxAzureTransports {
+		return transport, false
 	}
+	azureTransports.transports[key] = transport
+	return transport, true
+}
 
-	// Parse token.
-	token, err := jwt.ParseSigned(rawToken, []jose.SignatureAlgorithm{jose.RS256})
-	if err != nil {
-		return Report{}, err
+func newAzureTransport(tlsConfig *tls.Config) *http.Transport {
+	dialer := &net.Dialer{Timeout: 30 * time.Second, KeepAlive: 30 * time.Second}
+	return &http.Transport{
+		DialContext:           dialer.DialContext,
+		TLSClientConfig:       tlsConfig,
+		TLSHandshakeTimeout:   10 * time.Second,
+		ResponseHeaderTimeout: time.Minute,
+		IdleConnTimeout:       90 * time.Second,
+		MaxIdleConns:          16,
 	}
+}
 
-	// Verify token and get claims.
-	var publicClaims jwt.Claims
-	var privateClaims privateClaims
-	if err := token.Claims(&keySet, &publicClaims, &privateClaims); err != nil {
-		return Report{}, err
+func (o AzureTokenOptions) tlsConfig() *tls.Config {
+	if o.InsecureSkipVerify {
+		return &tls.Config{InsecureSkipVerify: true}
 	}
-
-	// Verify public claims.
-	if err := publicClaims.Validate(jwt.Expected{Issuer: baseURL.String(), Time: time.Now()}); err != nil {
-		return Report{}, err
+	if o.PinnedCertificate != nil {
+		pinned := o.PinnedCertificate
+		return &tls.Config{
+			// The chain isn't verified, the certificate is compared to the pinned one instead.
+			InsecureSkipVerify: true,
+			VerifyPeerCertificate: func(rawCerts [][]byte, _ [][]*x509.Certificate) error {
+				if len(rawCerts) == 0 || !bytes.Equal(rawCerts[0], pinned) {
+					return errCertificateNotPinned
+				}
+				return nil
+			},
+		}
 	}
+	return &tls.Config{RootCAs: o.RootCAs}
+}
 
-	// Create a report form the private claims.
-	data, err := base64.RawURLEncoding.DecodeString(privateClaims.Data)
+// postAttestationRequest sends the request and returns the token. If the request failed, it also returns whether it should be retried.
+func postAttestationRequest(ctx context.Context, client *http.Client, uri string, jsonReq []byte) (string, bool, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, uri, bytes.NewReader(jsonReq))
 	if err != nil {
-		return Report{}, err
+		return "", false, err
 	}
-	uniqueID, err := hex.DecodeString(privateClaims.UniqueID)
+	req.Header.Set("Content-Type", "application/json")
+
+	resp, err := client.Do(req)
 	if err != nil {
-		return Report{}, err
+		// A certificate error won't go away by retrying.
+		var certErr *tls.CertificateVerificationError
+		retry := ctx.Err() == nil && !errors.As(err, &certErr) && !errors.Is(err, errCertificateNotPinned)
+		return "", retry, err
 	}
-	signerID, err := hex.DecodeString(privateClaims.SignerID)
-	if err != nil {
-		return Report{}, err
+	defer resp.Body.Close()
+
+	// Check response and return the token.
+	if resp.StatusCode != http.StatusOK {
+		retry := resp.StatusCode == http.StatusTooManyRequests || resp.StatusCode >= http.StatusInternalServerError
+		return "", retry, fmt.Errorf("attestation request failed, attestation provider returned status code %v", resp.StatusCode)
 	}
-	productID := make([]byte, 16)
-	binary.LittleEndian.PutUint16(productID, uint16(privateClaims.ProductID))
-	return Report{
-		Data:            data,
-		SecurityVersion: privateClaims.SecurityVersion,
-		Debug:           privateClaims.Debug,
-		UniqueID:        uniqueID,
-		SignerID:        signerID,
-		ProductID:       productID,
-	}, nil
+	body := new(attestationResponse)
+	if err := json.NewDecoder(resp.Body).Decode(body); err != nil {
+		return "", false, err
+	}
+	return body.Token, false, nil
+}
+
+// VerifyAzureAttestationToken takes a Microsoft Azure Attestation Token in JSON Web Token compact
+// serialization format and verifies the tokens public claims and signature. The Attestation providers
+// keys are loaded from baseURL over an TLS connection. The validation is based on the trust in this TLS channel.
+// Note, that the token's issuer (iss) has to equal the baseURL's string representation.
+//
+// The keys are cached per baseURL, see AzureVerifier.
+//
+// Attention: the calling function needs to ensure the scheme of baseURL is HTTPS,
+// e.g. by calling the ParseHTTPS function of this package.
+func VerifyAzureAttestationToken(rawToken string, baseURL *url.URL) (AzureReport, error) {
+	verifier, _ := defaultAzureVerifiers.LoadOrStore(baseURL.String(), NewAzureVerifier(baseURL, KeySetConfig{}))
+	return verifier.(*AzureVerifier).Verify(rawToken)
 }
 
 // ParseHTTPS parses an URL and ensures its scheme is HTTPS.
@@ -156,8 +247,9 @@ func ParseHTTPS(URL string) (*url.URL, error) {
 // See https://docs.microsoft.com/en-us/rest/api/attestation/attestation/attestopenenclave
 // for REST API documentation of Azure Attestation Provider.
 type attestOERequest struct {
-	Report      string `json:"report"`
-	RuntimeData rtdata `json:"runtimeData"`
+	Report       string  `json:"report"`
+	RuntimeData  rtdata  `json:"runtimeData"`
+	InitTimeData *rtdata `json:"initTimeData,omitempty"`
 }
 
 type rtdata struct {
@@ -165,56 +257,99 @@ type rtdata struct {
 	DataType string `json:"dataType"`
 }
 
+func newRtdata(data []byte, isJSON bool) rtdata {
+	dataType := "Binary"
+	if isJSON {
+		dataType = "JSON"
+	}
+	return rtdata{Data: base64.RawURLEncoding.EncodeToString(data), DataType: dataType}
+}
+
 type attestationResponse struct {
 	Token string `json:"token"`
 }
 
+// AzureReport is a report that has been created from the claims of an Azure Attestation token.
+type AzureReport struct {
+	Report
+	AttestationType string            // The type of attestation (x-ms-attestation-type), e.g., "sgx".
+	PolicyHash      []byte            // The hash of the attestation policy that has been evaluated (x-ms-policy-hash).
+	Collateral      map[string]string // The hashes of the collateral used for the attestation (x-ms-sgx-collateral).
+	ConfigID        []byte            // The SGX CONFIGID of the enclave (x-ms-sgx-config-id).
+	Version         string            // The version of the token format (x-ms-ver).
+	Claims          map[string]any    // All claims of the token.
+}
+
 // privateClaims are some of the private claims of an Azure Attestation token.
 type privateClaims struct {
-	Data            string `json:"x-ms-sgx-ehd"`
-	SecurityVersion uint   `json:"x-ms-sgx-svn"`
-	Debug           bool   `json:"x-ms-sgx-is-debuggable"`
-	UniqueID        string `json:"x-ms-sgx-mrenclave"`
-	SignerID        string `json:"x-ms-sgx-mrsigner"`
-	ProductID       uint   `json:"x-ms-sgx-product-id"`
-}
-
-func parseKeySet(keySetBytes []byte) (jose.JSONWebKeySet, error) {
-	var rawKeySet struct {
-		Keys []struct {
-			X5c []string
-			Kid string
+	Data            string            `json:"x-ms-sgx-ehd"`
+	SecurityVersion uint              `json:"x-ms-sgx-svn"`
+	Debug           bool              `json:"x-ms-sgx-is-debuggable"`
+	UniqueID        string            `json:"x-ms-sgx-mrenclave"`
+	SignerID        string            `json:"x-ms-sgx-mrsigner"`
+	ProductID       uint              `json:"x-ms-sgx-product-id"`
+	AttestationType string            `json:"x-ms-attestation-type,omitempty"`
+	PolicyHash      string            `json:"x-ms-policy-hash,omitempty"`
+	Collateral      map[string]string `json:"x-ms-sgx-collateral,omitempty"`
+	ConfigID        string            `json:"x-ms-sgx-config-id,omitempty"`
+	Version         string            `json:"x-ms-ver,omitempty"`
+	// The token only carries the TCB status if the attestation policy issues it.
+	TCBStatus string `json:"x-ms-sgx-tcbstatus,omitempty"`
+}
+
+// toAzureReport creates a report from the private claims.
+func (c privateClaims) toAzureReport(rawClaims map[string]any) (AzureReport, error) {
+	data, err := base64.RawURLEncoding.DecodeString(c.Data)
+	if err != nil {
+		return AzureReport{}, err
+	}
+	uniqueID, err := hex.DecodeString(c.UniqueID)
+	if err != nil {
+		return AzureReport{}, err
+	}
+	signerID, err := hex.DecodeString(c.SignerID)
+	if err != nil {
+		return AzureReport{}, err
+	}
+	productID := make([]byte, 16)
+	binary.LittleEndian.PutUint16(productID, uint16(c.ProductID))
+
+	var policyHash, configID []byte
+	if c.PolicyHash != "" {
+		if policyHash, err = base64.RawURLEncoding.DecodeString(c.PolicyHash); err != nil {
+			return AzureReport{}, fmt.Errorf("decoding policy hash: %w", err)
 		}
 	}
-	if err := json.Unmarshal(keySetBytes, &rawKeySet); err != nil {
-		return jose.JSONWebKeySet{}, err
+	if c.ConfigID != "" {
+		if configID, err = hex.DecodeString(c.ConfigID); err != nil {
+			return AzureReport{}, fmt.Errorf("decoding config ID: %w", err)
+		}
 	}
 
-	var keySet jose.JSONWebKeySet
-	for _, key := range rawKeySet.Keys {
-		rawCert, _ := base64.StdEncoding.DecodeString(key.X5c[0])
-		cert, err := x509.ParseCertificate(rawCert)
+	tcbStatus := tcbstatus.Unknown
+	if c.TCBStatus != "" {
+		var err error
+		tcbStatus, err = tcbstatus.Parse(c.TCBStatus)
 		if err != nil {
-			return jose.JSONWebKeySet{}, err
+			return AzureReport{}, err
 		}
-		keySet.Keys = append(keySet.Keys, jose.JSONWebKey{KeyID: key.Kid, Key: cert.PublicKey})
 	}
 
-	return keySet, nil
-}
-
-func httpGet(url string) ([]byte, error) {
-	resp, err := http.Get(url)
-	if err != nil {
-		return nil, err
-	}
-	defer resp.Body.Close()
-	if resp.StatusCode != http.StatusOK {
-		return nil, fmt.Errorf("http response has status %v", resp.Status)
-	}
-	body, err := io.ReadAll(resp.Body)
-	if err != nil {
-		return nil, err
-	}
-	return body, nil
+	return AzureReport{
+		Report: Report{
+			Data:            data,
+			SecurityVersion: c.SecurityVersion,
+			Debug:           c.Debug,
+			UniqueID:        uniqueID,
+			SignerID:        signerID,
+			ProductID:       productID,
+			TCBStatus:       tcbStatus,
+		},
+		AttestationType: c.AttestationType,
+		PolicyHash:      policyHash,
+		Collateral:      c.Collateral,
+		ConfigID:        configID,
+		Version:         c.Version,
+		Claims:          rawClaims,
+	}, nil
 }
@@ -8,6 +8,7 @@ package attestation
 
 import (
 	"bytes"
+	"context"
 	"crypto/rand"
 	"crypto/rsa"
 	"crypto/x509"
@@ -17,14 +18,17 @@ import (
 	"encoding/hex"
 	"encoding/json"
 	"math/big"
+	"net"
 	"net/http"
 	"net/http/httptest"
 	"net/url"
+	"sync/atomic"
 	"testing"
 	"time"
 
 	"github.com/go-jose/go-jose/v4"
 	"github.com/go-jose/go-jose/v4/jwt"
+	"github.com/stretchr/testify/assert"
 	"github.com/stretchr/testify/require"
 )
 
@@ -272,10 +276,9 @@ func TestVerifyAzureAttestationToken(t *testing.T) {
 
 func DisabledTestSharedProviderKeyParsing(t *testing.T) {
 	require := require.New(t)
-	url := "https://shareduks.uks.attest.azure.net"
-	jwkSetBytes, err := httpGet(url + "/certs")
+	uri, err := url.Parse("https://shareduks.uks.attest.azure.net")
 	require.NoError(err)
-	_, err = parseKeySet(jwkSetBytes)
+	_, err = NewAzureVerifier(uri, KeySetConfig{}).keys.fetch(context.Background(), time.Now())
 	require.NoError(err)
 }
 
@@ -300,3 +303,141 @@ func TestParseHTTPS(t *testing.T) {
 		require.NoError(err)
 	}
 }
+
+func TestCreateAzureAttestationTokenContext(t *testing.T) {
+	var failures atomic.Int32
+	provider := httptest.NewTLSServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		if failures.Add(-1) >= 0 {
+			http.Error(w, "unavailable", http.StatusServiceUnavailable)
+			return
+		}
+		var req attestOERequest
+		if err := json.NewDecoder(r.Body).Decode(&req); err != nil {
+			http.Error(w, "could not decode json", http.StatusBadRequest)
+			return
+		}
+		// echo the request as token
+		token, err := json.Marshal(req)
+		if err != nil {
+			http.Error(w, "could not encode json", http.StatusInternalServerError)
+			return
+		}
+		_ = json.NewEncoder(w).Encode(attestationResponse{Token: string(token)})
+	}))
+	defer provider.Close()
+
+	roots := x509.NewCertPool()
+	roots.AddCert(provider.Certificate())
+	otherCert, _ := createCert(t, &x509.Certificate{}, nil, nil)
+
+	testCases := map[string]struct {
+		opts         AzureTokenOptions
+		failures     int32
+		wantErr      bool
+		wantRequest  attestOERequest
+		wantFailures int32
+	}{
+		"untrusted": {
+			wantErr: true,
+		},
+		"root CAs": {
+			opts:        AzureTokenOptions{RootCAs: roots},
+			wantRequest: attestOERequest{Report: "cmVwb3J0", RuntimeData: rtdata{Data: "eyJhIjoxfQ", DataType: "Binary"}},
+		},
+		"pinned certificate": {
+			opts:        AzureTokenOptions{PinnedCertificate: provider.Certificate().Raw},
+			wantRequest: attestOERequest{Report: "cmVwb3J0", RuntimeData: rtdata{Data: "eyJhIjoxfQ", DataType: "Binary"}},
+		},
+		"other pinned certificate": {
+			opts:    AzureTokenOptions{PinnedCertificate: otherCert.Raw},
+			wantErr: true,
+		},
+		"json and init-time data": {
+			opts: AzureTokenOptions{RootCAs: roots, RuntimeDataJSON: true, InitTimeData: []byte("init"), InitTimeDataJSON: false},
+			wantRequest: attestOERequest{
+				Report:       "cmVwb3J0",
+				RuntimeData:  rtdata{Data: "eyJhIjoxfQ", DataType: "JSON"},
+				InitTimeData: &rtdata{Data: "aW5pdA", DataType: "Binary"},
+			},
+		},
+		"retry": {
+			opts:         AzureTokenOptions{RootCAs: roots, Backoff: time.Millisecond},
+			failures:     2,
+			wantRequest:  attestOERequest{Report: "cmVwb3J0", RuntimeData: rtdata{Data: "eyJhIjoxfQ", DataType: "Binary"}},
+			wantFailures: -1,
+		},
+		"too many failures": {
+			opts:         AzureTokenOptions{RootCAs: roots, Backoff: time.Millisecond},
+			failures:     3,
+			wantErr:      true,
+			wantFailures: 0,
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			assert := assert.New(t)
+			require := require.New(t)
+
+			failures.Store(tc.failures)
+			token, err := CreateAzureAttestationTokenContext(context.Background(), []byte("report"), []byte(`{"a":1}`), provider.URL, tc.opts)
+			if tc.wantErr {
+				assert.Error(err)
+			} else {
+				require.NoError(err)
+				var request attestOERequest
+				require.NoError(json.Unmarshal([]byte(token), &request))
+				assert.Equal(tc.wantRequest, request)
+			}
+			if tc.failures > 0 {
+				assert.Equal(tc.wantFailures, failures.Load())
+			}
+		})
+	}
+}
+
+func TestCreateAzureAttestationTokenContextCanceled(t *testing.T) {
+	provider := httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		http.Error(w, "unavailable", http.StatusServiceUnavailable)
+	}))
+	defer provider.Close()
+
+	ctx, cancel := context.WithTimeout(context.Background(), 10*time.Millisecond)
+	defer cancel()
+	_, err := CreateAzureAttestationTokenContext(ctx, nil, nil, provider.URL, AzureTokenOptions{MaxAttempts: 100, Backoff: time.Hour})
+	assert.ErrorIs(t, err, context.DeadlineExceeded)
+}
+
+func TestCreateAzureAttestationTokenContextReusesConnections(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	var newConns atomic.Int32
+	provider := httptest.NewUnstartedServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		_ = json.NewEncoder(w).Encode(attestationResponse{Token: "token"})
+	}))
+	provider.Config.ConnState = func(_ net.Conn, state http.ConnState) {
+		if state == http.StateNew {
+			newConns.Add(1)
+		}
+	}
+	provider.StartTLS()
+	defer provider.Close()
+
+	roots := x509.NewCertPool()
+	roots.AddCert(provider.Certificate())
+	opts := AzureTokenOptions{RootCAs: roots}
+
+	for i := 0; i < 3; i++ {
+		token, err := CreateAzureAttestationTokenContext(context.Background(), []byte("report"), nil, provider.URL, opts)
+		require.NoError(err)
+		assert.Equal("token", token)
+	}
+	assert.EqualValues(1, newConns.Load())
+
+	transport, shared := azureTransport(opts)
+	assert.True(shared)
+	assert.NotZero(transport.TLSHandshakeTimeout)
+	otherTransport, _ := azureTransport(AzureTokenOptions{RootCAs: x509.NewCertPool()})
+	assert.NotSame(transport, otherTransport)
+}
@@ -0,0 +1,79 @@
+// Copyright (c) Edgeless Systems GmbH.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package attestation
+
+import (
+	"context"
+	"errors"
+	"fmt"
+	"net/url"
+	"sync"
+	"time"
+
+	"github.com/go-jose/go-jose/v4"
+	"github.com/go-jose/go-jose/v4/jwt"
+)
+
+// defaultAzureVerifiers caches an AzureVerifier per provider URL for VerifyAzureAttestationToken.
+var defaultAzureVerifiers sync.Map
+
+// AzureVerifier verifies Microsoft Azure Attestation tokens of a single attestation provider.
+//
+// The provider's key set is cached. It is fetched again if it is older than KeySetMaxAge or if a token
+// is signed with an unknown key.
+type AzureVerifier struct {
+	baseURL *url.URL
+	keys    *keySetCache
+	now     func() time.Time
+}
+
+// NewAzureVerifier creates a new AzureVerifier for the provider reachable under baseURL.
+func NewAzureVerifier(baseURL *url.URL, config KeySetConfig) *AzureVerifier {
+	return &AzureVerifier{
+		baseURL: baseURL,
+		keys:    newKeySetCache(baseURL.ResolveReference(&url.URL{Path: "/certs"}).String(), config),
+		now:     time.Now,
+	}
+}
+
+// Verify verifies the token's public claims and signature and returns the report contained in the token.
+// Note, that the token's issuer (iss) has to equal the baseURL's string representation.
+func (v *AzureVerifier) Verify(rawToken string) (AzureReport, error) {
+	return v.VerifyContext(context.Background(), rawToken)
+}
+
+// VerifyContext is like Verify, but uses ctx for fetching the key set.
+func (v *AzureVerifier) VerifyContext(ctx context.Context, rawToken string) (AzureReport, error) {
+	// Parse token.
+	token, err := jwt.ParseSigned(rawToken, []jose.SignatureAlgorithm{jose.RS256})
+	if err != nil {
+		return AzureReport{}, err
+	}
+	if len(token.Headers) != 1 {
+		return AzureReport{}, errors.New("token must have exactly one signature")
+	}
+
+	keySet, err := v.keys.get(ctx, token.Headers[0].KeyID, v.now())
+	if err != nil {
+		return AzureReport{}, fmt.Errorf("getting key set: %w", err)
+	}
+
+	// Verify token and get claims.
+	var publicClaims jwt.Claims
+	var privateClaims privateClaims
+	var rawClaims map[string]any
+	if err := token.Claims(keySet, &publicClaims, &privateClaims, &rawClaims); err != nil {
+		return AzureReport{}, err
+	}
+
+	// Verify public claims.
+	if err := publicClaims.Validate(jwt.Expected{Issuer: v.baseURL.String(), Time: v.now()}); err != nil {
+		return AzureReport{}, err
+	}
+
+	return privateClaims.toAzureReport(rawClaims)
+}
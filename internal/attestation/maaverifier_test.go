@@ -0,0 +1,250 @@
+// Copyright (c) Edgeless Systems GmbH.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package attestation
+
+import (
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/base64"
+	"encoding/json"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/edgelesssys/ego/attestation/tcbstatus"
+	"github.com/go-jose/go-jose/v4"
+	"github.com/go-jose/go-jose/v4/jwt"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+type testProvider struct {
+	server     *httptest.Server
+	fetchCount atomic.Int32
+	mut        sync.Mutex
+	keySet     []byte
+}
+
+func newTestProvider(t *testing.T) *testProvider {
+	p := &testProvider{}
+	p.server = httptest.NewServer(http.HandlerFunc(func(w http.ResponseWriter, r *http.Request) {
+		p.fetchCount.Add(1)
+		p.mut.Lock()
+		defer p.mut.Unlock()
+		_, _ = w.Write(p.keySet)
+	}))
+	t.Cleanup(p.server.Close)
+	return p
+}
+
+func (p *testProvider) setKeySet(keySet []byte) {
+	p.mut.Lock()
+	defer p.mut.Unlock()
+	p.keySet = keySet
+}
+
+func (p *testProvider) url(t *testing.T) *url.URL {
+	uri, err := url.Parse(p.server.URL)
+	require.NoError(t, err)
+	return uri
+}
+
+func createKeySet(t *testing.T, keys map[string][]*x509.Certificate) []byte {
+	type rawKey struct {
+		X5c []string `json:"x5c"`
+		Kty string   `json:"kty"`
+		Kid string   `json:"kid"`
+	}
+	var keySet struct {
+		Keys []rawKey `json:"keys"`
+	}
+	for kid, chain := range keys {
+		key := rawKey{Kty: "RSA", Kid: kid}
+		for _, cert := range chain {
+			key.X5c = append(key.X5c, base64.StdEncoding.EncodeToString(cert.Raw))
+		}
+		keySet.Keys = append(keySet.Keys, key)
+	}
+	keySetBytes, err := json.Marshal(keySet)
+	require.NoError(t, err)
+	return keySetBytes
+}
+
+func createCert(t *testing.T, template *x509.Certificate, parent *x509.Certificate, parentKey *rsa.PrivateKey) (*x509.Certificate, *rsa.PrivateKey) {
+	key, err := rsa.GenerateKey(rand.Reader, 2048)
+	require.NoError(t, err)
+	if template.SerialNumber == nil {
+		template.SerialNumber = &big.Int{}
+	}
+	if template.NotAfter.IsZero() {
+		template.NotAfter = time.Now().Add(time.Hour)
+	}
+	if parent == nil {
+		parent = template
+		parentKey = key
+	}
+	certBytes, err := x509.CreateCertificate(rand.Reader, template, parent, &key.PublicKey, parentKey)
+	require.NoError(t, err)
+	cert, err := x509.ParseCertificate(certBytes)
+	require.NoError(t, err)
+	return cert, key
+}
+
+func createToken(t *testing.T, key *rsa.PrivateKey, kid string, issuer string) string {
+	return createTokenWithClaims(t, key, kid, issuer, privateClaims{Data: base64.RawURLEncoding.EncodeToString([]byte("data")), SecurityVersion: 2})
+}
+
+func createTokenWithClaims(t *testing.T, key *rsa.PrivateKey, kid string, issuer string, privateClaims privateClaims) string {
+	sig, err := jose.NewSigner(jose.SigningKey{Algorithm: jose.RS256, Key: key}, (&jose.SignerOptions{}).WithType("JWT").WithHeader("kid", kid))
+	require.NoError(t, err)
+	publicClaims := jwt.Claims{
+		Expiry:    jwt.NewNumericDate(time.Now().Add(2 * defaultKeySetMaxAge)),
+		IssuedAt:  jwt.NewNumericDate(time.Now()),
+		Issuer:    issuer,
+		NotBefore: jwt.NewNumericDate(time.Now()),
+	}
+	token, err := jwt.Signed(sig).Claims(publicClaims).Claims(privateClaims).Serialize()
+	require.NoError(t, err)
+	return token
+}
+
+func TestAzureVerifierCache(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	cert1, key1 := createCert(t, &x509.Certificate{Subject: pkix.Name{CommonName: "key1"}}, nil, nil)
+	cert2, key2 := createCert(t, &x509.Certificate{Subject: pkix.Name{CommonName: "key2"}, NotAfter: time.Now().Add(2 * defaultKeySetMaxAge)}, nil, nil)
+
+	provider := newTestProvider(t)
+	provider.setKeySet(createKeySet(t, map[string][]*x509.Certificate{"key1": {cert1}}))
+
+	verifier := NewAzureVerifier(provider.url(t), KeySetConfig{})
+	now := time.Now()
+	verifier.now = func() time.Time { return now }
+
+	token1 := createToken(t, key1, "key1", provider.server.URL)
+	token2 := createToken(t, key2, "key2", provider.server.URL)
+
+	// keys are fetched once
+	for i := 0; i < 100; i++ {
+		report, err := verifier.Verify(token1)
+		require.NoError(err)
+		assert.EqualValues(2, report.SecurityVersion)
+	}
+	assert.EqualValues(1, provider.fetchCount.Load())
+
+	// unknown key doesn't trigger a fetch within the min refresh interval
+	_, err := verifier.Verify(token2)
+	assert.Error(err)
+	assert.EqualValues(1, provider.fetchCount.Load())
+
+	// key rotation: unknown key triggers a fetch
+	provider.setKeySet(createKeySet(t, map[string][]*x509.Certificate{"key2": {cert2}}))
+	now = now.Add(defaultMinKeySetRefreshInterval)
+	_, err = verifier.Verify(token2)
+	require.NoError(err)
+	assert.EqualValues(2, provider.fetchCount.Load())
+
+	// removed key is still cached
+	_, err = verifier.Verify(token1)
+	assert.Error(err)
+	assert.EqualValues(2, provider.fetchCount.Load())
+
+	// keys are fetched again after the max age
+	now = now.Add(defaultKeySetMaxAge)
+	_, err = verifier.Verify(token2)
+	require.NoError(err)
+	assert.EqualValues(3, provider.fetchCount.Load())
+}
+
+func TestAzureVerifierPinnedKeySet(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	cert, key := createCert(t, &x509.Certificate{}, nil, nil)
+	provider := newTestProvider(t)
+
+	verifier := NewAzureVerifier(provider.url(t), KeySetConfig{KeySet: createKeySet(t, map[string][]*x509.Certificate{"aaa": {cert}})})
+
+	_, err := verifier.Verify(createToken(t, key, "aaa", provider.server.URL))
+	require.NoError(err)
+	_, err = verifier.Verify(createToken(t, key, "bbb", provider.server.URL))
+	assert.Error(err)
+	assert.Zero(provider.fetchCount.Load())
+}
+
+func TestAzureVerifierClaims(t *testing.T) {
+	cert, key := createCert(t, &x509.Certificate{}, nil, nil)
+	provider := newTestProvider(t)
+	provider.setKeySet(createKeySet(t, map[string][]*x509.Certificate{"aaa": {cert}}))
+	verifier := NewAzureVerifier(provider.url(t), KeySetConfig{})
+
+	testCases := map[string]struct {
+		claims     privateClaims
+		wantErr    bool
+		wantReport AzureReport
+	}{
+		"minimal": {
+			claims: privateClaims{SecurityVersion: 2},
+			wantReport: AzureReport{
+				Report: Report{Data: []byte{}, SecurityVersion: 2, UniqueID: []byte{}, SignerID: []byte{}, ProductID: make([]byte, 16), TCBStatus: tcbstatus.Unknown},
+			},
+		},
+		"all claims": {
+			claims: privateClaims{
+				SecurityVersion: 2,
+				AttestationType: "sgx",
+				PolicyHash:      "AAEC",
+				Collateral:      map[string]string{"quotehash": "abcd"},
+				ConfigID:        "0102",
+				Version:         "1.0",
+				TCBStatus:       "SWHardeningNeeded",
+			},
+			wantReport: AzureReport{
+				Report:          Report{Data: []byte{}, SecurityVersion: 2, UniqueID: []byte{}, SignerID: []byte{}, ProductID: make([]byte, 16), TCBStatus: tcbstatus.SWHardeningNeeded},
+				AttestationType: "sgx",
+				PolicyHash:      []byte{0, 1, 2},
+				Collateral:      map[string]string{"quotehash": "abcd"},
+				ConfigID:        []byte{1, 2},
+				Version:         "1.0",
+			},
+		},
+		"invalid tcb status": {
+			claims:  privateClaims{TCBStatus: "foo"},
+			wantErr: true,
+		},
+		"invalid config id": {
+			claims:  privateClaims{ConfigID: "foo"},
+			wantErr: true,
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			assert := assert.New(t)
+			require := require.New(t)
+
+			report, err := verifier.Verify(createTokenWithClaims(t, key, "aaa", provider.server.URL, tc.claims))
+			if tc.wantErr {
+				assert.Error(err)
+				return
+			}
+			require.NoError(err)
+			assert.Equal(provider.server.URL, report.Claims["iss"])
+			assert.EqualValues(2, report.Claims["x-ms-sgx-svn"])
+			report.Claims = nil
+			assert.Equal(tc.wantReport, report)
+		})
+	}
+}
@@ -0,0 +1,377 @@
+// Copyright (c) Edgeless Systems GmbH.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package attestation
+
+import (
+	"bytes"
+	"context"
+	"crypto/x509"
+	"encoding/asn1"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"encoding/pem"
+	"errors"
+	"fmt"
+	"io"
+	"net/http"
+	"net/url"
+	"strings"
+	"time"
+)
+
+const (
+	quoteHeaderSize      = 48
+	quoteReportBodySize  = 384
+	quoteSignatureOffset = quoteHeaderSize + quoteReportBodySize + 4
+	qeReportCertDataSize = 384 + 64 // QE report and its signature
+
+	certDataPCKCertChain = 5
+	certDataQEReport     = 6
+
+	// oe_endorsements_t of openenclave/bits/attestation.h
+	oeSGXEndorsementsVersion = 1
+	oeEnclaveTypeSGX         = 2
+
+	maxPCCSResponseSize = 1 << 20
+)
+
+var (
+	oidSGXExtensions = asn1.ObjectIdentifier{1, 2, 840, 113741, 1, 13, 1}
+	oidFMSPC         = asn1.ObjectIdentifier{1, 2, 840, 113741, 1, 13, 1, 4}
+
+	errPCCSNotFound = errors.New("not found")
+)
+
+// PCCS fetches the collateral for verifying SGX quotes from an Intel Provisioning Certificate Caching Service
+// or a service with a compatible API, e.g., the Intel Provisioning Certification Service.
+type PCCS struct {
+	baseURL string
+	client  *http.Client
+	now     func() time.Time
+}
+
+// NewPCCS creates a new PCCS. baseURL is the base URL of the API, e.g., https://localhost:8081/sgx/certification/v4/.
+func NewPCCS(baseURL string, client *http.Client) *PCCS {
+	if !strings.HasSuffix(baseURL, "/") {
+		baseURL += "/"
+	}
+	return &PCCS{baseURL: baseURL, client: client, now: time.Now}
+}
+
+// CollateralKey returns the FMSPC and the CA type of the platform that created the report.
+func (p *PCCS) CollateralKey(reportBytes []byte) (string, error) {
+	quote, err := sgxQuoteFromReport(reportBytes)
+	if err != nil {
+		return "", err
+	}
+	certs, err := pckCertChain(quote)
+	if err != nil {
+		return "", err
+	}
+	fmspc, err := pckFMSPC(certs[0])
+	if err != nil {
+		return "", err
+	}
+
+	var ca string
+	switch certs[0].Issuer.CommonName {
+	case "Intel SGX PCK Processor CA":
+		ca = "processor"
+	case "Intel SGX PCK Platform CA":
+		ca = "platform"
+	default:
+		return "", fmt.Errorf("unknown PCK certificate issuer: %v", certs[0].Issuer.CommonName)
+	}
+	return strings.ToUpper(hex.EncodeToString(fmspc)) + "/" + ca, nil
+}
+
+// GetCollateral fetches the collateral for a key returned by CollateralKey. It returns the collateral in
+// Open Enclave's endorsements format and the earliest next update of the TCB info, the QE identity, and the CRLs.
+func (p *PCCS) GetCollateral(ctx context.Context, key string) ([]byte, time.Time, error) {
+	fmspc, ca, ok := strings.Cut(key, "/")
+	if !ok {
+		return nil, time.Time{}, fmt.Errorf("invalid collateral key: %v", key)
+	}
+
+	tcbInfo, tcbInfoHeader, err := p.get(ctx, "tcb?fmspc="+url.QueryEscape(fmspc))
+	if err != nil {
+		return nil, time.Time{}, fmt.Errorf("getting TCB info: %w", err)
+	}
+	qeIdentity, qeIdentityHeader, err := p.get(ctx, "qe/identity")
+	if err != nil {
+		return nil, time.Time{}, fmt.Errorf("getting QE identity: %w", err)
+	}
+	pckCRL, pckCRLHeader, err := p.get(ctx, "pckcrl?ca="+url.QueryEscape(ca)+"&encoding=der")
+	if err != nil {
+		return nil, time.Time{}, fmt.Errorf("getting PCK CRL: %w", err)
+	}
+
+	tcbInfoChain, err := issuerChain(tcbInfoHeader, "TCB-Info-Issuer-Chain", "SGX-TCB-Info-Issuer-Chain")
+	if err != nil {
+		return nil, time.Time{}, fmt.Errorf("getting TCB info: %w", err)
+	}
+	qeIdentityChain, err := issuerChain(qeIdentityHeader, "SGX-Enclave-Identity-Issuer-Chain")
+	if err != nil {
+		return nil, time.Time{}, fmt.Errorf("getting QE identity: %w", err)
+	}
+	pckCRLChain, err := issuerChain(pckCRLHeader, "SGX-PCK-CRL-Issuer-Chain")
+	if err != nil {
+		return nil, time.Time{}, fmt.Errorf("getting PCK CRL: %w", err)
+	}
+
+	rootCRL, err := p.getRootCACRL(ctx, pckCRLChain)
+	if err != nil {
+		return nil, time.Time{}, fmt.Errorf("getting root CA CRL: %w", err)
+	}
+
+	var nextUpdate time.Time
+	setNextUpdate := func(t time.Time) {
+		if nextUpdate.IsZero() || t.Before(nextUpdate) {
+			nextUpdate = t
+		}
+	}
+	var tcbInfoJSON struct {
+		TCBInfo struct{ NextUpdate time.Time }
+	}
+	if err := json.Unmarshal(tcbInfo, &tcbInfoJSON); err != nil {
+		return nil, time.Time{}, fmt.Errorf("parsing TCB info: %w", err)
+	}
+	setNextUpdate(tcbInfoJSON.TCBInfo.NextUpdate)
+	var qeIdentityJSON struct {
+		EnclaveIdentity struct{ NextUpdate time.Time }
+	}
+	if err := json.Unmarshal(qeIdentity, &qeIdentityJSON); err != nil {
+		return nil, time.Time{}, fmt.Errorf("parsing QE identity: %w", err)
+	}
+	setNextUpdate(qeIdentityJSON.EnclaveIdentity.NextUpdate)
+	if pckCRL, err = parseCRL(pckCRL, setNextUpdate); err != nil {
+		return nil, time.Time{}, fmt.Errorf("parsing PCK CRL: %w", err)
+	}
+	if rootCRL, err = parseCRL(rootCRL, setNextUpdate); err != nil {
+		return nil, time.Time{}, fmt.Errorf("parsing root CA CRL: %w", err)
+	}
+
+	// oe_sgx_endorsements_fields_t of openenclave/common/sgx/endorsements.h
+	version := binary.LittleEndian.AppendUint32(nil, oeSGXEndorsementsVersion)
+	endorsements := marshalEndorsements(
+		version,
+		cString(tcbInfo),
+		cString(tcbInfoChain),
+		pckCRL,
+		rootCRL,
+		cString(pckCRLChain),
+		cString(qeIdentity),
+		cString(qeIdentityChain),
+		cString([]byte(p.now().UTC().Format("2006-01-02T15:04:05Z"))),
+	)
+	return endorsements, nextUpdate, nil
+}
+
+// getRootCACRL gets the CRL of the Intel SGX Root CA from the PCCS. If the service doesn't provide it, e.g.,
+// if it's the Intel Provisioning Certification Service, it's fetched from the root CA certificate's CRL distribution point.
+func (p *PCCS) getRootCACRL(ctx context.Context, chain []byte) ([]byte, error) {
+	crl, _, err := p.get(ctx, "rootcacrl")
+	if !errors.Is(err, errPCCSNotFound) {
+		return crl, err
+	}
+
+	var root *x509.Certificate
+	for block, rest := pem.Decode(chain); block != nil; block, rest = pem.Decode(rest) {
+		if root, err = x509.ParseCertificate(block.Bytes); err != nil {
+			return nil, err
+		}
+	}
+	if root == nil || len(root.CRLDistributionPoints) == 0 {
+		return nil, errors.New("root CA certificate has no CRL distribution point")
+	}
+	crl, _, err = p.getURL(ctx, root.CRLDistributionPoints[0])
+	return crl, err
+}
+
+func (p *PCCS) get(ctx context.Context, path string) ([]byte, http.Header, error) {
+	return p.getURL(ctx, p.baseURL+path)
+}
+
+func (p *PCCS) getURL(ctx context.Context, uri string) ([]byte, http.Header, error) {
+	req, err := http.NewRequestWithContext(ctx, http.MethodGet, uri, nil)
+	if err != nil {
+		return nil, nil, err
+	}
+	resp, err := p.client.Do(req)
+	if err != nil {
+		return nil, nil, err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode == http.StatusNotFound {
+		return nil, nil, fmt.Errorf("%v: %w", uri, errPCCSNotFound)
+	}
+	if resp.StatusCode != http.StatusOK {
+		return nil, nil, fmt.Errorf("%v: %v", uri, resp.Status)
+	}
+	body, err := io.ReadAll(io.LimitReader(resp.Body, maxPCCSResponseSize))
+	if err != nil {
+		return nil, nil, err
+	}
+	return body, resp.Header, nil
+}
+
+// pckCertChain returns the PCK certificate chain of an SGX quote of version 3 or 4. The PCK certificate is the first element.
+func pckCertChain(quote []byte) ([]*x509.Certificate, error) {
+	if len(quote) < quoteSignatureOffset {
+		return nil, errors.New("quote too short")
+	}
+	signatureSize := binary.LittleEndian.Uint32(quote[quoteSignatureOffset-4:])
+	if uint64(signatureSize) > uint64(len(quote)-quoteSignatureOffset) {
+		return nil, errors.New("invalid quote signature size")
+	}
+	signature := quote[quoteSignatureOffset : quoteSignatureOffset+int(signatureSize)]
+	if len(signature) < 128 {
+		return nil, errors.New("quote signature too short")
+	}
+	// skip the report signature and the attestation key
+	certData := signature[128:]
+
+	var typ uint16
+	var data []byte
+	var err error
+	switch version := binary.LittleEndian.Uint16(quote); version {
+	case 3:
+		typ, data, err = qeReportCertData(certData)
+	case 4:
+		if typ, data, err = parseCertData(certData); err != nil {
+			return nil, err
+		}
+		if typ != certDataQEReport {
+			return nil, fmt.Errorf("unexpected certification data type: %v", typ)
+		}
+		typ, data, err = qeReportCertData(data)
+	default:
+		return nil, fmt.Errorf("unsupported quote version: %v", version)
+	}
+	if err != nil {
+		return nil, err
+	}
+	if typ != certDataPCKCertChain {
+		return nil, fmt.Errorf("unexpected certification data type: %v", typ)
+	}
+
+	var certs []*x509.Certificate
+	for block, rest := pem.Decode(data); block != nil; block, rest = pem.Decode(rest) {
+		cert, err := x509.ParseCertificate(block.Bytes)
+		if err != nil {
+			return nil, fmt.Errorf("parsing PCK certificate chain: %w", err)
+		}
+		certs = append(certs, cert)
+	}
+	if len(certs) == 0 {
+		return nil, errors.New("empty PCK certificate chain")
+	}
+	return certs, nil
+}
+
+// qeReportCertData parses the QE report certification data and returns the certification data it contains.
+func qeReportCertData(data []byte) (uint16, []byte, error) {
+	if len(data) < qeReportCertDataSize+2 {
+		return 0, nil, errors.New("QE report certification data too short")
+	}
+	data = data[qeReportCertDataSize:]
+	authDataSize := int(binary.LittleEndian.Uint16(data))
+	if len(data) < 2+authDataSize {
+		return 0, nil, errors.New("invalid QE authentication data size")
+	}
+	return parseCertData(data[2+authDataSize:])
+}
+
+func parseCertData(data []byte) (uint16, []byte, error) {
+	if len(data) < 6 {
+		return 0, nil, errors.New("certification data too short")
+	}
+	typ := binary.LittleEndian.Uint16(data)
+	size := binary.LittleEndian.Uint32(data[2:])
+	if uint64(size) > uint64(len(data)-6) {
+		return 0, nil, errors.New("invalid certification data size")
+	}
+	return typ, data[6 : 6+size], nil
+}
+
+// pckFMSPC returns the FMSPC of the platform from the SGX extensions of a PCK certificate.
+func pckFMSPC(cert *x509.Certificate) ([]byte, error) {
+	for _, ext := range cert.Extensions {
+		if !ext.Id.Equal(oidSGXExtensions) {
+			continue
+		}
+		var sgxExtensions []struct {
+			ID    asn1.ObjectIdentifier
+			Value asn1.RawValue
+		}
+		if _, err := asn1.Unmarshal(ext.Value, &sgxExtensions); err != nil {
+			return nil, fmt.Errorf("parsing SGX extensions: %w", err)
+		}
+		for _, sgxExt := range sgxExtensions {
+			if sgxExt.ID.Equal(oidFMSPC) {
+				if len(sgxExt.Value.Bytes) != 6 {
+					return nil, errors.New("invalid FMSPC")
+				}
+				return sgxExt.Value.Bytes, nil
+			}
+		}
+	}
+	return nil, errors.New("PCK certificate has no FMSPC")
+}
+
+// issuerChain returns the URL-encoded PEM certificate chain of the first of the given headers that is set.
+func issuerChain(header http.Header, keys ...string) ([]byte, error) {
+	for _, key := range keys {
+		if value := header.Get(key); value != "" {
+			chain, err := url.QueryUnescape(value)
+			if err != nil {
+				return nil, fmt.Errorf("%v: %w", key, err)
+			}
+			return []byte(chain), nil
+		}
+	}
+	return nil, fmt.Errorf("missing issuer chain header %v", keys[0])
+}
+
+// parseCRL returns the CRL in DER. The CRL may be encoded in DER, hex-encoded DER, or PEM.
+func parseCRL(crl []byte, setNextUpdate func(time.Time)) ([]byte, error) {
+	if block, _ := pem.Decode(crl); block != nil {
+		crl = block.Bytes
+	} else if der, err := hex.DecodeString(string(bytes.TrimSpace(crl))); err == nil {
+		crl = der
+	}
+	list, err := x509.ParseRevocationList(crl)
+	if err != nil {
+		return nil, err
+	}
+	setNextUpdate(list.NextUpdate)
+	return crl, nil
+}
+
+// marshalEndorsements creates an oe_endorsements_t. Its buffer consists of the offsets of the items
+// relative to the data, followed by the data.
+func marshalEndorsements(items ...[]byte) []byte {
+	var data []byte
+	offsets := make([]byte, 0, 4*len(items))
+	for _, item := range items {
+		offsets = binary.LittleEndian.AppendUint32(offsets, uint32(len(data)))
+		data = append(data, item...)
+	}
+
+	result := binary.LittleEndian.AppendUint32(nil, oeSGXEndorsementsVersion)
+	result = binary.LittleEndian.AppendUint32(result, oeEnclaveTypeSGX)
+	result = binary.LittleEndian.AppendUint32(result, uint32(len(offsets)+len(data)))
+	result = binary.LittleEndian.AppendUint32(result, uint32(len(items)))
+	result = append(result, offsets...)
+	return append(result, data...)
+}
+
+// cString returns s with a terminating null character as expected by Open Enclave.
+func cString(s []byte) []byte {
+	return append(bytes.Clone(s), 0)
+}
@@ -0,0 +1,304 @@
+// Copyright (c) Edgeless Systems GmbH.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package attestation
+
+import (
+	"context"
+	"crypto/rand"
+	"crypto/rsa"
+	"crypto/x509"
+	"crypto/x509/pkix"
+	"encoding/asn1"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/pem"
+	"math/big"
+	"net/http"
+	"net/http/httptest"
+	"net/url"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestPCCSCollateralKey(t *testing.T) {
+	chain := newTestPCKChain(t, "Intel SGX PCK Processor CA", "")
+
+	testCases := map[string]struct {
+		report  []byte
+		wantKey string
+		wantErr bool
+	}{
+		"quote v3": {
+			report:  newTestSGXReport(3, chain.pem),
+			wantKey: "00906ED50000/processor",
+		},
+		"quote v4": {
+			report:  newTestSGXReport(4, chain.pem),
+			wantKey: "00906ED50000/processor",
+		},
+		"platform CA": {
+			report:  newTestSGXReport(3, newTestPCKChain(t, "Intel SGX PCK Platform CA", "").pem),
+			wantKey: "00906ED50000/platform",
+		},
+		"unknown CA": {
+			report:  newTestSGXReport(3, newTestPCKChain(t, "Other CA", "").pem),
+			wantErr: true,
+		},
+		"unsupported quote version": {
+			report:  newTestSGXReport(5, chain.pem),
+			wantErr: true,
+		},
+		"no certificates": {
+			report:  newTestSGXReport(3, nil),
+			wantErr: true,
+		},
+		"truncated": {
+			report:  newTestSGXReport(3, chain.pem)[:600],
+			wantErr: true,
+		},
+		"empty": {
+			wantErr: true,
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			assert := assert.New(t)
+
+			key, err := NewPCCS("https://localhost", http.DefaultClient).CollateralKey(tc.report)
+			if tc.wantErr {
+				assert.Error(err)
+				return
+			}
+			assert.NoError(err)
+			assert.Equal(tc.wantKey, key)
+		})
+	}
+}
+
+func TestPCCSGetCollateral(t *testing.T) {
+	testCases := map[string]struct {
+		rootCACRL bool
+	}{
+		"PCCS":        {rootCACRL: true},
+		"Intel PCS":   {rootCACRL: false},
+		"trailing /":  {rootCACRL: true},
+		"hex encoded": {rootCACRL: true},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			assert := assert.New(t)
+			require := require.New(t)
+
+			now := time.Now()
+			mux := http.NewServeMux()
+			server := httptest.NewTLSServer(mux)
+			defer server.Close()
+			chain := newTestPCKChain(t, "Intel SGX PCK Processor CA", server.URL+"/root.crl")
+
+			const tcbInfo = `{"tcbInfo":{"nextUpdate":"2030-01-02T00:00:00Z"}}`
+			const qeIdentity = `{"enclaveIdentity":{"nextUpdate":"2030-01-01T00:00:00Z"}}`
+			pckCRL := createTestCRL(t, chain.pckCA, chain.pckCAKey, now.Add(time.Hour))
+			rootCRL := createTestCRL(t, chain.root, chain.rootKey, now.Add(2*time.Hour))
+			escapedChain := url.QueryEscape(string(chain.issuerPEM))
+
+			mux.HandleFunc("/sgx/certification/v4/tcb", func(w http.ResponseWriter, r *http.Request) {
+				if r.URL.Query().Get("fmspc") != "00906ED50000" {
+					http.NotFound(w, r)
+					return
+				}
+				w.Header().Set("TCB-Info-Issuer-Chain", escapedChain)
+				_, _ = w.Write([]byte(tcbInfo))
+			})
+			mux.HandleFunc("/sgx/certification/v4/qe/identity", func(w http.ResponseWriter, r *http.Request) {
+				w.Header().Set("SGX-Enclave-Identity-Issuer-Chain", escapedChain)
+				_, _ = w.Write([]byte(qeIdentity))
+			})
+			mux.HandleFunc("/sgx/certification/v4/pckcrl", func(w http.ResponseWriter, r *http.Request) {
+				if r.URL.Query().Get("ca") != "processor" {
+					http.NotFound(w, r)
+					return
+				}
+				w.Header().Set("SGX-PCK-CRL-Issuer-Chain", escapedChain)
+				if name == "hex encoded" {
+					_, _ = w.Write([]byte(hex.EncodeToString(pckCRL)))
+					return
+				}
+				_, _ = w.Write(pckCRL)
+			})
+			if tc.rootCACRL {
+				mux.HandleFunc("/sgx/certification/v4/rootcacrl", func(w http.ResponseWriter, r *http.Request) {
+					_, _ = w.Write([]byte(hex.EncodeToString(rootCRL)))
+				})
+			} else {
+				mux.HandleFunc("/root.crl", func(w http.ResponseWriter, r *http.Request) {
+					_, _ = w.Write(rootCRL)
+				})
+			}
+
+			baseURL := server.URL + "/sgx/certification/v4"
+			if name == "trailing /" {
+				baseURL += "/"
+			}
+			pccs := NewPCCS(baseURL, server.Client())
+			pccs.now = func() time.Time { return time.Date(2025, 1, 2, 3, 4, 5, 0, time.UTC) }
+
+			key, err := pccs.CollateralKey(newTestSGXReport(3, chain.pem))
+			require.NoError(err)
+			endorsements, nextUpdate, err := pccs.GetCollateral(context.Background(), key)
+			require.NoError(err)
+			assert.WithinDuration(now.Add(time.Hour), nextUpdate, time.Second)
+
+			items := parseTestEndorsements(t, endorsements)
+			require.Len(items, 9)
+			assert.Equal([]byte{1, 0, 0, 0}, items[0])
+			assert.Equal(tcbInfo+"\x00", string(items[1]))
+			assert.Equal(string(chain.issuerPEM)+"\x00", string(items[2]))
+			assert.Equal(pckCRL, items[3])
+			assert.Equal(rootCRL, items[4])
+			assert.Equal(string(chain.issuerPEM)+"\x00", string(items[5]))
+			assert.Equal(qeIdentity+"\x00", string(items[6]))
+			assert.Equal(string(chain.issuerPEM)+"\x00", string(items[7]))
+			assert.Equal("2025-01-02T03:04:05Z\x00", string(items[8]))
+
+			// unknown platform
+			_, _, err = pccs.GetCollateral(context.Background(), "00906ED50001/processor")
+			assert.Error(err)
+			_, _, err = pccs.GetCollateral(context.Background(), "invalid")
+			assert.Error(err)
+		})
+	}
+}
+
+func TestMarshalEndorsements(t *testing.T) {
+	// layout of oe_endorsements_t: version, enclave type, buffer size, number of items, offsets, data
+	want := []byte{
+		1, 0, 0, 0,
+		2, 0, 0, 0,
+		20, 0, 0, 0,
+		3, 0, 0, 0,
+		0, 0, 0, 0,
+		4, 0, 0, 0,
+		6, 0, 0, 0,
+		1, 0, 0, 0,
+		'a', 0,
+		0xbc, 0xde,
+	}
+	assert.Equal(t, want, marshalEndorsements([]byte{1, 0, 0, 0}, cString([]byte("a")), []byte{0xbc, 0xde}))
+}
+
+type testPCKChain struct {
+	root, pckCA       *x509.Certificate
+	rootKey, pckCAKey *rsa.PrivateKey
+	pem               []byte // PCK certificate, PCK CA, and root CA
+	issuerPEM         []byte // PCK CA and root CA
+}
+
+// newTestPCKChain creates a PCK certificate chain with FMSPC 00906ED50000.
+func newTestPCKChain(t *testing.T, caName string, crlDistributionPoint string) testPCKChain {
+	var chain testPCKChain
+	ca := func(name string) *x509.Certificate {
+		return &x509.Certificate{
+			Subject:               pkix.Name{CommonName: name},
+			IsCA:                  true,
+			BasicConstraintsValid: true,
+			KeyUsage:              x509.KeyUsageCertSign | x509.KeyUsageCRLSign,
+		}
+	}
+	rootTemplate := ca("Intel SGX Root CA")
+	if crlDistributionPoint != "" {
+		rootTemplate.CRLDistributionPoints = []string{crlDistributionPoint}
+	}
+	chain.root, chain.rootKey = createCert(t, rootTemplate, nil, nil)
+	chain.pckCA, chain.pckCAKey = createCert(t, ca(caName), chain.root, chain.rootKey)
+
+	fmspc, err := asn1.Marshal([]byte{0x00, 0x90, 0x6e, 0xd5, 0x00, 0x00})
+	require.NoError(t, err)
+	sgxExtensions, err := asn1.Marshal([]struct {
+		ID    asn1.ObjectIdentifier
+		Value asn1.RawValue
+	}{
+		{ID: asn1.ObjectIdentifier{1, 2, 840, 113741, 1, 13, 1, 1}, Value: asn1.RawValue{FullBytes: fmspc}},
+		{ID: oidFMSPC, Value: asn1.RawValue{FullBytes: fmspc}},
+	})
+	require.NoError(t, err)
+	pck, _ := createCert(t, &x509.Certificate{
+		Subject:         pkix.Name{CommonName: "Intel SGX PCK Certificate"},
+		ExtraExtensions: []pkix.Extension{{Id: oidSGXExtensions, Value: sgxExtensions}},
+	}, chain.pckCA, chain.pckCAKey)
+
+	for _, cert := range []*x509.Certificate{chain.pckCA, chain.root} {
+		chain.issuerPEM = append(chain.issuerPEM, pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: cert.Raw})...)
+	}
+	chain.pem = append(pem.EncodeToMemory(&pem.Block{Type: "CERTIFICATE", Bytes: pck.Raw}), chain.issuerPEM...)
+	return chain
+}
+
+func createTestCRL(t *testing.T, issuer *x509.Certificate, key *rsa.PrivateKey, nextUpdate time.Time) []byte {
+	crl, err := x509.CreateRevocationList(rand.Reader, &x509.RevocationList{
+		Number:     big.NewInt(1),
+		ThisUpdate: time.Now(),
+		NextUpdate: nextUpdate,
+	}, issuer, key)
+	require.NoError(t, err)
+	return crl
+}
+
+// newTestSGXReport creates an OE remote report with a quote that contains only the certification data.
+func newTestSGXReport(version uint16, pckChain []byte) []byte {
+	certData := func(typ uint16, data []byte) []byte {
+		result := binary.LittleEndian.AppendUint16(nil, typ)
+		result = binary.LittleEndian.AppendUint32(result, uint32(len(data)))
+		return append(result, data...)
+	}
+	qeReport := make([]byte, qeReportCertDataSize)
+	qeReport = binary.LittleEndian.AppendUint16(qeReport, 2)
+	qeReport = append(qeReport, 0xAA, 0xBB) // QE authentication data
+	qeReport = append(qeReport, certData(certDataPCKCertChain, pckChain)...)
+
+	signature := make([]byte, 128)
+	if version == 3 {
+		signature = append(signature, qeReport...)
+	} else {
+		signature = append(signature, certData(certDataQEReport, qeReport)...)
+	}
+
+	quote := make([]byte, quoteSignatureOffset-4)
+	binary.LittleEndian.PutUint16(quote, version)
+	quote = binary.LittleEndian.AppendUint32(quote, uint32(len(signature)))
+	quote = append(quote, signature...)
+
+	report := binary.LittleEndian.AppendUint32(nil, 1)
+	report = binary.LittleEndian.AppendUint32(report, oeReportTypeSGXRemote)
+	report = binary.LittleEndian.AppendUint64(report, uint64(len(quote)))
+	return append(report, quote...)
+}
+
+func parseTestEndorsements(t *testing.T, endorsements []byte) [][]byte {
+	require := require.New(t)
+	require.GreaterOrEqual(len(endorsements), 16)
+	require.EqualValues(oeSGXEndorsementsVersion, binary.LittleEndian.Uint32(endorsements))
+	require.EqualValues(oeEnclaveTypeSGX, binary.LittleEndian.Uint32(endorsements[4:]))
+	buffer := endorsements[16:]
+	require.EqualValues(len(buffer), binary.LittleEndian.Uint32(endorsements[8:]))
+	count := int(binary.LittleEndian.Uint32(endorsements[12:]))
+	data := buffer[4*count:]
+
+	items := make([][]byte, count)
+	for i := range items {
+		end := uint32(len(data))
+		if i+1 < count {
+			end = binary.LittleEndian.Uint32(buffer[4*(i+1):])
+		}
+		items[i] = data[binary.LittleEndian.Uint32(buffer[4*i:]):end]
+	}
+	return items
+}
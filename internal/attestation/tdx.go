@@ -0,0 +1,101 @@
+// Copyright (c) Edgeless Systems GmbH.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package attestation
+
+import (
+	"encoding/binary"
+	"errors"
+	"fmt"
+
+	"github.com/edgelesssys/ego/attestation/tcbstatus"
+)
+
+var errTDXTCBInfoUnavailable = errors.New("TDX TCB info isn't provided by the verifier, so the advisories are unknown")
+
+// TDXReport is a parsed TDX quote.
+type TDXReport struct {
+	Data             []byte
+	MRTD             []byte
+	RTMRs            [4][]byte
+	MRConfigID       []byte
+	MROwner          []byte
+	MROwnerConfig    []byte
+	MRSeam           []byte
+	MRSeamSigner     []byte
+	TDAttributes     []byte
+	XFAM             []byte
+	TEETCBSVN        []byte
+	TCBStatus        tcbstatus.Status
+	TCBAdvisories    []string
+	TCBAdvisoriesErr error
+}
+
+// TDXClaimNames are the names of the claims that Open Enclave's TDX verifier returns.
+type TDXClaimNames struct {
+	ReportData    string
+	MRTD          string
+	RTMRs         [4]string
+	MRConfigID    string
+	MROwner       string
+	MROwnerConfig string
+	MRSeam        string
+	MRSeamSigner  string
+	TDAttributes  string
+	XFAM          string
+	TEETCBSVN     string
+}
+
+// ParseTDXClaims parses the claims of a verified TDX quote. claims maps the claim names to their values.
+func ParseTDXClaims(claims map[string][]byte, names TDXClaimNames) (TDXReport, error) {
+	report := TDXReport{TCBStatus: tcbstatus.Unknown}
+
+	required := func(name string, size int) ([]byte, error) {
+		value, ok := claims[name]
+		if !ok {
+			return nil, fmt.Errorf("missing claim %v", name)
+		}
+		if len(value) != size {
+			return nil, fmt.Errorf("invalid size of claim %v: %v", name, len(value))
+		}
+		return value, nil
+	}
+
+	var err error
+	if report.Data, err = required(names.ReportData, 64); err != nil {
+		return TDXReport{}, err
+	}
+	if report.MRTD, err = required(names.MRTD, 48); err != nil {
+		return TDXReport{}, err
+	}
+	for i, name := range names.RTMRs {
+		if report.RTMRs[i], err = required(name, 48); err != nil {
+			return TDXReport{}, err
+		}
+	}
+	if report.TDAttributes, err = required(names.TDAttributes, 8); err != nil {
+		return TDXReport{}, err
+	}
+	report.MRConfigID = claims[names.MRConfigID]
+	report.MROwner = claims[names.MROwner]
+	report.MROwnerConfig = claims[names.MROwnerConfig]
+	report.MRSeam = claims[names.MRSeam]
+	report.MRSeamSigner = claims[names.MRSeamSigner]
+	report.XFAM = claims[names.XFAM]
+	report.TEETCBSVN = claims[names.TEETCBSVN]
+
+	if status, ok := claims[claimTCBStatus]; ok {
+		if len(status) < 4 {
+			return TDXReport{}, errors.New("invalid TCB status claim")
+		}
+		report.TCBStatus = tcbstatus.Status(binary.LittleEndian.Uint32(status))
+	}
+
+	// Open Enclave's TDX verifier doesn't return the TDX TCB info, so the advisories can't be determined.
+	// The SGX TCB info claims must not be used because they describe a different TCB.
+	report.TCBAdvisoriesErr = errTDXTCBInfoUnavailable
+	return report, nil
+}
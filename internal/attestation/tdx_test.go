@@ -0,0 +1,122 @@
+// Copyright (c) Edgeless Systems GmbH.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package attestation
+
+import (
+	"bytes"
+	"testing"
+
+	"github.com/edgelesssys/ego/attestation/tcbstatus"
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+func TestParseTDXClaims(t *testing.T) {
+	names := TDXClaimNames{
+		ReportData:    "report_data",
+		MRTD:          "mr_td",
+		RTMRs:         [4]string{"rt_mr0", "rt_mr1", "rt_mr2", "rt_mr3"},
+		MRConfigID:    "mr_config_id",
+		MROwner:       "mr_owner",
+		MROwnerConfig: "mr_owner_config",
+		MRSeam:        "mr_seam",
+		MRSeamSigner:  "mr_seam_signer",
+		TDAttributes:  "td_attributes",
+		XFAM:          "xfam",
+		TEETCBSVN:     "tee_tcb_svn",
+	}
+	validClaims := func() map[string][]byte {
+		return map[string][]byte{
+			"report_data":   bytes.Repeat([]byte{1}, 64),
+			"mr_td":         bytes.Repeat([]byte{2}, 48),
+			"rt_mr0":        bytes.Repeat([]byte{3}, 48),
+			"rt_mr1":        bytes.Repeat([]byte{4}, 48),
+			"rt_mr2":        bytes.Repeat([]byte{5}, 48),
+			"rt_mr3":        bytes.Repeat([]byte{6}, 48),
+			"mr_seam":       bytes.Repeat([]byte{7}, 48),
+			"td_attributes": {1, 0, 0, 0, 0, 0, 0, 0},
+			"tee_tcb_svn":   bytes.Repeat([]byte{8}, 16),
+		}
+	}
+	tcbInfo := []byte(`{"tcbInfo":{"tcbLevels":[{},{"advisoryIDs":["INTEL-SA-00001"]}]}}` + "\x00")
+
+	testCases := map[string]struct {
+		claims     func() map[string][]byte
+		wantErr    bool
+		wantStatus tcbstatus.Status
+	}{
+		"valid": {
+			claims:     validClaims,
+			wantStatus: tcbstatus.Unknown,
+		},
+		"tcb status": {
+			claims: func() map[string][]byte {
+				claims := validClaims()
+				claims[claimTCBStatus] = []byte{byte(tcbstatus.OutOfDate), 0, 0, 0}
+				return claims
+			},
+			wantStatus: tcbstatus.OutOfDate,
+		},
+		"sgx tcb info is ignored": {
+			claims: func() map[string][]byte {
+				claims := validClaims()
+				claims[claimTCBStatus] = []byte{byte(tcbstatus.OutOfDate), 0, 0, 0}
+				claims["sgx_tcb_info"] = tcbInfo
+				claims["sgx_tcb_info_index"] = []byte{1, 0, 0, 0}
+				return claims
+			},
+			wantStatus: tcbstatus.OutOfDate,
+		},
+		"missing rtmr": {
+			claims: func() map[string][]byte {
+				claims := validClaims()
+				delete(claims, "rt_mr2")
+				return claims
+			},
+			wantErr: true,
+		},
+		"invalid mrtd size": {
+			claims: func() map[string][]byte {
+				claims := validClaims()
+				claims["mr_td"] = claims["mr_td"][1:]
+				return claims
+			},
+			wantErr: true,
+		},
+		"invalid tcb status": {
+			claims: func() map[string][]byte {
+				claims := validClaims()
+				claims[claimTCBStatus] = []byte{1}
+				return claims
+			},
+			wantErr: true,
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			assert := assert.New(t)
+			require := require.New(t)
+
+			report, err := ParseTDXClaims(tc.claims(), names)
+			if tc.wantErr {
+				assert.Error(err)
+				return
+			}
+			require.NoError(err)
+
+			assert.Equal(bytes.Repeat([]byte{1}, 64), report.Data)
+			assert.Equal(bytes.Repeat([]byte{2}, 48), report.MRTD)
+			assert.Equal(bytes.Repeat([]byte{5}, 48), report.RTMRs[2])
+			assert.Equal(bytes.Repeat([]byte{7}, 48), report.MRSeam)
+			assert.Nil(report.MROwner)
+			assert.Equal(tc.wantStatus, report.TCBStatus)
+			assert.Empty(report.TCBAdvisories)
+			assert.Error(report.TCBAdvisoriesErr)
+		})
+	}
+}
@@ -0,0 +1,201 @@
+// Copyright (c) Edgeless Systems GmbH.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package attestation
+
+import (
+	"bytes"
+	"context"
+	"encoding/base64"
+	"encoding/binary"
+	"encoding/hex"
+	"encoding/json"
+	"errors"
+	"fmt"
+	"net/http"
+	"net/url"
+	"time"
+
+	"github.com/edgelesssys/ego/attestation/tcbstatus"
+	"github.com/go-jose/go-jose/v4"
+	"github.com/go-jose/go-jose/v4/jwt"
+)
+
+// DefaultTrustAuthorityIssuer is the issuer of Intel Trust Authority tokens.
+const DefaultTrustAuthorityIssuer = "Intel Trust Authority"
+
+// https://github.com/openenclave/openenclave/blob/master/include/openenclave/bits/report.h
+const (
+	oeReportHeaderVersion    = 1
+	oeReportTypeSGXRemote    = 2
+	oeReportHeaderSize       = 16
+	trustAuthorityAttestPath = "/appraisal/v1/attest"
+)
+
+// defaultTrustAuthorityTimeout limits the time a token request takes if no client is passed.
+const defaultTrustAuthorityTimeout = 30 * time.Second
+
+// CreateTrustAuthorityToken creates a token by sending the SGX quote contained in report to an
+// Intel Trust Authority compatible service reachable under baseURL. The report data must be the
+// SHA-256 hash of runtimeData. A JSON Web Token in compact serialization is returned.
+func CreateTrustAuthorityToken(ctx context.Context, report, runtimeData []byte, baseURL, apiKey string, client *http.Client) (string, error) {
+	quote, err := sgxQuoteFromReport(report)
+	if err != nil {
+		return "", err
+	}
+
+	uri, err := url.Parse(baseURL)
+	if err != nil {
+		return "", err
+	}
+	uri = uri.ResolveReference(&url.URL{Path: trustAuthorityAttestPath})
+
+	jsonReq, err := json.Marshal(trustAuthorityRequest{
+		Quote:       base64.StdEncoding.EncodeToString(quote),
+		RuntimeData: base64.StdEncoding.EncodeToString(runtimeData),
+	})
+	if err != nil {
+		return "", err
+	}
+	req, err := http.NewRequestWithContext(ctx, http.MethodPost, uri.String(), bytes.NewReader(jsonReq))
+	if err != nil {
+		return "", err
+	}
+	req.Header.Set("Content-Type", "application/json")
+	req.Header.Set("Accept", "application/json")
+	if apiKey != "" {
+		req.Header.Set("x-api-key", apiKey)
+	}
+
+	if client == nil {
+		client = &http.Client{Timeout: defaultTrustAuthorityTimeout}
+	}
+	resp, err := client.Do(req)
+	if err != nil {
+		return "", err
+	}
+	defer resp.Body.Close()
+	if resp.StatusCode != http.StatusOK {
+		return "", fmt.Errorf("attestation request failed, attestation service returned status code %v", resp.StatusCode)
+	}
+	var body attestationResponse
+	if err := json.NewDecoder(resp.Body).Decode(&body); err != nil {
+		return "", err
+	}
+	return body.Token, nil
+}
+
+// TrustAuthorityVerifier verifies Intel Trust Authority compatible tokens of SGX enclaves.
+type TrustAuthorityVerifier struct {
+	issuer string
+	keys   *keySetCache
+	now    func() time.Time
+}
+
+// NewTrustAuthorityVerifier creates a new TrustAuthorityVerifier for tokens of the given issuer
+// whose key set is available at certsURL.
+func NewTrustAuthorityVerifier(certsURL, issuer string, config KeySetConfig) *TrustAuthorityVerifier {
+	return &TrustAuthorityVerifier{issuer: issuer, keys: newKeySetCache(certsURL, config), now: time.Now}
+}
+
+// Verify verifies the token's public claims and signature and returns the report contained in the token.
+// The report's Data is the runtime data the token was created with.
+func (v *TrustAuthorityVerifier) Verify(ctx context.Context, rawToken string) (Report, error) {
+	token, err := jwt.ParseSigned(rawToken, []jose.SignatureAlgorithm{jose.PS384, jose.RS256})
+	if err != nil {
+		return Report{}, err
+	}
+	if len(token.Headers) != 1 {
+		return Report{}, errors.New("token must have exactly one signature")
+	}
+
+	keySet, err := v.keys.get(ctx, token.Headers[0].KeyID, v.now())
+	if err != nil {
+		return Report{}, fmt.Errorf("getting key set: %w", err)
+	}
+
+	var publicClaims jwt.Claims
+	var claims trustAuthorityClaims
+	if err := token.Claims(keySet, &publicClaims, &claims); err != nil {
+		return Report{}, err
+	}
+	if err := publicClaims.Validate(jwt.Expected{Issuer: v.issuer, Time: v.now()}); err != nil {
+		return Report{}, err
+	}
+
+	return claims.toReport()
+}
+
+type trustAuthorityRequest struct {
+	Quote       string `json:"quote"`
+	RuntimeData string `json:"runtime_data"`
+}
+
+// trustAuthorityClaims are the SGX claims of an Intel Trust Authority token.
+type trustAuthorityClaims struct {
+	AttesterType    string   `json:"attester_type"`
+	HeldData        string   `json:"attester_held_data"`
+	TCBStatus       string   `json:"attester_tcb_status"`
+	AdvisoryIDs     []string `json:"attester_advisory_ids,omitempty"`
+	UniqueID        string   `json:"sgx_mrenclave"`
+	SignerID        string   `json:"sgx_mrsigner"`
+	ProductID       uint16   `json:"sgx_isvprodid"`
+	SecurityVersion uint     `json:"sgx_isvsvn"`
+	Debug           bool     `json:"sgx_is_debuggable"`
+}
+
+func (c trustAuthorityClaims) toReport() (Report, error) {
+	if c.AttesterType != "SGX" {
+		return Report{}, fmt.Errorf("unsupported attester type: %q", c.AttesterType)
+	}
+	data, err := base64.StdEncoding.DecodeString(c.HeldData)
+	if err != nil {
+		return Report{}, fmt.Errorf("decoding held data: %w", err)
+	}
+	uniqueID, err := hex.DecodeString(c.UniqueID)
+	if err != nil {
+		return Report{}, err
+	}
+	signerID, err := hex.DecodeString(c.SignerID)
+	if err != nil {
+		return Report{}, err
+	}
+	productID := make([]byte, 16)
+	binary.LittleEndian.PutUint16(productID, c.ProductID)
+
+	tcbStatus, err := tcbstatus.Parse(c.TCBStatus)
+	if err != nil {
+		return Report{}, err
+	}
+
+	return Report{
+		Data:            data,
+		SecurityVersion: c.SecurityVersion,
+		Debug:           c.Debug,
+		UniqueID:        uniqueID,
+		SignerID:        signerID,
+		ProductID:       productID,
+		TCBStatus:       tcbStatus,
+		TCBAdvisories:   c.AdvisoryIDs,
+	}, nil
+}
+
+// sgxQuoteFromReport returns the SGX quote contained in an Open Enclave remote report.
+func sgxQuoteFromReport(report []byte) ([]byte, error) {
+	if len(report) < oeReportHeaderSize {
+		return nil, errors.New("report is too short")
+	}
+	version := binary.LittleEndian.Uint32(report)
+	reportType := binary.LittleEndian.Uint32(report[4:])
+	size := binary.LittleEndian.Uint64(report[8:])
+	if version != oeReportHeaderVersion || reportType != oeReportTypeSGXRemote {
+		return nil, fmt.Errorf("unsupported report: version %v, type %v", version, reportType)
+	}
+	if size != uint64(len(report)-oeReportHeaderSize) {
+		return nil, errors.New("invalid report size")
+	}
+	return report[oeReportHeaderSize:], nil
+}
@@ -0,0 +1,232 @@
+// Copyright (c) Edgeless Systems GmbH.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package attestation
+
+import (
+	"context"
+	"crypto/sha256"
+	"errors"
+	"slices"
+	"sync"
+	"time"
+)
+
+const (
+	defaultResultTTL     = 10 * time.Minute
+	defaultMaxResults    = 1024
+	defaultCollateralTTL = time.Hour
+)
+
+// VerifierConfig configures a Verifier.
+type VerifierConfig struct {
+	// ResultTTL is the time a verification result is cached. Results are never cached beyond the
+	// NextUpdate of the collateral they have been verified with. If negative, results aren't cached.
+	ResultTTL time.Duration
+	// MaxResults is the maximum number of cached results.
+	MaxResults int
+	// CollateralTTL is the time collateral is cached. Collateral is never cached beyond its
+	// NextUpdate. If negative, collateral isn't cached. It only applies if CollateralKey and GetCollateral are set.
+	CollateralTTL time.Duration
+	// CollateralKey returns the key of the collateral needed to verify the report. Reports that
+	// share the key share the collateral. If nil, the verify function obtains the collateral itself.
+	CollateralKey func(reportBytes []byte) (string, error)
+	// GetCollateral fetches the collateral for the key. It returns the collateral in Open Enclave's
+	// endorsements format and the time until the collateral may be used.
+	GetCollateral func(ctx context.Context, key string) (endorsements []byte, nextUpdate time.Time, err error)
+	// ReportErr is an error that verify returns together with a valid report, e.g., ErrTCBLevelInvalid.
+	// Such results are cached, too.
+	ReportErr error
+}
+
+// Verifier verifies remote reports and caches collateral and verification results.
+type Verifier struct {
+	verify func(reportBytes, endorsements []byte) (Report, error)
+	config VerifierConfig
+	now    func() time.Time
+
+	mut        sync.Mutex
+	collateral map[string]*collateralEntry
+	results    map[[sha256.Size]byte]resultEntry
+}
+
+type collateralEntry struct {
+	done         chan struct{}
+	endorsements []byte
+	nextUpdate   time.Time
+	expires      time.Time
+	err          error
+}
+
+type resultEntry struct {
+	report  Report
+	err     error
+	expires time.Time
+}
+
+// NewVerifier creates a new Verifier. verify verifies a report with the given endorsements. If the
+// endorsements are nil, verify must obtain the collateral itself.
+func NewVerifier(verify func(reportBytes, endorsements []byte) (Report, error), config VerifierConfig) *Verifier {
+	if config.ResultTTL == 0 {
+		config.ResultTTL = defaultResultTTL
+	}
+	if config.MaxResults <= 0 {
+		config.MaxResults = defaultMaxResults
+	}
+	if config.CollateralTTL == 0 {
+		config.CollateralTTL = defaultCollateralTTL
+	}
+	return &Verifier{
+		verify:     verify,
+		config:     config,
+		now:        time.Now,
+		collateral: map[string]*collateralEntry{},
+		results:    map[[sha256.Size]byte]resultEntry{},
+	}
+}
+
+// Verify verifies the report. Like the verify function, it returns the report together with
+// ReportErr if the report is valid, but not fully trusted.
+func (v *Verifier) Verify(ctx context.Context, reportBytes []byte) (Report, error) {
+	digest := sha256.Sum256(reportBytes)
+	if report, err, ok := v.getResult(digest); ok {
+		return report, err
+	}
+
+	var endorsements []byte
+	var nextUpdate time.Time
+	if v.config.CollateralKey != nil && v.config.GetCollateral != nil {
+		key, err := v.config.CollateralKey(reportBytes)
+		if err != nil {
+			return Report{}, err
+		}
+		endorsements, nextUpdate, err = v.getCollateral(ctx, key)
+		if err != nil {
+			return Report{}, err
+		}
+	}
+
+	report, err := v.verify(reportBytes, endorsements)
+	if err != nil && (v.config.ReportErr == nil || !errors.Is(err, v.config.ReportErr)) {
+		return Report{}, err
+	}
+	v.putResult(digest, report, err, nextUpdate)
+	return cloneReport(report), err
+}
+
+func (v *Verifier) getCollateral(ctx context.Context, key string) ([]byte, time.Time, error) {
+	for {
+		v.mut.Lock()
+		entry, ok := v.collateral[key]
+		if !ok || (isDone(entry.done) && !v.now().Before(entry.expires)) {
+			// fetch the collateral; concurrent callers wait for the result
+			entry = &collateralEntry{done: make(chan struct{})}
+			v.collateral[key] = entry
+			v.mut.Unlock()
+			v.fetchCollateral(ctx, key, entry)
+			return entry.endorsements, entry.nextUpdate, entry.err
+		}
+		v.mut.Unlock()
+
+		select {
+		case <-entry.done:
+		case <-ctx.Done():
+			return nil, time.Time{}, ctx.Err()
+		}
+		if entry.err == nil {
+			return entry.endorsements, entry.nextUpdate, nil
+		}
+		// The fetch failed. If it failed because the fetching caller has been canceled, retry with our context.
+		if ctx.Err() != nil {
+			return nil, time.Time{}, ctx.Err()
+		}
+		if !errors.Is(entry.err, context.Canceled) && !errors.Is(entry.err, context.DeadlineExceeded) {
+			return nil, time.Time{}, entry.err
+		}
+	}
+}
+
+func (v *Verifier) fetchCollateral(ctx context.Context, key string, entry *collateralEntry) {
+	entry.endorsements, entry.nextUpdate, entry.err = v.config.GetCollateral(ctx, key)
+	now := v.now()
+	if entry.err == nil && !now.Before(entry.nextUpdate) {
+		entry.err = errors.New("collateral is outdated")
+	}
+	entry.expires = entry.nextUpdate
+	if v.config.CollateralTTL < 0 {
+		entry.expires = now
+	} else if expires := now.Add(v.config.CollateralTTL); expires.Before(entry.expires) {
+		entry.expires = expires
+	}
+
+	v.mut.Lock()
+	if entry.err != nil && v.collateral[key] == entry {
+		// don't cache errors
+		delete(v.collateral, key)
+	}
+	v.mut.Unlock()
+	close(entry.done)
+}
+
+func (v *Verifier) getResult(digest [sha256.Size]byte) (Report, error, bool) {
+	if v.config.ResultTTL < 0 {
+		return Report{}, nil, false
+	}
+	v.mut.Lock()
+	defer v.mut.Unlock()
+	entry, ok := v.results[digest]
+	if !ok || !v.now().Before(entry.expires) {
+		return Report{}, nil, false
+	}
+	return cloneReport(entry.report), entry.err, true
+}
+
+func (v *Verifier) putResult(digest [sha256.Size]byte, report Report, err error, nextUpdate time.Time) {
+	if v.config.ResultTTL < 0 {
+		return
+	}
+	now := v.now()
+	expires := now.Add(v.config.ResultTTL)
+	if !nextUpdate.IsZero() && nextUpdate.Before(expires) {
+		expires = nextUpdate
+	}
+
+	v.mut.Lock()
+	defer v.mut.Unlock()
+	if len(v.results) >= v.config.MaxResults {
+		for digest, entry := range v.results {
+			if !now.Before(entry.expires) {
+				delete(v.results, digest)
+			}
+		}
+	}
+	for digest := range v.results {
+		if len(v.results) < v.config.MaxResults {
+			break
+		}
+		delete(v.results, digest)
+	}
+	v.results[digest] = resultEntry{report: cloneReport(report), err: err, expires: expires}
+}
+
+func isDone(done chan struct{}) bool {
+	select {
+	case <-done:
+		return true
+	default:
+		return false
+	}
+}
+
+// cloneReport prevents callers from modifying cached reports.
+func cloneReport(report Report) Report {
+	report.Data = slices.Clone(report.Data)
+	report.UniqueID = slices.Clone(report.UniqueID)
+	report.SignerID = slices.Clone(report.SignerID)
+	report.ProductID = slices.Clone(report.ProductID)
+	report.TCBAdvisories = slices.Clone(report.TCBAdvisories)
+	return report
+}
@@ -0,0 +1,332 @@
+// Copyright (c) Edgeless Systems GmbH.
+//
+// This Source Code Form is subject to the terms of the Mozilla Public
+// License, v. 2.0. If a copy of the MPL was not distributed with this
+// file, You can obtain one at https://mozilla.org/MPL/2.0/.
+
+package attestation
+
+import (
+	"context"
+	"crypto/sha256"
+	"errors"
+	"fmt"
+	"sync"
+	"sync/atomic"
+	"testing"
+	"time"
+
+	"github.com/stretchr/testify/assert"
+	"github.com/stretchr/testify/require"
+)
+
+var errTCBLevelInvalid = errors.New("tcb level invalid")
+
+// fakeVerify accepts reports with prefix 2 and returns errTCBLevelInvalid for prefix 3.
+func fakeVerify(reportBytes, _ []byte) (Report, error) {
+	if len(reportBytes) < 1 {
+		return Report{}, errors.New("invalid report")
+	}
+	report := Report{Data: reportBytes[1:], SecurityVersion: 2}
+	switch reportBytes[0] {
+	case 2:
+		return report, nil
+	case 3:
+		return report, errTCBLevelInvalid
+	}
+	return Report{}, errors.New("invalid report")
+}
+
+func TestVerifierResultCache(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	var verifyCount atomic.Int32
+	verifier := NewVerifier(func(reportBytes, endorsements []byte) (Report, error) {
+		verifyCount.Add(1)
+		return fakeVerify(reportBytes, endorsements)
+	}, VerifierConfig{ResultTTL: time.Hour, MaxResults: 2, ReportErr: errTCBLevelInvalid})
+	now := time.Now()
+	verifier.now = func() time.Time { return now }
+	ctx := context.Background()
+
+	report, err := verifier.Verify(ctx, []byte{2, 1})
+	require.NoError(err)
+	assert.Equal([]byte{1}, report.Data)
+	assert.EqualValues(1, verifyCount.Load())
+
+	// cached result can't be modified by the caller
+	report.Data[0] = 9
+	report, err = verifier.Verify(ctx, []byte{2, 1})
+	require.NoError(err)
+	assert.Equal([]byte{1}, report.Data)
+	assert.EqualValues(1, verifyCount.Load())
+
+	// valid report with error is cached
+	report, err = verifier.Verify(ctx, []byte{3, 1})
+	assert.ErrorIs(err, errTCBLevelInvalid)
+	assert.EqualValues(2, report.SecurityVersion)
+	_, err = verifier.Verify(ctx, []byte{3, 1})
+	assert.ErrorIs(err, errTCBLevelInvalid)
+	assert.EqualValues(2, verifyCount.Load())
+
+	// invalid report isn't cached
+	_, err = verifier.Verify(ctx, []byte{4})
+	assert.Error(err)
+	_, err = verifier.Verify(ctx, []byte{4})
+	assert.Error(err)
+	assert.EqualValues(4, verifyCount.Load())
+
+	// cache size is limited
+	_, err = verifier.Verify(ctx, []byte{2, 2})
+	require.NoError(err)
+	assert.Len(verifier.results, 2)
+
+	// result expires
+	now = now.Add(time.Hour)
+	_, err = verifier.Verify(ctx, []byte{2, 2})
+	require.NoError(err)
+	assert.EqualValues(6, verifyCount.Load())
+}
+
+func TestVerifierNoResultCache(t *testing.T) {
+	var verifyCount atomic.Int32
+	verifier := NewVerifier(func(reportBytes, endorsements []byte) (Report, error) {
+		verifyCount.Add(1)
+		return fakeVerify(reportBytes, endorsements)
+	}, VerifierConfig{ResultTTL: -1})
+
+	for i := 0; i < 2; i++ {
+		_, err := verifier.Verify(context.Background(), []byte{2, 1})
+		require.NoError(t, err)
+	}
+	assert.EqualValues(t, 2, verifyCount.Load())
+}
+
+func TestVerifierCollateralCache(t *testing.T) {
+	assert := assert.New(t)
+	require := require.New(t)
+
+	now := time.Now()
+	var fetchCount atomic.Int32
+	fetchErr := errors.New("fetch failed")
+	var failFetch atomic.Bool
+
+	verifier := NewVerifier(func(reportBytes, endorsements []byte) (Report, error) {
+		if string(endorsements) != "collateral-"+string(reportBytes[1:2]) {
+			return Report{}, errors.New("wrong collateral")
+		}
+		return fakeVerify(reportBytes, endorsements)
+	}, VerifierConfig{
+		ResultTTL: time.Hour,
+		// the second byte identifies the platform
+		CollateralKey: func(reportBytes []byte) (string, error) {
+			if len(reportBytes) < 2 {
+				return "", errors.New("invalid report")
+			}
+			return string(reportBytes[1:2]), nil
+		},
+		GetCollateral: func(ctx context.Context, key string) ([]byte, time.Time, error) {
+			fetchCount.Add(1)
+			if failFetch.Load() {
+				return nil, time.Time{}, fetchErr
+			}
+			return []byte("collateral-" + key), now.Add(30 * time.Minute), nil
+		},
+	})
+	verifier.now = func() time.Time { return now }
+	ctx := context.Background()
+
+	// reports of the same platform share the collateral
+	_, err := verifier.Verify(ctx, []byte{2, 'a', 1})
+	require.NoError(err)
+	_, err = verifier.Verify(ctx, []byte{2, 'a', 2})
+	require.NoError(err)
+	assert.EqualValues(1, fetchCount.Load())
+
+	// other platform
+	_, err = verifier.Verify(ctx, []byte{2, 'b', 1})
+	require.NoError(err)
+	assert.EqualValues(2, fetchCount.Load())
+
+	// result expires with the collateral before its TTL
+	now = now.Add(30 * time.Minute)
+	failFetch.Store(true)
+	_, err = verifier.Verify(ctx, []byte{2, 'a', 1})
+	assert.ErrorIs(err, fetchErr)
+	assert.EqualValues(3, fetchCount.Load())
+
+	// errors aren't cached
+	failFetch.Store(false)
+	_, err = verifier.Verify(ctx, []byte{2, 'a', 1})
+	require.NoError(err)
+	assert.EqualValues(4, fetchCount.Load())
+}
+
+func TestVerifierCollateralTTL(t *testing.T) {
+	testCases := map[string]struct {
+		ttl           time.Duration
+		wantFetches   int32
+		wantAfterHour int32
+	}{
+		"default": {
+			wantFetches:   1,
+			wantAfterHour: 2,
+		},
+		"longer than next update": {
+			ttl:           48 * time.Hour,
+			wantFetches:   1,
+			wantAfterHour: 1,
+		},
+		"disabled": {
+			ttl:           -1,
+			wantFetches:   2,
+			wantAfterHour: 3,
+		},
+	}
+
+	for name, tc := range testCases {
+		t.Run(name, func(t *testing.T) {
+			assert := assert.New(t)
+			require := require.New(t)
+
+			now := time.Now()
+			var fetchCount atomic.Int32
+			verifier := NewVerifier(fakeVerify, VerifierConfig{
+				ResultTTL:     -1,
+				CollateralTTL: tc.ttl,
+				CollateralKey: func([]byte) (string, error) { return "a", nil },
+				GetCollateral: func(context.Context, string) ([]byte, time.Time, error) {
+					fetchCount.Add(1)
+					return []byte("collateral"), now.Add(24 * time.Hour), nil
+				},
+			})
+			verifier.now = func() time.Time { return now }
+			ctx := context.Background()
+
+			for i := 0; i < 2; i++ {
+				_, err := verifier.Verify(ctx, []byte{2, 1})
+				require.NoError(err)
+			}
+			assert.Equal(tc.wantFetches, fetchCount.Load())
+
+			now = now.Add(time.Hour)
+			_, err := verifier.Verify(ctx, []byte{2, 1})
+			require.NoError(err)
+			assert.Equal(tc.wantAfterHour, fetchCount.Load())
+		})
+	}
+}
+
+func TestVerifierCollateralConcurrent(t *testing.T) {
+	assert := assert.New(t)
+
+	var fetchCount atomic.Int32
+	release := make(chan struct{})
+	verifier := NewVerifier(fakeVerify, VerifierConfig{
+		ResultTTL:     -1,
+		CollateralKey: func([]byte) (string, error) { return "key", nil },
+		GetCollateral: func(ctx context.Context, key string) ([]byte, time.Time, error) {
+			fetchCount.Add(1)
+			<-release
+			return []byte("collateral"), time.Now().Add(time.Hour), nil
+		},
+	})
+
+	const count = 10
+	var wg sync.WaitGroup
+	errs := make(chan error, count)
+	for i := 0; i < count; i++ {
+		wg.Add(1)
+		go func(i int) {
+			defer wg.Done()
+			_, err := verifier.Verify(context.Background(), []byte{2, byte(i)})
+			errs <- err
+		}(i)
+	}
+	time.Sleep(10 * time.Millisecond)
+	close(release)
+	wg.Wait()
+	close(errs)
+
+	for err := range errs {
+		assert.NoError(err)
+	}
+	assert.EqualValues(1, fetchCount.Load())
+}
+
+func TestVerifierCollateralCanceled(t *testing.T) {
+	verifier := NewVerifier(fakeVerify, VerifierConfig{
+		CollateralKey: func([]byte) (string, error) { return "key", nil },
+		GetCollateral: func(ctx context.Context, key string) ([]byte, time.Time, error) {
+			<-ctx.Done()
+			return nil, time.Time{}, ctx.Err()
+		},
+	})
+
+	ctx, cancel := context.WithCancel(context.Background())
+	cancel()
+	_, err := verifier.Verify(ctx, []byte{2})
+	assert.ErrorIs(t, err, context.Canceled)
+	assert.Empty(t, verifier.collateral)
+}
+
+// slowVerify simulates the cost of verifying a quote. The actual cost depends on the hardware and the
+// collateral service; an ECDSA quote verification typically takes milliseconds.
+func slowVerify(reportBytes, endorsements []byte) (Report, error) {
+	digest := sha256.Sum256(reportBytes)
+	for i := 0; i < 2000; i++ {
+		digest = sha256.Sum256(digest[:])
+	}
+	return fakeVerify(reportBytes, endorsements)
+}
+
+func slowGetCollateral(ctx context.Context, key string) ([]byte, time.Time, error) {
+	time.Sleep(time.Millisecond)
+	return []byte("collateral"), time.Now().Add(time.Hour), nil
+}
+
+func benchmarkVerifier(b *testing.B, config VerifierConfig) {
+	verifier := NewVerifier(slowVerify, config)
+	reports := make([][]byte, 16)
+	for i := range reports {
+		reports[i] = []byte{2, byte(i)}
+	}
+	ctx := context.Background()
+
+	b.ResetTimer()
+	b.RunParallel(func(pb *testing.PB) {
+		i := 0
+		for pb.Next() {
+			if _, err := verifier.Verify(ctx, reports[i%len(reports)]); err != nil {
+				b.Fatal(err)
+			}
+			i++
+		}
+	})
+}
+
+func BenchmarkVerifierNoCache(b *testing.B) {
+	// a new key for each verification simulates fetching the collateral each time
+	var keyCount atomic.Int64
+	benchmarkVerifier(b, VerifierConfig{
+		ResultTTL:     -1,
+		CollateralKey: func([]byte) (string, error) { return fmt.Sprint(keyCount.Add(1)), nil },
+		GetCollateral: slowGetCollateral,
+	})
+}
+
+func BenchmarkVerifierCollateralCache(b *testing.B) {
+	benchmarkVerifier(b, VerifierConfig{
+		ResultTTL:     -1,
+		CollateralKey: func([]byte) (string, error) { return "key", nil },
+		GetCollateral: slowGetCollateral,
+	})
+}
+
+func BenchmarkVerifierResultCache(b *testing.B) {
+	benchmarkVerifier(b, VerifierConfig{
+		CollateralKey: func([]byte) (string, error) { return "key", nil },
+		GetCollateral: slowGetCollateral,
+	})
+}
@@ -57,7 +57,7 @@ func main() {
 
 func verifyReport(reportBytes, certBytes, signer []byte) error {
 	report, err := eclient.VerifyRemoteReport(reportBytes)
-	if err == attestation.ErrTCBLevelInvalid {
+	if errors.Is(err, attestation.ErrTCBLevelInvalid) {
 		fmt.Printf("Warning: TCB level is invalid: %v\n%v\n", report.TCBStatus, tcbstatus.Explain(report.TCBStatus))
 		fmt.Println("We'll ignore this issue in this sample. For an app that should run in production, you must decide which of the different TCBStatus values are acceptable for you to continue.")
 	} else if err != nil {